@@ -1,23 +1,38 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
 
+	"github.com/pkg/errors"
 	"github.com/unhandled-exception/sophiadb/pkg/db"
 )
 
 const (
 	serverName    = "SophiaDB"
 	serverVersion = "0.1.0"
+
+	defaultDataDir = "./sdb_data"
 )
 
 func main() {
 	logger := newLogger()
+
+	if len(os.Args) > 1 && os.Args[1] == "rotate-key" {
+		if err := rotateKey(os.Args[2:]); err != nil {
+			logger.Fatal(err)
+		}
+
+		logger.Print("Encryption key rotated")
+
+		return
+	}
+
 	logger.Printf("Server %s starting", version())
 
-	db, err := db.NewDatabase("./sdb_data")
+	db, err := db.NewDatabase(defaultDataDir)
 	if err != nil {
 		logger.Fatal(err)
 	}
@@ -29,6 +44,37 @@ func main() {
 	logger.Print("Server finished")
 }
 
+// rotateKey перешифровывает закрытую базу новым ключом:
+// sophiadb rotate-key -data ./sdb_data -old-key-file old.key -new-key-file new.key
+func rotateKey(args []string) error {
+	flags := flag.NewFlagSet("rotate-key", flag.ExitOnError)
+
+	dataDir := flags.String("data", defaultDataDir, "data directory")
+	blockSize := flags.Uint("block-size", db.DefaultBlockSize, "block size of the database")
+	oldKeyFile := flags.String("old-key-file", "", "file with the current key")
+	newKeyFile := flags.String("new-key-file", "", "file with the new key")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *oldKeyFile == "" || *newKeyFile == "" {
+		return errors.New("both -old-key-file and -new-key-file are required")
+	}
+
+	oldKey, err := db.ReadKeyFile(*oldKeyFile)
+	if err != nil {
+		return err
+	}
+
+	newKey, err := db.ReadKeyFile(*newKeyFile)
+	if err != nil {
+		return err
+	}
+
+	return db.RotateKey(*dataDir, oldKey, newKey, db.WithBlockSize(uint32(*blockSize)))
+}
+
 func version() string {
 	return fmt.Sprintf("%s/%s", serverName, serverVersion)
 }
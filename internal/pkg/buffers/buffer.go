@@ -2,6 +2,7 @@ package buffers
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"github.com/pkg/errors"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/storage"
@@ -16,30 +17,82 @@ type Buffer struct {
 	fm *storage.Manager
 	lm *wal.Manager
 
-	content *types.Page
+	// content указывает на собственную страницу буфера или на страницу из View. Меняется под блокировкой буфера,
+	// а читается без нее, см. Content
+	content atomic.Pointer[types.Page]
+	page    *types.Page // Собственная страница буфера
 	block   *types.Block
-	pins    int
+	pins    atomic.Int32 // Меняется без блокировок, см. pinShared и unpinShared
 	txnum   types.TRX
 	lsn     types.LSN
+
+	frame      int             // Номер буфера в пуле
+	dirtyPages *dirtyPageTable // Таблица измененных буферов пула
+
+	// Упреждающее чтение связывает буфер с блоком раньше, чем прочитает страницу с диска.
+	// loading закрывается, когда чтение закончилось, loadErr — его ошибка
+	loading chan struct{}
+	loadErr error
+
+	// releaseView освобождает страницу отображенного в память файла, в которую смотрит content, см. storage.Manager.View
+	releaseView func()
 }
 
 // NewBuffer создает новый объект буфера
 func NewBuffer(fm *storage.Manager, lm *wal.Manager) *Buffer {
+	page := types.NewPage(fm.BlockSize())
+
 	buf := &Buffer{
-		fm:      fm,
-		lm:      lm,
-		content: types.NewPage(fm.BlockSize()),
-		pins:    0,
-		txnum:   -1,
-		lsn:     -1,
+		fm:    fm,
+		lm:    lm,
+		page:  page,
+		txnum: -1,
+		lsn:   -1,
 	}
 
+	buf.content.Store(page)
+
 	return buf
 }
 
-// Content возвращает страницу с соlержимым буфера
+// Content возвращает страницу с содержимым буфера. Страницу можно только читать, для изменения есть WritableContent.
+// Вызывается и под блокировкой буфера, поэтому content читается атомарно, а не под блокировкой
 func (buf *Buffer) Content() *types.Page {
-	return buf.content
+	return buf.content.Load()
+}
+
+// WritableContent возвращает страницу буфера для изменения.
+// Если буфер смотрит в отображенный в память файл, сначала копирует страницу в память буфера:
+// изменения не должны попасть в файл раньше записей журнала о них
+func (buf *Buffer) WritableContent() *types.Page {
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	if buf.releaseView != nil {
+		copy(buf.page.Content(), buf.Content().Content())
+		buf.dropView()
+	}
+
+	return buf.page
+}
+
+// IsView возвращает true, если буфер смотрит в отображенный в память файл
+func (buf *Buffer) IsView() bool {
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	return buf.releaseView != nil
+}
+
+// dropView возвращает буферу собственную страницу. Вызывается для незакрепленного буфера или под блокировкой буфера
+func (buf *Buffer) dropView() {
+	if buf.releaseView == nil {
+		return
+	}
+
+	buf.releaseView()
+	buf.releaseView = nil
+	buf.content.Store(buf.page)
 }
 
 // Block возвращает блок
@@ -56,6 +109,8 @@ func (buf *Buffer) SetModified(txnum types.TRX, lsn types.LSN) {
 	buf.mu.Lock()
 	defer buf.mu.Unlock()
 
+	buf.dirtyPages.move(buf, buf.txnum, txnum)
+
 	buf.txnum = txnum
 	if lsn >= 0 {
 		buf.lsn = lsn
@@ -64,17 +119,47 @@ func (buf *Buffer) SetModified(txnum types.TRX, lsn types.LSN) {
 
 // Pin закрепляет страницу в памяти и увеличивает счетчик закрпелений
 func (buf *Buffer) Pin() {
-	buf.pins++
+	buf.pins.Add(1)
 }
 
 // Unpin уменьщает счетчик закреплений в памяти
 func (buf *Buffer) Unpin() {
-	buf.pins--
+	buf.pins.Add(-1)
+}
+
+// pinShared закрепляет буфер, только если он уже закреплен. Раз буфер закреплен, его не вытеснят,
+// поэтому для этого не нужна исключительная блокировка пула
+func (buf *Buffer) pinShared() bool {
+	for {
+		pins := buf.pins.Load()
+		if pins <= 0 {
+			return false
+		}
+
+		if buf.pins.CompareAndSwap(pins, pins+1) {
+			return true
+		}
+	}
+}
+
+// unpinShared открепляет буфер, только если после этого он останется закрепленным.
+// Последнее открепление меняет число свободных буферов, поэтому идет под блокировкой пула
+func (buf *Buffer) unpinShared() bool {
+	for {
+		pins := buf.pins.Load()
+		if pins <= 1 {
+			return false
+		}
+
+		if buf.pins.CompareAndSwap(pins, pins-1) {
+			return true
+		}
+	}
 }
 
 // IsPinned возвращает признак закрплена страница или нет
 func (buf *Buffer) IsPinned() bool {
-	return buf.pins > 0
+	return buf.pins.Load() > 0
 }
 
 // ModifyingTX возвращает указатель транзакции
@@ -90,44 +175,126 @@ func (buf *Buffer) LSN() types.LSN {
 	return buf.lsn
 }
 
+// modification возвращает транзакцию и LSN последнего изменения под блокировкой буфера
+func (buf *Buffer) modification() (types.TRX, types.LSN) {
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	return buf.txnum, buf.lsn
+}
+
 // Возвращает LSN
 func (buf *Buffer) Pins() int {
-	return buf.pins
+	return int(buf.pins.Load())
 }
 
 // AssignToBlock cвязывает страницу буфера со странице на диске
 func (buf *Buffer) AssignToBlock(block types.Block) error {
-	if err := buf.Flush(); err != nil {
-		return errors.WithMessage(ErrFailedToAssignBlockToBuffer, err.Error())
+	_, err := buf.assignToBlock(block)
+
+	return err
+}
+
+// assignToBlock связывает буфер с блоком и возвращает true, если перед этим пришлось записать измененную страницу
+func (buf *Buffer) assignToBlock(block types.Block) (bool, error) {
+	written, err := buf.flush()
+	if err != nil {
+		return false, errors.WithMessage(ErrFailedToAssignBlockToBuffer, err.Error())
 	}
 
 	buf.block = &block
+	buf.loading = nil
+	buf.loadErr = nil
+
+	buf.dropView()
+
+	// Страница отображенного в память файла не копируется: пока буфер закреплен, его не вытеснят,
+	// а освобождается страница только при вытеснении или первом изменении
+	view, release, err := buf.fm.View(block)
+	if err != nil {
+		return written, errors.WithMessage(ErrFailedToAssignBlockToBuffer, err.Error())
+	}
+
+	if view != nil {
+		buf.content.Store(view)
+		buf.releaseView = release
+
+		return written, nil
+	}
 
 	if err := buf.fm.Read(buf.Block(), buf.Content()); err != nil {
-		return errors.WithMessage(ErrFailedToAssignBlockToBuffer, err.Error())
+		return written, errors.WithMessage(ErrFailedToAssignBlockToBuffer, err.Error())
+	}
+
+	return written, nil
+}
+
+// startLoading связывает буфер с блоком до чтения страницы
+func (buf *Buffer) startLoading(block types.Block) {
+	buf.dropView()
+
+	buf.block = &block
+	buf.loading = make(chan struct{})
+	buf.loadErr = nil
+}
+
+// finishLoading сообщает ждущим, что чтение страницы закончилось
+func (buf *Buffer) finishLoading(err error) {
+	buf.loadErr = err
+	close(buf.loading)
+}
+
+// waitLoaded ждет, пока упреждающее чтение загрузит страницу. Вызывается только для закрепленного буфера
+func (buf *Buffer) waitLoaded() error {
+	if buf.loading == nil {
+		return nil
+	}
+
+	<-buf.loading
+
+	if buf.loadErr != nil {
+		return errors.WithMessage(ErrFailedToAssignBlockToBuffer, buf.loadErr.Error())
 	}
 
 	return nil
 }
 
+// loadFailed проверяет, что упреждающее чтение не смогло прочитать страницу. Вызывается под блокировкой пула
+func (buf *Buffer) loadFailed() bool {
+	return buf.loading != nil && buf.loadErr != nil
+}
+
 // Flush сбрасывает страницу из памяти на диск
 func (buf *Buffer) Flush() error {
-	if buf.txnum < 0 {
-		return nil
-	}
+	_, err := buf.flush()
 
+	return err
+}
+
+// flush сбрасывает измененную страницу на диск. Сначала пишет на диск журнал до LSN последнего изменения страницы.
+// Возвращает true, если страницу пришлось записать
+func (buf *Buffer) flush() (bool, error) {
 	buf.mu.Lock()
 	defer buf.mu.Unlock()
 
+	if buf.txnum < 0 {
+		return false, nil
+	}
+
 	if err := buf.lm.Flush(buf.LSN(), false); err != nil {
-		return err
+		return false, err
 	}
 
 	if err := buf.fm.Write(buf.Block(), buf.Content()); err != nil {
-		return err
+		return false, err
+	}
+
+	if err := buf.fm.Sync(buf.Block().Filename); err != nil {
+		return false, err
 	}
 
+	buf.dirtyPages.move(buf, buf.txnum, -1)
 	buf.txnum = -1
 
-	return nil
+	return true, nil
 }
@@ -1,50 +1,239 @@
 package buffers
 
 import (
-	"container/ring"
 	"sync"
 
 	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
 )
 
 type BuffersPool struct {
-	mu sync.Mutex
+	mu sync.RWMutex
 
-	ring            *ring.Ring // Буферы храним в кольце, чтобы реализовать круговую стратегию поиска свобоных буферов
+	frames          []*Buffer
+	policy          ReplacementPolicy // Стратегия выбора буфера для замены
 	blocksToBuffers map[types.Block]*Buffer
 	len             int
+	newBuffer       newBufferFunc
+	stats           *statsCollector
+	dirtyPages      *dirtyPageTable
 }
 
 type newBufferFunc func() *Buffer
 
+type BuffersPoolOpt func(*buffersPoolConfig)
+
+type buffersPoolConfig struct {
+	newPolicy ReplacementPolicyFactory
+	stats     *statsCollector
+}
+
+// WithPoolReplacementPolicy задает стратегию замены буферов. По умолчанию — clock
+func WithPoolReplacementPolicy(newPolicy ReplacementPolicyFactory) BuffersPoolOpt {
+	return func(c *buffersPoolConfig) {
+		c.newPolicy = newPolicy
+	}
+}
+
+// withPoolStats передает пулу общий с менеджером сборщик статистики
+func withPoolStats(stats *statsCollector) BuffersPoolOpt {
+	return func(c *buffersPoolConfig) {
+		c.stats = stats
+	}
+}
+
 // NewBuffersPool создает новый пул буферов
-func NewBuffersPool(bLen int, nbf newBufferFunc) *BuffersPool {
+func NewBuffersPool(bLen int, nbf newBufferFunc, opts ...BuffersPoolOpt) *BuffersPool {
+	cfg := buffersPoolConfig{
+		newPolicy: NewClockPolicy,
+		stats:     newStatsCollector(),
+	}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
 	bp := &BuffersPool{
 		len:             bLen,
 		blocksToBuffers: make(map[types.Block]*Buffer, bLen),
-		ring:            ring.New(bLen),
+		frames:          make([]*Buffer, bLen),
+		newBuffer:       nbf,
+		stats:           cfg.stats,
+		dirtyPages:      newDirtyPageTable(),
 	}
 
 	for i := 0; i < bLen; i++ {
-		bp.ring.Value = nbf()
-		bp.ring = bp.ring.Next()
+		bp.frames[i] = bp.makeBuffer(i)
 	}
 
+	bp.policy = cfg.newPolicy(bp.frames)
+
 	return bp
 }
 
-// Buffers возвращает массив буферов в виде слайса
-func (bp *BuffersPool) Buffers() []*Buffer {
+func (bp *BuffersPool) makeBuffer(frame int) *Buffer {
+	buf := bp.newBuffer()
+	buf.frame = frame
+	buf.dirtyPages = bp.dirtyPages
+
+	return buf
+}
+
+// Len возвращает число буферов в пуле
+func (bp *BuffersPool) Len() int {
+	bp.mu.RLock()
+	defer bp.mu.RUnlock()
+
+	return bp.len
+}
+
+// AddBuffers добавляет в пул n пустых буферов
+func (bp *BuffersPool) AddBuffers(n int) {
 	bp.mu.Lock()
 	defer bp.mu.Unlock()
 
-	buffers := make([]*Buffer, bp.len)
+	frames := make([]*Buffer, bp.len, bp.len+n)
+	copy(frames, bp.frames)
+
+	for i := 0; i < n; i++ {
+		frames = append(frames, bp.makeBuffer(bp.len+i))
+	}
+
+	bp.setFrames(frames)
+}
+
+// RemoveUnpinnedBuffers убирает из пула до n незакрепленных буферов и возвращает, сколько убрано.
+// Сначала убирает пустые буферы, потом — выбранные стратегией замены. Измененные страницы записывает на диск
+func (bp *BuffersPool) RemoveUnpinnedBuffers(n int) (int, error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	victims := make(map[*Buffer]struct{}, n)
+
+	for _, buf := range bp.frames {
+		if len(victims) >= n {
+			break
+		}
+
+		if !buf.IsPinned() && buf.block == nil {
+			victims[buf] = struct{}{}
+		}
+	}
+
+	// Временно закрепляем выбранные буферы, чтобы стратегия замены не выбрала их повторно
+	for len(victims) < n {
+		buf := bp.policy.Victim()
+		if buf == nil {
+			break
+		}
+
+		buf.Pin()
+		victims[buf] = struct{}{}
+	}
+
+	for buf := range victims {
+		if buf.IsPinned() {
+			buf.Unpin()
+		}
+	}
+
+	frames := make([]*Buffer, 0, bp.len-len(victims))
+
+	var err error
+
+	for _, buf := range bp.frames {
+		if _, ok := victims[buf]; ok && err == nil {
+			if err = bp.evict(buf); err == nil {
+				continue
+			}
+		}
+
+		frames = append(frames, buf)
+	}
+
+	for i, buf := range frames {
+		buf.frame = i
+	}
+
+	removed := bp.len - len(frames)
+
+	bp.setFrames(frames)
+
+	return removed, err
+}
+
+// evict записывает измененную страницу на диск и отвязывает буфер от пула
+func (bp *BuffersPool) evict(buf *Buffer) error {
+	written, err := buf.flush()
+	if err != nil {
+		return err
+	}
 
-	for i := 0; i < bp.len; i++ {
-		buffers[i], _ = bp.ring.Value.(*Buffer)
-		bp.ring = bp.ring.Next()
+	if written {
+		bp.stats.dirtyWrite(buf.Block())
 	}
 
+	if buf.block != nil {
+		delete(bp.blocksToBuffers, *buf.block)
+		bp.stats.eviction(*buf.block)
+	}
+
+	buf.dropView()
+	buf.frame = -1
+
+	return nil
+}
+
+// dropViews отвязывает от блоков буферы, которые смотрят в отображенные в память файлы, и освобождает их страницы.
+// Закрепленные буферы не трогает
+func (bp *BuffersPool) dropViews() {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	for _, buf := range bp.frames {
+		if buf.IsPinned() || buf.releaseView == nil {
+			continue
+		}
+
+		delete(bp.blocksToBuffers, *buf.block)
+		bp.policy.Reset(buf)
+
+		buf.dropView()
+		buf.block = nil
+	}
+}
+
+func (bp *BuffersPool) setFrames(frames []*Buffer) {
+	bp.frames = frames
+	bp.len = len(frames)
+	bp.policy.Resize(frames)
+}
+
+// reserveBuffer связывает чистый незакрепленный буфер с блоком, не читая страницу с диска: ее прочитает упреждающее чтение
+func (bp *BuffersPool) reserveBuffer(buf *Buffer, block types.Block) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	oldBlock := buf.Block()
+
+	delete(bp.blocksToBuffers, oldBlock)
+	bp.blocksToBuffers[block] = buf
+
+	bp.policy.Reset(buf)
+	buf.startLoading(block)
+
+	if oldBlock.Filename != "" {
+		bp.stats.eviction(oldBlock)
+	}
+}
+
+// Buffers возвращает массив буферов в виде слайса
+func (bp *BuffersPool) Buffers() []*Buffer {
+	bp.mu.RLock()
+	defer bp.mu.RUnlock()
+
+	buffers := make([]*Buffer, bp.len)
+	copy(buffers, bp.frames)
+
 	return buffers
 }
 
@@ -53,25 +242,34 @@ func (bp *BuffersPool) FlushAll(txnum types.TRX) error {
 	bp.mu.Lock()
 	defer bp.mu.Unlock()
 
-	for i := 0; i < bp.len; i++ {
-		buf, ok := bp.ring.Value.(*Buffer)
-		if ok && buf.ModifyingTX() == txnum {
-			err := buf.Flush()
-			if err != nil {
-				return err
-			}
+	for _, buf := range bp.dirtyPages.buffers(txnum) {
+		written, err := buf.flush()
+		if err != nil {
+			return err
 		}
 
-		bp.ring = bp.ring.Next()
+		if written {
+			bp.stats.dirtyWrite(buf.Block())
+		}
 	}
 
 	return nil
 }
 
+// DirtyBuffers возвращает буферы с изменениями, которые еще не записаны на диск
+func (bp *BuffersPool) DirtyBuffers() []*Buffer {
+	return bp.dirtyPages.all()
+}
+
+// DirtyCount возвращает число буферов с изменениями, которые еще не записаны на диск
+func (bp *BuffersPool) DirtyCount() int {
+	return bp.dirtyPages.len()
+}
+
 // FindExistingBuffer ищет существующий буфер, соотоветсвующий блоку
 func (bp *BuffersPool) FindExistingBuffer(block types.Block) *Buffer {
-	bp.mu.Lock()
-	defer bp.mu.Unlock()
+	bp.mu.RLock()
+	defer bp.mu.RUnlock()
 
 	if buf, ok := bp.blocksToBuffers[block]; ok {
 		return buf
@@ -80,21 +278,26 @@ func (bp *BuffersPool) FindExistingBuffer(block types.Block) *Buffer {
 	return nil
 }
 
-// ChooseUnpinnedBuffer ищет незакрепленные буферы в памяти
+// ChooseUnpinnedBuffer выбирает незакрепленный буфер для замены по стратегии пула
 func (bp *BuffersPool) ChooseUnpinnedBuffer() *Buffer {
 	bp.mu.Lock()
 	defer bp.mu.Unlock()
 
-	for i := 0; i < bp.len; i++ {
-		bp.ring = bp.ring.Next()
+	return bp.policy.Victim()
+}
 
-		buf, ok := bp.ring.Value.(*Buffer)
-		if ok && !buf.IsPinned() {
-			return buf
-		}
-	}
+// Touch сообщает стратегии замены об обращении к буферу
+func (bp *BuffersPool) Touch(buf *Buffer) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
 
-	return nil
+	bp.policy.Touch(buf)
+}
+
+// TouchShared сообщает стратегии замены о повторном закреплении уже закрепленного буфера.
+// Блокировку пула не берет: вызывающий держит разделяемую блокировку части пула, под которой пул не меняется
+func (bp *BuffersPool) TouchShared(buf *Buffer) {
+	bp.policy.TouchShared(buf)
 }
 
 // AssignBufferToBlock связывает буфер с блоком на диске
@@ -104,7 +307,24 @@ func (bp *BuffersPool) AssignBufferToBlock(buf *Buffer, block types.Block) error
 
 	bp.blocksToBuffers[block] = buf
 
-	delete(bp.blocksToBuffers, buf.Block())
+	oldBlock := buf.Block()
+
+	delete(bp.blocksToBuffers, oldBlock)
+
+	bp.policy.Reset(buf)
 
-	return buf.AssignToBlock(block)
+	written, err := buf.assignToBlock(block)
+	if written {
+		bp.stats.dirtyWrite(oldBlock)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if oldBlock.Filename != "" {
+		bp.stats.eviction(oldBlock)
+	}
+
+	return nil
 }
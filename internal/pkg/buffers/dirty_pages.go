@@ -0,0 +1,93 @@
+package buffers
+
+import (
+	"sync"
+
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+// dirtyPageTable — таблица измененных, но еще не записанных на диск буферов в разрезе транзакций.
+// Нужна, чтобы при коммите и в фоновой записи не обходить весь пул
+type dirtyPageTable struct {
+	mu sync.Mutex
+
+	byTRX map[types.TRX]map[*Buffer]struct{}
+}
+
+func newDirtyPageTable() *dirtyPageTable {
+	return &dirtyPageTable{
+		byTRX: make(map[types.TRX]map[*Buffer]struct{}),
+	}
+}
+
+// move переносит буфер от транзакции from к транзакции to. Отрицательный номер — буфер без изменений
+func (t *dirtyPageTable) move(buf *Buffer, from, to types.TRX) {
+	if t == nil || from == to {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if bufs, ok := t.byTRX[from]; ok {
+		delete(bufs, buf)
+
+		if len(bufs) == 0 {
+			delete(t.byTRX, from)
+		}
+	}
+
+	if to < 0 {
+		return
+	}
+
+	bufs, ok := t.byTRX[to]
+	if !ok {
+		bufs = make(map[*Buffer]struct{})
+		t.byTRX[to] = bufs
+	}
+
+	bufs[buf] = struct{}{}
+}
+
+// buffers возвращает измененные транзакцией буферы
+func (t *dirtyPageTable) buffers(txnum types.TRX) []*Buffer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	bufs := make([]*Buffer, 0, len(t.byTRX[txnum]))
+	for buf := range t.byTRX[txnum] {
+		bufs = append(bufs, buf)
+	}
+
+	return bufs
+}
+
+// all возвращает все измененные буферы
+func (t *dirtyPageTable) all() []*Buffer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var bufs []*Buffer
+
+	for _, trxBufs := range t.byTRX {
+		for buf := range trxBufs {
+			bufs = append(bufs, buf)
+		}
+	}
+
+	return bufs
+}
+
+// len возвращает число измененных буферов
+func (t *dirtyPageTable) len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := 0
+	for _, bufs := range t.byTRX {
+		n += len(bufs)
+	}
+
+	return n
+}
@@ -10,3 +10,12 @@ var ErrFailedToAssignBlockToBuffer = errors.Wrap(ErrBuffers, "failed to assign a
 
 // ErrNoAvailableBuffers — нет свободных буферов в памяти
 var ErrNoAvailableBuffers = errors.Wrap(ErrBuffers, "no available buffers")
+
+// ErrUnknownReplacementPolicy — неизвестная стратегия замены буферов
+var ErrUnknownReplacementPolicy = errors.Wrap(ErrBuffers, "unknown replacement policy")
+
+// ErrBadPoolLen — недопустимый размер пула буферов
+var ErrBadPoolLen = errors.Wrap(ErrBuffers, "bad buffers pool length")
+
+// ErrResizeInterrupted — изменение размера пула прервано до того, как освободились закрепленные буферы
+var ErrResizeInterrupted = errors.Wrap(ErrBuffers, "buffers pool resize interrupted")
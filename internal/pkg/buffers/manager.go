@@ -1,32 +1,36 @@
 package buffers
 
 import (
+	"context"
+	"runtime"
 	"sync"
 	"time"
 
-	"github.com/pkg/errors"
-
 	"github.com/unhandled-exception/sophiadb/internal/pkg/storage"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
-	"github.com/unhandled-exception/sophiadb/internal/pkg/utils"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/wal"
 )
 
 var defaultMaxPinTimeout time.Duration = 10 * time.Second
 
-// Manager менеджер буферов в памяти
+// Manager менеджер буферов в памяти.
+// Пул разбит на части по хешу блока, у каждой части своя блокировка, очередь ожидания и стратегия замены
 type Manager struct {
-	mu sync.Mutex
-
-	Len            int
 	PinLockTimeout time.Duration
 
 	fm *storage.Manager
 	lm *wal.Manager
 
-	pinLock   *utils.Cond
-	pool      *BuffersPool
-	available int
+	partitions      []*partition
+	partitionsCount int
+
+	newPolicy ReplacementPolicyFactory
+
+	readAhead  int
+	prefetches sync.WaitGroup
+
+	writer    *backgroundWriter
+	closeOnce sync.Once
 }
 
 type ManagerOpt func(*Manager)
@@ -34,29 +38,60 @@ type ManagerOpt func(*Manager)
 // NewManager создает новый менеджер пулов
 func NewManager(fm *storage.Manager, lm *wal.Manager, pLen int, opts ...ManagerOpt) *Manager {
 	bm := &Manager{
-		fm:             fm,
-		lm:             lm,
-		Len:            pLen,
-		available:      pLen,
-		PinLockTimeout: defaultMaxPinTimeout,
-		pinLock:        utils.NewCond(&sync.Mutex{}),
+		fm:              fm,
+		lm:              lm,
+		PinLockTimeout:  defaultMaxPinTimeout,
+		partitionsCount: 1,
+		newPolicy:       NewClockPolicy,
 	}
 
-	bm.pool = NewBuffersPool(pLen, bm.newBuffer)
-
 	for _, opt := range opts {
 		opt(bm)
 	}
 
+	// В каждой части должен быть хотя бы один буфер
+	count := max(1, min(bm.partitionsCount, pLen))
+
+	bm.partitions = make([]*partition, count)
+	for i := range bm.partitions {
+		bm.partitions[i] = newPartition(partitionLen(pLen, count, i), bm.newBuffer, bm.newPolicy)
+	}
+
+	if bm.writer != nil {
+		go bm.runBackgroundWriter()
+	}
+
 	return bm
 }
 
+// WithPartitions делит пул на n частей с независимыми блокировками
+func WithPartitions(n int) ManagerOpt {
+	return func(m *Manager) {
+		m.partitionsCount = n
+	}
+}
+
+// minPartitionLen — меньше буферов в части не делаем при автоматическом выборе числа частей
+const minPartitionLen = 64
+
+// AutoPartitions подбирает число частей пула: по одной на процессор, но не меньше minPartitionLen буферов в части
+func AutoPartitions(pLen int) int {
+	return max(1, min(runtime.GOMAXPROCS(0), pLen/minPartitionLen))
+}
+
 func WithPinLockTimeout(pinLockTimeout time.Duration) ManagerOpt {
 	return func(m *Manager) {
 		m.PinLockTimeout = pinLockTimeout
 	}
 }
 
+// WithReplacementPolicy задает стратегию замены буферов в пуле
+func WithReplacementPolicy(newPolicy ReplacementPolicyFactory) ManagerOpt {
+	return func(m *Manager) {
+		m.newPolicy = newPolicy
+	}
+}
+
 // StorageManager возвращает менеджер хранилища
 func (bm *Manager) StorageManager() *storage.Manager {
 	return bm.fm
@@ -71,81 +106,147 @@ func (bm *Manager) SetMaxPinLockTime(t time.Duration) {
 	bm.PinLockTimeout = t
 }
 
+// Partitions возвращает число частей пула
+func (bm *Manager) Partitions() int {
+	return len(bm.partitions)
+}
+
+// partitionFor возвращает часть пула, в которой живет блок. Считаем FNV-1a от имени файла и номера блока
+func (bm *Manager) partitionFor(block types.Block) *partition {
+	if len(bm.partitions) == 1 {
+		return bm.partitions[0]
+	}
+
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+
+	h := uint32(offset32)
+
+	for i := 0; i < len(block.Filename); i++ {
+		h ^= uint32(block.Filename[i])
+		h *= prime32
+	}
+
+	h ^= uint32(block.Number)
+	h *= prime32
+
+	return bm.partitions[h%uint32(len(bm.partitions))]
+}
+
 // Available возвращает число доступных буферов
 func (bm *Manager) Available() int {
-	return bm.available
+	available := 0
+
+	for _, p := range bm.partitions {
+		p.mu.RLock()
+		available += p.available
+		p.mu.RUnlock()
+	}
+
+	return available
+}
+
+// Len возвращает число буферов в пуле
+func (bm *Manager) Len() int {
+	n := 0
+
+	for _, p := range bm.partitions {
+		n += p.pool.Len()
+	}
+
+	return n
 }
 
 // FlushAll сбрасывает все буферы транзакции на диск
 func (bm *Manager) FlushAll(txnum types.TRX) error {
-	return bm.pool.FlushAll(txnum)
+	for _, p := range bm.partitions {
+		if err := p.pool.FlushAll(txnum); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-// Unpin уменьшает счетчик закреплений. Если буфер освободился, то дает сигнал другим потокам, что появился свободный буфер
-func (bm *Manager) Unpin(buf *Buffer) {
-	bm.mu.Lock()
-	defer bm.mu.Unlock()
+// Stats возвращает снимок статистики пула буферов
+func (bm *Manager) Stats() Stats {
+	stats := Stats{
+		Files: make(map[string]Counters),
+	}
+
+	for _, p := range bm.partitions {
+		ps := p.stats.snapshot()
 
-	buf.Unpin()
+		stats.add(ps.Counters)
+		stats.PinWaits += ps.PinWaits
+		stats.PinTimeouts += ps.PinTimeouts
+		stats.DirtyPages += p.pool.DirtyCount()
 
-	if !buf.IsPinned() {
-		bm.available++
-		bm.pinLock.Broadcast()
+		for name, fc := range ps.Files {
+			c := stats.Files[name]
+			c.add(fc)
+			stats.Files[name] = c
+		}
 	}
+
+	return stats
 }
 
-// Pin — закрепляет блок в памяти
-func (bm *Manager) Pin(block types.Block) (*Buffer, error) {
-	buf, err := bm.tryToPin(block)
-	if err != nil && !errors.Is(err, ErrNoAvailableBuffers) {
-		return nil, err
-	}
+// Frames возвращает снимок состояния буферов пула. Буферы нумеруются подряд по всем частям пула
+func (bm *Manager) Frames() []FrameInfo {
+	var frames []FrameInfo
 
-	if errors.Is(err, ErrNoAvailableBuffers) {
-		bm.pinLock.L.Lock()
-		defer bm.pinLock.L.Unlock()
+	for _, p := range bm.partitions {
+		p.mu.RLock()
 
-		deadline := time.Now().Add(bm.PinLockTimeout)
+		offset := len(frames)
 
-		for buf == nil && time.Now().Before(deadline) {
-			bm.pinLock.WaitWithTimeout(bm.PinLockTimeout)
+		for _, buf := range p.pool.Buffers() {
+			txnum, lsn := buf.modification()
 
-			buf, err = bm.tryToPin(block)
-			if err != nil && !errors.Is(err, ErrNoAvailableBuffers) {
-				return nil, err
-			}
+			frames = append(frames, FrameInfo{
+				Frame:       offset + buf.frame,
+				Block:       buf.Block(),
+				Pins:        buf.Pins(),
+				ModifyingTX: txnum,
+				LSN:         lsn,
+			})
 		}
-	}
 
-	if buf == nil {
-		return nil, ErrNoAvailableBuffers
+		p.mu.RUnlock()
 	}
 
-	return buf, nil
+	return frames
 }
 
-func (bm *Manager) tryToPin(block types.Block) (*Buffer, error) {
-	bm.mu.Lock()
-	defer bm.mu.Unlock()
+// Unpin уменьшает счетчик закреплений. Если буфер освободился, то будит первого в очереди ожидания буфера
+func (bm *Manager) Unpin(buf *Buffer) {
+	bm.partitionFor(buf.Block()).unpin(buf)
+}
 
-	buf := bm.pool.FindExistingBuffer(block)
-	if buf == nil {
-		buf = bm.pool.ChooseUnpinnedBuffer()
-		if buf == nil {
-			return nil, ErrNoAvailableBuffers
-		}
+// Pin — закрепляет блок в памяти. Ждет свободный буфер не дольше PinLockTimeout
+func (bm *Manager) Pin(block types.Block) (*Buffer, error) {
+	return bm.PinContext(context.Background(), block)
+}
 
-		err := bm.pool.AssignBufferToBlock(buf, block)
-		if err != nil {
-			return nil, err
-		}
-	}
+// PinContext — закрепляет блок в памяти. Если в части пула, где живет блок, свободных буферов нет, то встает в очередь:
+// буферы достаются ждущим в порядке очереди. Ожидание прерывается по отмене контекста или через PinLockTimeout
+func (bm *Manager) PinContext(ctx context.Context, block types.Block) (*Buffer, error) {
+	p := bm.partitionFor(block)
 
-	if !buf.IsPinned() {
-		bm.available--
+	buf, err := p.pin(ctx, block, bm.PinLockTimeout)
+	if err != nil {
+		return nil, err
 	}
 
-	buf.Pin()
+	// Буфер мог зарезервировать упреждающее чтение, тогда ждем, пока оно прочитает страницу
+	if err := buf.waitLoaded(); err != nil {
+		p.unpin(buf)
+
+		return nil, err
+	}
 
 	return buf, nil
 }
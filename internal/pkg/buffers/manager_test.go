@@ -1,6 +1,9 @@
 package buffers_test
 
 import (
+	"context"
+	"fmt"
+	"os"
 	"path/filepath"
 	"sync"
 	"testing"
@@ -88,6 +91,39 @@ func (ts *BuffersManagerTestSuite) TestWaitToPinBuffer() {
 	require.NoError(t, err)
 }
 
+func (ts *BuffersManagerTestSuite) TestPinShared_Touch() {
+	t := ts.T()
+
+	sut, path := ts.createBuffersManager(2, buffers.WithReplacementPolicy(buffers.NewLRUPolicy))
+	defer sut.StorageManager().Close()
+
+	testutil.CreateFile(ts, filepath.Join(path, testFile), make([]byte, 10*400))
+
+	block1 := types.Block{Filename: testFile, Number: 1}
+	block2 := types.Block{Filename: testFile, Number: 2}
+	block3 := types.Block{Filename: testFile, Number: 3}
+
+	buf1, err := sut.Pin(block1)
+	require.NoError(t, err)
+
+	buf2, err := sut.Pin(block2)
+	require.NoError(t, err)
+	sut.Unpin(buf2)
+
+	// Повторное закрепление уже закрепленного блока идет быстрым путем и тоже считается обращением
+	buf1Again, err := sut.Pin(block1)
+	require.NoError(t, err)
+	require.Same(t, buf1, buf1Again)
+
+	sut.Unpin(buf1)
+	sut.Unpin(buf1Again)
+
+	buf3, err := sut.Pin(block3)
+	require.NoError(t, err)
+	require.Same(t, buf2, buf3)
+	sut.Unpin(buf3)
+}
+
 func (ts *BuffersManagerTestSuite) TestBuffersManager() {
 	bm, path := ts.createBuffersManager(3)
 	defer bm.StorageManager().Close()
@@ -206,3 +242,635 @@ func (ts *BuffersManagerTestSuite) TestConcurrency() {
 
 	wg.Wait()
 }
+
+func (ts *BuffersManagerTestSuite) TestStats() {
+	t := ts.T()
+
+	sut, path := ts.createBuffersManager(2, buffers.WithPinLockTimeout(10*time.Millisecond))
+	defer sut.StorageManager().Close()
+
+	otherFile := "other_file.dat"
+
+	testutil.CreateFile(ts, filepath.Join(path, testFile), make([]byte, 10*400))
+	testutil.CreateFile(ts, filepath.Join(path, otherFile), make([]byte, 10*400))
+
+	block0 := types.Block{Filename: testFile, Number: 0}
+	block1 := types.Block{Filename: testFile, Number: 1}
+	other0 := types.Block{Filename: otherFile, Number: 0}
+
+	buf0, err := sut.Pin(block0)
+	require.NoError(t, err)
+	buf0.SetModified(1, -1)
+	sut.Unpin(buf0)
+
+	buf0, err = sut.Pin(block0)
+	require.NoError(t, err)
+
+	buf1, err := sut.Pin(block1)
+	require.NoError(t, err)
+
+	// Все буферы закреплены — ждем и не дожидаемся свободного буфера
+	_, err = sut.Pin(other0)
+	require.ErrorIs(t, err, buffers.ErrNoAvailableBuffers)
+
+	frames := sut.Frames()
+	require.Len(t, frames, 2)
+	f0, f1 := frameOf(frames, block0), frameOf(frames, block1)
+	assert.Equal(t, buffers.FrameInfo{Frame: f0.Frame, Block: block0, Pins: 1, ModifyingTX: 1, LSN: -1}, f0)
+	assert.Equal(t, buffers.FrameInfo{Frame: f1.Frame, Block: block1, Pins: 1, ModifyingTX: -1, LSN: -1}, f1)
+	assert.NotEqual(t, f0.Frame, f1.Frame)
+
+	// Вытесняем измененный блок
+	sut.Unpin(buf0)
+	sut.Unpin(buf1)
+
+	_, err = sut.Pin(other0)
+	require.NoError(t, err)
+
+	stats := sut.Stats()
+
+	assert.Equal(t, buffers.Counters{Hits: 1, Misses: 3, Evictions: 1, DirtyWrites: 1}, stats.Counters)
+	assert.EqualValues(t, 1, stats.PinWaits)
+	assert.EqualValues(t, 1, stats.PinTimeouts)
+	assert.InDelta(t, 0.25, stats.HitRatio(), 0.001)
+	assert.Equal(t, map[string]buffers.Counters{
+		testFile:  {Hits: 1, Misses: 2, Evictions: 1, DirtyWrites: 1},
+		otherFile: {Misses: 1},
+	}, stats.Files)
+}
+
+func frameOf(frames []buffers.FrameInfo, block types.Block) buffers.FrameInfo {
+	for _, f := range frames {
+		if f.Block == block {
+			return f
+		}
+	}
+
+	return buffers.FrameInfo{}
+}
+
+func (ts *BuffersManagerTestSuite) TestBackgroundWriter() {
+	t := ts.T()
+
+	sut, path := ts.createBuffersManager(3, buffers.WithBackgroundWriter(5*time.Millisecond, 10))
+	defer sut.StorageManager().Close()
+	defer sut.Close()
+
+	testutil.CreateFile(ts, filepath.Join(path, testFile), make([]byte, 10*400))
+
+	block0 := types.Block{Filename: testFile, Number: 0}
+	block1 := types.Block{Filename: testFile, Number: 1}
+
+	buf0, err := sut.Pin(block0)
+	require.NoError(t, err)
+	ts.Require().NoError(buf0.Content().SetInt64(8, 12345))
+	buf0.SetModified(1, -1)
+	sut.Unpin(buf0)
+
+	// Закрепленный буфер фоновая запись не трогает
+	buf1, err := sut.Pin(block1)
+	require.NoError(t, err)
+	buf1.SetModified(2, -1)
+
+	require.Eventually(t, func() bool {
+		return buf0.ModifyingTX() == -1
+	}, time.Second, 5*time.Millisecond)
+
+	page := types.NewPage(400)
+	require.NoError(t, sut.StorageManager().Read(block0, page))
+	assert.EqualValues(t, 12345, testutil.Must(page.GetInt64(8)))
+
+	assert.EqualValues(t, 2, buf1.ModifyingTX())
+
+	stats := sut.Stats()
+	assert.EqualValues(t, 1, stats.BackgroundWrites)
+	assert.EqualValues(t, 1, stats.DirtyPages)
+
+	// После коммита второй транзакции таблица измененных буферов пуста
+	require.NoError(t, sut.FlushAll(2))
+	assert.Zero(t, sut.Stats().DirtyPages)
+	assert.EqualValues(t, 1, sut.Stats().DirtyWrites)
+
+	sut.Unpin(buf1)
+	sut.Close()
+}
+
+func (ts *BuffersManagerTestSuite) TestWriteDirtyPages_Limit() {
+	t := ts.T()
+
+	sut, path := ts.createBuffersManager(5)
+	defer sut.StorageManager().Close()
+
+	testutil.CreateFile(ts, filepath.Join(path, testFile), make([]byte, 10*400))
+
+	for i := 0; i < 4; i++ {
+		buf, err := sut.Pin(types.Block{Filename: testFile, Number: types.BlockID(i)})
+		require.NoError(t, err)
+
+		buf.SetModified(types.TRX(i%2), -1)
+		sut.Unpin(buf)
+	}
+
+	assert.Equal(t, 4, sut.Stats().DirtyPages)
+
+	written, err := sut.WriteDirtyPages(3)
+	require.NoError(t, err)
+	assert.Equal(t, 3, written)
+	assert.Equal(t, 1, sut.Stats().DirtyPages)
+
+	written, err = sut.WriteDirtyPages(3)
+	require.NoError(t, err)
+	assert.Equal(t, 1, written)
+	assert.Zero(t, sut.Stats().DirtyPages)
+}
+
+func (ts *BuffersManagerTestSuite) TestPinContext_Cancel() {
+	t := ts.T()
+
+	sut, path := ts.createBuffersManager(1)
+	defer sut.StorageManager().Close()
+
+	sut.SetMaxPinLockTime(5 * time.Second)
+
+	testutil.CreateFile(ts, filepath.Join(path, testFile), make([]byte, 10*400))
+
+	buf0, err := sut.Pin(types.Block{Filename: testFile, Number: 0})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	started := time.Now()
+
+	_, err = sut.PinContext(ctx, types.Block{Filename: testFile, Number: 1})
+	require.ErrorIs(t, err, buffers.ErrNoAvailableBuffers)
+	assert.ErrorContains(t, err, context.Canceled.Error())
+	assert.Less(t, time.Since(started), time.Second)
+
+	// Блок с уже закрепленным буфером закрепляется без ожидания
+	buf, err := sut.PinContext(ctx, types.Block{Filename: testFile, Number: 0})
+	require.NoError(t, err)
+	assert.Same(t, buf0, buf)
+
+	stats := sut.Stats()
+	assert.EqualValues(t, 1, stats.PinWaits)
+	assert.EqualValues(t, 1, stats.PinTimeouts)
+}
+
+func (ts *BuffersManagerTestSuite) TestPinContext_FIFO() {
+	t := ts.T()
+
+	sut, path := ts.createBuffersManager(1)
+	defer sut.StorageManager().Close()
+
+	sut.SetMaxPinLockTime(5 * time.Second)
+
+	testutil.CreateFile(ts, filepath.Join(path, testFile), make([]byte, 10*400))
+
+	buf0, err := sut.Pin(types.Block{Filename: testFile, Number: 0})
+	require.NoError(t, err)
+
+	order := make(chan int, 3)
+	pinned := make(chan *buffers.Buffer, 3)
+
+	waiter := func(n int) {
+		buf, err := sut.Pin(types.Block{Filename: testFile, Number: types.BlockID(n)})
+		assert.NoError(t, err)
+
+		order <- n
+		pinned <- buf
+	}
+
+	// Ждущие встают в очередь строго по одному
+	for n := 1; n <= 3; n++ {
+		go waiter(n)
+
+		require.Eventually(t, func() bool {
+			return sut.Stats().PinWaits == int64(n)
+		}, time.Second, time.Millisecond)
+	}
+
+	sut.Unpin(buf0)
+
+	for n := 1; n <= 3; n++ {
+		assert.Equal(t, n, <-order)
+		sut.Unpin(<-pinned)
+	}
+}
+
+func (ts *BuffersManagerTestSuite) TestResize_Grow() {
+	t := ts.T()
+
+	sut, path := ts.createBuffersManager(1)
+	defer sut.StorageManager().Close()
+
+	sut.SetMaxPinLockTime(5 * time.Second)
+
+	testutil.CreateFile(ts, filepath.Join(path, testFile), make([]byte, 10*400))
+
+	buf0, err := sut.Pin(types.Block{Filename: testFile, Number: 0})
+	require.NoError(t, err)
+
+	pinned := make(chan *buffers.Buffer)
+
+	go func() {
+		buf, err := sut.Pin(types.Block{Filename: testFile, Number: 1})
+		assert.NoError(t, err)
+
+		pinned <- buf
+	}()
+
+	require.Eventually(t, func() bool {
+		return sut.Stats().PinWaits == 1
+	}, time.Second, time.Millisecond)
+
+	// Новый буфер сразу достается ждущему в очереди
+	require.NoError(t, sut.Resize(3))
+	buf1 := <-pinned
+
+	assert.Equal(t, 3, sut.Len())
+	assert.Equal(t, 1, sut.Available())
+	assert.Len(t, sut.Frames(), 3)
+
+	sut.Unpin(buf0)
+	sut.Unpin(buf1)
+
+	assert.Equal(t, 3, sut.Available())
+
+	require.ErrorIs(t, sut.Resize(0), buffers.ErrBadPoolLen)
+}
+
+func (ts *BuffersManagerTestSuite) TestResize_Shrink() {
+	t := ts.T()
+
+	sut, path := ts.createBuffersManager(4)
+	defer sut.StorageManager().Close()
+
+	testutil.CreateFile(ts, filepath.Join(path, testFile), make([]byte, 10*400))
+
+	bufs := make([]*buffers.Buffer, 3)
+
+	for i := range bufs {
+		buf, err := sut.Pin(types.Block{Filename: testFile, Number: types.BlockID(i)})
+		require.NoError(t, err)
+
+		bufs[i] = buf
+	}
+
+	// Измененная страница при вытеснении записывается на диск
+	bufs[0].Content().SetInt64(80, 42) //nolint:mnd
+	bufs[0].SetModified(1, -1)
+	sut.Unpin(bufs[0])
+
+	// Незакрепленные буферы убираются сразу, закрепленные — когда их открепят
+	done := make(chan error)
+
+	go func() {
+		done <- sut.Resize(1)
+	}()
+
+	require.Eventually(t, func() bool {
+		return sut.Len() == 2
+	}, time.Second, time.Millisecond)
+
+	// Пока менеджер ждет буферы для удаления, новые запросы встают в очередь за ним
+	_, err := sut.PinContext(context.Background(), types.Block{Filename: testFile, Number: 5})
+	require.ErrorIs(t, err, buffers.ErrNoAvailableBuffers)
+
+	sut.Unpin(bufs[1])
+	require.NoError(t, <-done)
+
+	assert.Equal(t, 1, sut.Len())
+	assert.Equal(t, 0, sut.Available())
+	assert.Equal(t, bufs[2].Block(), sut.Frames()[0].Block)
+	assert.Zero(t, sut.Stats().DirtyPages)
+
+	sut.Unpin(bufs[2])
+	assert.Equal(t, 1, sut.Available())
+
+	buf, err := sut.Pin(types.Block{Filename: testFile, Number: 0})
+	require.NoError(t, err)
+	assert.EqualValues(t, 42, testutil.Must(buf.Content().GetInt64(80))) //nolint:mnd
+	sut.Unpin(buf)
+}
+
+func (ts *BuffersManagerTestSuite) TestResize_Cancel() {
+	t := ts.T()
+
+	sut, path := ts.createBuffersManager(3)
+	defer sut.StorageManager().Close()
+
+	testutil.CreateFile(ts, filepath.Join(path, testFile), make([]byte, 10*400))
+
+	bufs := make([]*buffers.Buffer, 2)
+
+	for i := range bufs {
+		buf, err := sut.Pin(types.Block{Filename: testFile, Number: types.BlockID(i)})
+		require.NoError(t, err)
+
+		bufs[i] = buf
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, sut.ResizeContext(ctx, -1), buffers.ErrBadPoolLen)
+	require.ErrorIs(t, sut.ResizeContext(ctx, 1), buffers.ErrResizeInterrupted)
+
+	// Свободный буфер успели убрать, закрепленные остались в пуле
+	assert.Equal(t, 2, sut.Len())
+	assert.Equal(t, 0, sut.Available())
+
+	for _, buf := range bufs {
+		sut.Unpin(buf)
+	}
+
+	assert.Equal(t, 2, sut.Available())
+}
+
+func (ts *BuffersManagerTestSuite) TestResize_Concurrency() {
+	t := ts.T()
+
+	sut, path := ts.createBuffersManager(8)
+	defer sut.StorageManager().Close()
+
+	sut.SetMaxPinLockTime(5 * time.Second)
+
+	testutil.CreateFile(ts, filepath.Join(path, testFile), make([]byte, 32*400))
+
+	var wg sync.WaitGroup
+
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+
+		go func(g int) {
+			defer wg.Done()
+
+			for i := 0; i < 200; i++ {
+				buf, err := sut.Pin(types.Block{Filename: testFile, Number: types.BlockID((g*7 + i) % 32)})
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				sut.Unpin(buf)
+			}
+		}(g)
+	}
+
+	for _, n := range []int{2, 16, 1, 8} {
+		require.NoError(t, sut.Resize(n))
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 8, sut.Len())
+	assert.Equal(t, 8, sut.Available())
+	assert.Len(t, sut.Frames(), 8)
+}
+
+func (ts *BuffersManagerTestSuite) TestPartitions() {
+	t := ts.T()
+
+	sut, path := ts.createBuffersManager(10, buffers.WithPartitions(4))
+	defer sut.StorageManager().Close()
+
+	testutil.CreateFile(ts, filepath.Join(path, testFile), make([]byte, 100*400))
+
+	assert.Equal(t, 4, sut.Partitions())
+	assert.Equal(t, 10, sut.Len())
+	assert.Equal(t, 10, sut.Available())
+
+	var wg sync.WaitGroup
+
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+
+		go func(g int) {
+			defer wg.Done()
+
+			for i := 0; i < 100; i++ {
+				block := types.Block{Filename: testFile, Number: types.BlockID((g + i) % 100)}
+
+				buf, err := sut.Pin(block)
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				// Повторное закрепление уже закрепленного буфера идет без исключительной блокировки
+				same, err := sut.Pin(block)
+				assert.NoError(t, err)
+				assert.Same(t, buf, same)
+
+				sut.Unpin(same)
+				sut.Unpin(buf)
+			}
+		}(g)
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 10, sut.Available())
+
+	// Номера буферов сквозные по всем частям пула
+	frames := sut.Frames()
+	require.Len(t, frames, 10)
+
+	for i, frame := range frames {
+		assert.Equal(t, i, frame.Frame)
+		assert.Zero(t, frame.Pins)
+	}
+
+	assert.EqualValues(t, 8*100*2, sut.Stats().Hits+sut.Stats().Misses)
+
+	require.ErrorIs(t, sut.Resize(3), buffers.ErrBadPoolLen)
+	require.NoError(t, sut.Resize(4))
+	assert.Equal(t, 4, sut.Len())
+	require.NoError(t, sut.Resize(12))
+	assert.Equal(t, 12, sut.Available())
+
+	// В пуле из одного буфера делить нечего
+	single, _ := ts.createBuffersManager(1, buffers.WithPartitions(4))
+	defer single.StorageManager().Close()
+
+	assert.Equal(t, 1, single.Partitions())
+}
+
+// BenchmarkPin_Parallel — закрепление блоков из многих горутин в пуле из одной части и в пуле, разбитом на части
+func BenchmarkPin_Parallel(b *testing.B) {
+	const (
+		poolLen = 256
+		blocks  = 128
+	)
+
+	for _, partitions := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("partitions=%d", partitions), func(b *testing.B) {
+			path := b.TempDir()
+
+			require.NoError(b, os.WriteFile(filepath.Join(path, testFile), make([]byte, blocks*400), 0o600))
+
+			fm, err := storage.NewFileManager(path, 400) //nolint:mnd
+			require.NoError(b, err)
+
+			defer fm.Close()
+
+			lm, err := wal.NewManager(fm, "wal_log.dat")
+			require.NoError(b, err)
+
+			sut := buffers.NewManager(fm, lm, poolLen, buffers.WithPartitions(partitions))
+
+			b.SetParallelism(4) //nolint:mnd
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+
+				for pb.Next() {
+					buf, err := sut.Pin(types.Block{Filename: testFile, Number: types.BlockID(i % blocks)})
+					if err != nil {
+						b.Error(err)
+
+						return
+					}
+
+					sut.Unpin(buf)
+
+					i++
+				}
+			})
+		})
+	}
+}
+
+func (ts *BuffersManagerTestSuite) TestPrefetch() {
+	t := ts.T()
+
+	sut, path := ts.createBuffersManager(4, buffers.WithReadAhead(8))
+	defer sut.StorageManager().Close()
+
+	assert.Equal(t, 8, sut.ReadAheadWindow())
+
+	data := make([]byte, 10*400)
+	for n := 0; n < 10; n++ {
+		data[n*400] = byte(n + 1)
+	}
+
+	testutil.CreateFile(ts, filepath.Join(path, testFile), data)
+
+	// Буфер с изменениями упреждающее чтение не вытесняет
+	buf0, err := sut.Pin(types.Block{Filename: testFile, Number: 0})
+	require.NoError(t, err)
+	buf0.SetModified(1, -1)
+	sut.Unpin(buf0)
+
+	sut.Prefetch(types.Block{Filename: testFile, Number: 1}, 100)
+	sut.Close()
+
+	stats := sut.Stats()
+	assert.EqualValues(t, 3, stats.Prefetches)
+	assert.Equal(t, 4, sut.Available())
+
+	for n := 1; n <= 3; n++ {
+		buf, err := sut.Pin(types.Block{Filename: testFile, Number: types.BlockID(n)})
+		require.NoError(t, err)
+		assert.Equal(t, byte(n+1), buf.Content().Content()[0])
+		sut.Unpin(buf)
+	}
+
+	assert.EqualValues(t, 3, sut.Stats().Hits-stats.Hits)
+	assert.EqualValues(t, 1, buf0.ModifyingTX())
+	assert.EqualValues(t, 0, buf0.Block().Number)
+}
+
+func (ts *BuffersManagerTestSuite) TestMmapViews() {
+	var blockSize uint32 = 400
+
+	path := testutil.CreateTestTemporaryDir(ts)
+	fm, err := storage.NewMmapFileManager(path, blockSize)
+	ts.Require().NoError(err)
+
+	testutil.CreateFile(ts, filepath.Join(path, testFile), make([]byte, 4*blockSize))
+
+	defer fm.Close()
+
+	lm, err := wal.NewManager(fm, "wal_log.dat")
+	ts.Require().NoError(err)
+
+	bm := buffers.NewManager(fm, lm, 2)
+	defer bm.Close()
+
+	block := types.Block{Filename: testFile, Number: 1}
+
+	buf, err := bm.Pin(block)
+	ts.Require().NoError(err)
+	ts.True(buf.IsView())
+
+	// Изменение копирует страницу в буфер и не попадает в файл до записи буфера на диск
+	buf.WritableContent().SetInt64(0, 42)
+	buf.SetModified(1, -1)
+	ts.False(buf.IsView())
+	ts.EqualValues(42, testutil.Must(buf.Content().GetInt64(0)))
+
+	page := types.NewPage(blockSize)
+	ts.Require().NoError(fm.Read(block, page))
+	ts.Zero(testutil.Must(page.GetInt64(0)))
+
+	ts.Require().NoError(bm.FlushAll(1))
+	bm.Unpin(buf)
+
+	// Вытесняем блок и читаем его снова через отображение
+	for i := int32(2); i < 4; i++ {
+		other, err := bm.Pin(types.Block{Filename: testFile, Number: types.BlockID(i)})
+		ts.Require().NoError(err)
+		ts.True(other.IsView())
+		bm.Unpin(other)
+	}
+
+	buf, err = bm.Pin(block)
+	ts.Require().NoError(err)
+	ts.True(buf.IsView())
+	ts.EqualValues(42, testutil.Must(buf.Content().GetInt64(0)))
+	bm.Unpin(buf)
+}
+
+func (ts *BuffersManagerTestSuite) TestMmapViews_ConcurrentContent() {
+	var blockSize uint32 = 400
+
+	path := testutil.CreateTestTemporaryDir(ts)
+	fm, err := storage.NewMmapFileManager(path, blockSize)
+	ts.Require().NoError(err)
+
+	testutil.CreateFile(ts, filepath.Join(path, testFile), make([]byte, 2*blockSize))
+
+	defer fm.Close()
+
+	lm, err := wal.NewManager(fm, "wal_log.dat")
+	ts.Require().NoError(err)
+
+	bm := buffers.NewManager(fm, lm, 2)
+	defer bm.Close()
+
+	buf, err := bm.Pin(types.Block{Filename: testFile, Number: 1})
+	ts.Require().NoError(err)
+	ts.True(buf.IsView())
+
+	// Страницу читают, пока другая горутина копирует ее из отображения в буфер
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 100; i++ {
+			_ = buf.Content().Content()[0]
+		}
+	}()
+
+	buf.WritableContent()
+	<-done
+
+	ts.False(buf.IsView())
+	bm.Unpin(buf)
+}
@@ -0,0 +1,247 @@
+package buffers
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+// partition — часть пула буферов со своей блокировкой, очередью ожидания и статистикой.
+// Блок всегда попадает в одну и ту же часть, поэтому части не мешают друг другу
+type partition struct {
+	mu sync.RWMutex
+
+	pool      *BuffersPool
+	available int
+	waiters   *list.List // Очередь ожидания свободного буфера
+	stats     *statsCollector
+}
+
+func newPartition(pLen int, nbf newBufferFunc, newPolicy ReplacementPolicyFactory) *partition {
+	p := &partition{
+		available: pLen,
+		waiters:   list.New(),
+		stats:     newStatsCollector(),
+	}
+
+	p.pool = NewBuffersPool(pLen, nbf,
+		WithPoolReplacementPolicy(newPolicy),
+		withPoolStats(p.stats),
+	)
+
+	return p
+}
+
+// dropViews освобождает страницы отображенных в память файлов у незакрепленных буферов
+func (p *partition) dropViews() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pool.dropViews()
+}
+
+// pin закрепляет блок в памяти. Если свободных буферов нет, то встает в очередь:
+// буферы достаются ждущим в порядке очереди. Ожидание прерывается по отмене контекста или через timeout
+func (p *partition) pin(ctx context.Context, block types.Block, timeout time.Duration) (*Buffer, error) {
+	// Быстрый путь: блок уже закреплен, его не вытеснят, и число свободных буферов не меняется.
+	// Обращение только отмечается в стратегии замены, эксклюзивных блокировок здесь нет
+	p.mu.RLock()
+
+	if buf := p.pool.FindExistingBuffer(block); buf != nil && buf.pinShared() {
+		p.pool.TouchShared(buf)
+		p.mu.RUnlock()
+		p.stats.hit(block)
+
+		return buf, nil
+	}
+
+	p.mu.RUnlock()
+
+	p.mu.Lock()
+
+	// Пока в очереди кто-то есть, новые запросы не забирают освободившиеся буферы вне очереди.
+	// Без очереди можно закрепить только блок, буфер которого уже закреплен
+	if p.waiters.Len() == 0 || p.pinnedBuffer(block) != nil {
+		buf, err := p.tryToPin(block)
+		if !errors.Is(err, ErrNoAvailableBuffers) {
+			p.mu.Unlock()
+
+			return buf, err
+		}
+	}
+
+	w := p.enqueue()
+
+	p.mu.Unlock()
+
+	p.stats.pinWait()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		select {
+		case <-w.ready:
+			p.mu.Lock()
+
+			buf, err := p.tryToPin(block)
+			if errors.Is(err, ErrNoAvailableBuffers) {
+				// Буфер успели закрепить повторно — ждем дальше, не теряя места в очереди
+				p.mu.Unlock()
+
+				continue
+			}
+
+			p.leaveQueue(w)
+			p.mu.Unlock()
+
+			return buf, err
+		case <-ctx.Done():
+			p.mu.Lock()
+			p.leaveQueue(w)
+			p.mu.Unlock()
+
+			p.stats.pinTimeout()
+
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, ErrNoAvailableBuffers
+			}
+
+			return nil, errors.WithMessage(ErrNoAvailableBuffers, ctx.Err().Error())
+		}
+	}
+}
+
+// unpin уменьшает счетчик закреплений. Если буфер освободился, то будит первого в очереди ожидания буфера
+func (p *partition) unpin(buf *Buffer) {
+	if buf.unpinShared() {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	buf.Unpin()
+
+	if !buf.IsPinned() {
+		p.available++
+		p.wakeFirstWaiter()
+	}
+}
+
+// pinWaiter — запрос в очереди ожидания свободного буфера
+type pinWaiter struct {
+	ready chan struct{}
+	elem  *list.Element
+}
+
+// enqueue ставит запрос в конец очереди. Вызывается под блокировкой части
+func (p *partition) enqueue() *pinWaiter {
+	w := &pinWaiter{
+		ready: make(chan struct{}, 1),
+	}
+	w.elem = p.waiters.PushBack(w)
+
+	if p.available > 0 {
+		p.wakeFirstWaiter()
+	}
+
+	return w
+}
+
+// wakeFirstWaiter будит первый запрос в очереди. Вызывается под блокировкой части
+func (p *partition) wakeFirstWaiter() {
+	if e := p.waiters.Front(); e != nil {
+		w, _ := e.Value.(*pinWaiter)
+
+		select {
+		case w.ready <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// leaveQueue убирает запрос из очереди и передает следующему свободный буфер, если он есть.
+// Вызывается под блокировкой части
+func (p *partition) leaveQueue(w *pinWaiter) {
+	p.waiters.Remove(w.elem)
+
+	if p.available > 0 {
+		p.wakeFirstWaiter()
+	}
+}
+
+// pinnedBuffer возвращает закрепленный буфер блока, если он есть. Вызывается под блокировкой части
+func (p *partition) pinnedBuffer(block types.Block) *Buffer {
+	buf := p.pool.FindExistingBuffer(block)
+	if buf == nil || !buf.IsPinned() {
+		return nil
+	}
+
+	return buf
+}
+
+// tryToPin закрепляет блок, если есть свободный буфер. Вызывается под блокировкой части
+func (p *partition) tryToPin(block types.Block) (*Buffer, error) {
+	buf := p.pool.FindExistingBuffer(block)
+
+	switch {
+	case buf == nil:
+		buf = p.pool.ChooseUnpinnedBuffer()
+		if buf == nil {
+			return nil, ErrNoAvailableBuffers
+		}
+
+		err := p.pool.AssignBufferToBlock(buf, block)
+		if err != nil {
+			return nil, err
+		}
+
+		p.stats.miss(block)
+	case !buf.IsPinned() && buf.loadFailed():
+		// Упреждающее чтение не смогло прочитать страницу — перечитываем ее обычным путем
+		if _, err := buf.assignToBlock(block); err != nil {
+			return nil, err
+		}
+
+		p.stats.miss(block)
+	default:
+		p.stats.hit(block)
+	}
+
+	if !buf.IsPinned() {
+		p.available--
+	}
+
+	buf.Pin()
+	p.pool.Touch(buf)
+
+	return buf, nil
+}
+
+// writeUnpinned пишет буфер на диск, если его никто не закрепил.
+// Держим блокировку части, чтобы буфер не закрепили и не поменяли во время записи
+func (p *partition) writeUnpinned(buf *Buffer) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if buf.IsPinned() {
+		return false, nil
+	}
+
+	written, err := buf.flush()
+	if err != nil {
+		return false, err
+	}
+
+	if written {
+		p.stats.backgroundWrite(buf.Block())
+	}
+
+	return written, nil
+}
@@ -0,0 +1,117 @@
+package buffers
+
+import (
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+// WithReadAhead задает окно упреждающего чтения в блоках. Ноль выключает упреждающее чтение
+func WithReadAhead(blocks int) ManagerOpt {
+	return func(m *Manager) {
+		m.readAhead = max(0, blocks)
+	}
+}
+
+// ReadAheadWindow возвращает окно упреждающего чтения в блоках
+func (bm *Manager) ReadAheadWindow() int {
+	return bm.readAhead
+}
+
+// Prefetch заранее читает в пул до count блоков файла, начиная с first, и не ждет чтения с диска.
+// Пропускает блоки, которые уже есть в пуле, и блоки, для которых нет свободного буфера без изменений,
+// чтобы не вытеснять страницы с записью на диск и не отбирать буферы у ждущих в очереди.
+// Идущие подряд блоки читаются с диска одним чтением
+func (bm *Manager) Prefetch(first types.Block, count int) {
+	size, err := bm.fm.Length(first.Filename)
+	if err != nil {
+		return
+	}
+
+	last := min(first.Number+types.BlockID(count), size)
+
+	var reserved []*Buffer
+
+	for n := first.Number; n < last; n++ {
+		block := types.Block{Filename: first.Filename, Number: n}
+
+		if buf := bm.partitionFor(block).reserve(block); buf != nil {
+			reserved = append(reserved, buf)
+		}
+	}
+
+	if len(reserved) == 0 {
+		return
+	}
+
+	bm.prefetches.Add(1)
+
+	go func() {
+		defer bm.prefetches.Done()
+
+		bm.load(reserved)
+	}()
+}
+
+// load читает страницы зарезервированных буферов. Буферы идут по возрастанию номеров блоков
+func (bm *Manager) load(reserved []*Buffer) {
+	for start := 0; start < len(reserved); {
+		end := start + 1
+		for end < len(reserved) && reserved[end].Block().Number == reserved[end-1].Block().Number+1 {
+			end++
+		}
+
+		run := reserved[start:end]
+
+		pages := make([]*types.Page, len(run))
+		for i, buf := range run {
+			pages[i] = buf.Content()
+		}
+
+		err := bm.fm.ReadBlocks(run[0].Block(), pages)
+
+		for _, buf := range run {
+			p := bm.partitionFor(buf.Block())
+
+			p.loaded(buf, err)
+			p.unpin(buf)
+		}
+
+		start = end
+	}
+}
+
+// reserve связывает свободный буфер без изменений с блоком, которого еще нет в пуле, и закрепляет его до конца чтения
+func (p *partition) reserve(block types.Block) *Buffer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.waiters.Len() > 0 || p.pool.FindExistingBuffer(block) != nil {
+		return nil
+	}
+
+	buf := p.pool.ChooseUnpinnedBuffer()
+	if buf == nil {
+		return nil
+	}
+
+	if txnum, _ := buf.modification(); txnum >= 0 {
+		return nil
+	}
+
+	p.pool.reserveBuffer(buf, block)
+	p.available--
+	buf.Pin()
+
+	return buf
+}
+
+// loaded отмечает, что страница зарезервированного буфера прочитана
+func (p *partition) loaded(buf *Buffer, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	buf.finishLoading(err)
+
+	if err == nil {
+		p.stats.prefetch(buf.Block())
+	}
+}
@@ -0,0 +1,424 @@
+package buffers
+
+import (
+	"container/heap"
+	"container/list"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+// ReplacementPolicy — стратегия выбора буфера для замены.
+// Методы, кроме TouchShared, вызываются под эксклюзивной блокировкой части пула, поэтому им не нужна своя синхронизация
+type ReplacementPolicy interface {
+	// Touch отмечает обращение к буферу. Вызывается после каждого закрепления буфера
+	Touch(buf *Buffer)
+
+	// TouchShared отмечает повторное закрепление уже закрепленного буфера. Вызывается под разделяемой блокировкой
+	// части пула параллельно с другими вызовами TouchShared, поэтому должен быть потокобезопасным и не брать блокировок
+	TouchShared(buf *Buffer)
+
+	// Reset вызывается перед тем, как связать буфер с новым блоком
+	Reset(buf *Buffer)
+
+	// Victim выбирает незакрепленный буфер для замены. Если таких нет, то возвращает nil
+	Victim() *Buffer
+
+	// Resize вызывается после изменения размера пула. Номера буферов в frames уже пересчитаны
+	Resize(frames []*Buffer)
+}
+
+// ReplacementPolicyFactory создает стратегию замены для буферов пула
+type ReplacementPolicyFactory func(frames []*Buffer) ReplacementPolicy
+
+const (
+	ClockPolicyName = "clock"
+	LRUPolicyName   = "lru"
+	LRU2PolicyName  = "lru2"
+
+	DefaultReplacementPolicyName = ClockPolicyName
+)
+
+var replacementPolicies = map[string]ReplacementPolicyFactory{
+	ClockPolicyName: NewClockPolicy,
+	LRUPolicyName:   NewLRUPolicy,
+	LRU2PolicyName:  NewLRU2Policy,
+}
+
+// ReplacementPolicyByName возвращает фабрику стратегии замены по имени: clock, lru или lru2
+func ReplacementPolicyByName(name string) (ReplacementPolicyFactory, error) {
+	factory, ok := replacementPolicies[name]
+	if !ok {
+		return nil, errors.WithMessagef(ErrUnknownReplacementPolicy, "%q", name)
+	}
+
+	return factory, nil
+}
+
+// ClockPolicy — алгоритм часов с битами обращения.
+// Стрелка обходит буферы по кругу, снимает бит обращения и выбирает первый буфер без него
+type ClockPolicy struct {
+	frames     []*Buffer
+	referenced []atomic.Bool
+	hand       int
+}
+
+func NewClockPolicy(frames []*Buffer) ReplacementPolicy {
+	return &ClockPolicy{
+		frames:     frames,
+		referenced: make([]atomic.Bool, len(frames)),
+	}
+}
+
+func (p *ClockPolicy) Touch(buf *Buffer) {
+	p.referenced[buf.frame].Store(true)
+}
+
+func (p *ClockPolicy) TouchShared(buf *Buffer) {
+	p.referenced[buf.frame].Store(true)
+}
+
+func (p *ClockPolicy) Reset(buf *Buffer) {
+	p.referenced[buf.frame].Store(false)
+}
+
+func (p *ClockPolicy) Victim() *Buffer {
+	// За первый круг снимаем биты обращения, за второй — гарантированно находим буфер, если он есть
+	for i := 0; i < 2*len(p.frames); i++ {
+		buf := p.frames[p.hand]
+		p.hand = (p.hand + 1) % len(p.frames)
+
+		if buf.IsPinned() {
+			continue
+		}
+
+		if p.referenced[buf.frame].Swap(false) {
+			continue
+		}
+
+		return buf
+	}
+
+	return nil
+}
+
+func (p *ClockPolicy) Resize(frames []*Buffer) {
+	// Биты обращения лежат по старым номерам буферов, поэтому переносим их до замены списка
+	referenced := make([]atomic.Bool, len(frames))
+	for i, buf := range p.frames {
+		if p.referenced[i].Load() && inFrames(frames, buf) {
+			referenced[buf.frame].Store(true)
+		}
+	}
+
+	p.frames = frames
+	p.referenced = referenced
+	p.hand = 0
+}
+
+// LRUPolicy вытесняет буфер, к которому дольше всего не обращались.
+// TouchShared не двигает очередь, а только отмечает обращение. Отмеченный буфер переносится в начало очереди,
+// когда до него дойдет выбор жертвы, поэтому среди таких обращений порядок приблизительный
+type LRUPolicy struct {
+	order    *list.List
+	elements []*list.Element
+	touched  []atomic.Bool
+}
+
+func NewLRUPolicy(frames []*Buffer) ReplacementPolicy {
+	p := &LRUPolicy{
+		order:    list.New(),
+		elements: make([]*list.Element, len(frames)),
+		touched:  make([]atomic.Bool, len(frames)),
+	}
+
+	for _, buf := range frames {
+		p.elements[buf.frame] = p.order.PushBack(buf)
+	}
+
+	return p
+}
+
+func (p *LRUPolicy) Touch(buf *Buffer) {
+	p.touched[buf.frame].Store(false)
+	p.order.MoveToFront(p.elements[buf.frame])
+}
+
+func (p *LRUPolicy) TouchShared(buf *Buffer) {
+	p.touched[buf.frame].Store(true)
+}
+
+func (p *LRUPolicy) Reset(buf *Buffer) {
+	p.touched[buf.frame].Store(false)
+	p.order.MoveToBack(p.elements[buf.frame])
+}
+
+func (p *LRUPolicy) Victim() *Buffer {
+	// Каждый отмеченный буфер переносится в начало не больше одного раза, поэтому обход конечен
+	for e := p.order.Back(); e != nil; {
+		prev := e.Prev()
+
+		buf, _ := e.Value.(*Buffer)
+
+		switch {
+		case p.touched[buf.frame].Swap(false):
+			p.order.MoveToFront(e)
+
+			// Буфер был первым в очереди и остался им, проверяем его еще раз
+			if prev == nil {
+				prev = e
+			}
+		case !buf.IsPinned():
+			return buf
+		}
+
+		e = prev
+	}
+
+	return nil
+}
+
+func (p *LRUPolicy) Resize(frames []*Buffer) {
+	// Отмеченные обращения лежат по старым номерам буферов, поэтому учитываем их до пересчета
+	for i, e := range p.elements {
+		if p.touched[i].Load() {
+			p.order.MoveToFront(e)
+		}
+	}
+
+	elements := make([]*list.Element, len(frames))
+
+	// Оставшиеся буферы сохраняют свое место в очереди, новые встают в ее конец
+	for e := p.order.Front(); e != nil; {
+		next := e.Next()
+
+		buf, _ := e.Value.(*Buffer)
+		if inFrames(frames, buf) {
+			elements[buf.frame] = e
+		} else {
+			p.order.Remove(e)
+		}
+
+		e = next
+	}
+
+	for _, buf := range frames {
+		if elements[buf.frame] == nil {
+			elements[buf.frame] = p.order.PushBack(buf)
+		}
+	}
+
+	p.elements = elements
+	p.touched = make([]atomic.Bool, len(frames))
+}
+
+// LRU2Policy — LRU-K при K = 2.
+// Вытесняет буфер с самым давним предпоследним обращением. Буферы, к которым обращались один раз,
+// вытесняются первыми, поэтому однократный проход по большой таблице не вымывает из пула горячие страницы.
+// Историю обращений храним по блокам, а не по буферам, и помним её какое-то время после вытеснения блока,
+// иначе при нехватке буферов горячий блок не успевает набрать два обращения.
+// Повторные закрепления уже закрепленного буфера считаем одним обращением: они только сдвигают последнее обращение.
+// Буферы лежат в куче по предпоследнему, а затем по последнему обращению. TouchShared только отмечает буфер,
+// а обращение учитывается, когда буфер окажется на вершине кучи: ключ от него только растет,
+// поэтому отложенный учет не меняет выбор жертвы
+type LRU2Policy struct {
+	frames    []*Buffer
+	queue     lru2Queue
+	entries   []*lru2Entry // Элементы кучи по номерам буферов
+	touched   []atomic.Bool
+	history   map[types.Block]*lru2History
+	counter   uint64
+	retention uint64
+}
+
+type lru2History struct {
+	last   uint64
+	penult uint64
+}
+
+type lru2Entry struct {
+	buf   *Buffer
+	key   lru2History
+	index int
+}
+
+// lru2RetentionFactor — сколько обращений в пересчете на один буфер помним историю блока
+const lru2RetentionFactor = 4
+
+func NewLRU2Policy(frames []*Buffer) ReplacementPolicy {
+	p := &LRU2Policy{
+		history: make(map[types.Block]*lru2History, len(frames)),
+	}
+
+	p.Resize(frames)
+
+	return p
+}
+
+func (p *LRU2Policy) Touch(buf *Buffer) {
+	p.touched[buf.frame].Store(false)
+	p.touch(buf, buf.Pins() <= 1)
+}
+
+func (p *LRU2Policy) TouchShared(buf *Buffer) {
+	p.touched[buf.frame].Store(true)
+}
+
+// Reset — у нового блока буфера еще не было обращений, пока его не закрепят
+func (p *LRU2Policy) Reset(buf *Buffer) {
+	p.touched[buf.frame].Store(false)
+
+	entry := p.entries[buf.frame]
+	entry.key = lru2History{}
+	heap.Fix(&p.queue, entry.index)
+}
+
+func (p *LRU2Policy) Victim() *Buffer {
+	var (
+		victim *Buffer
+		pinned []*lru2Entry
+	)
+
+	// Закрепленные буферы на время поиска вынимаем из кучи
+	for p.queue.Len() > 0 {
+		entry := p.queue[0]
+
+		if p.touched[entry.buf.frame].Swap(false) {
+			p.touch(entry.buf, false)
+
+			continue
+		}
+
+		if entry.buf.IsPinned() {
+			pinned = append(pinned, heap.Pop(&p.queue).(*lru2Entry)) //nolint:forcetypeassert
+
+			continue
+		}
+
+		victim = entry.buf
+
+		break
+	}
+
+	for _, entry := range pinned {
+		heap.Push(&p.queue, entry)
+	}
+
+	return victim
+}
+
+func (p *LRU2Policy) Resize(frames []*Buffer) {
+	p.frames = frames
+	p.retention = uint64(lru2RetentionFactor * len(frames))
+
+	entries := make([]*lru2Entry, len(frames))
+
+	// Отмеченные обращения лежат по старым номерам буферов, поэтому учитываем их до пересчета
+	for i, entry := range p.entries {
+		if p.touched[i].Load() {
+			entry.key = p.note(entry.buf, false)
+		}
+
+		if inFrames(frames, entry.buf) {
+			entries[entry.buf.frame] = entry
+		}
+	}
+
+	p.queue = make(lru2Queue, 0, len(frames))
+
+	for i, buf := range frames {
+		if entries[i] == nil {
+			entries[i] = &lru2Entry{buf: buf}
+		}
+
+		p.queue = append(p.queue, entries[i])
+	}
+
+	heap.Init(&p.queue)
+
+	p.entries = entries
+	p.touched = make([]atomic.Bool, len(frames))
+}
+
+// touch учитывает обращение к буферу и поднимает его в куче. first — первое закрепление буфера, а не повторное
+func (p *LRU2Policy) touch(buf *Buffer, first bool) {
+	entry := p.entries[buf.frame]
+	entry.key = p.note(buf, first)
+	heap.Fix(&p.queue, entry.index)
+
+	if uint64(len(p.history)) > 2*p.retention {
+		p.forget()
+	}
+}
+
+// note записывает обращение к блоку буфера в историю и возвращает ее
+func (p *LRU2Policy) note(buf *Buffer, first bool) lru2History {
+	p.counter++
+
+	h, ok := p.history[buf.Block()]
+	if !ok {
+		h = new(lru2History)
+		p.history[buf.Block()] = h
+	}
+
+	if first {
+		h.penult = h.last
+	}
+
+	h.last = p.counter
+
+	return *h
+}
+
+// forget удаляет историю вытесненных блоков, к которым давно не обращались
+func (p *LRU2Policy) forget() {
+	resident := make(map[types.Block]struct{}, len(p.frames))
+	for _, buf := range p.frames {
+		resident[buf.Block()] = struct{}{}
+	}
+
+	for block, h := range p.history {
+		if _, ok := resident[block]; !ok && p.counter-h.last > p.retention {
+			delete(p.history, block)
+		}
+	}
+}
+
+// lru2Queue — куча буферов, на вершине — буфер с самым давним предпоследним обращением
+type lru2Queue []*lru2Entry
+
+func (q lru2Queue) Len() int {
+	return len(q)
+}
+
+func (q lru2Queue) Less(i, j int) bool {
+	a, b := q[i].key, q[j].key
+
+	return a.penult < b.penult || (a.penult == b.penult && a.last < b.last)
+}
+
+func (q lru2Queue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *lru2Queue) Push(x any) {
+	entry, _ := x.(*lru2Entry)
+	entry.index = len(*q)
+	*q = append(*q, entry)
+}
+
+func (q *lru2Queue) Pop() any {
+	old := *q
+	entry := old[len(old)-1]
+	*q = old[:len(old)-1]
+
+	return entry
+}
+
+// inFrames проверяет, что буфер остался в пуле после изменения его размера
+func inFrames(frames []*Buffer, buf *Buffer) bool {
+	return buf.frame >= 0 && buf.frame < len(frames) && frames[buf.frame] == buf
+}
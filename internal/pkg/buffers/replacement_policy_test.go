@@ -0,0 +1,280 @@
+package buffers_test
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/buffers"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/storage"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/wal"
+)
+
+const (
+	testPolicyBlockSize = 400
+	testPolicyFile      = "policy_test.dat"
+)
+
+func newPolicyTestPool(tb testing.TB, bLen int, blocks int, newPolicy buffers.ReplacementPolicyFactory) (*buffers.BuffersPool, func()) {
+	tb.Helper()
+
+	path := tb.TempDir()
+
+	fm, err := storage.NewFileManager(path, testPolicyBlockSize)
+	require.NoError(tb, err)
+
+	require.NoError(tb, os.WriteFile(filepath.Join(path, testPolicyFile), make([]byte, blocks*testPolicyBlockSize), 0o600))
+
+	lm, err := wal.NewManager(fm, "wal_log.dat")
+	require.NoError(tb, err)
+
+	bp := buffers.NewBuffersPool(bLen, func() *buffers.Buffer {
+		return buffers.NewBuffer(fm, lm)
+	}, buffers.WithPoolReplacementPolicy(newPolicy))
+
+	return bp, func() {
+		require.NoError(tb, fm.Close())
+	}
+}
+
+// access закрепляет и сразу открепляет блок так же, как это делает менеджер буферов. Возвращает true при попадании в пул
+func access(tb testing.TB, bp *buffers.BuffersPool, n types.BlockID) bool {
+	tb.Helper()
+
+	block := types.Block{Filename: testPolicyFile, Number: n}
+
+	hit := true
+
+	buf := bp.FindExistingBuffer(block)
+	if buf == nil {
+		hit = false
+
+		buf = bp.ChooseUnpinnedBuffer()
+		require.NotNil(tb, buf)
+		require.NoError(tb, bp.AssignBufferToBlock(buf, block))
+	}
+
+	buf.Pin()
+	bp.Touch(buf)
+	buf.Unpin()
+
+	return hit
+}
+
+func poolBlocks(bp *buffers.BuffersPool) map[types.BlockID]bool {
+	blocks := make(map[types.BlockID]bool)
+
+	for _, buf := range bp.Buffers() {
+		if buf.Block().Filename != "" {
+			blocks[buf.Block().Number] = true
+		}
+	}
+
+	return blocks
+}
+
+func TestReplacementPolicyByName(t *testing.T) {
+	for _, name := range []string{buffers.ClockPolicyName, buffers.LRUPolicyName, buffers.LRU2PolicyName} {
+		factory, err := buffers.ReplacementPolicyByName(name)
+		require.NoError(t, err)
+		require.NotNil(t, factory)
+	}
+
+	_, err := buffers.ReplacementPolicyByName("mru")
+	require.ErrorIs(t, err, buffers.ErrUnknownReplacementPolicy)
+}
+
+func TestClockPolicy(t *testing.T) {
+	bp, clean := newPolicyTestPool(t, 3, 10, buffers.NewClockPolicy)
+	defer clean()
+
+	for _, n := range []types.BlockID{0, 1, 2} {
+		access(t, bp, n)
+	}
+
+	// Стрелка снимает биты обращения со всех буферов и вытесняет блок 0
+	access(t, bp, 3)
+	require.Equal(t, map[types.BlockID]bool{1: true, 2: true, 3: true}, poolBlocks(bp))
+
+	// У блока 1 снова есть бит обращения, поэтому вытесняется блок 2
+	require.True(t, access(t, bp, 1))
+	access(t, bp, 4)
+	require.Equal(t, map[types.BlockID]bool{1: true, 3: true, 4: true}, poolBlocks(bp))
+}
+
+func TestLRUPolicy(t *testing.T) {
+	bp, clean := newPolicyTestPool(t, 3, 10, buffers.NewLRUPolicy)
+	defer clean()
+
+	for _, n := range []types.BlockID{0, 1, 2, 0} {
+		access(t, bp, n)
+	}
+
+	access(t, bp, 3)
+	require.Equal(t, map[types.BlockID]bool{0: true, 2: true, 3: true}, poolBlocks(bp))
+
+	access(t, bp, 4)
+	require.Equal(t, map[types.BlockID]bool{0: true, 3: true, 4: true}, poolBlocks(bp))
+}
+
+func TestLRU2Policy_ScanResistance(t *testing.T) {
+	bp, clean := newPolicyTestPool(t, 4, 100, buffers.NewLRU2Policy)
+	defer clean()
+
+	for _, n := range []types.BlockID{0, 1, 0, 1} {
+		access(t, bp, n)
+	}
+
+	// Однократный проход по «таблице» не вытесняет горячие блоки 0 и 1
+	for n := types.BlockID(10); n < 50; n++ {
+		require.False(t, access(t, bp, n))
+	}
+
+	require.True(t, access(t, bp, 0))
+	require.True(t, access(t, bp, 1))
+}
+
+func TestReplacementPolicies_SkipPinned(t *testing.T) {
+	for name, newPolicy := range map[string]buffers.ReplacementPolicyFactory{
+		buffers.ClockPolicyName: buffers.NewClockPolicy,
+		buffers.LRUPolicyName:   buffers.NewLRUPolicy,
+		buffers.LRU2PolicyName:  buffers.NewLRU2Policy,
+	} {
+		bp, clean := newPolicyTestPool(t, 3, 10, newPolicy)
+
+		for _, buf := range bp.Buffers() {
+			buf.Pin()
+		}
+
+		require.Nil(t, bp.ChooseUnpinnedBuffer(), name)
+
+		free := bp.Buffers()[1]
+		free.Unpin()
+
+		require.Same(t, free, bp.ChooseUnpinnedBuffer(), name)
+
+		clean()
+	}
+}
+
+func TestReplacementPolicies_TouchShared(t *testing.T) {
+	for name, newPolicy := range map[string]buffers.ReplacementPolicyFactory{
+		buffers.ClockPolicyName: buffers.NewClockPolicy,
+		buffers.LRUPolicyName:   buffers.NewLRUPolicy,
+		buffers.LRU2PolicyName:  buffers.NewLRU2Policy,
+	} {
+		bp, clean := newPolicyTestPool(t, 3, 10, newPolicy)
+
+		// Блок 0 вытеснен, следующим стратегия выбрала бы блок 1
+		for _, n := range []types.BlockID{0, 1, 2, 3} {
+			access(t, bp, n)
+		}
+
+		buf := bp.FindExistingBuffer(types.Block{Filename: testPolicyFile, Number: 1})
+		require.NotNil(t, buf, name)
+
+		// Повторные закрепления отмечаются параллельно и без блокировки пула
+		var wg sync.WaitGroup
+
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				buf.Pin()
+				bp.TouchShared(buf)
+				buf.Unpin()
+			}()
+		}
+
+		wg.Wait()
+
+		access(t, bp, 4)
+		require.Equal(t, map[types.BlockID]bool{1: true, 3: true, 4: true}, poolBlocks(bp), name)
+
+		clean()
+	}
+}
+
+// BenchmarkReplacementPolicies сравнивает долю попаданий в пул при смешанной нагрузке:
+// точечные чтения горячих блоков вперемешку с последовательным чтением большой таблицы
+func BenchmarkReplacementPolicies(b *testing.B) {
+	const (
+		poolLen    = 64
+		hotBlocks  = 48
+		coldBlocks = 1024
+		scanBatch  = 64
+	)
+
+	for _, name := range []string{buffers.ClockPolicyName, buffers.LRUPolicyName, buffers.LRU2PolicyName} {
+		b.Run(name, func(b *testing.B) {
+			newPolicy, err := buffers.ReplacementPolicyByName(name)
+			require.NoError(b, err)
+
+			bp, clean := newPolicyTestPool(b, poolLen, hotBlocks+coldBlocks, newPolicy)
+			defer clean()
+
+			var hits, total int
+
+			cold := 0
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				for n := 0; n < hotBlocks; n++ {
+					if access(b, bp, types.BlockID(n)) {
+						hits++
+					}
+				}
+
+				for n := 0; n < scanBatch; n++ {
+					if access(b, bp, types.BlockID(hotBlocks+cold)) {
+						hits++
+					}
+
+					cold = (cold + 1) % coldBlocks
+				}
+
+				total += hotBlocks + scanBatch
+			}
+
+			b.ReportMetric(float64(hits)/float64(total)*100, "hit%") //nolint:mnd
+		})
+	}
+}
+
+func TestReplacementPolicies_Resize(t *testing.T) {
+	for name, newPolicy := range map[string]buffers.ReplacementPolicyFactory{
+		buffers.ClockPolicyName: buffers.NewClockPolicy,
+		buffers.LRUPolicyName:   buffers.NewLRUPolicy,
+		buffers.LRU2PolicyName:  buffers.NewLRU2Policy,
+	} {
+		bp, clean := newPolicyTestPool(t, 3, 10, newPolicy)
+
+		for _, n := range []types.BlockID{0, 1, 2} {
+			access(t, bp, n)
+		}
+
+		removed, err := bp.RemoveUnpinnedBuffers(2)
+		require.NoError(t, err, name)
+		require.Equal(t, 2, removed, name)
+		require.Equal(t, 1, bp.Len(), name)
+		require.Len(t, poolBlocks(bp), 1, name)
+
+		bp.AddBuffers(3)
+		require.Equal(t, 4, bp.Len(), name)
+
+		// После изменения размера стратегия выбирает только буферы, оставшиеся в пуле
+		for n := types.BlockID(3); n < 10; n++ {
+			access(t, bp, n)
+		}
+
+		require.Len(t, poolBlocks(bp), 4, name)
+
+		clean()
+	}
+}
@@ -0,0 +1,104 @@
+package buffers
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Resize меняет число буферов в пуле
+func (bm *Manager) Resize(n int) error {
+	return bm.ResizeContext(context.Background(), n)
+}
+
+// ResizeContext меняет число буферов в пуле. Буферы делятся между частями пула поровну,
+// поэтому в пуле должно остаться не меньше буферов, чем частей.
+// Новые буферы сразу достаются ждущим в очереди.
+// При уменьшении пула незакрепленные буферы вытесняются сразу, а закрепленные — по мере освобождения:
+// менеджер встает в очередь ожидания буферов и забирает освободившиеся буферы раньше тех, кто пришел после него.
+// Ожидание прерывается только отменой ctx, уже убранные буферы в пул при этом не возвращаются
+func (bm *Manager) ResizeContext(ctx context.Context, n int) error {
+	if n < len(bm.partitions) {
+		return errors.WithMessagef(ErrBadPoolLen, "%d, partitions %d", n, len(bm.partitions))
+	}
+
+	for i, p := range bm.partitions {
+		if err := p.resize(ctx, partitionLen(n, len(bm.partitions), i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// partitionLen возвращает число буферов в i-й из count частей пула длиной pLen
+func partitionLen(pLen int, count int, i int) int {
+	n := pLen / count
+	if i < pLen%count {
+		n++
+	}
+
+	return n
+}
+
+func (p *partition) resize(ctx context.Context, n int) error {
+	p.mu.Lock()
+
+	poolLen := p.pool.Len()
+
+	if n >= poolLen {
+		p.pool.AddBuffers(n - poolLen)
+		p.available += n - poolLen
+
+		if p.available > 0 {
+			p.wakeFirstWaiter()
+		}
+
+		p.mu.Unlock()
+
+		return nil
+	}
+
+	remaining, err := p.shrink(poolLen - n)
+	if err != nil || remaining == 0 {
+		p.mu.Unlock()
+
+		return err
+	}
+
+	w := p.enqueue()
+
+	p.mu.Unlock()
+
+	for {
+		select {
+		case <-w.ready:
+			p.mu.Lock()
+
+			remaining, err = p.shrink(remaining)
+			if err != nil || remaining == 0 {
+				p.leaveQueue(w)
+				p.mu.Unlock()
+
+				return err
+			}
+
+			p.mu.Unlock()
+		case <-ctx.Done():
+			p.mu.Lock()
+			p.leaveQueue(w)
+			p.mu.Unlock()
+
+			return errors.WithMessage(ErrResizeInterrupted, ctx.Err().Error())
+		}
+	}
+}
+
+// shrink убирает из пула до n незакрепленных буферов и возвращает, сколько еще осталось убрать.
+// Вызывается под блокировкой части
+func (p *partition) shrink(n int) (int, error) {
+	removed, err := p.pool.RemoveUnpinnedBuffers(n)
+	p.available -= removed
+
+	return n - removed, err
+}
@@ -0,0 +1,134 @@
+package buffers
+
+import (
+	"sync"
+
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+// Counters — счетчики обращений к пулу буферов
+type Counters struct {
+	Hits        int64 // Блок нашелся в пуле
+	Misses      int64 // Блок пришлось читать с диска
+	Evictions   int64 // Блок вытеснили из пула, чтобы освободить буфер
+	DirtyWrites int64 // Измененный блок синхронно записали на диск при вытеснении или коммите
+
+	BackgroundWrites int64 // Измененный блок записали на диск в фоне
+	Prefetches       int64 // Блок заранее прочитали в пул упреждающим чтением
+}
+
+// HitRatio возвращает долю попаданий в пул
+func (c Counters) HitRatio() float64 {
+	if c.Hits+c.Misses == 0 {
+		return 0
+	}
+
+	return float64(c.Hits) / float64(c.Hits+c.Misses)
+}
+
+func (c *Counters) add(o Counters) {
+	c.Hits += o.Hits
+	c.Misses += o.Misses
+	c.Evictions += o.Evictions
+	c.DirtyWrites += o.DirtyWrites
+	c.BackgroundWrites += o.BackgroundWrites
+	c.Prefetches += o.Prefetches
+}
+
+// Stats — статистика пула буферов
+type Stats struct {
+	Counters
+
+	PinWaits    int64 // Закрепление ждало освобождения буфера
+	PinTimeouts int64 // Закрепление не дождалось буфера
+
+	DirtyPages int // Буферы с изменениями, которые еще не записаны на диск
+
+	Files map[string]Counters // Счетчики в разрезе файлов
+}
+
+// FrameInfo — состояние буфера в пуле
+type FrameInfo struct {
+	Frame       int
+	Block       types.Block // Пустое имя файла у буфера, который еще не связан с блоком
+	Pins        int
+	ModifyingTX types.TRX // -1, если в буфере нет несохраненных изменений
+	LSN         types.LSN
+}
+
+type statsCollector struct {
+	mu sync.Mutex
+
+	stats Stats
+}
+
+func newStatsCollector() *statsCollector {
+	return &statsCollector{
+		stats: Stats{
+			Files: make(map[string]Counters),
+		},
+	}
+}
+
+func (c *statsCollector) count(filename string, delta Counters) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.add(delta)
+
+	fc := c.stats.Files[filename]
+	fc.add(delta)
+	c.stats.Files[filename] = fc
+}
+
+func (c *statsCollector) hit(block types.Block) {
+	c.count(block.Filename, Counters{Hits: 1})
+}
+
+func (c *statsCollector) miss(block types.Block) {
+	c.count(block.Filename, Counters{Misses: 1})
+}
+
+func (c *statsCollector) eviction(block types.Block) {
+	c.count(block.Filename, Counters{Evictions: 1})
+}
+
+func (c *statsCollector) dirtyWrite(block types.Block) {
+	c.count(block.Filename, Counters{DirtyWrites: 1})
+}
+
+func (c *statsCollector) backgroundWrite(block types.Block) {
+	c.count(block.Filename, Counters{BackgroundWrites: 1})
+}
+
+func (c *statsCollector) prefetch(block types.Block) {
+	c.count(block.Filename, Counters{Prefetches: 1})
+}
+
+func (c *statsCollector) pinWait() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.PinWaits++
+}
+
+func (c *statsCollector) pinTimeout() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.PinTimeouts++
+}
+
+func (c *statsCollector) snapshot() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.stats
+	s.Files = make(map[string]Counters, len(c.stats.Files))
+
+	for name, fc := range c.stats.Files {
+		s.Files[name] = fc
+	}
+
+	return s
+}
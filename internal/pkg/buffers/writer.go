@@ -0,0 +1,91 @@
+package buffers
+
+import (
+	"time"
+)
+
+// backgroundWriter понемногу пишет на диск измененные незакрепленные буферы,
+// чтобы при вытеснении буфера не приходилось синхронно записывать страницу
+type backgroundWriter struct {
+	delay    time.Duration
+	maxPages int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// WithBackgroundWriter включает фоновую запись: раз в delay пишет на диск до maxPages измененных незакрепленных буферов.
+// Запись журнала до LSN страницы по-прежнему идет перед записью самой страницы
+func WithBackgroundWriter(delay time.Duration, maxPages int) ManagerOpt {
+	return func(m *Manager) {
+		if delay <= 0 || maxPages <= 0 {
+			m.writer = nil
+
+			return
+		}
+
+		m.writer = &backgroundWriter{
+			delay:    delay,
+			maxPages: maxPages,
+			stop:     make(chan struct{}),
+			done:     make(chan struct{}),
+		}
+	}
+}
+
+func (bm *Manager) runBackgroundWriter() {
+	defer close(bm.writer.done)
+
+	ticker := time.NewTicker(bm.writer.delay)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-bm.writer.stop:
+			return
+		case <-ticker.C:
+			// Ошибку записи не считаем фатальной: страница останется измененной и запишется при вытеснении или коммите
+			_, _ = bm.WriteDirtyPages(bm.writer.maxPages)
+		}
+	}
+}
+
+// WriteDirtyPages записывает на диск до limit измененных незакрепленных буферов и возвращает число записанных страниц
+func (bm *Manager) WriteDirtyPages(limit int) (int, error) {
+	written := 0
+
+	for _, p := range bm.partitions {
+		for _, buf := range p.pool.DirtyBuffers() {
+			if written >= limit {
+				return written, nil
+			}
+
+			ok, err := p.writeUnpinned(buf)
+			if err != nil {
+				return written, err
+			}
+
+			if ok {
+				written++
+			}
+		}
+	}
+
+	return written, nil
+}
+
+// Close останавливает фоновую запись, дожидается упреждающего чтения и освобождает страницы отображенных в память файлов
+func (bm *Manager) Close() {
+	bm.closeOnce.Do(func() {
+		if bm.writer != nil {
+			close(bm.writer.stop)
+			<-bm.writer.done
+		}
+	})
+
+	bm.prefetches.Wait()
+
+	for _, p := range bm.partitions {
+		p.dropViews()
+	}
+}
@@ -3,22 +3,73 @@ package indexes
 import (
 	"math"
 
+	"github.com/pkg/errors"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/concurrency"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
 )
 
+const (
+	btreeLeafSuffix = "_leaf"
+	btreeDirSuffix  = "_dir"
+	// btreeRootBlock — корень дерева всегда лежит в первом блоке каталога: при разделении корня
+	// его записи переезжают в новые блоки
+	btreeRootBlock types.BlockID = 0
+)
+
+// BTreeIndex — B+-дерево. Листья связаны в список по возрастанию значений, страницы каталога одного уровня — тоже.
+// Страницы делятся пополам и никогда не сливаются, поэтому записи при разделении уезжают только вправо по списку
+// и поиск, который прочитал устаревший каталог, находит их, двигаясь по листьям вправо.
+//
+// Страницы читаются под короткими блокировками (indexTRX), а от фантомов защищают блокировки ключей:
+//   - чтение блокирует каждое прочитанное значение, а на уровне SERIALIZABLE и следующее значение после диапазона
+//     или SupremumKey, если диапазон дошел до конца индекса;
+//   - вставка блокирует новое значение до конца транзакции и на время вставки — следующее за ним значение,
+//     поэтому ждет транзакции, которые прочитали промежуток, куда попадает новое значение;
+//   - удаление блокирует удаляемое значение.
+//
+// Вставки, которые делят страницы, выполняются по одной: их упорядочивает блокировка конца файла листьев
 type BTreeIndex struct {
 	*BaseIndex
+
+	trx      scan.TRXInt
+	pages    btreePages
+	leafFile string
+	dirFile  string
+
+	low    scan.Constant
+	high   scan.Constant
+	start  types.Block
+	cursor *btreeCursor
+	done   bool
+}
+
+// btreeCursor — последняя запись, которую вернул поиск, и лист, в котором ее нашли
+type btreeCursor struct {
+	block types.Block
+	entry btreeEntry
 }
 
 func NewBTreeIndex(tx scan.TRXInt, idxName string, idxLayout records.Layout) (*BTreeIndex, error) {
+	trx := indexTRX{TRXInt: tx}
+
+	pages, err := newBTreePages(trx, idxLayout)
+	if err != nil {
+		return nil, err
+	}
+
 	return &BTreeIndex{
 		BaseIndex: &BaseIndex{
 			idxType:   BTreeIndexType,
 			idxName:   idxName,
 			idxLayout: idxLayout,
 		},
+		trx:      trx,
+		pages:    pages,
+		leafFile: idxName + btreeLeafSuffix,
+		dirFile:  idxName + btreeDirSuffix,
+		done:     true,
 	}, nil
 }
 
@@ -31,29 +82,550 @@ func BTreeIndexSearchCost(blocks int64, recordsPerBlock int64) int64 {
 }
 
 func (i *BTreeIndex) Close() {
-	panic("not implemented") // TODO: Implement
+	i.cursor = nil
+	i.done = true
 }
 
 func (i *BTreeIndex) SearchCost(blocks int64, recordsPerBlock int64) int64 {
-	panic("not implemented") // TODO: Implement
+	return BTreeIndexSearchCost(blocks, recordsPerBlock)
 }
 
 func (i *BTreeIndex) BeforeFirst(searchKey scan.Constant) error {
-	panic("not implemented") // TODO: Implement
+	return i.BeforeRange(searchKey, searchKey)
+}
+
+// BeforeRange начинает поиск значений от low до high включительно. nil — граница не задана
+func (i *BTreeIndex) BeforeRange(low scan.Constant, high scan.Constant) error {
+	i.Close()
+
+	i.low, i.high = low, high
+
+	ok, err := i.initialized()
+	if err != nil {
+		return errors.WithMessage(ErrFailedToScanIndex, err.Error())
+	}
+
+	if !ok && i.trx.IsolationLevel() == concurrency.Serializable {
+		// В пустой индекс первое значение вставит транзакция, которая сначала заблокирует SupremumKey
+		if err = i.trx.SLockKey(concurrency.SupremumResource(i.Name())); err != nil {
+			return errors.WithMessage(ErrFailedToScanIndex, err.Error())
+		}
+
+		if ok, err = i.initialized(); err != nil {
+			return errors.WithMessage(ErrFailedToScanIndex, err.Error())
+		}
+	}
+
+	if !ok {
+		return nil
+	}
+
+	if i.start, err = i.descend(low, btreeLeafLevel); err != nil {
+		return errors.WithMessage(ErrFailedToScanIndex, err.Error())
+	}
+
+	i.done = false
+
+	return nil
 }
 
 func (i *BTreeIndex) Next() (bool, error) {
-	panic("not implemented") // TODO: Implement
+	for !i.done {
+		block, entry, ok, err := i.nextEntry()
+		if err != nil {
+			return false, errors.WithMessage(ErrFailedToScanIndex, err.Error())
+		}
+
+		inRange := ok && (i.high == nil || entry.val.CompareTo(i.high) != scan.CompGreat)
+
+		key := concurrency.SupremumResource(i.Name())
+		if ok {
+			key = keyResource(i.Name(), entry.val)
+		}
+
+		// За диапазоном блокируем следующее значение, чтобы в промежуток до него не вставили новые
+		if inRange && locksReadKeys(i.trx) || !inRange && i.trx.IsolationLevel() == concurrency.Serializable {
+			if err = i.trx.SLockKey(key); err != nil {
+				return false, errors.WithMessage(ErrFailedToScanIndex, err.Error())
+			}
+
+			// Пока транзакция ждала блокировку, другая могла вставить значение перед заблокированным
+			nextBlock, nextEntry, nextOk, err := i.nextEntry()
+			if err != nil {
+				return false, errors.WithMessage(ErrFailedToScanIndex, err.Error())
+			}
+
+			if nextOk != ok || ok && !nextEntry.equal(entry) {
+				continue
+			}
+
+			block = nextBlock
+		}
+
+		if !inRange {
+			i.done = true
+
+			break
+		}
+
+		i.cursor = &btreeCursor{
+			block: block,
+			entry: entry,
+		}
+
+		return true, nil
+	}
+
+	return false, nil
 }
 
 func (i *BTreeIndex) RID() types.RID {
-	panic("not implemented") // TODO: Implement
+	if i.cursor == nil {
+		return types.RID{}
+	}
+
+	return i.cursor.entry.rid()
 }
 
 func (i *BTreeIndex) Insert(value scan.Constant, rid types.RID) error {
-	panic("not implemented") // TODO: Implement
+	if err := i.insert(btreeEntry{val: value, block: int64(rid.BlockNumber), slot: int64(rid.Slot)}); err != nil {
+		return errors.WithMessage(ErrFailedToScanIndex, err.Error())
+	}
+
+	return nil
 }
 
 func (i *BTreeIndex) Delete(value scan.Constant, rid types.RID) error {
-	panic("not implemented") // TODO: Implement
+	if err := i.delete(btreeEntry{val: value, block: int64(rid.BlockNumber), slot: int64(rid.Slot)}); err != nil {
+		return errors.WithMessage(ErrFailedToScanIndex, err.Error())
+	}
+
+	return nil
+}
+
+// nextEntry ищет запись после курсора, а до первого вызова Next — первую запись не меньше low.
+// Возвращает лист, в котором нашлась запись, и false, если записей дальше нет
+func (i *BTreeIndex) nextEntry() (types.Block, btreeEntry, bool, error) {
+	block := i.start
+	if i.cursor != nil {
+		block = i.cursor.block
+	}
+
+	positioned := false
+
+	for {
+		node, err := i.pages.read(block)
+		if err != nil {
+			return block, btreeEntry{}, false, err
+		}
+
+		pos := 0
+
+		if !positioned {
+			pos, positioned = i.position(node)
+		}
+
+		if positioned && pos < len(node.entries) {
+			return block, node.entries[pos], true, nil
+		}
+
+		if node.next == btreeNoBlock {
+			return block, btreeEntry{}, false, nil
+		}
+
+		block = types.Block{Filename: i.leafFile, Number: types.BlockID(node.next)}
+	}
+}
+
+// position ищет в листе место, с которого продолжается поиск. false — в листе такого места нет, надо идти дальше
+func (i *BTreeIndex) position(node *btreeNode) (int, bool) {
+	if i.cursor == nil {
+		for j, entry := range node.entries {
+			if i.low == nil || entry.val.CompareTo(i.low) != scan.CompLess {
+				return j, true
+			}
+		}
+
+		return 0, false
+	}
+
+	for j, entry := range node.entries {
+		if entry.equal(i.cursor.entry) {
+			return j + 1, true
+		}
+	}
+
+	// Запись курсора удалили, продолжаем с больших значений
+	for j, entry := range node.entries {
+		if entry.val.CompareTo(i.cursor.entry.val) == scan.CompGreat {
+			return j, true
+		}
+	}
+
+	return 0, false
+}
+
+// initialized проверяет, что дерево уже создано: его создает первая вставка. Если транзакцию, которая создавала
+// дерево, откатили, блоки в файлах остаются, а корень — пустым
+func (i *BTreeIndex) initialized() (bool, error) {
+	size, err := i.trx.Size(i.dirFile)
+	if err != nil || size == 0 {
+		return false, err
+	}
+
+	root, err := i.pages.read(types.Block{Filename: i.dirFile, Number: btreeRootBlock})
+	if err != nil {
+		return false, err
+	}
+
+	return len(root.entries) > 0, nil
+}
+
+func (i *BTreeIndex) create() error {
+	if err := i.pages.check(); err != nil {
+		return err
+	}
+
+	// Эксклюзивная блокировка конца файла каталога не дает двум транзакциям создать дерево дважды
+	if err := i.trx.XLock(types.Block{Filename: i.dirFile, Number: concurrency.EndOfFileBlock}, false); err != nil {
+		return err
+	}
+
+	if ok, err := i.initialized(); ok || err != nil {
+		return err
+	}
+
+	leaf, err := i.trx.Append(i.leafFile)
+	if err != nil {
+		return err
+	}
+
+	if err = i.pages.write(&btreeNode{block: leaf, level: btreeLeafLevel, next: btreeNoBlock}, 0); err != nil {
+		return err
+	}
+
+	root := types.Block{Filename: i.dirFile, Number: btreeRootBlock}
+
+	size, err := i.trx.Size(i.dirFile)
+	if err != nil {
+		return err
+	}
+
+	if size == 0 {
+		if root, err = i.trx.Append(i.dirFile); err != nil {
+			return err
+		}
+	}
+
+	if err = i.trx.XLock(root, false); err != nil {
+		return err
+	}
+
+	return i.pages.write(&btreeNode{
+		block:   root,
+		level:   0,
+		next:    btreeNoBlock,
+		entries: []btreeEntry{{val: i.pages.zero(), block: int64(leaf.Number)}},
+	}, 0)
+}
+
+// descend спускается от корня к странице уровня level, в которой может быть значение key.
+// Для повторяющихся значений это самая левая такая страница, nil ведет к самой левой странице уровня
+func (i *BTreeIndex) descend(key scan.Constant, level int64) (types.Block, error) {
+	block := types.Block{Filename: i.dirFile, Number: btreeRootBlock}
+
+	for {
+		node, err := i.pages.read(block)
+		if err != nil {
+			return block, err
+		}
+
+		if node.level == level {
+			return block, nil
+		}
+
+		if node.level < level || len(node.entries) == 0 {
+			return block, errors.WithMessagef(ErrBrokenIndex, "no level %d below block %s", level, block)
+		}
+
+		child := node.entries[0].block
+
+		for _, entry := range node.entries[1:] {
+			if key == nil || entry.val.CompareTo(key) != scan.CompLess {
+				break
+			}
+
+			child = entry.block
+		}
+
+		block = types.Block{Filename: i.dirFile, Number: types.BlockID(child)}
+		if node.level == 0 {
+			block.Filename = i.leafFile
+		}
+	}
+}
+
+// insertLeaf находит лист, в который надо вставить запись, и следующее после нее значение.
+// Каталог мог устареть, поэтому от найденного листа идем вправо, пока в следующих листьях есть значения меньше
+func (i *BTreeIndex) insertLeaf(entry btreeEntry) (types.Block, concurrency.Resource, error) {
+	next := concurrency.SupremumResource(i.Name())
+
+	block, err := i.descend(entry.val, btreeLeafLevel)
+	if err != nil {
+		return block, next, err
+	}
+
+	for cur := block; ; {
+		node, err := i.pages.read(cur)
+		if err != nil {
+			return block, next, err
+		}
+
+		for _, e := range node.entries {
+			switch e.val.CompareTo(entry.val) {
+			case scan.CompLess:
+				block = cur
+			case scan.CompGreat:
+				return block, keyResource(i.Name(), e.val), nil
+			}
+		}
+
+		if node.next == btreeNoBlock {
+			return block, next, nil
+		}
+
+		cur = types.Block{Filename: i.leafFile, Number: types.BlockID(node.next)}
+	}
+}
+
+func (i *BTreeIndex) insert(entry btreeEntry) error {
+	if err := i.trx.XLockKey(keyResource(i.Name(), entry.val)); err != nil {
+		return err
+	}
+
+	if err := i.create(); err != nil {
+		return err
+	}
+
+	for {
+		block, next, err := i.insertLeaf(entry)
+		if err != nil {
+			return err
+		}
+
+		if err = i.trx.XLockNextKey(next); err != nil {
+			return err
+		}
+
+		done, err := i.insertInto(block, next, entry)
+
+		i.trx.ReleaseNextKey(next)
+
+		if done || err != nil {
+			return err
+		}
+	}
+}
+
+// insertInto вставляет запись в лист block под эксклюзивной блокировкой листа.
+// false — пока транзакция ждала блокировки, лист или следующее значение изменились, и вставку надо повторить
+func (i *BTreeIndex) insertInto(block types.Block, next concurrency.Resource, entry btreeEntry) (bool, error) {
+	if err := i.trx.XLock(block, false); err != nil {
+		return false, err
+	}
+
+	if curBlock, curNext, err := i.insertLeaf(entry); err != nil || curBlock != block || curNext != next {
+		return false, err
+	}
+
+	node, err := i.pages.read(block)
+	if err != nil {
+		return false, err
+	}
+
+	pos := len(node.entries)
+
+	for j, e := range node.entries {
+		if e.val.CompareTo(entry.val) == scan.CompGreat {
+			pos = j
+
+			break
+		}
+	}
+
+	node.entries = append(node.entries[:pos], append([]btreeEntry{entry}, node.entries[pos:]...)...)
+
+	if len(node.entries) <= i.pages.capacity() {
+		return true, i.pages.write(node, pos)
+	}
+
+	return true, i.split(node, pos)
+}
+
+// split делит переполненную страницу: правая половина записей уезжает на новую страницу, которая встает
+// в список страниц уровня сразу за старой. Ссылка на новую страницу вставляется в каталог уровнем выше
+func (i *BTreeIndex) split(node *btreeNode, from int) error {
+	// Блокировка конца файла листьев держится до конца транзакции и упорядочивает разделения страниц
+	if err := i.trx.XLock(types.Block{Filename: i.leafFile, Number: concurrency.EndOfFileBlock}, false); err != nil {
+		return err
+	}
+
+	if node.block.Filename == i.dirFile && node.block.Number == btreeRootBlock {
+		return i.splitRoot(node)
+	}
+
+	newBlock, err := i.trx.Append(node.block.Filename)
+	if err != nil {
+		return err
+	}
+
+	mid := len(node.entries) / 2 //nolint:mnd
+
+	right := &btreeNode{
+		block:   newBlock,
+		level:   node.level,
+		next:    node.next,
+		entries: append([]btreeEntry(nil), node.entries[mid:]...),
+	}
+
+	node.entries = node.entries[:mid]
+	node.next = int64(newBlock.Number)
+
+	if err = i.pages.write(right, 0); err != nil {
+		return err
+	}
+
+	if err = i.pages.write(node, min(from, mid)); err != nil {
+		return err
+	}
+
+	return i.insertDir(node.level+1, node.block, btreeEntry{val: right.entries[0].val, block: int64(newBlock.Number)})
+}
+
+// splitRoot делит корень: его записи уезжают на две новые страницы, а корень поднимается на уровень выше
+func (i *BTreeIndex) splitRoot(root *btreeNode) error {
+	leftBlock, err := i.trx.Append(i.dirFile)
+	if err != nil {
+		return err
+	}
+
+	rightBlock, err := i.trx.Append(i.dirFile)
+	if err != nil {
+		return err
+	}
+
+	mid := len(root.entries) / 2 //nolint:mnd
+
+	left := &btreeNode{
+		block:   leftBlock,
+		level:   root.level,
+		next:    int64(rightBlock.Number),
+		entries: root.entries[:mid],
+	}
+
+	right := &btreeNode{
+		block:   rightBlock,
+		level:   root.level,
+		next:    btreeNoBlock,
+		entries: root.entries[mid:],
+	}
+
+	for _, node := range []*btreeNode{left, right} {
+		if err = i.pages.write(node, 0); err != nil {
+			return err
+		}
+	}
+
+	return i.pages.write(&btreeNode{
+		block: root.block,
+		level: root.level + 1,
+		next:  btreeNoBlock,
+		entries: []btreeEntry{
+			{val: left.entries[0].val, block: int64(leftBlock.Number)},
+			{val: right.entries[0].val, block: int64(rightBlock.Number)},
+		},
+	}, 0)
+}
+
+// insertDir вставляет в каталог уровня level ссылку на новую страницу сразу за ссылкой на страницу left
+func (i *BTreeIndex) insertDir(level int64, left types.Block, entry btreeEntry) error {
+	block, err := i.descend(entry.val, level)
+	if err != nil {
+		return err
+	}
+
+	for {
+		if err = i.trx.XLock(block, false); err != nil {
+			return err
+		}
+
+		node, err := i.pages.read(block)
+		if err != nil {
+			return err
+		}
+
+		for j, e := range node.entries {
+			if e.block != int64(left.Number) {
+				continue
+			}
+
+			node.entries = append(node.entries[:j+1], append([]btreeEntry{entry}, node.entries[j+1:]...)...)
+
+			if len(node.entries) <= i.pages.capacity() {
+				return i.pages.write(node, j+1)
+			}
+
+			return i.split(node, j+1)
+		}
+
+		if node.next == btreeNoBlock {
+			return errors.WithMessagef(ErrBrokenIndex, "no link to block %s at level %d", left, level)
+		}
+
+		block = types.Block{Filename: i.dirFile, Number: types.BlockID(node.next)}
+	}
+}
+
+func (i *BTreeIndex) delete(entry btreeEntry) error {
+	if err := i.trx.XLockKey(keyResource(i.Name(), entry.val)); err != nil {
+		return err
+	}
+
+	if ok, err := i.initialized(); !ok || err != nil {
+		return err
+	}
+
+	block, err := i.descend(entry.val, btreeLeafLevel)
+	if err != nil {
+		return err
+	}
+
+	for {
+		if err = i.trx.XLock(block, false); err != nil {
+			return err
+		}
+
+		node, err := i.pages.read(block)
+		if err != nil {
+			return err
+		}
+
+		for j, e := range node.entries {
+			if e.equal(entry) {
+				node.entries = append(node.entries[:j], node.entries[j+1:]...)
+
+				return i.pages.write(node, j)
+			}
+
+			if e.val.CompareTo(entry.val) == scan.CompGreat {
+				return nil
+			}
+		}
+
+		if node.next == btreeNoBlock {
+			return nil
+		}
+
+		block = types.Block{Filename: i.leafFile, Number: types.BlockID(node.next)}
+	}
 }
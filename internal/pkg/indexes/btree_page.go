@@ -0,0 +1,278 @@
+package indexes
+
+import (
+	"github.com/pkg/errors"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+// Страница B-дерева начинается с заголовка: уровень страницы, номер следующей страницы того же уровня
+// и число записей. За заголовком лежат записи фиксированного размера, отсортированные по значению
+const (
+	btreeLevelOffset uint32 = 0
+	btreeNextOffset  uint32 = 8
+	btreeCountOffset uint32 = 16
+	btreeHeaderSize  uint32 = 24
+)
+
+const (
+	// btreeLeafLevel — уровень листа. Каталог уровня 0 ссылается на листья, уровня n — на каталоги уровня n-1
+	btreeLeafLevel int64 = -1
+	// btreeNoBlock — у страницы нет следующей
+	btreeNoBlock int64 = -1
+	// btreeMinEntries — столько записей должно помещаться на страницу, чтобы ее можно было разделить
+	btreeMinEntries = 3
+)
+
+// btreeEntry — запись страницы B-дерева. В листе block и slot — RID записи таблицы,
+// в каталоге block — номер дочерней страницы, а val — наименьшее значение в ней
+type btreeEntry struct {
+	val   scan.Constant
+	block int64
+	slot  int64
+}
+
+func (e btreeEntry) rid() types.RID {
+	return types.RID{
+		BlockNumber: types.BlockID(e.block),
+		Slot:        types.SlotID(e.slot),
+	}
+}
+
+func (e btreeEntry) equal(another btreeEntry) bool {
+	return e.val.CompareTo(another.val) == scan.CompEqual && e.block == another.block && e.slot == another.slot
+}
+
+// btreeNode — страница B-дерева, прочитанная в память
+type btreeNode struct {
+	block   types.Block
+	level   int64
+	next    int64
+	entries []btreeEntry
+}
+
+// btreePages читает и пишет страницы B-дерева. Страница читается целиком под короткой разделяемой блокировкой,
+// а изменения пишутся в журнал одной записью на заголовок и одной на сдвинутые записи
+type btreePages struct {
+	trx       scan.TRXInt
+	valType   records.FieldType
+	valLen    uint32
+	entrySize uint32
+}
+
+func newBTreePages(trx scan.TRXInt, idxLayout records.Layout) (btreePages, error) {
+	field, _ := idxLayout.Schema.Field(IdxSchemaValueField)
+
+	//nolint:exhaustive
+	switch field.Type {
+	case records.Int64Field, records.Int8Field, records.StringField:
+	default:
+		return btreePages{}, errors.WithMessagef(ErrUnsupportedKey, "btree: field type %d", field.Type)
+	}
+
+	p := btreePages{
+		trx:     trx,
+		valType: field.Type,
+		valLen:  field.BytesLen(),
+	}
+
+	p.entrySize = p.valLen + 2*types.Int64Size //nolint:mnd
+
+	return p, nil
+}
+
+// capacity — сколько записей помещается на страницу
+func (p btreePages) capacity() int {
+	return int((p.trx.BlockSize() - btreeHeaderSize) / p.entrySize)
+}
+
+// check проверяет, что на страницу помещается столько записей, чтобы ее можно было разделить
+func (p btreePages) check() error {
+	if p.capacity() < btreeMinEntries {
+		return errors.WithMessagef(ErrUnsupportedKey, "btree: key of %d bytes doesn't fit block", p.valLen)
+	}
+
+	return nil
+}
+
+func (p btreePages) read(block types.Block) (*btreeNode, error) {
+	if err := p.trx.Pin(block); err != nil {
+		return nil, err
+	}
+
+	defer p.trx.Unpin(block)
+
+	page, err := p.trx.ReadPage(block)
+	if err != nil {
+		return nil, err
+	}
+
+	defer p.trx.EndRead(block)
+
+	node := &btreeNode{
+		block: block,
+	}
+
+	if node.level, err = page.GetInt64(btreeLevelOffset); err != nil {
+		return nil, err
+	}
+
+	if node.next, err = page.GetInt64(btreeNextOffset); err != nil {
+		return nil, err
+	}
+
+	count, err := page.GetInt64(btreeCountOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	if count < 0 || count > int64(p.capacity()) {
+		return nil, errors.WithMessagef(ErrBrokenIndex, "block %s has %d entries", block, count)
+	}
+
+	node.entries = make([]btreeEntry, count)
+
+	for j := range node.entries {
+		if node.entries[j], err = p.decode(page, p.entryOffset(j)); err != nil {
+			return nil, err
+		}
+	}
+
+	return node, nil
+}
+
+// write записывает заголовок страницы и ее записи, начиная с from: записи до from не менялись
+func (p btreePages) write(node *btreeNode, from int) error {
+	if err := p.trx.Pin(node.block); err != nil {
+		return err
+	}
+
+	defer p.trx.Unpin(node.block)
+
+	header := types.NewPage(btreeHeaderSize)
+	_ = header.SetInt64(btreeLevelOffset, node.level)
+	_ = header.SetInt64(btreeNextOffset, node.next)
+	_ = header.SetInt64(btreeCountOffset, int64(len(node.entries)))
+
+	if err := p.trx.PutBytes(node.block, 0, header.Content(), true); err != nil {
+		return err
+	}
+
+	if from >= len(node.entries) {
+		return nil
+	}
+
+	entries := node.entries[from:]
+	buf := types.NewPage(uint32(len(entries)) * p.entrySize)
+
+	for j, entry := range entries {
+		if err := p.encode(buf, uint32(j)*p.entrySize, entry); err != nil {
+			return err
+		}
+	}
+
+	return p.trx.PutBytes(node.block, p.entryOffset(from), buf.Content(), true)
+}
+
+// zero — значение для первой записи нового каталога. Первая запись страницы каталога ведет ко всем значениям
+// меньше второй, поэтому ее значение при поиске не сравнивается
+func (p btreePages) zero() scan.Constant {
+	//nolint:exhaustive
+	switch p.valType {
+	case records.Int64Field:
+		return scan.NewInt64Constant(0)
+	case records.Int8Field:
+		return scan.NewInt8Constant(0)
+	default:
+		return scan.NewStringConstant("")
+	}
+}
+
+func (p btreePages) entryOffset(j int) uint32 {
+	return btreeHeaderSize + uint32(j)*p.entrySize
+}
+
+func (p btreePages) decode(page *types.Page, offset uint32) (btreeEntry, error) {
+	var (
+		entry btreeEntry
+		err   error
+	)
+
+	//nolint:exhaustive
+	switch p.valType {
+	case records.Int64Field:
+		var v int64
+
+		v, err = page.GetInt64(offset)
+		entry.val = scan.NewInt64Constant(v)
+	case records.Int8Field:
+		var v int8
+
+		v, err = page.GetInt8(offset)
+		entry.val = scan.NewInt8Constant(v)
+	default:
+		var v string
+
+		v, err = page.GetString(offset)
+		entry.val = scan.NewStringConstant(v)
+	}
+
+	if err != nil {
+		return entry, err
+	}
+
+	if entry.block, err = page.GetInt64(offset + p.valLen); err != nil {
+		return entry, err
+	}
+
+	entry.slot, err = page.GetInt64(offset + p.valLen + types.Int64Size)
+
+	return entry, err
+}
+
+func (p btreePages) encode(page *types.Page, offset uint32, entry btreeEntry) error {
+	var err error
+
+	switch v := entry.val.Value().(type) {
+	case int64:
+		switch p.valType {
+		case records.Int64Field:
+			err = page.SetInt64(offset, v)
+		case records.Int8Field:
+			err = page.SetInt8(offset, int8(v))
+		default:
+			err = errors.WithMessagef(ErrUnsupportedKey, "btree: value %s", entry.val)
+		}
+	case int8:
+		switch p.valType {
+		case records.Int64Field:
+			err = page.SetInt64(offset, int64(v))
+		case records.Int8Field:
+			err = page.SetInt8(offset, v)
+		default:
+			err = errors.WithMessagef(ErrUnsupportedKey, "btree: value %s", entry.val)
+		}
+	case string:
+		switch {
+		case p.valType != records.StringField:
+			err = errors.WithMessagef(ErrUnsupportedKey, "btree: value %s", entry.val)
+		case uint32(len(v)) > p.valLen-types.Int32Size:
+			err = errors.WithMessagef(ErrUnsupportedKey, "btree: value %s is too long", entry.val)
+		default:
+			err = page.SetString(offset, v)
+		}
+	default:
+		err = errors.WithMessagef(ErrUnsupportedKey, "btree: value %s", entry.val)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err = page.SetInt64(offset+p.valLen, entry.block); err != nil {
+		return err
+	}
+
+	return page.SetInt64(offset+p.valLen+types.Int64Size, entry.slot)
+}
@@ -1,15 +1,324 @@
 package indexes_test
 
 import (
+	"fmt"
+	"math/rand"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/indexes"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/storage"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/concurrency"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/transaction"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
 )
 
 type BTreeIndexTestSuite struct {
-	suite.Suite
+	Suite
 }
 
 func TestBTreeIndextestSuite(t *testing.T) {
 	suite.Run(t, new(BTreeIndexTestSuite))
 }
+
+// scanRange возвращает RID всех записей индекса из диапазона
+func (ts *BTreeIndexTestSuite) scanRange(sut *indexes.BTreeIndex, low, high scan.Constant) []types.RID {
+	t := ts.T()
+
+	require.NoError(t, sut.BeforeRange(low, high))
+
+	rids := []types.RID{}
+
+	for {
+		ok, err := sut.Next()
+		require.NoError(t, err)
+
+		if !ok {
+			break
+		}
+
+		rids = append(rids, sut.RID())
+	}
+
+	return rids
+}
+
+func (ts *BTreeIndexTestSuite) newIndex(trx scan.TRXInt, layout records.Layout) *indexes.BTreeIndex {
+	sut, err := indexes.NewBTreeIndex(trx, "table_idx", layout)
+	ts.Require().NoError(err)
+
+	return sut
+}
+
+func (ts *BTreeIndexTestSuite) TestInt64Index() {
+	t := ts.T()
+
+	trxMan, fm := ts.newTRXManager(defaultLockTimeout, "")
+	defer fm.Close()
+
+	trx, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut := ts.newIndex(trx, indexes.NewIndexLayout(records.Int64Field, 0))
+	assert.EqualValues(t, 2, sut.SearchCost(1024, 316))
+
+	// Пустой индекс
+	assert.Empty(t, ts.scanRange(sut, nil, nil))
+	require.NoError(t, sut.Delete(scan.NewInt64Constant(1), types.RID{}))
+
+	const totalCount = 1000
+
+	// Каждое значение встречается пять раз, значения вставляются вперемешку
+	for _, i := range rand.New(rand.NewSource(1)).Perm(totalCount * 5) { //nolint:gosec
+		require.NoError(t, sut.Insert(
+			scan.NewInt64Constant(int64(i%totalCount)),
+			types.RID{BlockNumber: types.BlockID(i / totalCount), Slot: types.SlotID(i % totalCount)},
+		))
+	}
+
+	leaves, err := fm.Length("table_idx_leaf")
+	require.NoError(t, err)
+	assert.Greater(t, leaves, types.BlockID(10))
+
+	rids := ts.scanRange(sut, scan.NewInt64Constant(7), scan.NewInt64Constant(7))
+	assert.ElementsMatch(t, []types.RID{
+		{BlockNumber: 0, Slot: 7},
+		{BlockNumber: 1, Slot: 7},
+		{BlockNumber: 2, Slot: 7},
+		{BlockNumber: 3, Slot: 7},
+		{BlockNumber: 4, Slot: 7},
+	}, rids)
+
+	all := ts.scanRange(sut, nil, nil)
+	require.Len(t, all, totalCount*5)
+
+	for j := 1; j < len(all); j++ {
+		assert.LessOrEqual(t, all[j-1].Slot, all[j].Slot)
+	}
+
+	assert.Len(t, ts.scanRange(sut, scan.NewInt64Constant(990), nil), 50)
+	assert.Len(t, ts.scanRange(sut, nil, scan.NewInt64Constant(9)), 50)
+	assert.Len(t, ts.scanRange(sut, scan.NewInt64Constant(100), scan.NewInt64Constant(199)), 500)
+	assert.Empty(t, ts.scanRange(sut, scan.NewInt64Constant(totalCount), nil))
+
+	require.NoError(t, sut.Delete(scan.NewInt64Constant(7), types.RID{BlockNumber: 1, Slot: 7}))
+	require.NoError(t, sut.Delete(scan.NewInt64Constant(7), types.RID{BlockNumber: 1000, Slot: 7}))
+
+	rids = ts.scanRange(sut, scan.NewInt64Constant(7), scan.NewInt64Constant(7))
+	assert.ElementsMatch(t, []types.RID{
+		{BlockNumber: 0, Slot: 7},
+		{BlockNumber: 2, Slot: 7},
+		{BlockNumber: 3, Slot: 7},
+		{BlockNumber: 4, Slot: 7},
+	}, rids)
+
+	// Индекс переживает транзакцию
+	require.NoError(t, trx.Commit())
+
+	trx, err = trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut = ts.newIndex(trx, indexes.NewIndexLayout(records.Int64Field, 0))
+	assert.Len(t, ts.scanRange(sut, nil, nil), totalCount*5-1)
+
+	require.NoError(t, trx.Commit())
+}
+
+func (ts *BTreeIndexTestSuite) TestStringIndexSplitsRoot() {
+	t := ts.T()
+
+	trxMan, fm := ts.newTRXManager(defaultLockTimeout, "")
+	defer fm.Close()
+
+	trx, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	// На страницу помещается четыре записи, поэтому дерево растет на несколько уровней
+	layout := indexes.NewIndexLayout(records.StringField, 200)
+	sut := ts.newIndex(trx, layout)
+
+	const totalCount = 300
+
+	for _, i := range rand.New(rand.NewSource(2)).Perm(totalCount) { //nolint:gosec
+		require.NoError(t, sut.Insert(
+			scan.NewStringConstant(fmt.Sprintf("value %04d", i)),
+			types.RID{BlockNumber: types.BlockID(i), Slot: 1},
+		))
+	}
+
+	dirs, err := fm.Length("table_idx_dir")
+	require.NoError(t, err)
+	assert.Greater(t, dirs, types.BlockID(totalCount/4/4))
+
+	all := ts.scanRange(sut, nil, nil)
+	require.Len(t, all, totalCount)
+
+	for j, rid := range all {
+		assert.EqualValues(t, j, rid.BlockNumber)
+	}
+
+	rids := ts.scanRange(sut, scan.NewStringConstant("value 0100"), scan.NewStringConstant("value 0109"))
+	require.Len(t, rids, 10)
+	assert.EqualValues(t, 100, rids[0].BlockNumber)
+
+	err = sut.Insert(scan.NewInt64Constant(1), types.RID{})
+	require.ErrorIs(t, err, indexes.ErrFailedToScanIndex)
+	assert.ErrorContains(t, err, indexes.ErrUnsupportedKey.Error())
+
+	_, err = indexes.NewBTreeIndex(trx, "text_idx", indexes.NewIndexLayout(records.TextField, 0))
+	require.ErrorIs(t, err, indexes.ErrUnsupportedKey)
+
+	require.NoError(t, trx.Commit())
+}
+
+// newLockingIndex создает индекс со значениями 0, 10, 20, ... 9990 в несколько листьев
+func (ts *BTreeIndexTestSuite) newLockingIndex() (*transaction.TRXManager, *storage.Manager) {
+	t := ts.T()
+
+	trxMan, fm := ts.newTRXManager(defaultLockTimeout, "")
+
+	trx, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut := ts.newIndex(trx, indexes.NewIndexLayout(records.Int64Field, 0))
+
+	for i := int64(0); i < 1000; i++ {
+		require.NoError(t, sut.Insert(scan.NewInt64Constant(i*10), types.RID{BlockNumber: types.BlockID(i)}))
+	}
+
+	require.NoError(t, trx.Commit())
+
+	leaves, err := fm.Length("table_idx_leaf")
+	require.NoError(t, err)
+	require.Greater(t, leaves, types.BlockID(5))
+
+	return trxMan, fm
+}
+
+// insert вставляет значение в отдельной транзакции
+func (ts *BTreeIndexTestSuite) insert(trxMan *transaction.TRXManager, value int64) error {
+	t := ts.T()
+
+	trx, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut := ts.newIndex(trx, indexes.NewIndexLayout(records.Int64Field, 0))
+
+	if err = sut.Insert(scan.NewInt64Constant(value), types.RID{BlockNumber: types.BlockID(value)}); err != nil {
+		require.NoError(t, trx.Rollback())
+
+		return err
+	}
+
+	return trx.Commit()
+}
+
+func (ts *BTreeIndexTestSuite) TestRangeLocksPreventPhantoms() {
+	t := ts.T()
+
+	trxMan, fm := ts.newLockingIndex()
+	defer fm.Close()
+
+	reader, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut := ts.newIndex(reader, indexes.NewIndexLayout(records.Int64Field, 0))
+
+	low, high := scan.NewInt64Constant(4000), scan.NewInt64Constant(4100)
+	rids := ts.scanRange(sut, low, high)
+	require.Len(t, rids, 11)
+
+	// Вставки в другие листья и в промежутки за границами диапазона проходят сразу
+	require.NoError(t, ts.insert(trxMan, 55))
+	require.NoError(t, ts.insert(trxMan, 9995))
+	require.NoError(t, ts.insert(trxMan, 3985))
+	require.NoError(t, ts.insert(trxMan, 4115))
+
+	// В диапазон и в промежуток до следующего за ним значения вставить нельзя
+	for _, value := range []int64{4000, 4055, 4105, 3995} {
+		err = ts.insert(trxMan, value)
+		require.ErrorIs(t, err, indexes.ErrFailedToScanIndex, value)
+		assert.ErrorContains(t, err, "failed to lock", value)
+	}
+
+	assert.Equal(t, rids, ts.scanRange(sut, low, high))
+
+	require.NoError(t, reader.Commit())
+
+	require.NoError(t, ts.insert(trxMan, 4055))
+
+	trx, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut = ts.newIndex(trx, indexes.NewIndexLayout(records.Int64Field, 0))
+	assert.Len(t, ts.scanRange(sut, low, high), 12)
+	assert.Len(t, ts.scanRange(sut, nil, nil), 1005)
+
+	require.NoError(t, trx.Commit())
+}
+
+func (ts *BTreeIndexTestSuite) TestRangeLocksByIsolationLevel() {
+	t := ts.T()
+
+	trxMan, fm := ts.newLockingIndex()
+	defer fm.Close()
+
+	// REPEATABLE READ блокирует прочитанные значения, но не промежутки за диапазоном
+	reader, err := trxMan.Transaction(transaction.WithIsolationLevel(concurrency.RepeatableRead))
+	require.NoError(t, err)
+
+	sut := ts.newIndex(reader, indexes.NewIndexLayout(records.Int64Field, 0))
+	require.Len(t, ts.scanRange(sut, scan.NewInt64Constant(4000), scan.NewInt64Constant(4100)), 11)
+
+	require.NoError(t, ts.insert(trxMan, 4105))
+	require.ErrorContains(t, ts.insert(trxMan, 4095), "failed to lock")
+
+	require.NoError(t, reader.Commit())
+
+	// READ COMMITTED ключи не блокирует
+	reader, err = trxMan.Transaction(transaction.WithIsolationLevel(concurrency.ReadCommitted))
+	require.NoError(t, err)
+
+	sut = ts.newIndex(reader, indexes.NewIndexLayout(records.Int64Field, 0))
+	require.Len(t, ts.scanRange(sut, scan.NewInt64Constant(4000), scan.NewInt64Constant(4100)), 11)
+
+	require.NoError(t, ts.insert(trxMan, 4095))
+
+	require.NoError(t, reader.Commit())
+
+	// Поиск до конца индекса блокирует его конец
+	reader, err = trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut = ts.newIndex(reader, indexes.NewIndexLayout(records.Int64Field, 0))
+	require.Len(t, ts.scanRange(sut, scan.NewInt64Constant(9980), nil), 2)
+
+	require.ErrorContains(t, ts.insert(trxMan, 10000), "failed to lock")
+	require.ErrorContains(t, ts.insert(trxMan, 9975), "failed to lock")
+	require.NoError(t, ts.insert(trxMan, 9965))
+
+	require.NoError(t, reader.Commit())
+	require.NoError(t, ts.insert(trxMan, 10000))
+}
+
+func (ts *BTreeIndexTestSuite) TestEmptyIndexLocksSupremum() {
+	t := ts.T()
+
+	trxMan, fm := ts.newTRXManager(defaultLockTimeout, "")
+	defer fm.Close()
+
+	reader, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut := ts.newIndex(reader, indexes.NewIndexLayout(records.Int64Field, 0))
+	require.Empty(t, ts.scanRange(sut, scan.NewInt64Constant(1), scan.NewInt64Constant(10)))
+
+	require.ErrorContains(t, ts.insert(trxMan, 5), "failed to lock")
+
+	require.NoError(t, reader.Commit())
+	require.NoError(t, ts.insert(trxMan, 5))
+}
@@ -5,4 +5,6 @@ import "github.com/pkg/errors"
 var (
 	ErrFailedToScanIndex = errors.New("failed to scan index")
 	ErrUnknownIndexType  = errors.New("unknown index type")
+	ErrUnsupportedKey    = errors.New("unsupported index key type")
+	ErrBrokenIndex       = errors.New("index is broken")
 )
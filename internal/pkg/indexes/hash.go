@@ -19,7 +19,7 @@ func NewStaticHashIndex(trx scan.TRXInt, idxName string, idxLayout records.Layou
 			idxLayout: idxLayout,
 		},
 		bucketsCount: defaultBucketsCount,
-		trx:          trx,
+		trx:          indexTRX{TRXInt: trx},
 	}
 
 	return h, nil
@@ -38,7 +38,20 @@ type StaticHashIndex struct {
 	ts           *scan.TableScan
 }
 
+// BeforeFirst начинает поиск значения searchKey. На уровнях SERIALIZABLE и REPEATABLE READ значение блокируется
+// до конца транзакции: корзины читаются под короткими блокировками, и вставить или удалить это значение
+// другие транзакции не смогут
 func (i *StaticHashIndex) BeforeFirst(searchKey scan.Constant) error {
+	if locksReadKeys(i.trx) {
+		if err := i.trx.SLockKey(keyResource(i.Name(), searchKey)); err != nil {
+			return errors.WithMessage(ErrFailedToScanIndex, err.Error())
+		}
+	}
+
+	return i.openBucket(searchKey)
+}
+
+func (i *StaticHashIndex) openBucket(searchKey scan.Constant) error {
 	i.Close()
 
 	bucket := int(searchKey.Hash() % uint64(i.bucketsCount))
@@ -94,7 +107,11 @@ func (i *StaticHashIndex) RID() types.RID {
 }
 
 func (i *StaticHashIndex) Insert(value scan.Constant, rid types.RID) error {
-	if err := i.BeforeFirst(value); err != nil {
+	if err := i.trx.XLockKey(keyResource(i.Name(), value)); err != nil {
+		return errors.WithMessage(ErrFailedToScanIndex, err.Error())
+	}
+
+	if err := i.openBucket(value); err != nil {
 		return errors.WithMessage(ErrFailedToScanIndex, err.Error())
 	}
 
@@ -118,7 +135,11 @@ func (i *StaticHashIndex) Insert(value scan.Constant, rid types.RID) error {
 }
 
 func (i *StaticHashIndex) Delete(value scan.Constant, rid types.RID) error {
-	if err := i.BeforeFirst(value); err != nil {
+	if err := i.trx.XLockKey(keyResource(i.Name(), value)); err != nil {
+		return errors.WithMessage(ErrFailedToScanIndex, err.Error())
+	}
+
+	if err := i.openBucket(value); err != nil {
 		return errors.WithMessage(ErrFailedToScanIndex, err.Error())
 	}
 
@@ -118,3 +118,90 @@ func (ts *StaticHashIndexTestSuite) TestInt64HashIndex() {
 
 	assert.EqualValues(t, 4, cnt)
 }
+
+func (ts *StaticHashIndexTestSuite) TestKeyLocks() {
+	t := ts.T()
+
+	trxMan, fm := ts.newTRXManager(defaultLockTimeout, t.TempDir())
+	defer fm.Close()
+
+	layout := indexes.NewIndexLayout(records.Int64Field, 0)
+
+	// Значение, которое попадает в ту же корзину, что и искомое
+	value := scan.NewInt64Constant(7)
+	neighbour := scan.NewInt64Constant(8)
+
+	for neighbour.Hash()%100 != value.Hash()%100 {
+		neighbour = scan.NewInt64Constant(neighbour.Value().(int64) + 1) //nolint:forcetypeassert
+	}
+
+	// change выполняет вставку или удаление в отдельной транзакции
+	change := func(f func(sut indexes.Index) error) error {
+		trx, err := trxMan.Transaction()
+		require.NoError(t, err)
+
+		sut, err := indexes.NewStaticHashIndex(trx, "table_idx", layout)
+		require.NoError(t, err)
+
+		if err = f(sut); err != nil {
+			require.NoError(t, trx.Rollback())
+
+			return err
+		}
+
+		return trx.Commit()
+	}
+
+	require.NoError(t, change(func(sut indexes.Index) error {
+		return sut.Insert(value, types.RID{BlockNumber: 1, Slot: 1})
+	}))
+
+	count := func(sut indexes.Index) int {
+		require.NoError(t, sut.BeforeFirst(value))
+
+		cnt := 0
+
+		for {
+			ok, err := sut.Next()
+			require.NoError(t, err)
+
+			if !ok {
+				return cnt
+			}
+
+			cnt++
+		}
+	}
+
+	reader, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut, err := indexes.NewStaticHashIndex(reader, "table_idx", layout)
+	require.NoError(t, err)
+
+	require.Equal(t, 1, count(sut))
+
+	// Прочитанное значение нельзя ни вставить, ни удалить, а соседнее в той же корзине — можно
+	err = change(func(sut indexes.Index) error {
+		return sut.Insert(value, types.RID{BlockNumber: 1, Slot: 2})
+	})
+	require.ErrorIs(t, err, indexes.ErrFailedToScanIndex)
+	assert.ErrorContains(t, err, "failed to lock")
+
+	err = change(func(sut indexes.Index) error {
+		return sut.Delete(value, types.RID{BlockNumber: 1, Slot: 1})
+	})
+	assert.ErrorContains(t, err, "failed to lock")
+
+	require.NoError(t, change(func(sut indexes.Index) error {
+		return sut.Insert(neighbour, types.RID{BlockNumber: 2, Slot: 1})
+	}))
+
+	assert.Equal(t, 1, count(sut))
+
+	require.NoError(t, reader.Commit())
+
+	require.NoError(t, change(func(sut indexes.Index) error {
+		return sut.Insert(value, types.RID{BlockNumber: 1, Slot: 2})
+	}))
+}
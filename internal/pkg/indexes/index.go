@@ -33,6 +33,13 @@ type Index interface {
 	Delete(value scan.Constant, rid types.RID) error
 }
 
+// RangeIndex — индекс, который умеет искать значения из диапазона
+type RangeIndex interface {
+	Index
+
+	BeforeRange(low scan.Constant, high scan.Constant) error
+}
+
 const (
 	IdxSchemaBlockField = "block"
 	IdxSchemaIDField    = "id"
@@ -63,6 +70,10 @@ func NewIndexLayout(valueType records.FieldType, length int64) records.Layout {
 		schema.AddInt8Field(IdxSchemaValueField)
 	case records.StringField:
 		schema.AddStringField(IdxSchemaValueField, length)
+	case records.TextField:
+		schema.AddTextField(IdxSchemaValueField)
+	case records.BlobField:
+		schema.AddBlobField(IdxSchemaValueField)
 	}
 
 	return records.NewLayout(schema)
@@ -24,17 +24,17 @@ func TestNewIndexLayout(t *testing.T) {
 		{
 			name: "index on int64 field",
 			args: args{records.Int64Field, 0},
-			want: "schema: block int64, id int64, dataval int64, slot size: 25",
+			want: "schema: block int64, id int64, dataval int64, slot size: 26",
 		},
 		{
 			name: "index on int8 field",
 			args: args{records.Int8Field, 0},
-			want: "schema: block int64, id int64, dataval int8, slot size: 18",
+			want: "schema: block int64, id int64, dataval int8, slot size: 19",
 		},
 		{
 			name: "index on string field",
 			args: args{records.StringField, 34},
-			want: "schema: block int64, id int64, dataval varchar(34), slot size: 157",
+			want: "schema: block int64, id int64, dataval varchar(34), slot size: 158",
 		},
 	}
 
@@ -0,0 +1,36 @@
+package indexes
+
+import (
+	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/concurrency"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+// indexTRX читает страницы индекса под короткими блокировками: разделяемая блокировка страницы снимается сразу после
+// чтения на любом уровне изоляции. От фантомов индекс защищают блокировки ключей, а страницы, которые изменила
+// другая транзакция, остаются под ее эксклюзивной блокировкой до конца транзакции
+type indexTRX struct {
+	scan.TRXInt
+}
+
+func (t indexTRX) EndRead(block types.Block) {
+	t.ReleaseRead(block)
+}
+
+func (t indexTRX) Size(filename string) (types.BlockID, error) {
+	size, err := t.TRXInt.Size(filename)
+
+	t.ReleaseRead(types.Block{Filename: filename, Number: concurrency.EndOfFileBlock})
+
+	return size, err
+}
+
+// keyResource — ресурс, которым блокируется значение ключа индекса
+func keyResource(idxName string, value scan.Constant) concurrency.Resource {
+	return concurrency.KeyResource(idxName, value.String())
+}
+
+// locksReadKeys проверяет, что чтение из индекса блокирует прочитанные ключи: на уровне READ COMMITTED их не блокируем
+func locksReadKeys(trx scan.TRXInt) bool {
+	return trx.IsolationLevel() != concurrency.ReadCommitted
+}
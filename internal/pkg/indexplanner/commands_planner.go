@@ -8,17 +8,31 @@ import (
 )
 
 type IndexCommandsPlanner struct {
-	mdm sqlCommandsPlannerMetadataManager
+	mdm                sqlCommandsPlannerMetadataManager
+	defaultCompression string
 }
 
-func NewIndexCommandsPlanner(mdm sqlCommandsPlannerMetadataManager) *IndexCommandsPlanner {
+type IndexCommandsPlannerOpt func(*IndexCommandsPlanner)
+
+func NewIndexCommandsPlanner(mdm sqlCommandsPlannerMetadataManager, opts ...IndexCommandsPlannerOpt) *IndexCommandsPlanner {
 	p := &IndexCommandsPlanner{
 		mdm: mdm,
 	}
 
+	for _, opt := range opts {
+		opt(p)
+	}
+
 	return p
 }
 
+// WithDefaultCompression задает алгоритм сжатия страниц для таблиц, созданных без with (compression = ...)
+func WithDefaultCompression(codecName string) IndexCommandsPlannerOpt {
+	return func(p *IndexCommandsPlanner) {
+		p.defaultCompression = codecName
+	}
+}
+
 func (p *IndexCommandsPlanner) ExecuteInsert(stmt parse.InsertStatement, trx scan.TRXInt) (int64, error) {
 	var (
 		plan Plan
@@ -62,8 +76,9 @@ func (p *IndexCommandsPlanner) ExecuteInsert(stmt parse.InsertStatement, trx sca
 			return 0, errors.WithMessage(ErrExecuteError, werr.Error())
 		}
 
+		// NULL в индекс не попадает: поле = NULL не выполняется ни для одной записи
 		idxInfo, ok := indexes[field]
-		if ok {
+		if ok && !scan.IsNull(values[i]) {
 			idx, err := idxInfo.Open()
 			if err != nil {
 				return 0, errors.WithMessagef(ErrExecuteError, "failed to open index (%s): %q", plan, err)
@@ -124,6 +139,10 @@ func (p *IndexCommandsPlanner) ExecuteDelete(stmt parse.DeleteStatement, trx sca
 				return true, errors.WithMessage(ErrExecuteError, err.Error())
 			}
 
+			if scan.IsNull(val) {
+				continue
+			}
+
 			idx, werr := ii.Open()
 			if werr != nil {
 				return true, errors.WithMessage(ErrExecuteError, err.Error())
@@ -207,14 +226,16 @@ func (p *IndexCommandsPlanner) ExecuteUpdate(stmt parse.UpdateStatement, trx sca
 
 				defer idx.Close()
 
-				err2 = idx.Delete(oldVal, rid)
-				if err2 != nil {
-					return true, err2
+				if !scan.IsNull(oldVal) {
+					if err2 = idx.Delete(oldVal, rid); err2 != nil {
+						return true, err2
+					}
 				}
 
-				err2 = idx.Insert(oldVal, rid)
-				if err2 != nil {
-					return true, err2
+				if !scan.IsNull(expr.Value) {
+					if err2 = idx.Insert(expr.Value, rid); err2 != nil {
+						return true, err2
+					}
 				}
 			}
 		}
@@ -230,7 +251,7 @@ func (p *IndexCommandsPlanner) ExecuteUpdate(stmt parse.UpdateStatement, trx sca
 }
 
 func (p *IndexCommandsPlanner) ExecuteCreateTable(stmt parse.CreateTableStatement, trx scan.TRXInt) (int64, error) {
-	if err := p.mdm.CreateTable(stmt.TableName(), stmt.Schema(), trx); err != nil {
+	if err := planner.CreateTable(p.mdm, stmt, p.defaultCompression, trx); err != nil {
 		return 0, errors.WithMessage(ErrExecuteError, err.Error())
 	}
 
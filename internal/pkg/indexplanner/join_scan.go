@@ -1,6 +1,8 @@
 package indexplanner
 
 import (
+	"io"
+
 	"github.com/unhandled-exception/sophiadb/internal/pkg/indexes"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
@@ -119,6 +121,14 @@ func (s *JoinScan) GetVal(fieldName string) (scan.Constant, error) {
 	return s.lhs.GetVal(fieldName)
 }
 
+func (s *JoinScan) GetLargeReader(fieldName string) (io.Reader, int64, error) {
+	if s.rhs.HasField(fieldName) {
+		return s.rhs.GetLargeReader(fieldName)
+	}
+
+	return scan.LargeReader(s.lhs, fieldName)
+}
+
 func (s *JoinScan) resetIndex() error {
 	searchKey, err := s.lhs.GetVal(s.fieldName)
 	if err != nil {
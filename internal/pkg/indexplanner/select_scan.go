@@ -1,6 +1,8 @@
 package indexplanner
 
 import (
+	"io"
+
 	"github.com/unhandled-exception/sophiadb/internal/pkg/indexes"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
@@ -69,3 +71,7 @@ func (ss *SelectScan) GetString(fieldName string) (string, error) {
 func (ss *SelectScan) GetVal(fieldName string) (scan.Constant, error) {
 	return ss.ts.GetVal(fieldName)
 }
+
+func (ss *SelectScan) GetLargeReader(fieldName string) (io.Reader, int64, error) {
+	return ss.ts.GetLargeReader(fieldName)
+}
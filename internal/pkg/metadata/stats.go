@@ -129,8 +129,10 @@ func (s *Stats) calcTableStat(tableName string, trx scan.TRXInt) (StatInfo, erro
 			case records.Int8Field:
 				buf = make([]byte, 1)
 				buf[0] = uint8(value.(int8)) //nolint:forcetypeassert
-			case records.StringField:
+			case records.StringField, records.TextField:
 				buf = []byte(value.(string)) //nolint:forcetypeassert
+			case records.BlobField:
+				buf, _ = value.([]byte)
 			default:
 				verr = errors.WithMessagef(verr, "unknown field type %d for field %s", fieldType, name)
 			}
@@ -12,6 +12,7 @@ import (
 	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/transaction"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
 )
 
 const (
@@ -111,7 +112,11 @@ func (ts *StatsTestSuite) TestCreateStatsManagerAndRefreshStatistics() {
 	si, err := sut.GetStatInfo(testStatTable, layout, trx)
 	require.NoError(t, err)
 
-	assert.EqualValues(t, testStatTableRecords/(defaultTestBlockSize/layout.SlotSize)+1, si.Blocks)
+	// Записи переменной длины, поэтому блоков не больше, чем нужно под записи наибольшей длины
+	blocks, err := trx.Size(scan.TableFilename(testStatTable))
+	require.NoError(t, err)
+	assert.LessOrEqual(t, blocks, types.BlockID(testStatTableRecords/(defaultTestBlockSize/layout.SlotSize)+1))
+	assert.EqualValues(t, blocks, si.Blocks)
 	assert.EqualValues(t, testStatTableRecords, si.Records)
 
 	idCnt, ok := si.DistinctValues("id")
@@ -149,11 +149,11 @@ func (ts *TablesTestSuite) TestCreateTable_Ok() {
 
 		switch fi.FieldName {
 		case "id":
-			assert.Equal(t, fieldInfo{TableName: fmt.Sprintf("test_table_%d", i), FieldName: "id", FieldType: 1, Length: 0, Offset: 1}, fi)
+			assert.Equal(t, fieldInfo{TableName: fmt.Sprintf("test_table_%d", i), FieldName: "id", FieldType: 1, Length: 0, Offset: 2}, fi)
 		case "name":
-			assert.Equal(t, fieldInfo{TableName: fmt.Sprintf("test_table_%d", i), FieldName: "name", FieldType: 2, Length: 25, Offset: 9}, fi)
+			assert.Equal(t, fieldInfo{TableName: fmt.Sprintf("test_table_%d", i), FieldName: "name", FieldType: 2, Length: 25, Offset: 10}, fi)
 		case "age":
-			assert.Equal(t, fieldInfo{TableName: fmt.Sprintf("test_table_%d", i), FieldName: "age", FieldType: 3, Length: 0, Offset: 113}, fi)
+			assert.Equal(t, fieldInfo{TableName: fmt.Sprintf("test_table_%d", i), FieldName: "age", FieldType: 3, Length: 0, Offset: 114}, fi)
 		default:
 			return true, fmt.Errorf("unknown field %s", fi.FieldName)
 		}
@@ -241,7 +241,7 @@ func (ts *TablesTestSuite) TestLayout_Ok() {
 		require.NoError(t, err)
 
 		assert.Equal(t, "id int64, name varchar(25), age int8", layout.Schema.String())
-		assert.EqualValues(t, 114, layout.SlotSize)
+		assert.EqualValues(t, 115, layout.SlotSize)
 	}
 }
 
@@ -13,6 +13,9 @@ const (
 	StmtCreateTable
 	StmtCreateIndex
 	StmtCreateView
+	StmtSavepoint
+	StmtRollbackToSavepoint
+	StmtReleaseSavepoint
 )
 
 type (
@@ -30,6 +33,9 @@ var statementsConstructors = []struct {
 	{stmtType: StmtCreateTable, creator: func(q string) (Statement, error) { return NewSQLCreateTableStatement(q) }},
 	{stmtType: StmtCreateIndex, creator: func(q string) (Statement, error) { return NewSQLCreateIndexStatement(q) }},
 	{stmtType: StmtCreateView, creator: func(q string) (Statement, error) { return NewSQLCreateViewStatement(q) }},
+	{stmtType: StmtSavepoint, creator: func(q string) (Statement, error) { return NewSQLSavepointStatement(q) }},
+	{stmtType: StmtRollbackToSavepoint, creator: func(q string) (Statement, error) { return NewSQLRollbackToSavepointStatement(q) }},
+	{stmtType: StmtReleaseSavepoint, creator: func(q string) (Statement, error) { return NewSQLReleaseSavepointStatement(q) }},
 }
 
 func ParseQuery(q string) (StmtType, Statement, error) {
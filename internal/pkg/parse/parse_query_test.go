@@ -52,6 +52,18 @@ func (ts *ParseQueryTestSuite) TestParseQuery_Ok() {
 			query:    "create view view1 as select f1 from table1",
 			stmtType: parse.StmtCreateView,
 		},
+		{
+			query:    "savepoint sp1",
+			stmtType: parse.StmtSavepoint,
+		},
+		{
+			query:    "rollback to savepoint sp1",
+			stmtType: parse.StmtRollbackToSavepoint,
+		},
+		{
+			query:    "release savepoint sp1",
+			stmtType: parse.StmtReleaseSavepoint,
+		},
 	}
 
 	for _, tc := range tt {
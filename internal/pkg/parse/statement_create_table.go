@@ -10,11 +10,17 @@ type CreateTableStatement interface {
 
 	TableName() string
 	Schema() records.Schema
+	// Compression — алгоритм сжатия страниц из with (compression = '...'). Пустая строка — не задан
+	Compression() string
 }
 
+// CompressionOption — параметр таблицы с алгоритмом сжатия страниц
+const CompressionOption = "compression"
+
 type SQLCreateTableStatement struct {
-	tableName string
-	schema    records.Schema
+	tableName   string
+	schema      records.Schema
+	compression string
 }
 
 func NewSQLCreateTableStatement(q string) (*SQLCreateTableStatement, error) {
@@ -37,6 +43,10 @@ func (s SQLCreateTableStatement) String() string {
 
 	q := "create table " + s.TableName() + " (" + s.Schema().String() + ")"
 
+	if s.compression != "" {
+		q += " with (" + CompressionOption + " = '" + s.compression + "')"
+	}
+
 	return q
 }
 
@@ -48,11 +58,16 @@ func (s SQLCreateTableStatement) Schema() records.Schema {
 	return s.schema
 }
 
+func (s SQLCreateTableStatement) Compression() string {
+	return s.compression
+}
+
 func (s *SQLCreateTableStatement) Parse(lex Lexer) error {
 	var err error
 
 	s.tableName = ""
 	s.schema = records.NewSchema()
+	s.compression = ""
 
 	if err = lex.EatKeyword("create"); err != nil {
 		return ErrInvalidStatement
@@ -84,6 +99,52 @@ func (s *SQLCreateTableStatement) Parse(lex Lexer) error {
 		return err
 	}
 
+	if ok, _ := lex.MatchKeyword("with"); ok {
+		if err = s.parseOptions(lex); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseOptions разбирает параметры таблицы: with (compression = 'zstd')
+func (s *SQLCreateTableStatement) parseOptions(lex Lexer) error {
+	var err error
+
+	if err = lex.EatKeyword("with"); err != nil {
+		return err
+	}
+
+	if err = lex.EatDelim("("); err != nil {
+		return err
+	}
+
+	option, err := lex.EatID()
+	if err != nil {
+		return err
+	}
+
+	if option != CompressionOption {
+		return lex.WrapLexerError(ErrBadSyntax)
+	}
+
+	if err = lex.EatDelim("="); err != nil {
+		return err
+	}
+
+	if s.compression, err = lex.EatStringConstant(); err != nil {
+		return err
+	}
+
+	if s.compression == "" {
+		return lex.WrapLexerError(ErrBadSyntax)
+	}
+
+	if err = lex.EatDelim(")"); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -117,6 +178,10 @@ func (s SQLCreateTableStatement) parseSchema(lex Lexer) (records.Schema, error)
 		}
 
 		schema.AddStringField(fieldName, length)
+	case lex.EatKeyword("text") == nil:
+		schema.AddTextField(fieldName)
+	case lex.EatKeyword("blob") == nil:
+		schema.AddBlobField(fieldName)
 	default:
 		return schema, lex.WrapLexerError(ErrBadSyntax)
 	}
@@ -33,6 +33,14 @@ func (ts *SQLCreateTableStatementTestSuite) TestStatement_Ok() {
 			query:  "create table table1 (id int64)",
 			parsed: "create table table1 (id int64)",
 		},
+		{
+			query:  "create table table1 (id int64) with ( compression = 'zstd' )",
+			parsed: "create table table1 (id int64) with (compression = 'zstd')",
+		},
+		{
+			query:  "create table table1 (id int64, body text, data blob)",
+			parsed: "create table table1 (id int64, body text, data blob)",
+		},
 	}
 
 	for _, tc := range tt {
@@ -120,6 +128,26 @@ func (ts *SQLCreateTableStatementTestSuite) TestStatement_Fail() {
 			query: "create table table1 (,,,)",
 			err:   parse.ErrBadSyntax,
 		},
+		{
+			query: "create table table1 (id int64) with",
+			err:   parse.ErrBadSyntax,
+		},
+		{
+			query: "create table table1 (id int64) with (fillfactor = '10')",
+			err:   parse.ErrBadSyntax,
+		},
+		{
+			query: "create table table1 (id int64) with (compression = zstd)",
+			err:   parse.ErrBadSyntax,
+		},
+		{
+			query: "create table table1 (id int64) with (compression = '')",
+			err:   parse.ErrBadSyntax,
+		},
+		{
+			query: "create table table1 (id int64) with (compression = 'zstd'",
+			err:   parse.ErrBadSyntax,
+		},
 	}
 
 	for _, tc := range tt {
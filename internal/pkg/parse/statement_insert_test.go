@@ -29,6 +29,10 @@ func (ts *SQLInsertStatementTestSuite) TestStatement_Ok() {
 			query:  "insert into table1 (field1, field_2, field3) values (124, 12345, 'test')",
 			parsed: "insert into table1 (field1, field_2, field3) values (124, 12345, 'test')",
 		},
+		{
+			query:  "insert into table1 (field1, field_2) values (NULL, null)",
+			parsed: "insert into table1 (field1, field_2) values (null, null)",
+		},
 	}
 
 	for _, tc := range tt {
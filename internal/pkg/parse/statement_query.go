@@ -12,12 +12,14 @@ type SelectStatement interface {
 	Fields() FieldsList
 	Tables() TablesList
 	Pred() scan.Predicate
+	Locking() scan.LockingClause
 }
 
 type SQLSelectStatement struct {
-	fields FieldsList
-	tables TablesList
-	pred   scan.Predicate
+	fields  FieldsList
+	tables  TablesList
+	pred    scan.Predicate
+	locking scan.LockingClause
 }
 
 func NewSQLSelectStatement(q string) (*SQLSelectStatement, error) {
@@ -44,6 +46,10 @@ func (s SQLSelectStatement) String() string {
 		q += " where " + pred
 	}
 
+	if locking := s.locking.String(); locking != "" {
+		q += " " + locking
+	}
+
 	return q
 }
 
@@ -63,12 +69,17 @@ func (s SQLSelectStatement) Pred() scan.Predicate {
 	return s.pred
 }
 
+func (s SQLSelectStatement) Locking() scan.LockingClause {
+	return s.locking
+}
+
 func (s *SQLSelectStatement) Parse(lex Lexer) error {
 	var err error
 
 	s.fields = nil
 	s.tables = nil
 	s.pred = nil
+	s.locking = scan.LockingClause{}
 
 	if err = lex.EatKeyword("select"); err != nil {
 		return ErrInvalidStatement
@@ -103,5 +114,20 @@ func (s *SQLSelectStatement) Parse(lex Lexer) error {
 		}
 	}
 
+	switch ok, err := lex.MatchKeyword("for"); {
+	case errors.Is(err, ErrEOF):
+	case ok:
+		_ = lex.EatKeyword("for")
+
+		if s.locking, err = parseLockingClause(lex); err != nil {
+			return err
+		}
+	}
+
+	// После условия и блокировки в запросе ничего быть не может
+	if !lex.EOF() {
+		return lex.WrapLexerError(ErrBadSyntax)
+	}
+
 	return nil
 }
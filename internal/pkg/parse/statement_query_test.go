@@ -4,8 +4,10 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/parse"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
 )
 
 type SQLSelectStatementTestSuite struct {
@@ -33,6 +35,26 @@ func (ts *SQLSelectStatementTestSuite) TestStatement_Ok() {
 			query:  "select one, two, three from table1 where 1=1 and field1=field2 and field1=125 and field2=12345 and field3='value'",
 			parsed: "select one, two, three from table1 where 1 = 1 and field1 = field2 and field1 = 125 and field2 = 12345 and field3 = 'value'",
 		},
+		{
+			query:  "select one from table1 for update",
+			parsed: "select one from table1 for update",
+		},
+		{
+			query:  "select one from table1 where one = 1 FOR SHARE",
+			parsed: "select one from table1 where one = 1 for share",
+		},
+		{
+			query:  "select one from table1 where one = 1 for update nowait",
+			parsed: "select one from table1 where one = 1 for update nowait",
+		},
+		{
+			query:  "select one from table1 for share skip locked",
+			parsed: "select one from table1 for share skip locked",
+		},
+		{
+			query:  "select one from table1 where one IS NULL and two is not null and three = null",
+			parsed: "select one from table1 where one is null and two is not null and three = null",
+		},
 	}
 
 	for _, tc := range tt {
@@ -104,6 +126,42 @@ func (ts *SQLSelectStatementTestSuite) TestStatement_Fail() {
 			query: "select one from table1 where 1=1 tail",
 			err:   parse.ErrBadSyntax,
 		},
+		{
+			query: "select one from table1 where one is",
+			err:   parse.ErrBadSyntax,
+		},
+		{
+			query: "select one from table1 where one is not 1",
+			err:   parse.ErrBadSyntax,
+		},
+		{
+			query: "select one from table1 where one is null null",
+			err:   parse.ErrBadSyntax,
+		},
+		{
+			query: "select one from table1 for",
+			err:   parse.ErrBadSyntax,
+		},
+		{
+			query: "select one from table1 for delete",
+			err:   parse.ErrBadSyntax,
+		},
+		{
+			query: "select one from table1 for update skip",
+			err:   parse.ErrBadSyntax,
+		},
+		{
+			query: "select one from table1 for update nowait skip locked",
+			err:   parse.ErrBadSyntax,
+		},
+		{
+			query: "select one from table1 where one = 1 garbage",
+			err:   parse.ErrBadSyntax,
+		},
+		{
+			query: "select one from table1 where one = 1 ,",
+			err:   parse.ErrBadSyntax,
+		},
 	}
 
 	for _, tc := range tt {
@@ -112,3 +170,54 @@ func (ts *SQLSelectStatementTestSuite) TestStatement_Fail() {
 		assert.ErrorIsf(t, err, tc.err, "no error for: %s", tc.query)
 	}
 }
+
+func (ts *SQLSelectStatementTestSuite) TestStatement_Locking() {
+	t := ts.T()
+
+	tt := []struct {
+		query   string
+		locking scan.LockingClause
+	}{
+		{
+			query:   "select one from table1",
+			locking: scan.LockingClause{},
+		},
+		{
+			query:   "select one from table1 for update",
+			locking: scan.LockingClause{Strength: scan.LockForUpdate, Wait: scan.LockWait},
+		},
+		{
+			query:   "select one from table1 for share nowait",
+			locking: scan.LockingClause{Strength: scan.LockForShare, Wait: scan.LockNoWait},
+		},
+		{
+			query:   "select one from table1 where one = 1 for update skip locked",
+			locking: scan.LockingClause{Strength: scan.LockForUpdate, Wait: scan.LockSkipLocked},
+		},
+	}
+
+	for _, tc := range tt {
+		sut, err := parse.NewSQLSelectStatement(tc.query)
+		require.NoError(t, err, tc.query)
+
+		assert.Equal(t, tc.locking, sut.Locking(), tc.query)
+	}
+}
+
+func (ts *SQLSelectStatementTestSuite) TestParse_TrailingTokens() {
+	t := ts.T()
+
+	// Лишние токены после запроса — ошибка самого разбора, а не только NewSQLSelectStatement
+	for _, query := range []string{
+		"select one from table1 garbage",
+		"select one from table1 where one = 1 garbage",
+		"select one from table1 where one = 1 for update garbage",
+	} {
+		stmt := new(parse.SQLSelectStatement)
+
+		assert.ErrorIsf(t, stmt.Parse(parse.NewSQLLexer(query)), parse.ErrBadSyntax, "no error for: %s", query)
+	}
+
+	stmt := new(parse.SQLSelectStatement)
+	assert.NoError(t, stmt.Parse(parse.NewSQLLexer("select one from table1 where one = 1 for update")))
+}
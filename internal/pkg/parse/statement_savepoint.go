@@ -0,0 +1,158 @@
+package parse
+
+import (
+	"github.com/pkg/errors"
+)
+
+// SavepointStatement — запросы SAVEPOINT, ROLLBACK TO SAVEPOINT и RELEASE SAVEPOINT
+type SavepointStatement interface {
+	Statement
+
+	Name() string
+}
+
+// SQLSavepointStatement — SAVEPOINT name
+type SQLSavepointStatement struct {
+	name string
+}
+
+func NewSQLSavepointStatement(q string) (*SQLSavepointStatement, error) {
+	stmt := new(SQLSavepointStatement)
+
+	return stmt, parseSavepointStatement(q, stmt)
+}
+
+func (s SQLSavepointStatement) String() string {
+	if s.name == "" {
+		return ""
+	}
+
+	return "savepoint " + s.name
+}
+
+func (s SQLSavepointStatement) Name() string {
+	return s.name
+}
+
+func (s *SQLSavepointStatement) Parse(lex Lexer) error {
+	s.name = ""
+
+	if err := lex.EatKeyword("savepoint"); err != nil {
+		return ErrInvalidStatement
+	}
+
+	name, err := lex.EatID()
+	if err != nil {
+		return err
+	}
+
+	s.name = name
+
+	return nil
+}
+
+// SQLRollbackToSavepointStatement — ROLLBACK TO [SAVEPOINT] name
+type SQLRollbackToSavepointStatement struct {
+	name string
+}
+
+func NewSQLRollbackToSavepointStatement(q string) (*SQLRollbackToSavepointStatement, error) {
+	stmt := new(SQLRollbackToSavepointStatement)
+
+	return stmt, parseSavepointStatement(q, stmt)
+}
+
+func (s SQLRollbackToSavepointStatement) String() string {
+	if s.name == "" {
+		return ""
+	}
+
+	return "rollback to savepoint " + s.name
+}
+
+func (s SQLRollbackToSavepointStatement) Name() string {
+	return s.name
+}
+
+func (s *SQLRollbackToSavepointStatement) Parse(lex Lexer) error {
+	s.name = ""
+
+	if err := lex.EatKeyword("rollback"); err != nil {
+		return ErrInvalidStatement
+	}
+
+	// Просто ROLLBACK — это конец транзакции, а не откат к точке сохранения
+	if err := lex.EatKeyword("to"); err != nil {
+		return ErrInvalidStatement
+	}
+
+	name, err := parseSavepointName(lex)
+	if err != nil {
+		return err
+	}
+
+	s.name = name
+
+	return nil
+}
+
+// SQLReleaseSavepointStatement — RELEASE [SAVEPOINT] name
+type SQLReleaseSavepointStatement struct {
+	name string
+}
+
+func NewSQLReleaseSavepointStatement(q string) (*SQLReleaseSavepointStatement, error) {
+	stmt := new(SQLReleaseSavepointStatement)
+
+	return stmt, parseSavepointStatement(q, stmt)
+}
+
+func (s SQLReleaseSavepointStatement) String() string {
+	if s.name == "" {
+		return ""
+	}
+
+	return "release savepoint " + s.name
+}
+
+func (s SQLReleaseSavepointStatement) Name() string {
+	return s.name
+}
+
+func (s *SQLReleaseSavepointStatement) Parse(lex Lexer) error {
+	s.name = ""
+
+	if err := lex.EatKeyword("release"); err != nil {
+		return ErrInvalidStatement
+	}
+
+	name, err := parseSavepointName(lex)
+	if err != nil {
+		return err
+	}
+
+	s.name = name
+
+	return nil
+}
+
+func parseSavepointStatement(q string, stmt Statement) error {
+	lex := NewSQLLexer(q)
+
+	err := stmt.Parse(lex)
+
+	if errors.Is(err, ErrEOF) || (err == nil && !lex.EOF()) {
+		return lex.WrapLexerError(ErrBadSyntax)
+	}
+
+	return err
+}
+
+// parseSavepointName разбирает [SAVEPOINT] name
+func parseSavepointName(lex Lexer) (string, error) {
+	if ok, _ := lex.MatchKeyword("savepoint"); ok {
+		_ = lex.EatKeyword("savepoint")
+	}
+
+	return lex.EatID()
+}
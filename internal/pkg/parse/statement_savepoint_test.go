@@ -0,0 +1,133 @@
+package parse_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/parse"
+)
+
+type SQLSavepointStatementTestSuite struct {
+	suite.Suite
+}
+
+func TestSQLSavepointStatementTestSuite(t *testing.T) {
+	suite.Run(t, new(SQLSavepointStatementTestSuite))
+}
+
+var (
+	_ parse.SavepointStatement = &parse.SQLSavepointStatement{}
+	_ parse.SavepointStatement = &parse.SQLRollbackToSavepointStatement{}
+	_ parse.SavepointStatement = &parse.SQLReleaseSavepointStatement{}
+)
+
+func (ts *SQLSavepointStatementTestSuite) TestStatement_Ok() {
+	t := ts.T()
+
+	tt := []struct {
+		query  string
+		parsed string
+		name   string
+		new    func(q string) (parse.SavepointStatement, error)
+	}{
+		{
+			query:  "savepoint sp1",
+			parsed: "savepoint sp1",
+			name:   "sp1",
+			new:    func(q string) (parse.SavepointStatement, error) { return parse.NewSQLSavepointStatement(q) },
+		},
+		{
+			query:  "SAVEPOINT Before_Update",
+			parsed: "savepoint before_update",
+			name:   "before_update",
+			new:    func(q string) (parse.SavepointStatement, error) { return parse.NewSQLSavepointStatement(q) },
+		},
+		{
+			query:  "rollback to savepoint sp1",
+			parsed: "rollback to savepoint sp1",
+			name:   "sp1",
+			new:    func(q string) (parse.SavepointStatement, error) { return parse.NewSQLRollbackToSavepointStatement(q) },
+		},
+		{
+			query:  "rollback to sp1",
+			parsed: "rollback to savepoint sp1",
+			name:   "sp1",
+			new:    func(q string) (parse.SavepointStatement, error) { return parse.NewSQLRollbackToSavepointStatement(q) },
+		},
+		{
+			query:  "release savepoint sp1",
+			parsed: "release savepoint sp1",
+			name:   "sp1",
+			new:    func(q string) (parse.SavepointStatement, error) { return parse.NewSQLReleaseSavepointStatement(q) },
+		},
+		{
+			query:  "release sp1",
+			parsed: "release savepoint sp1",
+			name:   "sp1",
+			new:    func(q string) (parse.SavepointStatement, error) { return parse.NewSQLReleaseSavepointStatement(q) },
+		},
+	}
+
+	for _, tc := range tt {
+		sut, err := tc.new(tc.query)
+		assert.NoErrorf(t, err, "error: %s for: %s", err, tc.query)
+
+		if err == nil {
+			assert.Equal(t, tc.parsed, sut.String())
+			assert.Equal(t, tc.name, sut.Name())
+		}
+	}
+}
+
+func (ts *SQLSavepointStatementTestSuite) TestStatement_Fail() {
+	t := ts.T()
+
+	tt := []struct {
+		query string
+		err   error
+		new   func(q string) (parse.SavepointStatement, error)
+	}{
+		{
+			query: "select field from table1",
+			err:   parse.ErrInvalidStatement,
+			new:   func(q string) (parse.SavepointStatement, error) { return parse.NewSQLSavepointStatement(q) },
+		},
+		{
+			query: "savepoint",
+			err:   parse.ErrBadSyntax,
+			new:   func(q string) (parse.SavepointStatement, error) { return parse.NewSQLSavepointStatement(q) },
+		},
+		{
+			query: "savepoint sp1 sp2",
+			err:   parse.ErrBadSyntax,
+			new:   func(q string) (parse.SavepointStatement, error) { return parse.NewSQLSavepointStatement(q) },
+		},
+		{
+			query: "rollback",
+			err:   parse.ErrInvalidStatement,
+			new:   func(q string) (parse.SavepointStatement, error) { return parse.NewSQLRollbackToSavepointStatement(q) },
+		},
+		{
+			query: "rollback to savepoint",
+			err:   parse.ErrBadSyntax,
+			new:   func(q string) (parse.SavepointStatement, error) { return parse.NewSQLRollbackToSavepointStatement(q) },
+		},
+		{
+			query: "release",
+			err:   parse.ErrBadSyntax,
+			new:   func(q string) (parse.SavepointStatement, error) { return parse.NewSQLReleaseSavepointStatement(q) },
+		},
+		{
+			query: "release savepoint 'sp1'",
+			err:   parse.ErrBadSyntax,
+			new:   func(q string) (parse.SavepointStatement, error) { return parse.NewSQLReleaseSavepointStatement(q) },
+		},
+	}
+
+	for _, tc := range tt {
+		_, err := tc.new(tc.query)
+
+		assert.ErrorIsf(t, err, tc.err, "no error for: %s", tc.query)
+	}
+}
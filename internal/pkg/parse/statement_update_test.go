@@ -33,6 +33,10 @@ func (ts *SQLUpdateStatementTestSuite) TestStatement_Ok() {
 			query:  "update table1 set field1 = 123, field2 = 12345, field3 = 'value' where 1=1 and field1=field2 and field1=125 and field2=12345 and field3='value'",
 			parsed: "update table1 set field1 = 123, field2 = 12345, field3 = 'value' where 1 = 1 and field1 = field2 and field1 = 125 and field2 = 12345 and field3 = 'value'",
 		},
+		{
+			query:  "update table1 set field1 = null where field2 is not null",
+			parsed: "update table1 set field1 = null where field2 is not null",
+		},
 	}
 
 	for _, tc := range tt {
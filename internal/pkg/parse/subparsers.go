@@ -23,6 +23,10 @@ func parseConstant(lex Lexer) (scan.Constant, error) {
 		return scan.NewStringConstant(value), nil
 	}
 
+	if lex.EatKeyword("null") == nil {
+		return scan.NewNullConstant(), nil
+	}
+
 	return nil, lex.WrapLexerError(ErrBadSyntax)
 }
 
@@ -47,6 +51,10 @@ func parseAndTerm(lex Lexer) (scan.Term, error) {
 		return nil, err
 	}
 
+	if lex.EatKeyword("is") == nil {
+		return parseIsNullTerm(lex, lhs)
+	}
+
 	err = lex.EatDelim("=")
 	if err != nil {
 		return nil, err
@@ -60,6 +68,21 @@ func parseAndTerm(lex Lexer) (scan.Term, error) {
 	return scan.NewEqualTerm(lhs, rhs), nil
 }
 
+// parseIsNullTerm разбирает хвост условия после IS: [NOT] NULL
+func parseIsNullTerm(lex Lexer, expr scan.Expression) (scan.Term, error) {
+	not := lex.EatKeyword("not") == nil
+
+	if err := lex.EatKeyword("null"); err != nil {
+		return nil, err
+	}
+
+	if not {
+		return scan.NewIsNotNullTerm(expr), nil
+	}
+
+	return scan.NewIsNullTerm(expr), nil
+}
+
 func parsePredicate(lex Lexer) (scan.Predicate, error) {
 	term, err := parseAndTerm(lex)
 	if err != nil {
@@ -82,6 +105,33 @@ func parsePredicate(lex Lexer) (scan.Predicate, error) {
 	return pred, nil
 }
 
+// parseLockingClause разбирает хвост после FOR: UPDATE | SHARE [NOWAIT | SKIP LOCKED]
+func parseLockingClause(lex Lexer) (scan.LockingClause, error) {
+	var lc scan.LockingClause
+
+	switch {
+	case lex.EatKeyword("update") == nil:
+		lc.Strength = scan.LockForUpdate
+	case lex.EatKeyword("share") == nil:
+		lc.Strength = scan.LockForShare
+	default:
+		return lc, lex.WrapLexerError(ErrBadSyntax)
+	}
+
+	switch {
+	case lex.EatKeyword("nowait") == nil:
+		lc.Wait = scan.LockNoWait
+	case lex.EatKeyword("skip") == nil:
+		if err := lex.EatKeyword("locked"); err != nil {
+			return lc, err
+		}
+
+		lc.Wait = scan.LockSkipLocked
+	}
+
+	return lc, nil
+}
+
 type FieldsList []string
 
 func (f FieldsList) String() string {
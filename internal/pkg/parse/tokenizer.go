@@ -27,27 +27,42 @@ const (
 )
 
 var reservedIdentifiers = map[string]tokenType{
-	"select":  TokKeyword,
-	"from":    TokKeyword,
-	"where":   TokKeyword,
-	"and":     TokKeyword,
-	"insert":  TokKeyword,
-	"into":    TokKeyword,
-	"values":  TokKeyword,
-	"delete":  TokKeyword,
-	"update":  TokKeyword,
-	"set":     TokKeyword,
-	"create":  TokKeyword,
-	"table":   TokKeyword,
-	"varchar": TokKeyword,
-	"int":     TokKeyword,
-	"int64":   TokKeyword,
-	"int8":    TokKeyword,
-	"view":    TokKeyword,
-	"as":      TokKeyword,
-	"index":   TokKeyword,
-	"on":      TokKeyword,
-	"using":   TokKeyword,
+	"select":    TokKeyword,
+	"from":      TokKeyword,
+	"where":     TokKeyword,
+	"and":       TokKeyword,
+	"insert":    TokKeyword,
+	"into":      TokKeyword,
+	"values":    TokKeyword,
+	"delete":    TokKeyword,
+	"update":    TokKeyword,
+	"set":       TokKeyword,
+	"create":    TokKeyword,
+	"table":     TokKeyword,
+	"varchar":   TokKeyword,
+	"int":       TokKeyword,
+	"int64":     TokKeyword,
+	"int8":      TokKeyword,
+	"text":      TokKeyword,
+	"blob":      TokKeyword,
+	"view":      TokKeyword,
+	"as":        TokKeyword,
+	"index":     TokKeyword,
+	"on":        TokKeyword,
+	"using":     TokKeyword,
+	"for":       TokKeyword,
+	"share":     TokKeyword,
+	"nowait":    TokKeyword,
+	"skip":      TokKeyword,
+	"locked":    TokKeyword,
+	"savepoint": TokKeyword,
+	"rollback":  TokKeyword,
+	"to":        TokKeyword,
+	"release":   TokKeyword,
+	"with":      TokKeyword,
+	"null":      TokKeyword,
+	"is":        TokKeyword,
+	"not":       TokKeyword,
 }
 
 // Token описывает токен из потока токенов
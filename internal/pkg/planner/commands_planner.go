@@ -17,17 +17,31 @@ type sqlCommandsPlannerMetadataManager interface {
 }
 
 type SQLCommandsPlanner struct {
-	mdm sqlCommandsPlannerMetadataManager
+	mdm                sqlCommandsPlannerMetadataManager
+	defaultCompression string
 }
 
-func NewSQLCommandsPlanner(mdm sqlCommandsPlannerMetadataManager) *SQLCommandsPlanner {
+type SQLCommandsPlannerOpt func(*SQLCommandsPlanner)
+
+func NewSQLCommandsPlanner(mdm sqlCommandsPlannerMetadataManager, opts ...SQLCommandsPlannerOpt) *SQLCommandsPlanner {
 	p := &SQLCommandsPlanner{
 		mdm: mdm,
 	}
 
+	for _, opt := range opts {
+		opt(p)
+	}
+
 	return p
 }
 
+// WithDefaultCompression задает алгоритм сжатия страниц для таблиц, созданных без with (compression = ...)
+func WithDefaultCompression(codecName string) SQLCommandsPlannerOpt {
+	return func(p *SQLCommandsPlanner) {
+		p.defaultCompression = codecName
+	}
+}
+
 func (p *SQLCommandsPlanner) ExecuteInsert(stmt parse.InsertStatement, trx scan.TRXInt) (int64, error) {
 	var (
 		plan Plan
@@ -163,7 +177,7 @@ func (p *SQLCommandsPlanner) ExecuteUpdate(stmt parse.UpdateStatement, trx scan.
 }
 
 func (p *SQLCommandsPlanner) ExecuteCreateTable(stmt parse.CreateTableStatement, trx scan.TRXInt) (int64, error) {
-	if err := p.mdm.CreateTable(stmt.TableName(), stmt.Schema(), trx); err != nil {
+	if err := CreateTable(p.mdm, stmt, p.defaultCompression, trx); err != nil {
 		return 0, errors.WithMessage(ErrExecuteError, err.Error())
 	}
 
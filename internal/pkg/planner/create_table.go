@@ -0,0 +1,55 @@
+package planner
+
+import (
+	"github.com/pkg/errors"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/parse"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/storage"
+)
+
+type tableCreator interface {
+	CreateTable(tableName string, schema records.Schema, trx scan.TRXInt) error
+}
+
+// compressionTRX — транзакция, которая умеет включать сжатие страниц файла
+type compressionTRX interface {
+	SetCompression(filename string, codecName string) error
+}
+
+// CreateTable создает таблицу и включает сжатие ее страниц.
+// Алгоритм из with (compression = ...) важнее defaultCompression
+func CreateTable(mdm tableCreator, stmt parse.CreateTableStatement, defaultCompression string, trx scan.TRXInt) error {
+	compression := stmt.Compression()
+	if compression == "" {
+		compression = defaultCompression
+	}
+
+	if err := storage.ValidCompression(compression); err != nil {
+		return err
+	}
+
+	if err := mdm.CreateTable(stmt.TableName(), stmt.Schema(), trx); err != nil {
+		return err
+	}
+
+	if compression == "" || compression == storage.CompressionNone {
+		return nil
+	}
+
+	ctrx, ok := trx.(compressionTRX)
+	if !ok {
+		return errors.Errorf("transaction doesn't support compression of table %s", stmt.TableName())
+	}
+
+	if err := ctrx.SetCompression(scan.TableFilename(stmt.TableName()), compression); err != nil {
+		return err
+	}
+
+	// Длинные значения TEXT и BLOB сжимаются вместе с таблицей
+	if stmt.Schema().HasLargeFields() {
+		return ctrx.SetCompression(scan.OverflowFilename(stmt.TableName()), compression)
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package planner
 
 import (
+	"github.com/pkg/errors"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/parse"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
 )
@@ -23,6 +24,13 @@ type Planner interface {
 	ExecuteCommand(cmd string, trx scan.TRXInt) (rows int64, err error)
 }
 
+// savepointsTRX — транзакция с точками сохранения
+type savepointsTRX interface {
+	Savepoint(name string) error
+	RollbackToSavepoint(name string) error
+	ReleaseSavepoint(name string) error
+}
+
 type SQLPlanner struct {
 	queryPlanner    QueryPlanner
 	commandsPlanner CommandsPlanner
@@ -75,7 +83,26 @@ func (p *SQLPlanner) ExecuteCommand(cmd string, trx scan.TRXInt) (int64, error)
 		return p.commandsPlanner.ExecuteCreateIndex(stmt.(parse.CreateIndexStatement), trx)
 	case parse.StmtCreateView:
 		return p.commandsPlanner.ExecuteCreateView(stmt.(parse.CreateViewStatement), trx)
+	case parse.StmtSavepoint, parse.StmtRollbackToSavepoint, parse.StmtReleaseSavepoint:
+		return 0, p.executeSavepoint(stmtType, stmt.(parse.SavepointStatement), trx)
 	}
 
 	return 0, parse.ErrInvalidStatement
 }
+
+func (p *SQLPlanner) executeSavepoint(stmtType parse.StmtType, stmt parse.SavepointStatement, trx scan.TRXInt) error {
+	spTRX, ok := trx.(savepointsTRX)
+	if !ok {
+		return errors.WithMessage(ErrExecuteError, "transaction does not support savepoints")
+	}
+
+	//nolint:exhaustive
+	switch stmtType {
+	case parse.StmtRollbackToSavepoint:
+		return spTRX.RollbackToSavepoint(stmt.Name())
+	case parse.StmtReleaseSavepoint:
+		return spTRX.ReleaseSavepoint(stmt.Name())
+	}
+
+	return spTRX.Savepoint(stmt.Name())
+}
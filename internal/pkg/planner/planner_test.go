@@ -193,6 +193,44 @@ func (ts *PlannerTestSuite) TestExecuteDelete() {
 	ts.requireRowsCount(cnt-cnt/10, sc)
 }
 
+func (ts *PlannerTestSuite) TestExecuteSavepoint() {
+	t := ts.T()
+
+	sut, trx, mdm, clean := ts.newSUT()
+	defer clean()
+
+	require.NoError(t, mdm.CreateTable("table1", ts.testLayout().Schema, trx))
+
+	sc, err := scan.NewTableScan(trx, "table1", ts.testLayout())
+	require.NoError(t, err)
+
+	defer sc.Close()
+
+	_, err = sut.ExecuteCommand("insert into table1 (id, age) values (1, 10)", trx)
+	require.NoError(t, err)
+
+	_, err = sut.ExecuteCommand("savepoint sp1", trx)
+	require.NoError(t, err)
+
+	_, err = sut.ExecuteCommand("insert into table1 (id, age) values (2, 20)", trx)
+	require.NoError(t, err)
+
+	ts.requireRowsCount(2, sc)
+
+	_, err = sut.ExecuteCommand("rollback to savepoint sp1", trx)
+	require.NoError(t, err)
+
+	ts.requireRowsCount(1, sc)
+
+	_, err = sut.ExecuteCommand("release savepoint sp1", trx)
+	require.NoError(t, err)
+
+	_, err = sut.ExecuteCommand("rollback to sp1", trx)
+	require.ErrorIs(t, err, transaction.ErrSavepointNotFound)
+
+	require.NoError(t, trx.Commit())
+}
+
 func (ts *PlannerTestSuite) TestExecuteUpdate() {
 	t := ts.T()
 
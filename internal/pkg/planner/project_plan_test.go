@@ -12,6 +12,7 @@ import (
 	"github.com/unhandled-exception/sophiadb/internal/pkg/planner"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/transaction"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
 )
 
 var _ planner.Plan = &planner.ProjectPlan{}
@@ -75,7 +76,11 @@ func (ts *ProjectPlanTestSuite) TestPlan() {
 	assert.Equal(t, "choose id, age from (scan table data)", sut.String())
 	assert.Equal(t, "id int64, age int8", sut.Schema().String())
 	assert.EqualValues(t, cnt, sut.Records())
-	assert.EqualValues(t, blocks, sut.BlocksAccessed())
+	// Короткие строки занимают меньше места, чем запись наибольшей длины, поэтому блоков меньше, чем blocks
+	fLen, err := trx.Size(scan.TableFilename(testDataTable))
+	require.NoError(t, err)
+	assert.Less(t, fLen, types.BlockID(blocks))
+	assert.EqualValues(t, fLen, sut.BlocksAccessed())
 
 	c, ok := sut.DistinctValues("age")
 	assert.True(t, ok)
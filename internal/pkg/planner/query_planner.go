@@ -15,19 +15,39 @@ type sqlQueryPlannerMetadataManager interface {
 }
 
 type SQLQueryPlanner struct {
-	mdm sqlQueryPlannerMetadataManager
+	mdm           sqlQueryPlannerMetadataManager
+	virtualTables map[string]VirtualTable
 }
 
-func NewSQLQueryPlanner(mdm sqlQueryPlannerMetadataManager) *SQLQueryPlanner {
+type SQLQueryPlannerOpt func(*SQLQueryPlanner)
+
+func NewSQLQueryPlanner(mdm sqlQueryPlannerMetadataManager, opts ...SQLQueryPlannerOpt) *SQLQueryPlanner {
 	p := &SQLQueryPlanner{
-		mdm: mdm,
+		mdm:           mdm,
+		virtualTables: make(map[string]VirtualTable),
+	}
+
+	for _, opt := range opts {
+		opt(p)
 	}
 
 	return p
 }
 
+// WithVirtualTable регистрирует виртуальную таблицу. Виртуальные таблицы закрывают одноименные таблицы и представления
+func WithVirtualTable(tableName string, vt VirtualTable) SQLQueryPlannerOpt {
+	return func(p *SQLQueryPlanner) {
+		p.virtualTables[tableName] = vt
+	}
+}
+
 func (p *SQLQueryPlanner) CreatePlan(stmt parse.SelectStatement, trx scan.TRXInt) (Plan, error) {
-	plan, err := p.makeTablesPlan(stmt, trx)
+	return p.createPlan(stmt, stmt.Locking(), trx)
+}
+
+// createPlan строит план запроса. Блокирующее чтение из внешнего запроса распространяется на таблицы представлений
+func (p *SQLQueryPlanner) createPlan(stmt parse.SelectStatement, locking scan.LockingClause, trx scan.TRXInt) (Plan, error) {
+	plan, err := p.makeTablesPlan(stmt, locking, trx)
 	if err != nil {
 		return nil, err
 	}
@@ -45,16 +65,22 @@ func (p *SQLQueryPlanner) CreatePlan(stmt parse.SelectStatement, trx scan.TRXInt
 	return plan, nil
 }
 
-func (p *SQLQueryPlanner) makeTablesPlan(stmt parse.SelectStatement, trx scan.TRXInt) (Plan, error) {
+func (p *SQLQueryPlanner) makeTablesPlan(stmt parse.SelectStatement, locking scan.LockingClause, trx scan.TRXInt) (Plan, error) {
 	plans := make([]Plan, len(stmt.Tables()))
 
 	for i, table := range stmt.Tables() {
+		if vt, ok := p.virtualTables[table]; ok {
+			plans[i] = NewVirtualTablePlan(table, vt)
+
+			continue
+		}
+
 		switch viewDef, err := p.mdm.ViewDef(table, trx); {
 		case errors.Is(err, metadata.ErrViewNotFound):
 		case err != nil:
 			return nil, err
 		default:
-			vp, err := p.makeViewPlan(viewDef, trx)
+			vp, err := p.makeViewPlan(viewDef, locking, trx)
 			if err != nil {
 				return nil, err
 			}
@@ -66,7 +92,7 @@ func (p *SQLQueryPlanner) makeTablesPlan(stmt parse.SelectStatement, trx scan.TR
 
 		var err error
 
-		plans[i], err = NewTablePlan(trx, table, p.mdm)
+		plans[i], err = NewTablePlan(trx, table, p.mdm, WithLocking(locking))
 		if err != nil {
 			return nil, err
 		}
@@ -84,7 +110,7 @@ func (p *SQLQueryPlanner) makeTablesPlan(stmt parse.SelectStatement, trx scan.TR
 	return plan, nil
 }
 
-func (p *SQLQueryPlanner) makeViewPlan(viewDef string, trx scan.TRXInt) (Plan, error) {
+func (p *SQLQueryPlanner) makeViewPlan(viewDef string, locking scan.LockingClause, trx scan.TRXInt) (Plan, error) {
 	stmtType, stmt, err := parse.ParseQuery(viewDef)
 
 	switch {
@@ -94,5 +120,5 @@ func (p *SQLQueryPlanner) makeViewPlan(viewDef string, trx scan.TRXInt) (Plan, e
 		return nil, err
 	}
 
-	return p.CreatePlan(stmt.(parse.SelectStatement), trx) //nolint:forcetypeassert
+	return p.createPlan(stmt.(parse.SelectStatement), locking, trx) //nolint:forcetypeassert
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/unhandled-exception/sophiadb/internal/pkg/planner"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/transaction"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
 )
 
 var _ planner.Plan = &planner.SelectPlan{}
@@ -84,7 +85,11 @@ func (ts *SelectPlanTestSuite) TestPlan() {
 	assert.Equal(t, "select from (scan table data) where id = 777", sut.String())
 	assert.Equal(t, ts.testLayout().Schema.String(), sut.Schema().String())
 	assert.EqualValues(t, cnt, sut.Records())
-	assert.EqualValues(t, blocks, sut.BlocksAccessed())
+	// Короткие строки занимают меньше места, чем запись наибольшей длины, поэтому блоков меньше, чем blocks
+	fLen, err := trx.Size(scan.TableFilename(testDataTable))
+	require.NoError(t, err)
+	assert.Less(t, fLen, types.BlockID(blocks))
+	assert.EqualValues(t, fLen, sut.BlocksAccessed())
 
 	c, ok := sut.DistinctValues("age")
 	assert.True(t, ok)
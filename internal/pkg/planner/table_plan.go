@@ -14,19 +14,26 @@ type TablePlan struct {
 	tablename string
 	layout    records.Layout
 	stats     metadata.StatInfo
+	locking   scan.LockingClause
 }
 
+type TablePlanOpt func(*TablePlan)
+
 type tablePlanMetadataManager interface {
 	Layout(tableName string, trx scan.TRXInt) (records.Layout, error)
 	GetStatInfo(tableName string, layout records.Layout, trx scan.TRXInt) (metadata.StatInfo, error)
 }
 
-func NewTablePlan(trx scan.TRXInt, tableName string, md tablePlanMetadataManager) (*TablePlan, error) {
+func NewTablePlan(trx scan.TRXInt, tableName string, md tablePlanMetadataManager, opts ...TablePlanOpt) (*TablePlan, error) {
 	p := &TablePlan{
 		trx:       trx,
 		tablename: tableName,
 	}
 
+	for _, opt := range opts {
+		opt(p)
+	}
+
 	var err error
 
 	p.layout, err = md.Layout(tableName, trx)
@@ -42,8 +49,15 @@ func NewTablePlan(trx scan.TRXInt, tableName string, md tablePlanMetadataManager
 	return p, nil
 }
 
+// WithLocking — план для блокирующего чтения (SELECT ... FOR UPDATE | FOR SHARE)
+func WithLocking(lc scan.LockingClause) TablePlanOpt {
+	return func(p *TablePlan) {
+		p.locking = lc
+	}
+}
+
 func (p *TablePlan) Open() (scan.Scan, error) {
-	return scan.NewTableScan(p.trx, p.tablename, p.layout)
+	return scan.NewTableScan(p.trx, p.tablename, p.layout, scan.WithLocking(p.locking))
 }
 
 func (p *TablePlan) Schema() records.Schema {
@@ -63,5 +77,9 @@ func (p *TablePlan) DistinctValues(fieldName string) (int64, bool) {
 }
 
 func (p *TablePlan) String() string {
+	if locking := p.locking.String(); locking != "" {
+		return fmt.Sprintf("scan table %s %s", p.tablename, locking)
+	}
+
 	return fmt.Sprintf("scan table %s", p.tablename)
 }
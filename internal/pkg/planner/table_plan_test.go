@@ -13,6 +13,7 @@ import (
 	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/transaction"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
 )
 
 var _ planner.Plan = &planner.TablePlan{}
@@ -73,7 +74,11 @@ func (ts *TablePlanTestSuite) TestPlan() {
 	assert.Equal(t, "scan table data", sut.String())
 	assert.Equal(t, ts.testLayout().Schema.String(), sut.Schema().String())
 	assert.EqualValues(t, cnt, sut.Records())
-	assert.EqualValues(t, blocks, sut.BlocksAccessed())
+	// Короткие строки занимают меньше места, чем запись наибольшей длины, поэтому блоков меньше, чем blocks
+	fLen, err := trx.Size(scan.TableFilename(testDataTable))
+	require.NoError(t, err)
+	assert.Less(t, fLen, types.BlockID(blocks))
+	assert.EqualValues(t, fLen, sut.BlocksAccessed())
 
 	c, ok := sut.DistinctValues("age")
 	assert.True(t, ok)
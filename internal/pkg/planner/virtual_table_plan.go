@@ -0,0 +1,57 @@
+package planner
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
+)
+
+// VirtualTable — таблица, записи которой вычисляются при чтении, например системное представление sdb_locks
+type VirtualTable interface {
+	Schema() records.Schema
+	Rows() ([][]scan.Constant, error)
+}
+
+// VirtualTablePlan — план чтения виртуальной таблицы. Виртуальные таблицы только для чтения
+type VirtualTablePlan struct {
+	tablename string
+	vt        VirtualTable
+}
+
+func NewVirtualTablePlan(tableName string, vt VirtualTable) *VirtualTablePlan {
+	return &VirtualTablePlan{
+		tablename: tableName,
+		vt:        vt,
+	}
+}
+
+func (p *VirtualTablePlan) Open() (scan.Scan, error) {
+	rows, err := p.vt.Rows()
+	if err != nil {
+		return nil, errors.WithMessage(ErrFailedToCreatePlan, err.Error())
+	}
+
+	return scan.NewMemoryScan(p.vt.Schema(), rows), nil
+}
+
+func (p *VirtualTablePlan) Schema() records.Schema {
+	return p.vt.Schema()
+}
+
+func (p *VirtualTablePlan) BlocksAccessed() int64 {
+	return 0
+}
+
+func (p *VirtualTablePlan) Records() int64 {
+	return 1
+}
+
+func (p *VirtualTablePlan) DistinctValues(fieldName string) (int64, bool) {
+	return 1, false
+}
+
+func (p *VirtualTablePlan) String() string {
+	return fmt.Sprintf("scan virtual table %s", p.tablename)
+}
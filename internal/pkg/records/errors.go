@@ -6,4 +6,9 @@ var (
 	ErrRecordPage    = errors.New("record page error")
 	ErrSlotNotFound  = errors.Wrap(ErrRecordPage, "slot not found")
 	ErrFieldNotFound = errors.Wrap(ErrRecordPage, "field not found")
+	ErrFieldType     = errors.Wrap(ErrRecordPage, "wrong field type")
+	ErrValueTooLong  = errors.Wrap(ErrRecordPage, "value too long")
+	ErrBadRecord     = errors.Wrap(ErrRecordPage, "bad record")
+	ErrSlotForwarded = errors.Wrap(ErrRecordPage, "record moved to another block")
+	ErrNoSpace       = errors.Wrap(ErrRecordPage, "no space in page")
 )
@@ -6,6 +6,10 @@ import (
 	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
 )
 
+// Layout описывает запись таблицы. Записи на странице переменной длины, см. RecordPage,
+// поэтому SlotSize — наибольший размер записи вместе с байтом флага, а Offsets — смещения полей в записи наибольшего размера.
+// По ним оцениваются размеры таблиц и индексов.
+// Запись начинается с битовой карты NULL: бит i установлен, если i-е поле схемы не задано
 type Layout struct {
 	Schema   Schema
 	SlotSize uint32
@@ -18,7 +22,7 @@ func NewLayout(schema Schema) Layout {
 		Offsets: make(map[string]uint32, schema.Count()),
 	}
 
-	size := uint32(types.Int8Size)
+	size := types.Int8Size + l.NullsSize()
 	for _, name := range schema.Fields() {
 		l.Offsets[name] = size
 
@@ -42,3 +46,19 @@ func (l Layout) String() string {
 func (l Layout) Offset(name string) uint32 {
 	return l.Offsets[name]
 }
+
+// NullsSize — размер битовой карты NULL в байтах
+func (l Layout) NullsSize() uint32 {
+	return (uint32(l.Schema.Count()) + 7) / 8 //nolint:mnd
+}
+
+// NullBit возвращает байт битовой карты NULL и маску бита для поля
+func (l Layout) NullBit(name string) (uint32, byte, bool) {
+	for i, field := range l.Schema.Fields() {
+		if field == name {
+			return uint32(i) / 8, 1 << (i % 8), true //nolint:mnd
+		}
+	}
+
+	return 0, 0, false
+}
@@ -27,8 +27,35 @@ func (ts *LayoutTestSuite) TestCreateLayout() {
 
 	sut := records.NewLayout(schema)
 
-	assert.EqualValues(t, 1+8+(128*4+4)+(64*4+4)+8, sut.SlotSize)
-	assert.EqualValues(t, 1+8+(128*4+4), sut.Offset("job"))
+	assert.EqualValues(t, 1+1+8+(128*4+4)+(64*4+4)+8, sut.SlotSize)
+	assert.EqualValues(t, 1+1+8+(128*4+4), sut.Offset("job"))
 
-	assert.Equal(t, "schema: id int64, username varchar(128), job varchar(64), age int64, slot size: 793", sut.String())
+	assert.Equal(t, "schema: id int64, username varchar(128), job varchar(64), age int64, slot size: 794", sut.String())
+}
+
+func (ts *LayoutTestSuite) TestNullBitmap() {
+	t := ts.T()
+
+	schema := records.NewSchema()
+	for _, name := range []string{"f0", "f1", "f2", "f3", "f4", "f5", "f6", "f7", "f8"} {
+		schema.AddInt8Field(name)
+	}
+
+	sut := records.NewLayout(schema)
+
+	assert.EqualValues(t, 2, sut.NullsSize())
+	assert.EqualValues(t, 1+2+9, sut.SlotSize)
+
+	idx, mask, ok := sut.NullBit("f1")
+	assert.True(t, ok)
+	assert.EqualValues(t, 0, idx)
+	assert.EqualValues(t, 0b10, mask)
+
+	idx, mask, ok = sut.NullBit("f8")
+	assert.True(t, ok)
+	assert.EqualValues(t, 1, idx)
+	assert.EqualValues(t, 0b1, mask)
+
+	_, _, ok = sut.NullBit("unknown")
+	assert.False(t, ok)
 }
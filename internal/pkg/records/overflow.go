@@ -0,0 +1,369 @@
+package records
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+// Overflow — файл страниц переполнения таблицы. В нем лежат значения TEXT и BLOB, которые не поместились в запись.
+//
+// Значение занимает цепочку страниц [следующий блок][длина данных][данные], у последней страницы следующий блок — 0.
+// Блок 0 — заголовок файла с началом списка свободных страниц и границей занятых страниц. Страницы удаленных значений
+// попадают в этот список и переиспользуются. Все изменения журналируются, поэтому откат транзакции восстанавливает
+// и цепочки, и список, а страницы, которые транзакция добавила в файл, оказываются за границей и выделяются снова.
+// Заголовок блокируется на запись при каждом выделении и освобождении страниц, поэтому транзакции,
+// которые пишут длинные значения в одну таблицу, выполняются по очереди
+type Overflow struct {
+	TRX      overflowTRX
+	Filename string
+}
+
+const (
+	overflowHeaderBlock types.BlockID = 0
+	overflowEndOffset                 = types.Int32Size // Граница занятых страниц в заголовке, после начала списка свободных
+
+	overflowNextOffset = 0
+	overflowSizeOffset = types.Int32Size
+	overflowPageHeader = 2 * types.Int32Size //nolint:mnd
+
+	// Страница пишется кусками не больше этой доли блока, чтобы прежнее содержимое помещалось в запись журнала
+	overflowPutRatio = 4
+)
+
+func NewOverflow(trx overflowTRX, filename string) *Overflow {
+	return &Overflow{
+		TRX:      trx,
+		Filename: filename,
+	}
+}
+
+// Write кладет значение в новую цепочку страниц и возвращает ее первый блок
+func (o *Overflow) Write(value []byte) (types.BlockID, error) {
+	capacity := o.capacity()
+	pages := max((len(value)+int(capacity)-1)/int(capacity), 1)
+
+	blocks := make([]types.BlockID, pages)
+
+	for i := range blocks {
+		block, err := o.allocate()
+		if err != nil {
+			return 0, err
+		}
+
+		blocks[i] = block
+	}
+
+	for i, block := range blocks {
+		var next types.BlockID
+		if i+1 < len(blocks) {
+			next = blocks[i+1]
+		}
+
+		chunk := value[min(i*int(capacity), len(value)):min((i+1)*int(capacity), len(value))]
+
+		if err := o.writePage(block, next, chunk); err != nil {
+			return 0, err
+		}
+	}
+
+	return blocks[0], nil
+}
+
+// Read собирает значение длины length из цепочки, которая начинается с блока first
+func (o *Overflow) Read(first types.BlockID, length uint32) ([]byte, error) {
+	r := o.NewReader(first, length)
+
+	value := make([]byte, length)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return nil, err
+	}
+
+	// Дочитываем цепочку, чтобы убедиться, что за значением нет лишних страниц
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// NewReader возвращает читателя значения длины length из цепочки, которая начинается с блока first.
+// Страницы читаются по одной, когда до них доходит чтение
+func (o *Overflow) NewReader(first types.BlockID, length uint32) *OverflowReader {
+	return &OverflowReader{
+		overflow: o,
+		first:    first,
+		block:    first,
+		length:   length,
+		maxPages: length/o.capacity() + 1,
+	}
+}
+
+// OverflowReader читает значение из цепочки страниц переполнения. Цепочка проверяется так же, как в Overflow.Read:
+// лишние и недостающие страницы и байты — ошибка ErrBadRecord
+type OverflowReader struct {
+	overflow *Overflow
+
+	first    types.BlockID
+	block    types.BlockID
+	length   uint32
+	maxPages uint32
+
+	pages uint32
+	read  uint32
+	data  []byte
+	end   bool
+}
+
+func (r *OverflowReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		if r.end {
+			return 0, io.EOF
+		}
+
+		if err := r.nextPage(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+
+	return n, nil
+}
+
+// Len возвращает длину всего значения
+func (r *OverflowReader) Len() uint32 {
+	return r.length
+}
+
+func (r *OverflowReader) nextPage() error {
+	o := r.overflow
+
+	if r.block == overflowHeaderBlock || r.pages >= r.maxPages {
+		return errors.WithMessagef(ErrBadRecord, "%s: broken overflow chain at block %d", o.Filename, r.block)
+	}
+
+	next, data, err := o.readPage(r.block)
+	if err != nil {
+		return err
+	}
+
+	r.pages++
+	r.read += uint32(len(data))
+	r.data = data
+	r.block = next
+	r.end = next == 0
+
+	if r.read > r.length || (r.end && r.read != r.length) {
+		return errors.WithMessagef(ErrBadRecord, "%s: overflow chain from block %d has %d bytes, not %d",
+			o.Filename, r.first, r.read, r.length)
+	}
+
+	return nil
+}
+
+// Free отдает страницы цепочки, которая начинается с блока first, в список свободных страниц
+func (o *Overflow) Free(first types.BlockID) error {
+	if first == overflowHeaderBlock {
+		return nil
+	}
+
+	header := o.block(overflowHeaderBlock)
+
+	if err := o.TRX.XLock(header, false); err != nil {
+		return o.wrapError(err)
+	}
+
+	last := first
+
+	for {
+		raw, err := o.fetch(last, overflowNextOffset, types.Int32Size)
+		if err != nil {
+			return err
+		}
+
+		next := types.BlockID(byteOrder.Uint32(raw))
+		if next == 0 {
+			break
+		}
+
+		last = next
+	}
+
+	head, err := o.fetch(overflowHeaderBlock, 0, types.Int32Size)
+	if err != nil {
+		return err
+	}
+
+	if err := o.put(last, overflowNextOffset, head); err != nil {
+		return err
+	}
+
+	return o.put(overflowHeaderBlock, 0, byteOrder.AppendUint32(nil, uint32(first)))
+}
+
+// allocate берет страницу из списка свободных, а если он пуст — добавляет в файл новую
+func (o *Overflow) allocate() (types.BlockID, error) {
+	size, err := o.TRX.Size(o.Filename)
+	if err != nil {
+		return 0, o.wrapError(err)
+	}
+
+	if size == 0 {
+		if _, err = o.TRX.Append(o.Filename); err != nil {
+			return 0, o.wrapError(err)
+		}
+	}
+
+	if err = o.TRX.XLock(o.block(overflowHeaderBlock), false); err != nil {
+		return 0, o.wrapError(err)
+	}
+
+	raw, err := o.fetch(overflowHeaderBlock, 0, types.Int32Size)
+	if err != nil {
+		return 0, err
+	}
+
+	if head := types.BlockID(byteOrder.Uint32(raw)); head != 0 {
+		next, err := o.fetch(head, overflowNextOffset, types.Int32Size)
+		if err != nil {
+			return 0, err
+		}
+
+		if err := o.put(overflowHeaderBlock, 0, next); err != nil {
+			return 0, err
+		}
+
+		return head, nil
+	}
+
+	// Список пуст: берем первую страницу за границей занятых, а если ее нет — добавляем в файл новую.
+	// Граница меняется с журналом, поэтому откат транзакции возвращает ее назад, и добавленные страницы
+	// переиспользуются, а не теряются в конце файла. Заголовок заблокирован до конца транзакции,
+	// поэтому до отката за границу никто другой не выйдет
+	raw, err = o.fetch(overflowHeaderBlock, overflowEndOffset, types.Int32Size)
+	if err != nil {
+		return 0, err
+	}
+
+	size, err = o.TRX.Size(o.Filename)
+	if err != nil {
+		return 0, o.wrapError(err)
+	}
+
+	end := types.BlockID(byteOrder.Uint32(raw))
+
+	// В файлах до появления границы на ее месте 0: тогда заняты все страницы. Это верно при любом исходе транзакции,
+	// поэтому пишем без журнала
+	if end == 0 {
+		end = size
+
+		if err := o.putBytes(overflowHeaderBlock, overflowEndOffset, byteOrder.AppendUint32(nil, uint32(end)), false); err != nil {
+			return 0, err
+		}
+	}
+
+	if end >= size {
+		block, err := o.TRX.Append(o.Filename)
+		if err != nil {
+			return 0, o.wrapError(err)
+		}
+
+		end = block.Number
+	}
+
+	if err := o.put(overflowHeaderBlock, overflowEndOffset, byteOrder.AppendUint32(nil, uint32(end+1))); err != nil {
+		return 0, err
+	}
+
+	return end, nil
+}
+
+func (o *Overflow) writePage(block types.BlockID, next types.BlockID, data []byte) error {
+	header := byteOrder.AppendUint32(nil, uint32(next))
+	header = byteOrder.AppendUint32(header, uint32(len(data)))
+
+	if err := o.put(block, overflowNextOffset, header); err != nil {
+		return err
+	}
+
+	step := int(o.TRX.BlockSize() / overflowPutRatio)
+
+	for pos := 0; pos < len(data); pos += step {
+		if err := o.put(block, overflowPageHeader+uint32(pos), data[pos:min(pos+step, len(data))]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *Overflow) readPage(block types.BlockID) (types.BlockID, []byte, error) {
+	header, err := o.fetch(block, overflowNextOffset, overflowPageHeader)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	size := byteOrder.Uint32(header[overflowSizeOffset:])
+	if size > o.capacity() {
+		return 0, nil, errors.WithMessagef(ErrBadRecord, "%s: bad overflow page %d", o.Filename, block)
+	}
+
+	data, err := o.fetch(block, overflowPageHeader, size)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return types.BlockID(byteOrder.Uint32(header)), data, nil
+}
+
+func (o *Overflow) capacity() uint32 {
+	return o.TRX.BlockSize() - overflowPageHeader
+}
+
+func (o *Overflow) block(number types.BlockID) types.Block {
+	return types.Block{Filename: o.Filename, Number: number}
+}
+
+func (o *Overflow) fetch(number types.BlockID, offset uint32, size uint32) ([]byte, error) {
+	block := o.block(number)
+
+	if err := o.TRX.Pin(block); err != nil {
+		return nil, o.wrapError(err)
+	}
+
+	defer o.TRX.Unpin(block)
+
+	value, err := o.TRX.FetchBytes(block, offset, int(size))
+	if err != nil {
+		return nil, o.wrapError(err)
+	}
+
+	return value, nil
+}
+
+func (o *Overflow) put(number types.BlockID, offset uint32, value []byte) error {
+	return o.putBytes(number, offset, value, true)
+}
+
+func (o *Overflow) putBytes(number types.BlockID, offset uint32, value []byte, okToLog bool) error {
+	block := o.block(number)
+
+	if err := o.TRX.Pin(block); err != nil {
+		return o.wrapError(err)
+	}
+
+	defer o.TRX.Unpin(block)
+
+	if err := o.TRX.PutBytes(block, offset, value, okToLog); err != nil {
+		return o.wrapError(err)
+	}
+
+	return nil
+}
+
+func (o *Overflow) wrapError(err error) error {
+	return errors.WithMessagef(ErrRecordPage, "%s: %s", o.Filename, err)
+}
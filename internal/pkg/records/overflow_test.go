@@ -0,0 +1,224 @@
+package records_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/testutil"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+const testOverflowFile = "data.ovf"
+
+type OverflowTestSuite struct {
+	Suite
+}
+
+func TestOverflowTestSuite(t *testing.T) {
+	suite.Run(t, new(OverflowTestSuite))
+}
+
+func (ts *OverflowTestSuite) TestWriteAndRead() {
+	t := ts.T()
+
+	trxMan, fm := ts.newTRXManager(defaultLockTimeout, "")
+	defer fm.Close()
+
+	trx, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut := records.NewOverflow(trx, testOverflowFile)
+
+	values := [][]byte{
+		[]byte("short"),
+		bytes.Repeat([]byte("0123456789"), defaultTestBlockSize/10),
+		bytes.Repeat([]byte{0, 1, 2, '\'', '\\', '\n'}, defaultTestBlockSize),
+	}
+
+	firsts := make([]types.BlockID, len(values))
+
+	for i, value := range values {
+		firsts[i], err = sut.Write(value)
+		require.NoError(t, err)
+		assert.Positive(t, firsts[i])
+	}
+
+	require.NoError(t, trx.Commit())
+
+	trx, err = trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut = records.NewOverflow(trx, testOverflowFile)
+
+	for i, value := range values {
+		read, err := sut.Read(firsts[i], uint32(len(value)))
+		require.NoError(t, err)
+		assert.Equal(t, value, read)
+	}
+
+	_, err = sut.Read(firsts[1], uint32(len(values[1])+1))
+	require.ErrorIs(t, err, records.ErrBadRecord)
+
+	require.NoError(t, trx.Commit())
+}
+
+func (ts *OverflowTestSuite) TestReader() {
+	t := ts.T()
+
+	trxMan, fm := ts.newTRXManager(defaultLockTimeout, "")
+	defer fm.Close()
+
+	trx, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	value := bytes.Repeat([]byte("0123456789"), 3*defaultTestBlockSize/10)
+
+	first, err := records.NewOverflow(trx, testOverflowFile).Write(value)
+	require.NoError(t, err)
+	require.NoError(t, trx.Commit())
+
+	trx, err = trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut := records.NewOverflow(trx, testOverflowFile)
+
+	// Страницы читаются только по мере чтения значения, каждая прочитанная страница остается заблокированной
+	r := sut.NewReader(first, uint32(len(value)))
+	assert.EqualValues(t, len(value), r.Len())
+	assert.Zero(t, trx.Info().Locks)
+
+	chunk := make([]byte, 7)
+	n, err := r.Read(chunk)
+	require.NoError(t, err)
+	assert.Equal(t, value[:n], chunk[:n])
+	assert.Equal(t, 1, trx.Info().Locks)
+
+	rest, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, value[n:], rest)
+	assert.Equal(t, 4, trx.Info().Locks)
+
+	_, err = io.ReadAll(sut.NewReader(first, uint32(len(value)-1)))
+	require.ErrorIs(t, err, records.ErrBadRecord)
+
+	_, err = io.ReadAll(sut.NewReader(first, uint32(len(value)+1)))
+	require.ErrorIs(t, err, records.ErrBadRecord)
+
+	require.NoError(t, trx.Commit())
+}
+
+func (ts *OverflowTestSuite) TestFreeReusesPages() {
+	t := ts.T()
+
+	trxMan, fm := ts.newTRXManager(defaultLockTimeout, "")
+	defer fm.Close()
+
+	trx, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut := records.NewOverflow(trx, testOverflowFile)
+
+	value := bytes.Repeat([]byte("x"), 3*defaultTestBlockSize)
+
+	first, err := sut.Write(value)
+	require.NoError(t, err)
+
+	size := testutil.Must(trx.Size(testOverflowFile))
+	assert.EqualValues(t, 1+4, size)
+
+	require.NoError(t, sut.Free(first))
+
+	for i := 0; i < 10; i++ {
+		first, err = sut.Write(value)
+		require.NoError(t, err)
+
+		require.NoError(t, sut.Free(first))
+	}
+
+	assert.Equal(t, size, testutil.Must(trx.Size(testOverflowFile)))
+
+	short, err := sut.Write([]byte("short"))
+	require.NoError(t, err)
+
+	long, err := sut.Write(value[:2*defaultTestBlockSize])
+	require.NoError(t, err)
+
+	assert.Equal(t, size, testutil.Must(trx.Size(testOverflowFile)))
+
+	assert.Equal(t, []byte("short"), testutil.Must(sut.Read(short, 5)))
+	assert.Equal(t, value[:2*defaultTestBlockSize], testutil.Must(sut.Read(long, 2*defaultTestBlockSize)))
+
+	require.NoError(t, trx.Commit())
+}
+
+func (ts *OverflowTestSuite) TestRollback() {
+	t := ts.T()
+
+	trxMan, fm := ts.newTRXManager(defaultLockTimeout, "")
+	defer fm.Close()
+
+	trx, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut := records.NewOverflow(trx, testOverflowFile)
+
+	value := bytes.Repeat([]byte("first "), defaultTestBlockSize)
+
+	first, err := sut.Write(value)
+	require.NoError(t, err)
+	require.NoError(t, trx.Commit())
+
+	trx, err = trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut = records.NewOverflow(trx, testOverflowFile)
+
+	require.NoError(t, sut.Free(first))
+
+	// Освобожденные страницы переиспользуются и затираются, откат должен их восстановить
+	_, err = sut.Write(bytes.Repeat([]byte("second"), defaultTestBlockSize))
+	require.NoError(t, err)
+
+	require.NoError(t, trx.Rollback())
+
+	trx, err = trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut = records.NewOverflow(trx, testOverflowFile)
+
+	assert.Equal(t, value, testutil.Must(sut.Read(first, uint32(len(value)))))
+
+	require.NoError(t, trx.Commit())
+
+	// Страницы, которые откаченная транзакция добавила в файл, не теряются, а попадают в список свободных
+	long := bytes.Repeat([]byte("third "), 3*defaultTestBlockSize)
+
+	trx, err = trxMan.Transaction()
+	require.NoError(t, err)
+
+	_, err = records.NewOverflow(trx, testOverflowFile).Write(long)
+	require.NoError(t, err)
+	require.NoError(t, trx.Rollback())
+
+	size, err := fm.Length(testOverflowFile)
+	require.NoError(t, err)
+
+	trx, err = trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut = records.NewOverflow(trx, testOverflowFile)
+
+	third, err := sut.Write(long)
+	require.NoError(t, err)
+	assert.Equal(t, long, testutil.Must(sut.Read(third, uint32(len(long)))))
+	assert.Equal(t, value, testutil.Must(sut.Read(first, uint32(len(value)))))
+
+	require.NoError(t, trx.Commit())
+
+	assert.Equal(t, size, testutil.Must(fm.Length(testOverflowFile)))
+}
@@ -1,12 +1,26 @@
 package records
 
 import (
-	"fmt"
+	"cmp"
+	"encoding/binary"
+	"math"
+	"slices"
 
 	"github.com/pkg/errors"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
 )
 
+// Страница записей устроена как slotted page:
+//
+//	[число слотов][длина области записей][каталог слотов →] ... свободно ... [← записи]
+//
+// Элемент каталога — флаг слота, смещение и длина записи. Записи переменной длины пакуются с конца страницы,
+// поэтому строка занимает столько байт, сколько в ней есть, а не сколько разрешает схема.
+// Запись начинается с битовой карты NULL из Layout, новая запись целиком состоит из NULL.
+// Поле TEXT или BLOB хранит в записи короткое значение или ссылку на цепочку страниц переполнения, см. LargeValue.
+// Номер слота не меняется, пока запись жива: при сжатии страницы записи сдвигаются, а каталог переписывается.
+// Если обновленная запись не помещается в страницу, TableScan переносит ее в другой блок,
+// а в слоте оставляет указатель переноса. Пустая страница — нулевые байты, Format может ее не журналировать
 type (
 	SlotFlag int8
 )
@@ -14,16 +28,61 @@ type (
 var StartSlotID types.SlotID = -1
 
 const (
-	EmptySlot = 0
-	UsedSlot  = 1
+	EmptySlot SlotFlag = 0
+	UsedSlot  SlotFlag = 1
+	// ForwardedSlot — запись перенесена в другой блок, в слоте лежит ее RID
+	ForwardedSlot SlotFlag = 2
+	// MovedSlot — перенесенная запись. Ее читают только через указатель переноса, сканирование ее пропускает
+	MovedSlot SlotFlag = 3
 )
 
+const (
+	slotCountOffset = 0
+	areaSizeOffset  = types.Int32Size
+	pageHeaderSize  = 2 * types.Int32Size //nolint:mnd
+
+	// В блоках меньше 64 КиБ смещение и длина записи в каталоге занимают по 2 байта, в больших — по 4
+	shortPosSize = 2
+	shortPosMax  = math.MaxUint16
+
+	// forwardSize — размер указателя переноса. Короче запись не бывает, чтобы указатель всегда вставал на ее место
+	forwardSize = 2 * types.Int32Size //nolint:mnd
+
+	// Новой записи оставляем место для роста, но не больше этой доли блока
+	insertReserveRatio = 8
+
+	// Старший бит длины поля TEXT или BLOB означает, что значение лежит в страницах переполнения
+	largeExternalFlag = 1 << 31
+)
+
+var byteOrder = binary.LittleEndian
+
 type RecordPage struct {
 	Layout Layout
 	TRX    trxInt
 	Block  types.Block
 }
 
+type slotEntry struct {
+	flag   SlotFlag
+	offset uint32
+	length uint32
+}
+
+// LargeValue — значение поля TEXT или BLOB в записи. Короткое значение лежит в Data,
+// длинное — в цепочке страниц переполнения с первым блоком Overflow, см. Overflow
+type LargeValue struct {
+	Data     []byte
+	Length   uint32
+	Overflow types.BlockID
+	External bool
+}
+
+type pageHeader struct {
+	count    uint32
+	areaSize uint32
+}
+
 func NewRecordPage(trx trxInt, block types.Block, layout Layout) (*RecordPage, error) {
 	rp := &RecordPage{
 		Layout: layout,
@@ -39,177 +98,887 @@ func NewRecordPage(trx trxInt, block types.Block, layout Layout) (*RecordPage, e
 }
 
 func (rp *RecordPage) GetInt64(slot types.SlotID, fieldName string) (int64, error) {
-	if !rp.Layout.Schema.HasField(fieldName) {
-		return 0, errors.WithMessagef(ErrFieldNotFound, "field %s", fieldName)
+	page, err := rp.readPage()
+	if err != nil {
+		return 0, err
 	}
 
-	offset := rp.offset(slot) + rp.Layout.Offset(fieldName)
+	defer rp.endRead()
 
-	val, err := rp.TRX.GetInt64(rp.Block, offset)
+	value, err := rp.field(page, slot, fieldName, Int64Field)
 	if err != nil {
-		return val, errors.WithMessage(ErrRecordPage, err.Error())
+		return 0, err
 	}
 
-	return val, nil
+	return int64(byteOrder.Uint64(value)), nil
 }
 
 func (rp *RecordPage) GetString(slot types.SlotID, fieldName string) (string, error) {
-	if !rp.Layout.Schema.HasField(fieldName) {
-		return "", errors.WithMessagef(ErrFieldNotFound, "field %s", fieldName)
+	page, err := rp.readPage()
+	if err != nil {
+		return "", err
 	}
 
-	offset := rp.offset(slot) + rp.Layout.Offset(fieldName)
+	defer rp.endRead()
 
-	val, err := rp.TRX.GetString(rp.Block, offset)
+	value, err := rp.field(page, slot, fieldName, StringField)
 	if err != nil {
-		return val, errors.WithMessage(ErrRecordPage, err.Error())
+		return "", err
 	}
 
-	return val, nil
+	return string(value[types.Int32Size:]), nil
 }
 
 func (rp *RecordPage) GetInt8(slot types.SlotID, fieldName string) (int8, error) {
-	if !rp.Layout.Schema.HasField(fieldName) {
-		return 0, errors.WithMessagef(ErrFieldNotFound, "field %s", fieldName)
+	page, err := rp.readPage()
+	if err != nil {
+		return 0, err
 	}
 
-	offset := rp.offset(slot) + rp.Layout.Offset(fieldName)
+	defer rp.endRead()
 
-	val, err := rp.TRX.GetInt8(rp.Block, offset)
+	value, err := rp.field(page, slot, fieldName, Int8Field)
 	if err != nil {
-		return val, errors.WithMessage(ErrRecordPage, err.Error())
+		return 0, err
 	}
 
-	return val, nil
+	return int8(value[0]), nil
 }
 
-func (rp *RecordPage) SetInt64(slot types.SlotID, fieldName string, value int64) error {
-	if !rp.Layout.Schema.HasField(fieldName) {
-		return errors.WithMessagef(ErrFieldNotFound, "field %s", fieldName)
+// IsNull проверяет, что поле записи не задано. Get* для такого поля возвращают нулевое значение
+func (rp *RecordPage) IsNull(slot types.SlotID, fieldName string) (bool, error) {
+	idx, mask, ok := rp.Layout.NullBit(fieldName)
+	if !ok {
+		return false, errors.WithMessagef(ErrFieldNotFound, "field %s", fieldName)
+	}
+
+	page, err := rp.readPage()
+	if err != nil {
+		return false, err
 	}
 
-	offset := rp.offset(slot) + rp.Layout.Offset(fieldName)
+	defer rp.endRead()
 
-	if err := rp.TRX.SetInt64(rp.Block, offset, value, true); err != nil {
-		return errors.WithMessage(ErrRecordPage, err.Error())
+	row, _, err := rp.rowView(page, slot)
+	if err != nil {
+		return false, err
 	}
 
-	return nil
+	if idx >= uint32(len(row)) {
+		return false, errors.WithMessagef(ErrBadRecord, "slot %d: null bitmap", slot)
+	}
+
+	return row[idx]&mask != 0, nil
 }
 
-func (rp *RecordPage) SetString(slot types.SlotID, fieldName string, value string) error {
-	if !rp.Layout.Schema.HasField(fieldName) {
-		return errors.WithMessagef(ErrFieldNotFound, "field %s", fieldName)
+// SetNull сбрасывает поле в NULL. Значение поля заменяется нулевым, чтобы строка не занимала место
+func (rp *RecordPage) SetNull(slot types.SlotID, fieldName string) error {
+	var value []byte
+
+	fieldType := rp.Layout.Schema.Type(fieldName)
+
+	//nolint:exhaustive
+	switch fieldType {
+	case Int64Field:
+		value = make([]byte, types.Int64Size)
+	case Int8Field:
+		value = make([]byte, types.Int8Size)
+	case StringField, TextField, BlobField:
+		value = appendString(nil, "")
 	}
 
-	offset := rp.offset(slot) + rp.Layout.Offset(fieldName)
+	return rp.setField(slot, fieldName, fieldType, value, true)
+}
 
-	if err := rp.TRX.SetString(rp.Block, offset, value, true); err != nil {
-		return errors.WithMessage(ErrRecordPage, err.Error())
+// GetLarge возвращает значение поля TEXT или BLOB так, как оно лежит в записи
+func (rp *RecordPage) GetLarge(slot types.SlotID, fieldName string) (LargeValue, error) {
+	fieldType, err := rp.largeFieldType(fieldName)
+	if err != nil {
+		return LargeValue{}, err
 	}
 
-	return nil
+	page, err := rp.readPage()
+	if err != nil {
+		return LargeValue{}, err
+	}
+
+	defer rp.endRead()
+
+	cell, err := rp.field(page, slot, fieldName, fieldType)
+	if err != nil {
+		return LargeValue{}, err
+	}
+
+	length := byteOrder.Uint32(cell)
+
+	if length&largeExternalFlag != 0 {
+		return LargeValue{
+			Length:   length &^ largeExternalFlag,
+			Overflow: types.BlockID(byteOrder.Uint32(cell[types.Int32Size:])),
+			External: true,
+		}, nil
+	}
+
+	return LargeValue{
+		Data:   slices.Clone(cell[types.Int32Size:]),
+		Length: length,
+	}, nil
 }
 
-func (rp *RecordPage) SetInt8(slot types.SlotID, fieldName string, value int8) error {
-	if !rp.Layout.Schema.HasField(fieldName) {
-		return errors.WithMessagef(ErrFieldNotFound, "field %s", fieldName)
+// SetLarge кладет в запись значение поля TEXT или BLOB. Значение в записи не может быть длиннее InlineLimit
+func (rp *RecordPage) SetLarge(slot types.SlotID, fieldName string, value LargeValue) error {
+	fieldType, err := rp.largeFieldType(fieldName)
+	if err != nil {
+		return err
 	}
 
-	offset := rp.offset(slot) + rp.Layout.Offset(fieldName)
+	var cell []byte
+
+	switch {
+	case value.Length&largeExternalFlag != 0:
+		return errors.WithMessagef(ErrValueTooLong, "field %s", fieldName)
+	case value.External:
+		cell = byteOrder.AppendUint32(cell, value.Length|largeExternalFlag)
+		cell = byteOrder.AppendUint32(cell, uint32(value.Overflow))
+	case uint32(len(value.Data)) > rp.InlineLimit():
+		return errors.WithMessagef(ErrValueTooLong, "field %s", fieldName)
+	default:
+		cell = appendString(cell, string(value.Data))
+	}
 
-	if err := rp.TRX.SetInt8(rp.Block, offset, value, true); err != nil {
-		return errors.WithMessage(ErrRecordPage, err.Error())
+	return rp.setField(slot, fieldName, fieldType, cell, false)
+}
+
+// InlineLimit — наибольшее значение TEXT или BLOB, которое хранится в записи.
+// В маленьких блоках порог ниже LargeInlineMax, чтобы запись со всеми длинными полями поместилась в блок
+func (rp *RecordPage) InlineLimit() uint32 {
+	var large uint32
+
+	for _, name := range rp.Layout.Schema.Fields() {
+		if rp.Layout.Schema.Type(name).IsLarge() {
+			large++
+		}
 	}
 
-	return nil
+	return min(LargeInlineMax, rp.TRX.BlockSize()/(insertReserveRatio*max(large, 1)))
+}
+
+func (rp *RecordPage) SetInt64(slot types.SlotID, fieldName string, value int64) error {
+	return rp.setField(slot, fieldName, Int64Field, byteOrder.AppendUint64(nil, uint64(value)), false)
+}
+
+func (rp *RecordPage) SetString(slot types.SlotID, fieldName string, value string) error {
+	if field, ok := rp.Layout.Schema.Field(fieldName); ok && field.Type == StringField && types.Int32Size+uint32(len(value)) > field.BytesLen() {
+		return errors.WithMessagef(ErrValueTooLong, "field %s", fieldName)
+	}
+
+	return rp.setField(slot, fieldName, StringField, appendString(nil, value), false)
+}
+
+func (rp *RecordPage) SetInt8(slot types.SlotID, fieldName string, value int8) error {
+	return rp.setField(slot, fieldName, Int8Field, []byte{byte(value)}, false)
 }
 
+// Format размечает пустую страницу и возвращает, сколько записей наибольшей длины в нее поместится
 func (rp *RecordPage) Format() (int32, error) {
-	slot := types.SlotID(0)
-	schema := rp.Layout.Schema
+	if err := rp.TRX.PutBytes(rp.Block, 0, make([]byte, pageHeaderSize), false); err != nil {
+		return 0, errors.WithMessage(ErrRecordPage, err.Error())
+	}
+
+	return int32((rp.TRX.BlockSize() - pageHeaderSize) / (rp.maxRowSize() + rp.entrySize())), nil
+}
+
+func (rp *RecordPage) Delete(slot types.SlotID) error {
+	hdr, entry, err := rp.entry(slot)
+	if err != nil {
+		return err
+	}
+
+	// Запись на границе области записей отдаем в свободное место сразу, остальные — при сжатии страницы
+	if entry.length > 0 && entry.offset == rp.areaStart(hdr) {
+		hdr.areaSize -= entry.length
 
-	for rp.isValidSlot(slot) {
-		if err := rp.TRX.SetInt8(rp.Block, rp.offset(slot), EmptySlot, false); err != nil {
-			return 0, errors.WithMessagef(ErrRecordPage, err.Error())
+		if err := rp.setHeader(hdr); err != nil {
+			return err
 		}
+	}
+
+	return rp.setEntry(slot, slotEntry{flag: EmptySlot})
+}
 
-		slotOffset := rp.offset(slot)
-		for _, name := range schema.Fields() {
-			pos := slotOffset + rp.Layout.Offset(name)
+func (rp *RecordPage) NextAfter(slot types.SlotID) (types.SlotID, error) {
+	page, err := rp.readPage()
+	if err != nil {
+		return StartSlotID, err
+	}
+
+	defer rp.endRead()
+
+	hdr, err := rp.pageHeader(page)
+	if err != nil {
+		return StartSlotID, err
+	}
 
-			var err error
+	for i := max(slot+1, 0); uint32(i) < hdr.count; i++ {
+		entry, err := rp.pageEntry(page, hdr, i)
+		if err != nil {
+			return StartSlotID, err
+		}
 
-			//nolint:exhaustive
-			switch t := schema.Type(name); t {
-			case Int64Field:
-				err = rp.TRX.SetInt64(rp.Block, pos, 0, false)
-			case Int8Field:
-				err = rp.TRX.SetInt8(rp.Block, pos, 0, false)
-			case StringField:
-				err = rp.TRX.SetString(rp.Block, pos, "", false)
-			default:
-				err = fmt.Errorf("unknown filed type '%d' for field '%s'", t, name)
+		if entry.flag == UsedSlot || entry.flag == ForwardedSlot {
+			return i, nil
+		}
+	}
+
+	return StartSlotID, ErrSlotNotFound
+}
+
+// InsertAfter занимает пустой слот после slot или добавляет новый и кладет в него запись, в которой все поля NULL.
+// Если места в странице не хватает, возвращает ErrSlotNotFound
+func (rp *RecordPage) InsertAfter(slot types.SlotID) (types.SlotID, error) {
+	return rp.insert(slot, rp.emptyRow(), UsedSlot)
+}
+
+// InsertMoved кладет в страницу запись, перенесенную из другого блока, и возвращает ее слот.
+// Если места в странице не хватает, возвращает ErrSlotNotFound
+func (rp *RecordPage) InsertMoved(row []byte) (types.SlotID, error) {
+	return rp.insert(StartSlotID, row, MovedSlot)
+}
+
+// Row возвращает байты записи слота
+func (rp *RecordPage) Row(slot types.SlotID) ([]byte, error) {
+	row, _, err := rp.row(slot)
+
+	return row, err
+}
+
+// Forward возвращает адрес, куда перенесена запись слота. Если запись на месте, ok == false
+func (rp *RecordPage) Forward(slot types.SlotID) (types.RID, bool, error) {
+	page, err := rp.readPage()
+	if err != nil {
+		return types.RID{}, false, err
+	}
+
+	defer rp.endRead()
+
+	hdr, err := rp.pageHeader(page)
+	if err != nil {
+		return types.RID{}, false, err
+	}
+
+	entry, err := rp.pageEntry(page, hdr, slot)
+	if err != nil || entry.flag != ForwardedSlot {
+		return types.RID{}, false, err
+	}
+
+	ptr, err := page.FetchBytes(entry.offset, forwardSize)
+	if err != nil {
+		return types.RID{}, false, errors.WithMessage(ErrRecordPage, err.Error())
+	}
+
+	return types.RID{
+		BlockNumber: types.BlockID(byteOrder.Uint32(ptr)),
+		Slot:        types.SlotID(byteOrder.Uint32(ptr[types.Int32Size:])),
+	}, true, nil
+}
+
+// SetForward заменяет запись слота указателем переноса на rid
+func (rp *RecordPage) SetForward(slot types.SlotID, rid types.RID) error {
+	_, entry, err := rp.entry(slot)
+	if err != nil {
+		return err
+	}
+
+	if entry.flag == EmptySlot {
+		return errors.WithMessagef(ErrSlotNotFound, "slot %d is empty", slot)
+	}
+
+	ptr := byteOrder.AppendUint32(nil, uint32(rid.BlockNumber))
+	ptr = byteOrder.AppendUint32(ptr, uint32(rid.Slot))
+
+	if err := rp.put(entry.offset, ptr); err != nil {
+		return err
+	}
+
+	return rp.setEntry(slot, slotEntry{flag: ForwardedSlot, offset: entry.offset, length: entry.length})
+}
+
+// Compact сдвигает записи к концу страницы, чтобы свободное место стало непрерывным
+func (rp *RecordPage) Compact() error {
+	_, err := rp.compact(StartSlotID)
+
+	return err
+}
+
+// maxRowSize — наибольший размер записи. SlotSize учитывает байт флага слота из прежнего формата страницы
+func (rp *RecordPage) maxRowSize() uint32 {
+	return max(rp.Layout.SlotSize-types.Int8Size, forwardSize)
+}
+
+// reserve — сколько места нужно под новую запись. Оставляем место для роста, чтобы запись не пришлось сразу переносить
+func (rp *RecordPage) reserve(rowSize uint32) uint32 {
+	return max(rowSize, min(rp.maxRowSize(), rp.TRX.BlockSize()/insertReserveRatio))
+}
+
+func (rp *RecordPage) emptyRow() []byte {
+	row := make([]byte, rp.Layout.NullsSize(), rp.maxRowSize())
+
+	for i := range rp.Layout.Schema.Count() {
+		row[i/8] |= 1 << (i % 8) //nolint:mnd
+	}
+
+	for _, name := range rp.Layout.Schema.Fields() {
+		//nolint:exhaustive
+		switch rp.Layout.Schema.Type(name) {
+		case Int64Field:
+			row = append(row, make([]byte, types.Int64Size)...)
+		case Int8Field:
+			row = append(row, 0)
+		case StringField, TextField, BlobField:
+			row = appendString(row, "")
+		}
+	}
+
+	return padRow(row)
+}
+
+// padRow дополняет запись нулями до размера указателя переноса. Хвост записи при чтении полей не читается
+func padRow(row []byte) []byte {
+	if len(row) < forwardSize {
+		row = append(row, make([]byte, forwardSize-len(row))...)
+	}
+
+	return row
+}
+
+func appendString(b []byte, value string) []byte {
+	b = byteOrder.AppendUint32(b, uint32(len(value)))
+
+	return append(b, value...)
+}
+
+// fieldBounds ищет поле в байтах записи. Поля лежат в порядке схемы после битовой карты NULL, перед строкой — ее длина
+func (rp *RecordPage) fieldBounds(row []byte, fieldName string, fieldType FieldType) (uint32, uint32, error) {
+	schema := rp.Layout.Schema
+
+	if !schema.HasField(fieldName) {
+		return 0, 0, errors.WithMessagef(ErrFieldNotFound, "field %s", fieldName)
+	}
+
+	if t := schema.Type(fieldName); t != fieldType {
+		return 0, 0, errors.WithMessagef(ErrFieldType, "field %s has type %d, not %d", fieldName, t, fieldType)
+	}
+
+	pos := rp.Layout.NullsSize()
+
+	for _, name := range schema.Fields() {
+		var size uint32
+
+		//nolint:exhaustive
+		switch schema.Type(name) {
+		case Int64Field:
+			size = types.Int64Size
+		case Int8Field:
+			size = types.Int8Size
+		case StringField:
+			if pos+types.Int32Size > uint32(len(row)) {
+				return 0, 0, errors.WithMessagef(ErrBadRecord, "field %s", name)
 			}
 
-			if err != nil {
-				return 0, errors.WithMessagef(ErrRecordPage, err.Error())
+			size = types.Int32Size + byteOrder.Uint32(row[pos:])
+		case TextField, BlobField:
+			if pos+types.Int32Size > uint32(len(row)) {
+				return 0, 0, errors.WithMessagef(ErrBadRecord, "field %s", name)
+			}
+
+			size = byteOrder.Uint32(row[pos:])
+			if size&largeExternalFlag != 0 {
+				size = types.Int32Size
 			}
+
+			size += types.Int32Size
 		}
 
-		slot++
+		if uint64(pos)+uint64(size) > uint64(len(row)) {
+			return 0, 0, errors.WithMessagef(ErrBadRecord, "field %s", name)
+		}
+
+		if name == fieldName {
+			return pos, pos + size, nil
+		}
+
+		pos += size
 	}
 
-	return int32(slot), nil
+	return 0, 0, errors.WithMessagef(ErrFieldNotFound, "field %s", fieldName)
 }
 
-func (rp *RecordPage) Delete(slot types.SlotID) error {
-	return rp.setFlag(slot, EmptySlot)
+func (rp *RecordPage) largeFieldType(fieldName string) (FieldType, error) {
+	switch fieldType := rp.Layout.Schema.Type(fieldName); {
+	case fieldType == NotFoundField:
+		return fieldType, errors.WithMessagef(ErrFieldNotFound, "field %s", fieldName)
+	case !fieldType.IsLarge():
+		return fieldType, errors.WithMessagef(ErrFieldType, "field %s has type %d, not text or blob", fieldName, fieldType)
+	default:
+		return fieldType, nil
+	}
 }
 
-func (rp *RecordPage) NextAfter(slot types.SlotID) (types.SlotID, error) {
-	return rp.searchAfter(slot, UsedSlot)
+// field возвращает байты поля записи из страницы page. Срез смотрит в буфер, читать его можно только до endRead
+func (rp *RecordPage) field(page *types.Page, slot types.SlotID, fieldName string, fieldType FieldType) ([]byte, error) {
+	if !rp.Layout.Schema.HasField(fieldName) {
+		return nil, errors.WithMessagef(ErrFieldNotFound, "field %s", fieldName)
+	}
+
+	row, _, err := rp.rowView(page, slot)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end, err := rp.fieldBounds(row, fieldName, fieldType)
+	if err != nil {
+		return nil, err
+	}
+
+	return row[start:end], nil
 }
 
-func (rp *RecordPage) InsertAfter(slot types.SlotID) (types.SlotID, error) {
-	newSlot, err := rp.searchAfter(slot, EmptySlot)
+// setField заменяет байты поля и его бит в карте NULL.
+// Если длина записи не изменилась, пишет только поле и карту, иначе переписывает запись целиком
+func (rp *RecordPage) setField(slot types.SlotID, fieldName string, fieldType FieldType, value []byte, null bool) error {
+	idx, mask, ok := rp.Layout.NullBit(fieldName)
+	if !ok {
+		return errors.WithMessagef(ErrFieldNotFound, "field %s", fieldName)
+	}
+
+	row, entry, err := rp.row(slot)
+	if err != nil {
+		return err
+	}
+
+	start, end, err := rp.fieldBounds(row, fieldName, fieldType)
+	if err != nil {
+		return err
+	}
+
+	nulls := row[idx]
+	if null {
+		row[idx] |= mask
+	} else {
+		row[idx] &^= mask
+	}
+
+	if int(end-start) == len(value) {
+		if row[idx] != nulls {
+			if err := rp.put(entry.offset+idx, row[idx:idx+1]); err != nil {
+				return err
+			}
+		}
+
+		return rp.put(entry.offset+start, value)
+	}
+
+	newRow := make([]byte, 0, len(row)-int(end-start)+len(value))
+	newRow = append(newRow, row[:start]...)
+	newRow = append(newRow, value...)
+	newRow = append(newRow, row[end:]...)
+
+	return rp.updateRow(slot, entry, padRow(newRow))
+}
+
+// updateRow кладет новую версию записи: на старое место, если она не длиннее, иначе в свободное место страницы.
+// Если места не хватает и после сжатия, возвращает ErrNoSpace
+func (rp *RecordPage) updateRow(slot types.SlotID, entry slotEntry, row []byte) error {
+	size := uint32(len(row))
+
+	if size <= entry.length {
+		if err := rp.put(entry.offset, row); err != nil {
+			return err
+		}
+
+		entry.length = size
+
+		return rp.setEntry(slot, entry)
+	}
+
+	hdr, entries, err := rp.directory()
+	if err != nil {
+		return err
+	}
+
+	dirEnd := rp.directoryEnd(hdr.count)
+	areaStart := rp.areaStart(hdr)
+
+	var offset uint32
+
+	switch {
+	case entry.offset == areaStart && areaStart-dirEnd >= size-entry.length:
+		// Запись на границе области растет в свободное место, не оставляя дыры
+		offset = entry.offset - (size - entry.length)
+	case areaStart-dirEnd >= size:
+		offset = areaStart - size
+	case rp.freeSpace(hdr, entries)+entry.length >= size:
+		if hdr, err = rp.compact(slot); err != nil {
+			return err
+		}
+
+		offset = rp.areaStart(hdr) - size
+	default:
+		return errors.WithMessagef(ErrNoSpace, "slot %d: %d bytes", slot, size)
+	}
+
+	if err := rp.put(offset, row); err != nil {
+		return err
+	}
+
+	if offset < rp.areaStart(hdr) {
+		hdr.areaSize = rp.TRX.BlockSize() - offset
+
+		if err := rp.setHeader(hdr); err != nil {
+			return err
+		}
+	}
+
+	return rp.setEntry(slot, slotEntry{flag: entry.flag, offset: offset, length: size})
+}
+
+func (rp *RecordPage) insert(after types.SlotID, row []byte, flag SlotFlag) (types.SlotID, error) {
+	hdr, entries, err := rp.directory()
 	if err != nil {
 		return StartSlotID, err
 	}
 
-	if err := rp.setFlag(newSlot, UsedSlot); err != nil {
+	slot := types.SlotID(hdr.count)
+
+	for i := max(after+1, 0); int(i) < len(entries); i++ {
+		if entries[i].flag == EmptySlot {
+			slot = i
+
+			break
+		}
+	}
+
+	size := uint32(len(row))
+
+	need := rp.reserve(size)
+	if slot == types.SlotID(hdr.count) {
+		need += rp.entrySize()
+	}
+
+	if rp.freeSpace(hdr, entries) < need {
+		return StartSlotID, ErrSlotNotFound
+	}
+
+	if slot == types.SlotID(hdr.count) {
+		hdr.count++
+	}
+
+	if rp.areaStart(hdr)-rp.directoryEnd(hdr.count) < size {
+		if hdr, err = rp.compact(StartSlotID); err != nil {
+			return StartSlotID, err
+		}
+
+		hdr.count = max(hdr.count, uint32(slot)+1)
+	}
+
+	hdr.areaSize += size
+	offset := rp.areaStart(hdr)
+
+	if err := rp.put(offset, row); err != nil {
+		return StartSlotID, err
+	}
+
+	if err := rp.setEntry(slot, slotEntry{flag: flag, offset: offset, length: size}); err != nil {
+		return StartSlotID, err
+	}
+
+	if err := rp.setHeader(hdr); err != nil {
 		return StartSlotID, err
 	}
 
-	return newSlot, nil
+	return slot, nil
 }
 
-func (rp *RecordPage) offset(slot types.SlotID) uint32 {
-	return uint32(slot) * rp.Layout.SlotSize
+// compact сдвигает живые записи к концу страницы. Запись слота except считается удаленной: ее место освобождается.
+// Записи двигаем от конца страницы к началу, поэтому сдвиг не затирает еще не перенесенные записи
+func (rp *RecordPage) compact(except types.SlotID) (pageHeader, error) {
+	hdr, entries, err := rp.directory()
+	if err != nil {
+		return hdr, err
+	}
+
+	live := make([]types.SlotID, 0, len(entries))
+
+	for i, entry := range entries {
+		if entry.flag != EmptySlot && entry.length > 0 && types.SlotID(i) != except {
+			live = append(live, types.SlotID(i))
+		}
+	}
+
+	slices.SortFunc(live, func(a, b types.SlotID) int {
+		return cmp.Compare(entries[b].offset, entries[a].offset)
+	})
+
+	end := rp.TRX.BlockSize()
+
+	for _, slot := range live {
+		entry := entries[slot]
+		offset := end - entry.length
+
+		if offset != entry.offset {
+			row, err := rp.fetch(entry.offset, entry.length)
+			if err != nil {
+				return hdr, err
+			}
+
+			if err := rp.put(offset, row); err != nil {
+				return hdr, err
+			}
+
+			entry.offset = offset
+
+			if err := rp.setEntry(slot, entry); err != nil {
+				return hdr, err
+			}
+		}
+
+		end = offset
+	}
+
+	if except >= 0 && int(except) < len(entries) {
+		entry := entries[except]
+		entry.offset, entry.length = end, 0
+
+		if err := rp.setEntry(except, entry); err != nil {
+			return hdr, err
+		}
+	}
+
+	hdr.areaSize = rp.TRX.BlockSize() - end
+
+	if err := rp.setHeader(hdr); err != nil {
+		return hdr, err
+	}
+
+	return hdr, nil
 }
 
-func (rp *RecordPage) setFlag(slot types.SlotID, flag SlotFlag) error {
-	return rp.TRX.SetInt8(rp.Block, rp.offset(slot), int8(flag), true)
+// freeSpace — место в странице, которое останется после сжатия
+func (rp *RecordPage) freeSpace(hdr pageHeader, entries []slotEntry) uint32 {
+	used := rp.directoryEnd(hdr.count)
+
+	for _, entry := range entries {
+		if entry.flag != EmptySlot {
+			used += entry.length
+		}
+	}
+
+	return rp.TRX.BlockSize() - min(used, rp.TRX.BlockSize())
 }
 
-func (rp *RecordPage) isValidSlot(slot types.SlotID) bool {
-	return rp.offset(slot+1) <= rp.TRX.BlockSize()
+func (rp *RecordPage) directoryEnd(count uint32) uint32 {
+	return pageHeaderSize + count*rp.entrySize()
 }
 
-func (rp *RecordPage) searchAfter(slot types.SlotID, flag SlotFlag) (types.SlotID, error) {
-	slot++
-	for rp.isValidSlot(slot) {
-		f, err := rp.TRX.GetInt8(rp.Block, rp.offset(slot))
-		if err != nil {
-			return -1, errors.WithMessagef(ErrRecordPage, err.Error())
-		}
+func (rp *RecordPage) areaStart(hdr pageHeader) uint32 {
+	return rp.TRX.BlockSize() - hdr.areaSize
+}
 
-		if SlotFlag(f) == flag {
-			return slot, nil
-		}
+// row возвращает копию записи слота, которую можно менять
+func (rp *RecordPage) row(slot types.SlotID) ([]byte, slotEntry, error) {
+	page, err := rp.readPage()
+	if err != nil {
+		return nil, slotEntry{}, err
+	}
+
+	defer rp.endRead()
 
-		slot++
+	row, entry, err := rp.rowView(page, slot)
+	if err != nil {
+		return nil, entry, err
 	}
 
-	return StartSlotID, ErrSlotNotFound
+	return slices.Clone(row), entry, nil
+}
+
+// rowView ищет запись слота в странице page. Срез смотрит в буфер, читать его можно только до endRead
+func (rp *RecordPage) rowView(page *types.Page, slot types.SlotID) ([]byte, slotEntry, error) {
+	hdr, err := rp.pageHeader(page)
+	if err != nil {
+		return nil, slotEntry{}, err
+	}
+
+	entry, err := rp.pageEntry(page, hdr, slot)
+	if err != nil {
+		return nil, entry, err
+	}
+
+	switch entry.flag {
+	case EmptySlot:
+		return nil, entry, errors.WithMessagef(ErrSlotNotFound, "slot %d is empty", slot)
+	case ForwardedSlot:
+		return nil, entry, errors.WithMessagef(ErrSlotForwarded, "slot %d", slot)
+	}
+
+	row, err := page.FetchBytes(entry.offset, int(entry.length))
+	if err != nil {
+		return nil, entry, errors.WithMessage(ErrRecordPage, err.Error())
+	}
+
+	return row, entry, nil
+}
+
+func (rp *RecordPage) pageHeader(page *types.Page) (pageHeader, error) {
+	raw, err := page.FetchBytes(0, pageHeaderSize)
+	if err != nil {
+		return pageHeader{}, errors.WithMessage(ErrRecordPage, err.Error())
+	}
+
+	hdr := pageHeader{
+		count:    byteOrder.Uint32(raw[slotCountOffset:]),
+		areaSize: byteOrder.Uint32(raw[areaSizeOffset:]),
+	}
+
+	if rp.directoryEnd(hdr.count) > rp.TRX.BlockSize()-min(hdr.areaSize, rp.TRX.BlockSize()) {
+		return hdr, errors.WithMessagef(ErrBadRecord, "%s: bad page header", rp.Block)
+	}
+
+	return hdr, nil
+}
+
+func (rp *RecordPage) setHeader(hdr pageHeader) error {
+	raw := byteOrder.AppendUint32(nil, hdr.count)
+	raw = byteOrder.AppendUint32(raw, hdr.areaSize)
+
+	return rp.put(0, raw)
+}
+
+// entry читает заголовок страницы и элемент каталога слота
+func (rp *RecordPage) entry(slot types.SlotID) (pageHeader, slotEntry, error) {
+	page, err := rp.readPage()
+	if err != nil {
+		return pageHeader{}, slotEntry{}, err
+	}
+
+	defer rp.endRead()
+
+	hdr, err := rp.pageHeader(page)
+	if err != nil {
+		return hdr, slotEntry{}, err
+	}
+
+	entry, err := rp.pageEntry(page, hdr, slot)
+
+	return hdr, entry, err
+}
+
+func (rp *RecordPage) pageEntry(page *types.Page, hdr pageHeader, slot types.SlotID) (slotEntry, error) {
+	if slot < 0 || uint32(slot) >= hdr.count {
+		return slotEntry{}, errors.WithMessagef(ErrSlotNotFound, "slot %d", slot)
+	}
+
+	raw, err := page.FetchBytes(rp.directoryEnd(uint32(slot)), int(rp.entrySize()))
+	if err != nil {
+		return slotEntry{}, errors.WithMessage(ErrRecordPage, err.Error())
+	}
+
+	return rp.decodeEntry(raw), nil
+}
+
+func (rp *RecordPage) setEntry(slot types.SlotID, entry slotEntry) error {
+	raw := make([]byte, 0, rp.entrySize())
+	raw = append(raw, byte(entry.flag))
+
+	if rp.entrySize() == types.Int8Size+2*shortPosSize {
+		raw = byteOrder.AppendUint16(raw, uint16(entry.offset))
+		raw = byteOrder.AppendUint16(raw, uint16(entry.length))
+	} else {
+		raw = byteOrder.AppendUint32(raw, entry.offset)
+		raw = byteOrder.AppendUint32(raw, entry.length)
+	}
+
+	return rp.put(rp.directoryEnd(uint32(slot)), raw)
+}
+
+// directory читает заголовок и весь каталог слотов
+func (rp *RecordPage) directory() (pageHeader, []slotEntry, error) {
+	page, err := rp.readPage()
+	if err != nil {
+		return pageHeader{}, nil, err
+	}
+
+	defer rp.endRead()
+
+	hdr, err := rp.pageHeader(page)
+	if err != nil {
+		return hdr, nil, err
+	}
+
+	raw, err := page.FetchBytes(pageHeaderSize, int(hdr.count*rp.entrySize()))
+	if err != nil {
+		return hdr, nil, errors.WithMessage(ErrRecordPage, err.Error())
+	}
+
+	entries := make([]slotEntry, hdr.count)
+	for i := range entries {
+		entries[i] = rp.decodeEntry(raw[uint32(i)*rp.entrySize():])
+	}
+
+	return hdr, entries, nil
+}
+
+func (rp *RecordPage) entrySize() uint32 {
+	if rp.TRX.BlockSize() <= shortPosMax {
+		return types.Int8Size + 2*shortPosSize //nolint:mnd
+	}
+
+	return types.Int8Size + 2*types.Int32Size //nolint:mnd
+}
+
+func (rp *RecordPage) decodeEntry(raw []byte) slotEntry {
+	entry := slotEntry{
+		flag: SlotFlag(raw[0]),
+	}
+
+	if rp.entrySize() == types.Int8Size+2*shortPosSize {
+		entry.offset = uint32(byteOrder.Uint16(raw[types.Int8Size:]))
+		entry.length = uint32(byteOrder.Uint16(raw[types.Int8Size+shortPosSize:]))
+	} else {
+		entry.offset = byteOrder.Uint32(raw[types.Int8Size:])
+		entry.length = byteOrder.Uint32(raw[types.Int8Size+types.Int32Size:])
+	}
+
+	return entry
+}
+
+// readPage возвращает страницу блока для чтения без копирования. Когда чтение закончено, нужно вызвать endRead
+func (rp *RecordPage) readPage() (*types.Page, error) {
+	page, err := rp.TRX.ReadPage(rp.Block)
+	if err != nil {
+		return nil, errors.WithMessage(ErrRecordPage, err.Error())
+	}
+
+	return page, nil
+}
+
+func (rp *RecordPage) endRead() {
+	rp.TRX.EndRead(rp.Block)
+}
+
+// fetch возвращает копию участка страницы, например чтобы переписать его в другое место той же страницы
+func (rp *RecordPage) fetch(offset uint32, size uint32) ([]byte, error) {
+	value, err := rp.TRX.FetchBytes(rp.Block, offset, int(size))
+	if err != nil {
+		return nil, errors.WithMessage(ErrRecordPage, err.Error())
+	}
+
+	return value, nil
+}
+
+func (rp *RecordPage) put(offset uint32, value []byte) error {
+	if err := rp.TRX.PutBytes(rp.Block, offset, value, true); err != nil {
+		return errors.WithMessage(ErrRecordPage, err.Error())
+	}
+
+	return nil
 }
@@ -1,13 +1,16 @@
 package records_test
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/testutil"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/transaction"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
 )
@@ -39,7 +42,7 @@ func (ts *RecordPageTestSuite) newTestRecordPage(t *testing.T) (*records.RecordP
 	formatedSlots, err := sut.Format()
 	require.NoError(t, err)
 	assert.Greater(t, formatedSlots, int32(0))
-	assert.EqualValues(t, defaultTestBlockSize/layout.SlotSize, formatedSlots)
+	assert.LessOrEqual(t, formatedSlots, int32(defaultTestBlockSize/layout.SlotSize))
 
 	assert.Equal(t, []string{"<START, 1>"}, ts.fetchWAL(t, trxMan))
 
@@ -86,6 +89,38 @@ func (ts *RecordPageTestSuite) TestRecordPage() {
 	require.NoError(t, trx.Commit())
 }
 
+func (ts *RecordPageTestSuite) TestReadsDoNotAllocate() {
+	t := ts.T()
+
+	sut, trx, clean := ts.newTestRecordPage(t)
+	defer clean()
+
+	slot, err := sut.InsertAfter(records.StartSlotID)
+	require.NoError(t, err)
+
+	require.NoError(t, sut.SetInt64(slot, "id", 1))
+	require.NoError(t, sut.SetInt8(slot, "age", 2))
+	require.NoError(t, sut.SetString(slot, "name", "user"))
+
+	// Заголовок, слот и поле читаются из одной страницы без копирования
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = testutil.Must(sut.GetInt64(slot, "id"))
+		_ = testutil.Must(sut.GetInt8(slot, "age"))
+		_ = testutil.Must(sut.IsNull(slot, "name"))
+		_ = testutil.Must(sut.NextAfter(records.StartSlotID))
+	})
+	assert.Zero(t, allocs)
+
+	// Строка копируется из страницы один раз
+	allocs = testing.AllocsPerRun(100, func() {
+		_ = testutil.Must(sut.GetString(slot, "name"))
+	})
+	assert.EqualValues(t, 1, allocs)
+
+	trx.Unpin(sut.Block)
+	require.NoError(t, trx.Commit())
+}
+
 func (ts *RecordPageTestSuite) TestGetAndSetUnknownFieldsWithError() {
 	t := ts.T()
 
@@ -109,6 +144,124 @@ func (ts *RecordPageTestSuite) TestGetAndSetUnknownFieldsWithError() {
 	require.NoError(t, trx.Commit())
 }
 
+func (ts *RecordPageTestSuite) TestNulls() {
+	t := ts.T()
+
+	sut, trx, clean := ts.newTestRecordPage(t)
+	defer clean()
+
+	slot, err := sut.InsertAfter(records.StartSlotID)
+	require.NoError(t, err)
+
+	for _, name := range sut.Layout.Schema.Fields() {
+		isNull, nerr := sut.IsNull(slot, name)
+		require.NoError(t, nerr)
+		assert.True(t, isNull, name)
+	}
+
+	require.NoError(t, sut.SetInt64(slot, "id", 1))
+	require.NoError(t, sut.SetString(slot, "name", "user 1"))
+	require.NoError(t, sut.SetInt8(slot, "age", 0))
+
+	for _, name := range sut.Layout.Schema.Fields() {
+		isNull, nerr := sut.IsNull(slot, name)
+		require.NoError(t, nerr)
+		assert.False(t, isNull, name)
+	}
+
+	require.NoError(t, sut.SetNull(slot, "name"))
+
+	isNull, err := sut.IsNull(slot, "name")
+	require.NoError(t, err)
+	assert.True(t, isNull)
+
+	name, err := sut.GetString(slot, "name")
+	require.NoError(t, err)
+	assert.Empty(t, name)
+
+	id, err := sut.GetInt64(slot, "id")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, id)
+
+	isNull, err = sut.IsNull(slot, "id")
+	require.NoError(t, err)
+	assert.False(t, isNull)
+
+	_, err = sut.IsNull(slot, "unknown")
+	require.ErrorIs(t, err, records.ErrFieldNotFound)
+	require.ErrorIs(t, sut.SetNull(slot, "unknown"), records.ErrFieldNotFound)
+
+	trx.Unpin(sut.Block)
+	require.NoError(t, trx.Commit())
+}
+
+func (ts *RecordPageTestSuite) TestLargeValues() {
+	t := ts.T()
+
+	trxMan, fm := ts.newTRXManager(defaultLockTimeout, "")
+	defer fm.Close()
+
+	trx, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	block, err := trx.Append(testDataFile)
+	require.NoError(t, err)
+
+	schema := records.NewSchema()
+	schema.AddInt64Field("id")
+	schema.AddTextField("body")
+	schema.AddBlobField("data")
+
+	sut, err := records.NewRecordPage(trx, block, records.NewLayout(schema))
+	require.NoError(t, err)
+
+	_, err = sut.Format()
+	require.NoError(t, err)
+
+	// Два длинных поля делят восьмую часть блока
+	assert.EqualValues(t, defaultTestBlockSize/8/2, sut.InlineLimit())
+
+	slot, err := sut.InsertAfter(records.StartSlotID)
+	require.NoError(t, err)
+
+	value, err := sut.GetLarge(slot, "body")
+	require.NoError(t, err)
+	assert.Equal(t, records.LargeValue{Data: []byte{}}, value)
+
+	require.NoError(t, sut.SetInt64(slot, "id", 1))
+	require.NoError(t, sut.SetLarge(slot, "body", records.LargeValue{Data: []byte("inline text"), Length: 11}))
+	require.NoError(t, sut.SetLarge(slot, "data", records.LargeValue{Length: 100500, Overflow: 12, External: true}))
+
+	value, err = sut.GetLarge(slot, "body")
+	require.NoError(t, err)
+	assert.Equal(t, records.LargeValue{Data: []byte("inline text"), Length: 11}, value)
+
+	value, err = sut.GetLarge(slot, "data")
+	require.NoError(t, err)
+	assert.Equal(t, records.LargeValue{Length: 100500, Overflow: 12, External: true}, value)
+
+	assert.EqualValues(t, 1, testutil.Must(sut.GetInt64(slot, "id")))
+	assert.False(t, testutil.Must(sut.IsNull(slot, "data")))
+
+	long := make([]byte, sut.InlineLimit()+1)
+	require.ErrorIs(t, sut.SetLarge(slot, "body", records.LargeValue{Data: long, Length: uint32(len(long))}), records.ErrValueTooLong)
+	require.ErrorIs(t, sut.SetLarge(slot, "id", records.LargeValue{}), records.ErrFieldType)
+	require.ErrorIs(t, sut.SetLarge(slot, "unknown", records.LargeValue{}), records.ErrFieldNotFound)
+
+	_, err = sut.GetLarge(slot, "id")
+	require.ErrorIs(t, err, records.ErrFieldType)
+
+	require.NoError(t, sut.SetNull(slot, "data"))
+
+	value, err = sut.GetLarge(slot, "data")
+	require.NoError(t, err)
+	assert.Equal(t, records.LargeValue{Data: []byte{}}, value)
+	assert.True(t, testutil.Must(sut.IsNull(slot, "data")))
+
+	trx.Unpin(block)
+	require.NoError(t, trx.Commit())
+}
+
 func (ts *RecordPageTestSuite) TestDeleteSlot() {
 	t := ts.T()
 
@@ -134,7 +287,196 @@ func (ts *RecordPageTestSuite) TestNoNewSlot() {
 	sut, _, clean := ts.newTestRecordPage(t)
 	defer clean()
 
-	_, err := sut.InsertAfter(types.SlotID(defaultTestBlockSize / sut.Layout.SlotSize))
-	require.Error(t, err)
+	slot := records.StartSlotID
+
+	var err error
+
+	for {
+		slot, err = sut.InsertAfter(slot)
+		if err != nil {
+			break
+		}
+
+		require.NoError(t, sut.SetString(slot, "name", "user"))
+	}
+
 	assert.ErrorIs(t, err, records.ErrSlotNotFound)
+
+	// Короткие записи занимают меньше места, чем запись наибольшей длины
+	next, err := sut.NextAfter(types.SlotID(defaultTestBlockSize / sut.Layout.SlotSize))
+	require.NoError(t, err)
+	assert.Greater(t, next, types.SlotID(defaultTestBlockSize/sut.Layout.SlotSize))
+}
+
+func (ts *RecordPageTestSuite) TestVariableLengthRecords() {
+	t := ts.T()
+
+	sut, trx, clean := ts.newTestRecordPage(t)
+	defer clean()
+
+	slots := make([]types.SlotID, 0, 10)
+
+	for i := 0; i < 10; i++ {
+		slot, err := sut.InsertAfter(records.StartSlotID)
+		require.NoError(t, err)
+
+		require.NoError(t, sut.SetInt64(slot, "id", int64(i)))
+		require.NoError(t, sut.SetString(slot, "name", fmt.Sprintf("user %d", i)))
+		require.NoError(t, sut.SetInt8(slot, "age", int8(i)))
+
+		slots = append(slots, slot)
+	}
+
+	// Строки растут и укорачиваются, соседние поля и записи не меняются
+	for round, name := range []string{"", "a much longer user name", "x"} {
+		for i, slot := range slots {
+			require.NoError(t, sut.SetString(slot, "name", name), "round %d", round)
+
+			assert.EqualValues(t, i, testutil.Must(sut.GetInt64(slot, "id")))
+			assert.EqualValues(t, name, testutil.Must(sut.GetString(slot, "name")))
+			assert.EqualValues(t, i, testutil.Must(sut.GetInt8(slot, "age")))
+		}
+	}
+
+	require.NoError(t, sut.Compact())
+
+	for i, slot := range slots {
+		assert.EqualValues(t, i, testutil.Must(sut.GetInt64(slot, "id")))
+		assert.EqualValues(t, "x", testutil.Must(sut.GetString(slot, "name")))
+	}
+
+	require.ErrorIs(t, sut.SetString(slots[0], "name", strings.Repeat("x", 25*4+1)), records.ErrValueTooLong)
+	require.ErrorIs(t, sut.SetInt64(slots[0], "name", 1), records.ErrFieldType)
+
+	trx.Unpin(sut.Block)
+	require.NoError(t, trx.Commit())
+}
+
+func (ts *RecordPageTestSuite) TestCompactionReusesSpace() {
+	t := ts.T()
+
+	sut, trx, clean := ts.newTestRecordPage(t)
+	defer clean()
+
+	// Заполняем страницу, удаляем каждую вторую запись и растим оставшиеся на освободившееся место
+	slots := make([]types.SlotID, 0)
+
+	for {
+		slot, err := sut.InsertAfter(records.StartSlotID)
+		if errors.Is(err, records.ErrSlotNotFound) {
+			break
+		}
+
+		require.NoError(t, err)
+		require.NoError(t, sut.SetInt64(slot, "id", int64(slot)))
+
+		slots = append(slots, slot)
+	}
+
+	for i := 0; i < len(slots); i += 2 {
+		require.NoError(t, sut.Delete(slots[i]))
+	}
+
+	name := strings.Repeat("y", 10)
+
+	for i := 1; i < len(slots); i += 2 {
+		require.NoError(t, sut.SetString(slots[i], "name", name), "slot %d", slots[i])
+	}
+
+	for i := 1; i < len(slots); i += 2 {
+		assert.EqualValues(t, slots[i], testutil.Must(sut.GetInt64(slots[i], "id")))
+		assert.EqualValues(t, name, testutil.Must(sut.GetString(slots[i], "name")))
+	}
+
+	// Когда место кончится, запись, которая не помещается, нужно переносить в другой блок
+	long := strings.Repeat("z", 100)
+
+	for i := 1; i < len(slots); i += 2 {
+		if err := sut.SetString(slots[i], "name", long); err != nil {
+			require.ErrorIs(t, err, records.ErrNoSpace)
+			assert.EqualValues(t, name, testutil.Must(sut.GetString(slots[i], "name")))
+
+			break
+		}
+
+		require.Less(t, i+2, len(slots), "page never ran out of space")
+	}
+
+	trx.Unpin(sut.Block)
+	require.NoError(t, trx.Commit())
+}
+
+func (ts *RecordPageTestSuite) TestForwarding() {
+	t := ts.T()
+
+	sut, trx, clean := ts.newTestRecordPage(t)
+	defer clean()
+
+	home, err := sut.InsertAfter(records.StartSlotID)
+	require.NoError(t, err)
+	require.NoError(t, sut.SetInt64(home, "id", 1))
+
+	row, err := sut.Row(home)
+	require.NoError(t, err)
+
+	moved, err := sut.InsertMoved(row)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, testutil.Must(sut.GetInt64(moved, "id")))
+
+	rid := types.RID{BlockNumber: 7, Slot: 3}
+	require.NoError(t, sut.SetForward(home, rid))
+
+	fwd, ok, err := sut.Forward(home)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, rid, fwd)
+
+	_, ok, err = sut.Forward(moved)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = sut.GetInt64(home, "id")
+	require.ErrorIs(t, err, records.ErrSlotForwarded)
+
+	// Сканирование видит запись в слоте с указателем переноса, а перенесенную копию пропускает
+	next, err := sut.NextAfter(records.StartSlotID)
+	require.NoError(t, err)
+	assert.Equal(t, home, next)
+
+	_, err = sut.NextAfter(next)
+	require.ErrorIs(t, err, records.ErrSlotNotFound)
+
+	trx.Unpin(sut.Block)
+	require.NoError(t, trx.Commit())
+}
+
+func (ts *RecordPageTestSuite) TestRollback() {
+	t := ts.T()
+
+	sut, trx, clean := ts.newTestRecordPage(t)
+	defer clean()
+
+	slot, err := sut.InsertAfter(records.StartSlotID)
+	require.NoError(t, err)
+	require.NoError(t, sut.SetString(slot, "name", "first"))
+
+	require.NoError(t, trx.Savepoint("sp"))
+
+	require.NoError(t, sut.SetString(slot, "name", "a much longer name"))
+	require.NoError(t, sut.Compact())
+
+	for i := 0; i < 5; i++ {
+		_, err := sut.InsertAfter(records.StartSlotID)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, trx.RollbackToSavepoint("sp"))
+
+	assert.EqualValues(t, "first", testutil.Must(sut.GetString(slot, "name")))
+
+	_, err = sut.NextAfter(slot)
+	require.ErrorIs(t, err, records.ErrSlotNotFound)
+
+	trx.Unpin(sut.Block)
+	require.NoError(t, trx.Commit())
 }
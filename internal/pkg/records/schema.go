@@ -14,8 +14,19 @@ const (
 	Int64Field
 	StringField
 	Int8Field
+	// TextField и BlobField — строки и байты без ограничения длины. Длинные значения лежат вне записи, см. Overflow
+	TextField
+	BlobField
 )
 
+// LargeInlineMax — наибольшее значение TEXT или BLOB, которое хранится в самой записи
+const LargeInlineMax = 255
+
+// IsLarge проверяет, что значения поля могут храниться вне записи
+func (t FieldType) IsLarge() bool {
+	return t == TextField || t == BlobField
+}
+
 type FieldInfo struct {
 	Type   FieldType
 	Length int64
@@ -30,6 +41,8 @@ func (fi FieldInfo) BytesLen() uint32 {
 		return types.PageInt64BytesLen()
 	case StringField:
 		return types.PageStringBytesLen(fi.Length)
+	case TextField, BlobField:
+		return types.Int32Size + LargeInlineMax
 	default:
 		return 0
 	}
@@ -109,6 +122,25 @@ func (s *Schema) AddStringField(name string, length int64) {
 	s.AddField(name, StringField, length)
 }
 
+func (s *Schema) AddTextField(name string) {
+	s.AddField(name, TextField, 0)
+}
+
+func (s *Schema) AddBlobField(name string) {
+	s.AddField(name, BlobField, 0)
+}
+
+// HasLargeFields проверяет, что в схеме есть поля TEXT или BLOB
+func (s Schema) HasLargeFields() bool {
+	for _, field := range s.info {
+		if field.Type.IsLarge() {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (s *Schema) AddAll(schema Schema) {
 	for _, name := range schema.Fields() {
 		field, ok := schema.Field(name)
@@ -133,6 +165,10 @@ func (s Schema) String() string {
 			str = fmt.Sprintf("%s int64", name)
 		case StringField:
 			str = fmt.Sprintf("%s varchar(%d)", name, field.Length)
+		case TextField:
+			str = fmt.Sprintf("%s text", name)
+		case BlobField:
+			str = fmt.Sprintf("%s blob", name)
 		}
 
 		fields = append(fields, str)
@@ -56,3 +56,29 @@ func (ts *SchemaTestSuite) TestAddSchema() {
 
 	assert.Equal(t, "id int64, username varchar(128), job varchar(64), age int8", sut.String())
 }
+
+func (ts *SchemaTestSuite) TestLargeFields() {
+	t := ts.T()
+
+	sut := records.NewSchema()
+	sut.AddInt64Field("id")
+	sut.AddStringField("title", 20)
+
+	assert.False(t, sut.HasLargeFields())
+
+	sut.AddTextField("body")
+	sut.AddBlobField("image")
+
+	assert.True(t, sut.HasLargeFields())
+	assert.Equal(t, "id int64, title varchar(20), body text, image blob", sut.String())
+
+	assert.Equal(t, records.TextField, sut.Type("body"))
+	assert.Equal(t, records.BlobField, sut.Type("image"))
+	assert.True(t, sut.Type("body").IsLarge())
+	assert.True(t, sut.Type("image").IsLarge())
+	assert.False(t, sut.Type("title").IsLarge())
+
+	field, ok := sut.Field("body")
+	assert.True(t, ok)
+	assert.EqualValues(t, 4+records.LargeInlineMax, field.BytesLen())
+}
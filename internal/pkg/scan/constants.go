@@ -1,7 +1,9 @@
 package scan
 
 import (
+	"bytes"
 	"encoding/binary"
+	"encoding/hex"
 	"strconv"
 
 	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
@@ -166,6 +168,9 @@ func (c StringConstant) CompareTo(another Constant) CompResult {
 	switch another.Type() { //nolint:exhaustive
 	case records.StringField:
 		value, _ = another.Value().(string)
+	case records.BlobField:
+		v, _ := another.Value().([]byte)
+		value = string(v)
 	default:
 		return CompUncomparable
 	}
@@ -180,3 +185,82 @@ func (c StringConstant) CompareTo(another Constant) CompResult {
 
 	return CompEqual
 }
+
+func NewBytesConstant(value []byte) BytesConstant {
+	return BytesConstant{
+		vType: records.BlobField,
+		value: value,
+	}
+}
+
+// BytesConstant — значение поля BLOB. Сравнивается побайтно с BLOB и строками
+type BytesConstant struct {
+	value []byte
+	vType records.FieldType
+}
+
+func (c BytesConstant) Value() any {
+	return c.value
+}
+
+func (c BytesConstant) Type() records.FieldType {
+	return c.vType
+}
+
+func (c BytesConstant) String() string {
+	return `x'` + hex.EncodeToString(c.value) + `'`
+}
+
+func (c BytesConstant) Hash() uint64 {
+	return xxh3.Hash(c.value)
+}
+
+func (c BytesConstant) CompareTo(another Constant) CompResult {
+	var value []byte
+
+	switch another.Type() { //nolint:exhaustive
+	case records.BlobField:
+		value, _ = another.Value().([]byte)
+	case records.StringField:
+		v, _ := another.Value().(string)
+		value = []byte(v)
+	default:
+		return CompUncomparable
+	}
+
+	return CompResult(bytes.Compare(c.value, value))
+}
+
+// NullConstant — значение NULL. У NULL нет типа, и оно несравнимо ни с одним значением, даже с другим NULL
+type NullConstant struct{}
+
+func NewNullConstant() NullConstant {
+	return NullConstant{}
+}
+
+// IsNull проверяет, что значение — NULL
+func IsNull(c Constant) bool {
+	_, ok := c.(NullConstant)
+
+	return ok
+}
+
+func (c NullConstant) Value() any {
+	return nil
+}
+
+func (c NullConstant) Type() records.FieldType {
+	return records.NotFoundField
+}
+
+func (c NullConstant) String() string {
+	return "null"
+}
+
+func (c NullConstant) Hash() uint64 {
+	return xxh3.Hash(nil)
+}
+
+func (c NullConstant) CompareTo(another Constant) CompResult {
+	return CompUncomparable
+}
@@ -1,10 +1,12 @@
 package scan_test
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
 )
 
@@ -12,6 +14,8 @@ var (
 	_ scan.Constant = scan.Int64Constant{}
 	_ scan.Constant = scan.Int8Constant{}
 	_ scan.Constant = scan.StringConstant{}
+	_ scan.Constant = scan.NullConstant{}
+	_ scan.Constant = scan.BytesConstant{}
 )
 
 type ConstantsTestSuite struct {
@@ -95,3 +99,48 @@ func (ts *ConstantsTestSuite) TestStringConstant() {
 
 	assert.Equal(t, uint64(0x9ec9f7918d7dfc40), sut.Hash())
 }
+
+func (ts *ConstantsTestSuite) TestNullConstant() {
+	t := ts.T()
+
+	sut := scan.NewNullConstant()
+
+	assert.Nil(t, sut.Value())
+	assert.Equal(t, "null", sut.String())
+	assert.True(t, scan.IsNull(sut))
+	assert.False(t, scan.IsNull(scan.NewInt64Constant(0)))
+	assert.False(t, scan.IsNull(scan.NewStringConstant("")))
+
+	assert.Equal(t, scan.CompUncomparable, sut.CompareTo(sut))
+	assert.Equal(t, scan.CompUncomparable, sut.CompareTo(scan.NewInt64Constant(0)))
+	assert.Equal(t, scan.CompUncomparable, scan.NewInt64Constant(0).CompareTo(sut))
+	assert.Equal(t, scan.CompUncomparable, scan.NewInt8Constant(0).CompareTo(sut))
+	assert.Equal(t, scan.CompUncomparable, scan.NewStringConstant("").CompareTo(sut))
+
+	assert.Equal(t, sut.Hash(), scan.NewNullConstant().Hash())
+}
+
+func (ts *ConstantsTestSuite) TestBytesConstant() {
+	t := ts.T()
+
+	value := []byte{0, 1, 't', 'e', 's', 't'}
+	sut := scan.NewBytesConstant(value)
+
+	res, ok := sut.Value().([]byte)
+	assert.True(t, ok)
+	assert.Equal(t, value, res)
+	assert.Equal(t, records.BlobField, sut.Type())
+	assert.Equal(t, `x'000174657374'`, sut.String())
+
+	assert.Equal(t, scan.CompEqual, sut.CompareTo(scan.NewBytesConstant(value)))
+	assert.Equal(t, scan.CompLess, sut.CompareTo(scan.NewBytesConstant(append(value, 0))))
+	assert.Equal(t, scan.CompGreat, sut.CompareTo(scan.NewBytesConstant(value[:1])))
+
+	assert.Equal(t, scan.CompEqual, scan.NewBytesConstant([]byte("test")).CompareTo(scan.NewStringConstant("test")))
+	assert.Equal(t, scan.CompEqual, scan.NewStringConstant("test").CompareTo(scan.NewBytesConstant([]byte("test"))))
+
+	assert.Equal(t, scan.CompUncomparable, sut.CompareTo(scan.NewInt64Constant(0)))
+	assert.Equal(t, scan.CompUncomparable, sut.CompareTo(scan.NewNullConstant()))
+
+	assert.Equal(t, sut.Hash(), scan.NewBytesConstant(bytes.Clone(value)).Hash())
+}
@@ -1,7 +1,10 @@
 package scan
 
 import (
+	"io"
+
 	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/concurrency"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
 )
 
@@ -16,7 +19,22 @@ type TRXInt interface {
 	SetString(block types.Block, offset uint32, value string, okToLog bool) error
 	SetInt64(block types.Block, offset uint32, value int64, okToLog bool) error
 	SetInt8(block types.Block, offset uint32, value int8, okToLog bool) error
+	FetchBytes(block types.Block, offset uint32, size int) ([]byte, error)
+	ReadPage(block types.Block) (*types.Page, error)
+	EndRead(block types.Block)
+	PutBytes(block types.Block, offset uint32, value []byte, okToLog bool) error
 	Size(filename string) (types.BlockID, error)
+	SLock(block types.Block, noWait bool) error
+	XLock(block types.Block, noWait bool) error
+	BeginRead(block types.Block, noWait bool) error
+	SLockRow(block types.Block, slot types.SlotID, noWait bool) error
+	XLockRow(block types.Block, slot types.SlotID, noWait bool) error
+	SLockKey(key concurrency.Resource) error
+	XLockKey(key concurrency.Resource) error
+	XLockNextKey(key concurrency.Resource) error
+	ReleaseNextKey(key concurrency.Resource)
+	ReleaseRead(block types.Block)
+	IsolationLevel() concurrency.IsolationLevel
 }
 
 type Scan interface {
@@ -33,6 +51,20 @@ type Scan interface {
 	GetVal(fieldName string) (Constant, error)
 }
 
+// LargeValueScan — скан, который отдает поля TEXT и BLOB по частям, не собирая длинное значение в памяти
+type LargeValueScan interface {
+	// GetLargeReader возвращает читателя значения и его длину. Для NULL читатель nil
+	GetLargeReader(fieldName string) (io.Reader, int64, error)
+}
+
+// RowLockScan — скан, который блокирует выбранные записи для SELECT ... FOR UPDATE | FOR SHARE
+type RowLockScan interface {
+	// LockingRows сообщает, что скан блокирует записи
+	LockingRows() bool
+	// LockRow блокирует текущую запись. false — запись нужно пропустить
+	LockRow() (bool, error)
+}
+
 type UpdateScan interface {
 	Scan
 
@@ -74,8 +74,13 @@ func ForEachValue(ts Scan, call func(name string, fieldType records.FieldType, v
 			value, err = ts.GetInt64(name)
 		case records.Int8Field:
 			value, err = ts.GetInt8(name)
-		case records.StringField:
+		case records.StringField, records.TextField:
 			value, err = ts.GetString(name)
+		case records.BlobField:
+			var c Constant
+			if c, err = ts.GetVal(name); err == nil {
+				value = c.Value()
+			}
 		default:
 			err = ErrUnknownFieldType
 		}
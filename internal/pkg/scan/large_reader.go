@@ -0,0 +1,33 @@
+package scan
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// LargeReader возвращает читателя поля TEXT или BLOB и длину значения, для NULL читатель nil.
+// Если скан не умеет отдавать значение по частям, оно читается целиком через GetVal
+func LargeReader(s Scan, fieldName string) (io.Reader, int64, error) {
+	if ls, ok := s.(LargeValueScan); ok {
+		return ls.GetLargeReader(fieldName)
+	}
+
+	val, err := s.GetVal(fieldName)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch v := val.Value().(type) {
+	case nil:
+		return nil, 0, nil
+	case string:
+		return strings.NewReader(v), int64(len(v)), nil
+	case []byte:
+		return bytes.NewReader(v), int64(len(v)), nil
+	default:
+		return nil, 0, errors.WithMessagef(ErrScan, "field '%s' is not text or blob", fieldName)
+	}
+}
@@ -0,0 +1,51 @@
+package scan
+
+// LockStrength — какую блокировку читающая выборка берет на записи: FOR SHARE или FOR UPDATE
+type LockStrength uint8
+
+const (
+	LockNone LockStrength = iota
+	LockForShare
+	LockForUpdate
+)
+
+// LockWaitPolicy — что делать, если запись заблокирована другой транзакцией
+type LockWaitPolicy uint8
+
+const (
+	// LockWait — ждем блокировку до таймаута
+	LockWait LockWaitPolicy = iota
+	// LockNoWait — сразу возвращаем ошибку
+	LockNoWait
+	// LockSkipLocked — пропускаем заблокированную запись, а блок, который меняет другая транзакция, — целиком
+	LockSkipLocked
+)
+
+// LockingClause описывает блокирующее чтение: SELECT ... FOR UPDATE | FOR SHARE [NOWAIT | SKIP LOCKED]
+type LockingClause struct {
+	Strength LockStrength
+	Wait     LockWaitPolicy
+}
+
+func (lc LockingClause) String() string {
+	var s string
+
+	switch lc.Strength {
+	case LockNone:
+		return ""
+	case LockForShare:
+		s = "for share"
+	case LockForUpdate:
+		s = "for update"
+	}
+
+	switch lc.Wait {
+	case LockWait:
+	case LockNoWait:
+		s += " nowait"
+	case LockSkipLocked:
+		s += " skip locked"
+	}
+
+	return s
+}
@@ -0,0 +1,109 @@
+package scan
+
+import (
+	"github.com/pkg/errors"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
+)
+
+// MemoryScan — сканирование записей из памяти. Используется для виртуальных таблиц
+type MemoryScan struct {
+	schema  records.Schema
+	rows    [][]Constant
+	fields  map[string]int
+	current int
+}
+
+// NewMemoryScan создаёт сканирование по записям rows. Значения в записи идут в порядке полей схемы
+func NewMemoryScan(schema records.Schema, rows [][]Constant) *MemoryScan {
+	s := &MemoryScan{
+		schema:  schema,
+		rows:    rows,
+		fields:  make(map[string]int, schema.Count()),
+		current: -1,
+	}
+
+	for i, field := range schema.Fields() {
+		s.fields[field] = i
+	}
+
+	return s
+}
+
+func (s *MemoryScan) Schema() records.Schema {
+	return s.schema
+}
+
+func (s *MemoryScan) Close() {}
+
+func (s *MemoryScan) BeforeFirst() error {
+	s.current = -1
+
+	return nil
+}
+
+func (s *MemoryScan) Next() (bool, error) {
+	if s.current < len(s.rows) {
+		s.current++
+	}
+
+	return s.current < len(s.rows), nil
+}
+
+func (s *MemoryScan) HasField(fieldName string) bool {
+	return s.schema.HasField(fieldName)
+}
+
+func (s *MemoryScan) GetInt64(fieldName string) (int64, error) {
+	val, err := s.GetVal(fieldName)
+	if err != nil {
+		return 0, err
+	}
+
+	v, ok := val.Value().(int64)
+	if !ok {
+		return 0, errors.WithMessagef(ErrScan, "field '%s' is not int64", fieldName)
+	}
+
+	return v, nil
+}
+
+func (s *MemoryScan) GetInt8(fieldName string) (int8, error) {
+	val, err := s.GetVal(fieldName)
+	if err != nil {
+		return 0, err
+	}
+
+	v, ok := val.Value().(int8)
+	if !ok {
+		return 0, errors.WithMessagef(ErrScan, "field '%s' is not int8", fieldName)
+	}
+
+	return v, nil
+}
+
+func (s *MemoryScan) GetString(fieldName string) (string, error) {
+	val, err := s.GetVal(fieldName)
+	if err != nil {
+		return "", err
+	}
+
+	v, ok := val.Value().(string)
+	if !ok {
+		return "", errors.WithMessagef(ErrScan, "field '%s' is not string", fieldName)
+	}
+
+	return v, nil
+}
+
+func (s *MemoryScan) GetVal(fieldName string) (Constant, error) {
+	i, ok := s.fields[fieldName]
+	if !ok {
+		return nil, ErrFieldNotFound
+	}
+
+	if s.current < 0 || s.current >= len(s.rows) {
+		return nil, ErrEmptyScan
+	}
+
+	return s.rows[s.current][i], nil
+}
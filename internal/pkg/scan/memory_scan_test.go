@@ -0,0 +1,84 @@
+package scan_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
+)
+
+type MemoryScanTestsuite struct {
+	suite.Suite
+}
+
+var _ scan.Scan = &scan.MemoryScan{}
+
+func TestMemoryScanTestsuite(t *testing.T) {
+	suite.Run(t, new(MemoryScanTestsuite))
+}
+
+func (ts *MemoryScanTestsuite) TestIterate() {
+	t := ts.T()
+
+	schema := records.NewSchema()
+	schema.AddInt64Field("id")
+	schema.AddStringField("name", 10)
+	schema.AddInt8Field("age")
+
+	sut := scan.NewMemoryScan(schema, [][]scan.Constant{
+		{scan.NewInt64Constant(1), scan.NewStringConstant("one"), scan.NewInt8Constant(10)},
+		{scan.NewInt64Constant(2), scan.NewStringConstant("two"), scan.NewInt8Constant(20)},
+	})
+	defer sut.Close()
+
+	_, err := sut.GetInt64("id")
+	assert.ErrorIs(t, err, scan.ErrEmptyScan)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, sut.BeforeFirst())
+
+		ids := []int64{}
+
+		require.NoError(t, scan.ForEach(sut, func() (bool, error) {
+			id, err := sut.GetInt64("id")
+			require.NoError(t, err)
+
+			name, err := sut.GetString("name")
+			require.NoError(t, err)
+
+			age, err := sut.GetInt8("age")
+			require.NoError(t, err)
+
+			assert.Equal(t, int8(id*10), age)
+			assert.NotEmpty(t, name)
+
+			ids = append(ids, id)
+
+			return false, nil
+		}))
+
+		assert.Equal(t, []int64{1, 2}, ids)
+	}
+
+	ok, err := sut.Next()
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.True(t, sut.HasField("name"))
+	assert.False(t, sut.HasField("unknown"))
+
+	_, err = sut.GetVal("unknown")
+	assert.ErrorIs(t, err, scan.ErrFieldNotFound)
+
+	require.NoError(t, sut.BeforeFirst())
+
+	ok, err = sut.Next()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	_, err = sut.GetString("id")
+	assert.ErrorIs(t, err, scan.ErrScan)
+}
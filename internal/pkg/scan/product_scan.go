@@ -1,6 +1,10 @@
 package scan
 
-import "github.com/unhandled-exception/sophiadb/internal/pkg/records"
+import (
+	"io"
+
+	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
+)
 
 type ProductScan struct {
 	s1     Scan
@@ -113,3 +117,24 @@ func (s *ProductScan) GetVal(fieldName string) (Constant, error) {
 
 	return s.s2.GetVal(fieldName)
 }
+
+func (s *ProductScan) LockingRows() bool {
+	return lockingRows(s.s1) || lockingRows(s.s2)
+}
+
+// LockRow блокирует текущие записи обеих таблиц
+func (s *ProductScan) LockRow() (bool, error) {
+	if ok, err := lockRow(s.s1); !ok || err != nil {
+		return false, err
+	}
+
+	return lockRow(s.s2)
+}
+
+func (s *ProductScan) GetLargeReader(fieldName string) (io.Reader, int64, error) {
+	if s.s1.Schema().HasField(fieldName) {
+		return LargeReader(s.s1, fieldName)
+	}
+
+	return LargeReader(s.s2, fieldName)
+}
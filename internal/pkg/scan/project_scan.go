@@ -1,6 +1,10 @@
 package scan
 
-import "github.com/unhandled-exception/sophiadb/internal/pkg/records"
+import (
+	"io"
+
+	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
+)
 
 type ProjectScan struct {
 	s      Scan
@@ -78,3 +82,19 @@ func (s *ProjectScan) GetVal(fieldName string) (Constant, error) {
 
 	return s.s.GetVal(fieldName)
 }
+
+func (s *ProjectScan) LockingRows() bool {
+	return lockingRows(s.s)
+}
+
+func (s *ProjectScan) LockRow() (bool, error) {
+	return lockRow(s.s)
+}
+
+func (s *ProjectScan) GetLargeReader(fieldName string) (io.Reader, int64, error) {
+	if !s.HasField(fieldName) {
+		return nil, 0, ErrFieldNotFound
+	}
+
+	return LargeReader(s.s, fieldName)
+}
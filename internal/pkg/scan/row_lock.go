@@ -0,0 +1,17 @@
+package scan
+
+// lockingRows проверяет, что скан блокирует выбранные записи
+func lockingRows(s Scan) bool {
+	rs, ok := s.(RowLockScan)
+
+	return ok && rs.LockingRows()
+}
+
+// lockRow блокирует текущую запись скана, если скан блокирует записи
+func lockRow(s Scan) (bool, error) {
+	if rs, ok := s.(RowLockScan); ok {
+		return rs.LockRow()
+	}
+
+	return true, nil
+}
@@ -1,6 +1,8 @@
 package scan
 
 import (
+	"io"
+
 	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
 )
@@ -45,6 +47,13 @@ func (ss *SelectScan) Next() (bool, error) {
 			return false, err
 		}
 
+		if ok && lockingRows(ss.s) {
+			ok, err = ss.lockRow()
+			if err != nil {
+				return false, err
+			}
+		}
+
 		if ok {
 			return true, nil
 		}
@@ -53,6 +62,17 @@ func (ss *SelectScan) Next() (bool, error) {
 	return false, nil
 }
 
+// lockRow блокирует запись, которая подошла под условие. Пока транзакция ждала блокировку,
+// запись могли изменить, поэтому условие проверяется еще раз
+func (ss *SelectScan) lockRow() (bool, error) {
+	ok, err := lockRow(ss.s)
+	if !ok || err != nil {
+		return false, err
+	}
+
+	return ss.pred.IsSatisfied(ss.s)
+}
+
 func (ss *SelectScan) HasField(fieldName string) bool {
 	return ss.s.HasField(fieldName)
 }
@@ -73,6 +93,10 @@ func (ss *SelectScan) GetVal(fieldName string) (Constant, error) {
 	return ss.s.GetVal(fieldName)
 }
 
+func (ss *SelectScan) GetLargeReader(fieldName string) (io.Reader, int64, error) {
+	return LargeReader(ss.s, fieldName)
+}
+
 func (ss *SelectScan) SetInt64(fieldName string, value int64) error {
 	us, ok := ss.s.(UpdateScan)
 	if !ok {
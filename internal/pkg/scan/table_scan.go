@@ -3,10 +3,14 @@ package scan
 import (
 	"github.com/pkg/errors"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/concurrency"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
 )
 
-const tableSuffix = ".tbl"
+const (
+	tableSuffix    = ".tbl"
+	overflowSuffix = ".ovf"
+)
 
 type TableScan struct {
 	trx       TRXInt
@@ -16,10 +20,39 @@ type TableScan struct {
 
 	rp          *records.RecordPage
 	currentSlot types.SlotID
+
+	fwd *records.RecordPage // Страница, куда перенесена текущая запись
+
+	overflow *records.Overflow // Страницы переполнения с длинными значениями TEXT и BLOB
+
+	locking      LockingClause
+	skippedBlock bool
+	// rowLocked — текущая запись под эксклюзивной блокировкой транзакции, и перед изменением ее брать не нужно
+	rowLocked bool
+
+	readAheadEnd types.BlockID // Блоки до этого номера уже запрошены упреждающим чтением
 }
 
-func NewTableScan(trx TRXInt, tablename string, layout records.Layout) (*TableScan, error) {
-	filename := tablename + tableSuffix
+// readAheadTRX — транзакция, которая умеет заранее читать блоки в пул буферов
+type readAheadTRX interface {
+	ReadAheadWindow() int
+	Prefetch(first types.Block, count int)
+}
+
+type TableScanOpt func(*TableScan)
+
+// TableFilename возвращает имя файла с записями таблицы
+func TableFilename(tablename string) string {
+	return tablename + tableSuffix
+}
+
+// OverflowFilename возвращает имя файла со страницами переполнения таблицы
+func OverflowFilename(tablename string) string {
+	return tablename + overflowSuffix
+}
+
+func NewTableScan(trx TRXInt, tablename string, layout records.Layout, opts ...TableScanOpt) (*TableScan, error) {
+	filename := TableFilename(tablename)
 
 	ts := &TableScan{
 		trx:       trx,
@@ -28,24 +61,24 @@ func NewTableScan(trx TRXInt, tablename string, layout records.Layout) (*TableSc
 		layout:    layout,
 	}
 
-	size, err := trx.Size(filename)
-	if err != nil {
-		return nil, errors.WithMessage(ErrScan, err.Error())
+	for _, opt := range opts {
+		opt(ts)
 	}
 
-	if size == 0 {
-		if err := ts.moveToNewBlock(); err != nil {
-			return nil, err
-		}
-	} else {
-		if err := ts.moveToBlock(0); err != nil {
-			return nil, err
-		}
+	if err := ts.BeforeFirst(); err != nil {
+		return nil, err
 	}
 
 	return ts, nil
 }
 
+// WithLocking включает блокирующее чтение: записи, которые выбрал запрос, блокируются через LockRow
+func WithLocking(lc LockingClause) TableScanOpt {
+	return func(ts *TableScan) {
+		ts.locking = lc
+	}
+}
+
 func (ts *TableScan) Layout() records.Layout {
 	return ts.layout
 }
@@ -58,19 +91,52 @@ func (ts *TableScan) Close() {
 	if ts.rp != nil {
 		ts.trx.Unpin(ts.rp.Block)
 	}
+
+	ts.closeForward()
 }
 
+// BeforeFirst встает перед первой записью таблицы.
+// Для пустого файла блок не создаем, чтобы чтение не требовало записи, — блок добавит первая вставка
 func (ts *TableScan) BeforeFirst() error {
+	size, err := ts.trx.Size(ts.Filename)
+	if err != nil {
+		return errors.WithMessage(ErrScan, err.Error())
+	}
+
+	ts.readAheadEnd = 0
+
+	if size == 0 {
+		ts.Close()
+
+		ts.rp = nil
+		ts.currentSlot = records.StartSlotID
+		ts.skippedBlock = false
+		ts.rowLocked = false
+
+		return nil
+	}
+
 	return ts.moveToBlock(0)
 }
 
 func (ts *TableScan) Next() (bool, error) {
-	currentSlot, err := ts.rp.NextAfter(ts.currentSlot)
+	if ts.rp == nil {
+		if err := ts.BeforeFirst(); err != nil {
+			return false, err
+		}
+
+		if ts.rp == nil {
+			return false, nil
+		}
+	}
+
+	currentSlot, err := ts.nextAfter(ts.currentSlot)
 	if err != nil && !errors.Is(err, records.ErrSlotNotFound) {
 		return false, errors.WithMessage(ErrScan, err.Error())
 	}
 
 	ts.currentSlot = currentSlot
+	ts.rowLocked = false
 
 	for ts.currentSlot < 0 {
 		ok, err := ts.atLastBlock()
@@ -86,8 +152,8 @@ func (ts *TableScan) Next() (bool, error) {
 			return false, err
 		}
 
-		currentSlot, err = ts.rp.NextAfter(ts.currentSlot)
-		if err != nil {
+		currentSlot, err = ts.nextAfter(ts.currentSlot)
+		if err != nil && !errors.Is(err, records.ErrSlotNotFound) {
 			return false, errors.WithMessage(ErrScan, err.Error())
 		}
 
@@ -98,7 +164,9 @@ func (ts *TableScan) Next() (bool, error) {
 }
 
 func (ts *TableScan) GetInt64(fieldName string) (int64, error) {
-	val, err := ts.rp.GetInt64(ts.currentSlot, fieldName)
+	val, err := readField(ts, func(rp *records.RecordPage, slot types.SlotID) (int64, error) {
+		return rp.GetInt64(slot, fieldName)
+	})
 	if err != nil {
 		return 0, errors.WithMessage(ErrScan, err.Error())
 	}
@@ -107,7 +175,9 @@ func (ts *TableScan) GetInt64(fieldName string) (int64, error) {
 }
 
 func (ts *TableScan) GetInt8(fieldName string) (int8, error) {
-	val, err := ts.rp.GetInt8(ts.currentSlot, fieldName)
+	val, err := readField(ts, func(rp *records.RecordPage, slot types.SlotID) (int8, error) {
+		return rp.GetInt8(slot, fieldName)
+	})
 	if err != nil {
 		return 0, errors.WithMessage(ErrScan, err.Error())
 	}
@@ -115,8 +185,17 @@ func (ts *TableScan) GetInt8(fieldName string) (int8, error) {
 	return val, nil
 }
 
+// GetString читает поля VARCHAR и TEXT
 func (ts *TableScan) GetString(fieldName string) (string, error) {
-	val, err := ts.rp.GetString(ts.currentSlot, fieldName)
+	if ts.Layout().Schema.Type(fieldName) == records.TextField {
+		val, err := ts.getLarge(fieldName)
+
+		return string(val), err
+	}
+
+	val, err := readField(ts, func(rp *records.RecordPage, slot types.SlotID) (string, error) {
+		return rp.GetString(slot, fieldName)
+	})
 	if err != nil {
 		return "", errors.WithMessage(ErrScan, err.Error())
 	}
@@ -124,8 +203,33 @@ func (ts *TableScan) GetString(fieldName string) (string, error) {
 	return val, nil
 }
 
+// IsNull проверяет, что поле текущей записи не задано
+func (ts *TableScan) IsNull(fieldName string) (bool, error) {
+	val, err := readField(ts, func(rp *records.RecordPage, slot types.SlotID) (bool, error) {
+		return rp.IsNull(slot, fieldName)
+	})
+	if err != nil {
+		return false, errors.WithMessage(ErrScan, err.Error())
+	}
+
+	return val, nil
+}
+
+// GetVal возвращает NullConstant, если поле не задано
 func (ts *TableScan) GetVal(fieldName string) (Constant, error) {
-	switch t := ts.Layout().Schema.Type(fieldName); t {
+	t := ts.Layout().Schema.Type(fieldName)
+	if t != records.NotFoundField {
+		isNull, err := ts.IsNull(fieldName)
+		if err != nil {
+			return nil, err
+		}
+
+		if isNull {
+			return NewNullConstant(), nil
+		}
+	}
+
+	switch t {
 	case records.Int64Field:
 		val, err := ts.GetInt64(fieldName)
 		if err != nil {
@@ -140,13 +244,20 @@ func (ts *TableScan) GetVal(fieldName string) (Constant, error) {
 		}
 
 		return NewInt8Constant(val), nil
-	case records.StringField:
+	case records.StringField, records.TextField:
 		val, err := ts.GetString(fieldName)
 		if err != nil {
 			return nil, err
 		}
 
 		return NewStringConstant(val), nil
+	case records.BlobField:
+		val, err := ts.GetBytes(fieldName)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewBytesConstant(val), nil
 	case records.NotFoundField:
 		return nil, errors.WithMessagef(ErrScan, "field '%s' not found", fieldName)
 	default:
@@ -159,7 +270,10 @@ func (ts *TableScan) HasField(fieldName string) bool {
 }
 
 func (ts *TableScan) SetInt64(fieldName string, value int64) error {
-	if err := ts.rp.SetInt64(ts.currentSlot, fieldName, value); err != nil {
+	err := ts.writeField(func(rp *records.RecordPage, slot types.SlotID) error {
+		return rp.SetInt64(slot, fieldName, value)
+	})
+	if err != nil {
 		return errors.WithMessage(ErrScan, err.Error())
 	}
 
@@ -167,15 +281,44 @@ func (ts *TableScan) SetInt64(fieldName string, value int64) error {
 }
 
 func (ts *TableScan) SetInt8(fieldName string, value int8) error {
-	if err := ts.rp.SetInt8(ts.currentSlot, fieldName, value); err != nil {
+	err := ts.writeField(func(rp *records.RecordPage, slot types.SlotID) error {
+		return rp.SetInt8(slot, fieldName, value)
+	})
+	if err != nil {
 		return errors.WithMessage(ErrScan, err.Error())
 	}
 
 	return nil
 }
 
+// SetString пишет поля VARCHAR и TEXT
 func (ts *TableScan) SetString(fieldName string, value string) error {
-	if err := ts.rp.SetString(ts.currentSlot, fieldName, value); err != nil {
+	if ts.Layout().Schema.Type(fieldName) == records.TextField {
+		return ts.setLarge(fieldName, []byte(value))
+	}
+
+	err := ts.writeField(func(rp *records.RecordPage, slot types.SlotID) error {
+		return rp.SetString(slot, fieldName, value)
+	})
+	if err != nil {
+		return errors.WithMessage(ErrScan, err.Error())
+	}
+
+	return nil
+}
+
+// SetNull сбрасывает поле текущей записи в NULL. Страницы переполнения прежнего значения освобождаются
+func (ts *TableScan) SetNull(fieldName string) error {
+	if ts.Layout().Schema.Type(fieldName).IsLarge() {
+		return ts.replaceLarge(fieldName, func(rp *records.RecordPage, slot types.SlotID) error {
+			return rp.SetNull(slot, fieldName)
+		})
+	}
+
+	err := ts.writeField(func(rp *records.RecordPage, slot types.SlotID) error {
+		return rp.SetNull(slot, fieldName)
+	})
+	if err != nil {
 		return errors.WithMessage(ErrScan, err.Error())
 	}
 
@@ -183,6 +326,10 @@ func (ts *TableScan) SetString(fieldName string, value string) error {
 }
 
 func (ts *TableScan) SetVal(fieldName string, value Constant) error {
+	if IsNull(value) && ts.HasField(fieldName) {
+		return ts.SetNull(fieldName)
+	}
+
 	//nolint:exhaustive
 	switch t := ts.Layout().Schema.Type(fieldName); t {
 	case records.Int64Field:
@@ -219,6 +366,21 @@ func (ts *TableScan) SetVal(fieldName string, value Constant) error {
 		if err := ts.SetString(fieldName, v); err != nil {
 			return err
 		}
+	case records.TextField, records.BlobField:
+		var v []byte
+
+		switch value := value.Value().(type) {
+		case string:
+			v = []byte(value)
+		case []byte:
+			v = value
+		default:
+			return errors.WithMessagef(ErrScan, "failed to convert fields (%s) constant to value (bytes)", fieldName)
+		}
+
+		if err := ts.setLarge(fieldName, v); err != nil {
+			return err
+		}
 	default:
 		return errors.WithMessagef(ErrScan, "unknown field type %d for field '%s'", t, fieldName)
 	}
@@ -227,6 +389,18 @@ func (ts *TableScan) SetVal(fieldName string, value Constant) error {
 }
 
 func (ts *TableScan) Insert() error {
+	if ts.rp == nil {
+		if err := ts.BeforeFirst(); err != nil {
+			return err
+		}
+
+		if ts.rp == nil {
+			if err := ts.moveToNewBlock(); err != nil {
+				return err
+			}
+		}
+	}
+
 	currentSlot, err := ts.rp.InsertAfter(ts.currentSlot)
 
 	if err != nil && !errors.Is(err, records.ErrSlotNotFound) {
@@ -259,10 +433,37 @@ func (ts *TableScan) Insert() error {
 		ts.currentSlot = currentSlot
 	}
 
+	// Новую запись до фиксации не видит никто, кроме этой транзакции, поэтому заблокировать ее другие не могли
+	ts.rowLocked = true
+
 	return nil
 }
 
 func (ts *TableScan) Delete() error {
+	if err := ts.lockForWrite(); err != nil {
+		return err
+	}
+
+	if err := ts.freeOverflow(); err != nil {
+		return err
+	}
+
+	rid, forwarded, err := ts.rp.Forward(ts.currentSlot)
+	if err != nil {
+		return err
+	}
+
+	if forwarded {
+		rp, err := ts.forwardPage(rid.BlockNumber)
+		if err != nil {
+			return err
+		}
+
+		if err := rp.Delete(rid.Slot); err != nil {
+			return err
+		}
+	}
+
 	if err := ts.rp.Delete(ts.currentSlot); err != nil {
 		return err
 	}
@@ -278,6 +479,14 @@ func (ts *TableScan) MoveToRID(rid types.RID) error {
 		Number:   rid.BlockNumber,
 	}
 
+	// По RID переходим к конкретной записи, поэтому занятый блок пропустить нельзя
+	switch locked, err := ts.lockBlock(block); {
+	case err != nil:
+		return err
+	case !locked:
+		return errors.WithMessagef(ErrScan, "%s: %s", concurrency.ErrLockNotAvailable, block)
+	}
+
 	rp, err := records.NewRecordPage(ts.trx, block, ts.Layout())
 	if err != nil {
 		return errors.WithMessage(ErrScan, err.Error())
@@ -285,6 +494,8 @@ func (ts *TableScan) MoveToRID(rid types.RID) error {
 
 	ts.rp = rp
 	ts.currentSlot = rid.Slot
+	ts.skippedBlock = false
+	ts.rowLocked = false
 
 	return nil
 }
@@ -301,6 +512,7 @@ func (ts *TableScan) RID() types.RID {
 }
 
 func (ts *TableScan) moveToBlock(blockNumber types.BlockID) error {
+	ts.readAhead(blockNumber)
 	ts.Close()
 
 	block := types.Block{
@@ -308,6 +520,11 @@ func (ts *TableScan) moveToBlock(blockNumber types.BlockID) error {
 		Number:   blockNumber,
 	}
 
+	locked, err := ts.lockBlock(block)
+	if err != nil {
+		return err
+	}
+
 	rp, err := records.NewRecordPage(ts.trx, block, ts.Layout())
 	if err != nil {
 		return errors.WithMessage(ErrScan, err.Error())
@@ -315,6 +532,8 @@ func (ts *TableScan) moveToBlock(blockNumber types.BlockID) error {
 
 	ts.rp = rp
 	ts.currentSlot = records.StartSlotID
+	ts.skippedBlock = !locked
+	ts.rowLocked = false
 
 	return nil
 }
@@ -339,6 +558,8 @@ func (ts *TableScan) moveToNewBlock() error {
 
 	ts.rp = rp
 	ts.currentSlot = records.StartSlotID
+	ts.skippedBlock = false
+	ts.rowLocked = false
 
 	return nil
 }
@@ -351,3 +572,316 @@ func (ts *TableScan) atLastBlock() (bool, error) {
 
 	return ts.rp.Block.Number == size-1, nil
 }
+
+// lockBlock готовит блок к блокирующему чтению. Возвращает false, если блок нужно пропустить (SKIP LOCKED).
+// Записи блокируются по одной в LockRow, а блок блокируется, только чтобы потом не ждать его напрасно:
+//   - FOR UPDATE на уровнях SERIALIZABLE и REPEATABLE READ сразу берет эксклюзивную блокировку блока. Прочитанный блок
+//     и так остается под разделяемой блокировкой до конца транзакции, и две транзакции, которые выбрали разные записи
+//     одного блока, не смогли бы потом повысить свои блокировки, чтобы изменить эти записи;
+//   - с NOWAIT и SKIP LOCKED проверяем, что блок не меняет другая транзакция. Ее незафиксированные изменения читать
+//     нельзя, а ждать фиксации NOWAIT и SKIP LOCKED не должны
+func (ts *TableScan) lockBlock(block types.Block) (bool, error) {
+	var err error
+
+	noWait := ts.locking.Wait != LockWait
+
+	switch {
+	case ts.locking.Strength == LockNone:
+		return true, nil
+	case ts.locking.Strength == LockForUpdate && ts.trx.IsolationLevel() != concurrency.ReadCommitted:
+		err = ts.trx.XLock(block, noWait)
+	case noWait:
+		if err = ts.trx.BeginRead(block, true); err == nil {
+			ts.trx.EndRead(block)
+		}
+	default:
+		return true, nil
+	}
+
+	return ts.lockResult(err)
+}
+
+// LockRow блокирует текущую запись для SELECT ... FOR UPDATE | FOR SHARE. Блокировка записи держится до конца
+// транзакции на любом уровне изоляции. Возвращает false, если запись нужно пропустить: ее держит другая транзакция
+// (SKIP LOCKED) или запись удалили, пока транзакция ждала блокировку
+func (ts *TableScan) LockRow() (bool, error) {
+	if !ts.LockingRows() || ts.rp == nil {
+		return true, nil
+	}
+
+	var err error
+
+	noWait := ts.locking.Wait != LockWait
+
+	switch ts.locking.Strength {
+	case LockNone:
+	case LockForShare:
+		err = ts.trx.SLockRow(ts.rp.Block, ts.currentSlot, noWait)
+	case LockForUpdate:
+		err = ts.trx.XLockRow(ts.rp.Block, ts.currentSlot, noWait)
+	}
+
+	if ok, err := ts.lockResult(err); !ok {
+		return false, err
+	}
+
+	ts.rowLocked = ts.locking.Strength == LockForUpdate
+
+	next, err := ts.rp.NextAfter(ts.currentSlot - 1)
+	switch {
+	case errors.Is(err, records.ErrSlotNotFound):
+		return false, nil
+	case err != nil:
+		return false, errors.WithMessage(ErrScan, err.Error())
+	}
+
+	return next == ts.currentSlot, nil
+}
+
+// LockingRows проверяет, что скан блокирует выбранные записи
+func (ts *TableScan) LockingRows() bool {
+	return ts.locking.Strength != LockNone
+}
+
+// lockResult разбирает результат взятия блокировки: занятый блок или запись с SKIP LOCKED пропускаются
+func (ts *TableScan) lockResult(err error) (bool, error) {
+	switch {
+	case err == nil:
+		return true, nil
+	case ts.locking.Wait == LockSkipLocked && errors.Is(err, concurrency.ErrLockNotAvailable):
+		return false, nil
+	default:
+		return false, errors.WithMessage(ErrScan, err.Error())
+	}
+}
+
+// lockForWrite берет эксклюзивную блокировку текущей записи перед ее изменением,
+// чтобы изменение дождалось блокировок FOR SHARE и FOR UPDATE других транзакций
+func (ts *TableScan) lockForWrite() error {
+	if ts.rowLocked {
+		return nil
+	}
+
+	if ts.rp == nil {
+		return errors.WithMessage(ErrScan, "no current record")
+	}
+
+	if err := ts.trx.XLockRow(ts.rp.Block, ts.currentSlot, false); err != nil {
+		return errors.WithMessage(ErrScan, err.Error())
+	}
+
+	ts.rowLocked = true
+
+	return nil
+}
+
+// nextAfter ищет следующую запись в текущем блоке. В пропущенном блоке записей нет
+func (ts *TableScan) nextAfter(slot types.SlotID) (types.SlotID, error) {
+	if ts.skippedBlock {
+		return records.StartSlotID, nil
+	}
+
+	return ts.rp.NextAfter(slot)
+}
+
+// readAhead при последовательном чтении таблицы заранее читает в пул следующие блоки.
+// Следующую порцию запрашиваем, когда до конца уже запрошенных блоков остается половина окна
+func (ts *TableScan) readAhead(blockNumber types.BlockID) {
+	trx, ok := ts.trx.(readAheadTRX)
+	if !ok || ts.rp == nil || ts.rp.Block.Number+1 != blockNumber {
+		return
+	}
+
+	window := types.BlockID(trx.ReadAheadWindow())
+	if window <= 0 || blockNumber+window/2 < ts.readAheadEnd { //nolint:mnd
+		return
+	}
+
+	start := blockNumber + 1
+	if start < ts.readAheadEnd {
+		start = ts.readAheadEnd
+	}
+	end := blockNumber + 1 + window
+
+	trx.Prefetch(types.Block{Filename: ts.Filename, Number: start}, int(end-start))
+
+	ts.readAheadEnd = end
+}
+
+// readField читает поле текущей записи. Перенесенную запись читает по указателю переноса
+func readField[T any](ts *TableScan, get func(rp *records.RecordPage, slot types.SlotID) (T, error)) (T, error) {
+	val, err := get(ts.rp, ts.currentSlot)
+	if !errors.Is(err, records.ErrSlotForwarded) {
+		return val, err
+	}
+
+	rp, slot, err := ts.forwarded()
+	if err != nil {
+		return val, err
+	}
+
+	return get(rp, slot)
+}
+
+// writeField пишет поле текущей записи. Если запись перестала помещаться в свою страницу, переносит ее в другой блок.
+// Запись, которая не помещается и в новый блок, записать нельзя
+func (ts *TableScan) writeField(set func(rp *records.RecordPage, slot types.SlotID) error) error {
+	if err := ts.lockForWrite(); err != nil {
+		return err
+	}
+
+	rp, slot := ts.rp, ts.currentSlot
+
+	for fresh := false; ; {
+		err := set(rp, slot)
+
+		switch {
+		case errors.Is(err, records.ErrSlotForwarded) && rp == ts.rp:
+			if rp, slot, err = ts.forwarded(); err != nil {
+				return err
+			}
+		case errors.Is(err, records.ErrNoSpace) && !fresh:
+			if rp, slot, fresh, err = ts.moveRow(rp, slot); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+	}
+}
+
+// forwarded возвращает страницу и слот, где лежит текущая запись
+func (ts *TableScan) forwarded() (*records.RecordPage, types.SlotID, error) {
+	rid, ok, err := ts.rp.Forward(ts.currentSlot)
+	if err != nil || !ok {
+		return ts.rp, ts.currentSlot, err
+	}
+
+	rp, err := ts.forwardPage(rid.BlockNumber)
+	if err != nil {
+		return nil, records.StartSlotID, err
+	}
+
+	return rp, rid.Slot, nil
+}
+
+func (ts *TableScan) forwardPage(blockNumber types.BlockID) (*records.RecordPage, error) {
+	if ts.fwd != nil && ts.fwd.Block.Number == blockNumber {
+		return ts.fwd, nil
+	}
+
+	rp, err := records.NewRecordPage(ts.trx, types.Block{Filename: ts.Filename, Number: blockNumber}, ts.Layout())
+	if err != nil {
+		return nil, errors.WithMessage(ErrScan, err.Error())
+	}
+
+	ts.closeForward()
+	ts.fwd = rp
+
+	return rp, nil
+}
+
+func (ts *TableScan) closeForward() {
+	if ts.fwd != nil {
+		ts.trx.Unpin(ts.fwd.Block)
+		ts.fwd = nil
+	}
+}
+
+// moveRow переносит текущую запись из слота slot страницы rp в последний блок файла, а если там нет места — в новый блок.
+// В слоте, на который указывает RID записи, остается указатель переноса, прежняя перенесенная копия удаляется.
+// Возвращает новое место записи и признак того, что запись перенесена в новый блок
+func (ts *TableScan) moveRow(rp *records.RecordPage, slot types.SlotID) (*records.RecordPage, types.SlotID, bool, error) {
+	row, err := rp.Row(slot)
+	if err != nil {
+		return nil, records.StartSlotID, false, errors.WithMessage(ErrScan, err.Error())
+	}
+
+	size, err := ts.trx.Size(ts.Filename)
+	if err != nil {
+		return nil, records.StartSlotID, false, errors.WithMessage(ErrScan, err.Error())
+	}
+
+	target, newSlot, err := ts.moveRowTo(size-1, row, rp)
+	fresh := target == nil
+
+	if fresh && err == nil {
+		target, newSlot, err = ts.moveRowTo(-1, row, rp)
+	}
+
+	if err != nil {
+		return nil, records.StartSlotID, false, err
+	}
+
+	rid := types.RID{BlockNumber: target.Block.Number, Slot: newSlot}
+
+	if err := ts.rp.SetForward(ts.currentSlot, rid); err != nil {
+		ts.trx.Unpin(target.Block)
+
+		return nil, records.StartSlotID, false, errors.WithMessage(ErrScan, err.Error())
+	}
+
+	if rp != ts.rp {
+		if err := rp.Delete(slot); err != nil {
+			ts.trx.Unpin(target.Block)
+
+			return nil, records.StartSlotID, false, errors.WithMessage(ErrScan, err.Error())
+		}
+	}
+
+	ts.closeForward()
+	ts.fwd = target
+
+	return target, newSlot, fresh, nil
+}
+
+// moveRowTo кладет перенесенную запись в блок blockNumber, а при blockNumber < 0 — в новый блок.
+// Блоки, где запись уже лежит, не подходят. Если места нет, возвращает nil без ошибки
+func (ts *TableScan) moveRowTo(blockNumber types.BlockID, row []byte, from *records.RecordPage) (*records.RecordPage, types.SlotID, error) {
+	if blockNumber == ts.rp.Block.Number || blockNumber == from.Block.Number {
+		return nil, records.StartSlotID, nil
+	}
+
+	var (
+		block types.Block
+		err   error
+	)
+
+	if blockNumber < 0 {
+		block, err = ts.trx.Append(ts.Filename)
+	} else {
+		block = types.Block{Filename: ts.Filename, Number: blockNumber}
+	}
+
+	if err != nil {
+		return nil, records.StartSlotID, errors.WithMessage(ErrScan, err.Error())
+	}
+
+	rp, err := records.NewRecordPage(ts.trx, block, ts.Layout())
+	if err != nil {
+		return nil, records.StartSlotID, errors.WithMessage(ErrScan, err.Error())
+	}
+
+	if blockNumber < 0 {
+		if _, err = rp.Format(); err != nil {
+			ts.trx.Unpin(block)
+
+			return nil, records.StartSlotID, errors.WithMessage(ErrScan, err.Error())
+		}
+	}
+
+	slot, err := rp.InsertMoved(row)
+
+	switch {
+	case err == nil:
+		return rp, slot, nil
+	case errors.Is(err, records.ErrSlotNotFound) && blockNumber >= 0:
+		ts.trx.Unpin(block)
+
+		return nil, records.StartSlotID, nil
+	default:
+		ts.trx.Unpin(block)
+
+		return nil, records.StartSlotID, errors.WithMessage(ErrScan, err.Error())
+	}
+}
@@ -0,0 +1,165 @@
+package scan
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+// GetBytes читает поля TEXT и BLOB. Длинное значение собирается из страниц переполнения целиком,
+// прочитать его по частям позволяет GetLargeReader
+func (ts *TableScan) GetBytes(fieldName string) ([]byte, error) {
+	return ts.getLarge(fieldName)
+}
+
+// GetLargeReader возвращает читателя поля TEXT или BLOB. Страницы переполнения читаются, только когда читают значение,
+// поэтому дочитать его нужно до того, как транзакция изменит или удалит запись
+func (ts *TableScan) GetLargeReader(fieldName string) (io.Reader, int64, error) {
+	if !ts.Layout().Schema.Type(fieldName).IsLarge() {
+		return nil, 0, errors.WithMessagef(ErrScan, "field '%s' is not text or blob", fieldName)
+	}
+
+	isNull, err := ts.IsNull(fieldName)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if isNull {
+		return nil, 0, nil
+	}
+
+	lv, err := readField(ts, func(rp *records.RecordPage, slot types.SlotID) (records.LargeValue, error) {
+		return rp.GetLarge(slot, fieldName)
+	})
+	if err != nil {
+		return nil, 0, errors.WithMessage(ErrScan, err.Error())
+	}
+
+	if !lv.External {
+		return bytes.NewReader(lv.Data), int64(len(lv.Data)), nil
+	}
+
+	return ts.overflowFile().NewReader(lv.Overflow, lv.Length), int64(lv.Length), nil
+}
+
+// SetBytes пишет поля TEXT и BLOB. Значение длиннее порога RecordPage.InlineLimit уходит в страницы переполнения,
+// а страницы прежнего значения освобождаются
+func (ts *TableScan) SetBytes(fieldName string, value []byte) error {
+	return ts.setLarge(fieldName, value)
+}
+
+func (ts *TableScan) getLarge(fieldName string) ([]byte, error) {
+	lv, err := readField(ts, func(rp *records.RecordPage, slot types.SlotID) (records.LargeValue, error) {
+		return rp.GetLarge(slot, fieldName)
+	})
+	if err != nil {
+		return nil, errors.WithMessage(ErrScan, err.Error())
+	}
+
+	if !lv.External {
+		return lv.Data, nil
+	}
+
+	value, err := ts.overflowFile().Read(lv.Overflow, lv.Length)
+	if err != nil {
+		return nil, errors.WithMessage(ErrScan, err.Error())
+	}
+
+	return value, nil
+}
+
+func (ts *TableScan) setLarge(fieldName string, value []byte) error {
+	if ts.rp == nil {
+		return errors.WithMessagef(ErrScan, "field %s: no current record", fieldName)
+	}
+
+	// Блокировку записи берем до того, как писать страницы переполнения, чтобы не писать их зря
+	if err := ts.lockForWrite(); err != nil {
+		return err
+	}
+
+	lv := records.LargeValue{
+		Data:   value,
+		Length: uint32(len(value)),
+	}
+
+	if uint64(len(value)) > uint64(ts.rp.InlineLimit()) {
+		first, err := ts.overflowFile().Write(value)
+		if err != nil {
+			return errors.WithMessage(ErrScan, err.Error())
+		}
+
+		lv = records.LargeValue{
+			Length:   uint32(len(value)),
+			Overflow: first,
+			External: true,
+		}
+	}
+
+	err := ts.replaceLarge(fieldName, func(rp *records.RecordPage, slot types.SlotID) error {
+		return rp.SetLarge(slot, fieldName, lv)
+	})
+	if err != nil && lv.External {
+		// Запись не изменилась, новая цепочка никому не нужна
+		_ = ts.overflowFile().Free(lv.Overflow)
+	}
+
+	return err
+}
+
+// replaceLarge меняет поле TEXT или BLOB функцией set и освобождает страницы переполнения прежнего значения
+func (ts *TableScan) replaceLarge(fieldName string, set func(rp *records.RecordPage, slot types.SlotID) error) error {
+	old, err := readField(ts, func(rp *records.RecordPage, slot types.SlotID) (records.LargeValue, error) {
+		return rp.GetLarge(slot, fieldName)
+	})
+	if err != nil {
+		return errors.WithMessage(ErrScan, err.Error())
+	}
+
+	if err := ts.writeField(set); err != nil {
+		return errors.WithMessage(ErrScan, err.Error())
+	}
+
+	if old.External {
+		if err := ts.overflowFile().Free(old.Overflow); err != nil {
+			return errors.WithMessage(ErrScan, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// freeOverflow освобождает страницы переполнения всех полей текущей записи
+func (ts *TableScan) freeOverflow() error {
+	for _, fieldName := range ts.Layout().Schema.Fields() {
+		if !ts.Layout().Schema.Type(fieldName).IsLarge() {
+			continue
+		}
+
+		lv, err := readField(ts, func(rp *records.RecordPage, slot types.SlotID) (records.LargeValue, error) {
+			return rp.GetLarge(slot, fieldName)
+		})
+		if err != nil {
+			return errors.WithMessage(ErrScan, err.Error())
+		}
+
+		if lv.External {
+			if err := ts.overflowFile().Free(lv.Overflow); err != nil {
+				return errors.WithMessage(ErrScan, err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+func (ts *TableScan) overflowFile() *records.Overflow {
+	if ts.overflow == nil {
+		ts.overflow = records.NewOverflow(ts.trx, OverflowFilename(ts.Tablename))
+	}
+
+	return ts.overflow
+}
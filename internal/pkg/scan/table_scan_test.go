@@ -2,17 +2,23 @@ package scan_test
 
 import (
 	"fmt"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/gojuno/minimock/v3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/buffers"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/storage"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/testutil"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/concurrency"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/transaction"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/wal"
 )
 
 var (
@@ -70,18 +76,22 @@ func (ts *TableScanTestSuite) TestGetAndSetValues() {
 	wsut.Close()
 	require.NoError(t, wtx.Commit())
 
-	fLen, err := fm.Length(wsut.Filename)
+	// Короткие строки занимают меньше места, чем запись наибольшей длины
+	wLen, err := fm.Length(wsut.Filename)
 	require.NoError(t, err)
-	assert.EqualValues(t, blocks, fLen)
+	assert.Less(t, wLen, types.BlockID(blocks))
+
+	// Папку с данными может открыть только один менеджер
+	wsutClean()
 
 	rsut, rtx, fm, rsutClean := ts.newSUT(testPath)
 	defer rsutClean()
 
 	require.NoError(t, rsut.BeforeFirst())
 
-	fLen, err = fm.Length(rsut.Filename)
+	fLen, err := fm.Length(rsut.Filename)
 	require.NoError(t, err)
-	assert.EqualValues(t, blocks, fLen)
+	assert.EqualValues(t, wLen, fLen)
 
 	// Сканируем таблицу
 	for i := 0; i < int(cnt); i++ {
@@ -138,14 +148,14 @@ func (ts *TableScanTestSuite) TestDelete() {
 	for i := 0; i < int(cnt-3); i++ {
 		_, _ = sut.Next()
 	}
-	assert.Equal(t, types.RID{BlockNumber: 1, Slot: 26}, sut.RID())
+	rid := sut.RID()
 
 	require.NoError(t, sut.Delete())
 
 	_ = sut.BeforeFirst()
 	require.NoError(t, sut.Insert())
 
-	assert.Equal(t, types.RID{BlockNumber: 1, Slot: 26}, sut.RID())
+	assert.Equal(t, rid, sut.RID())
 }
 
 func (ts *TableScanTestSuite) TestRID() {
@@ -171,10 +181,14 @@ func (ts *TableScanTestSuite) TestRID() {
 	_ = sut.BeforeFirst()
 
 	assert.NoError(t, sut.MoveToRID(types.RID{
-		BlockNumber: 1,
+		BlockNumber: 0,
 		Slot:        15,
 	}))
-	assert.Equal(t, types.RID{BlockNumber: 1, Slot: 15}, sut.RID())
+	assert.Equal(t, types.RID{BlockNumber: 0, Slot: 15}, sut.RID())
+
+	id, err := sut.GetInt64("id")
+	require.NoError(t, err)
+	assert.EqualValues(t, 16, id)
 }
 
 func (ts *TableScanTestSuite) TestGetAndSetConstants() {
@@ -229,6 +243,57 @@ func (ts *TableScanTestSuite) TestGetAndSetConstants() {
 	require.ErrorIs(t, err, scan.ErrScan)
 }
 
+func (ts *TableScanTestSuite) TestNulls() {
+	t := ts.T()
+
+	sut, tx, _, clean := ts.newSUT("")
+	defer clean()
+	defer func() {
+		_ = tx.Commit()
+	}()
+
+	require.NoError(t, sut.Insert())
+	require.NoError(t, sut.SetVal("id", scan.NewInt64Constant(1)))
+
+	rid := sut.RID()
+
+	require.NoError(t, sut.Insert())
+	require.NoError(t, sut.SetVal("id", scan.NewInt64Constant(2)))
+	require.NoError(t, sut.SetVal("name", scan.NewStringConstant("user 2")))
+	require.NoError(t, sut.SetVal("age", scan.NewInt8Constant(0)))
+	require.NoError(t, sut.SetVal("name", scan.NewNullConstant()))
+
+	require.NoError(t, sut.MoveToRID(rid))
+
+	for name, null := range map[string]bool{"id": false, "name": true, "age": true} {
+		isNull, err := sut.IsNull(name)
+		require.NoError(t, err)
+		assert.Equal(t, null, isNull, name)
+
+		val, err := sut.GetVal(name)
+		require.NoError(t, err)
+		assert.Equal(t, null, scan.IsNull(val), name)
+	}
+
+	age, err := sut.GetInt8("age")
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, age)
+
+	ok, err := sut.Next()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	for name, null := range map[string]bool{"id": false, "name": true, "age": false} {
+		val, err := sut.GetVal(name)
+		require.NoError(t, err)
+		assert.Equal(t, null, scan.IsNull(val), name)
+	}
+
+	_, err = sut.IsNull("unknown")
+	require.ErrorIs(t, err, scan.ErrScan)
+	require.ErrorIs(t, sut.SetNull("unknown"), scan.ErrScan)
+}
+
 func (ts *TableScanTestSuite) TestHasField() {
 	t := ts.T()
 
@@ -442,3 +507,364 @@ func (ts *TableScanTestSuite) TestForEachAndForEachValue_Errors() {
 
 	assert.EqualValues(t, cnt/2, i)
 }
+
+func (ts *TableScanTestSuite) TestReadAhead() {
+	t := ts.T()
+	testPath := t.TempDir()
+
+	wsut, wtx, fm, wsutClean := ts.newSUT(testPath)
+	defer wsutClean()
+
+	const blocks = 20
+	cnt := int((defaultTestBlockSize / wsut.Layout().SlotSize) * blocks)
+
+	for i := 0; i < cnt; i++ {
+		require.NoError(t, wsut.Insert())
+		require.NoError(t, wsut.SetInt64("id", int64(i)))
+	}
+
+	wsut.Close()
+	require.NoError(t, wtx.Commit())
+
+	fLen, err := fm.Length(wsut.Filename)
+	require.NoError(t, err)
+	require.Greater(t, fLen, types.BlockID(4))
+
+	// Читаем таблицу через новый пул буферов, в котором еще нет ее блоков
+	lm, err := wal.NewManager(fm, testWALFile)
+	require.NoError(t, err)
+
+	bm := buffers.NewManager(fm, lm, defaultTestBuffersPoolLen, buffers.WithReadAhead(4))
+
+	rtx, err := transaction.NewTRXManager(fm, bm, lm).Transaction()
+	require.NoError(t, err)
+
+	rsut, err := scan.NewTableScan(rtx, testDataTable, ts.testLayout())
+	require.NoError(t, err)
+
+	read := 0
+
+	for {
+		ok, err := rsut.Next()
+		require.NoError(t, err)
+
+		if !ok {
+			break
+		}
+
+		id, err := rsut.GetInt64("id")
+		require.NoError(t, err)
+		require.EqualValues(t, read, id)
+
+		read++
+	}
+
+	rsut.Close()
+	require.NoError(t, rtx.Commit())
+	bm.Close()
+
+	assert.Equal(t, cnt, read)
+
+	// С диска по одному читаются только первые два блока: упреждающее чтение включается на втором блоке
+	stats := bm.Stats().Files[rsut.Filename]
+	assert.EqualValues(t, 2, stats.Misses)
+	assert.EqualValues(t, fLen-2, stats.Prefetches)
+}
+
+func (ts *TableScanTestSuite) TestForwardedRecords() {
+	t := ts.T()
+
+	trxMan, fm := ts.newTRXManager(defaultLockTimeout, "")
+	defer fm.Close()
+
+	schema := records.NewSchema()
+	schema.AddInt64Field("id")
+	schema.AddStringField("text", 1000)
+
+	layout := records.NewLayout(schema)
+
+	trx, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut, err := scan.NewTableScan(trx, "forwarded", layout)
+	require.NoError(t, err)
+
+	const cnt = 50
+
+	rids := make([]types.RID, 0, cnt)
+
+	for i := 0; i < cnt; i++ {
+		require.NoError(t, sut.Insert())
+		require.NoError(t, sut.SetInt64("id", int64(i)))
+		require.NoError(t, sut.SetString("text", strings.Repeat("a", 50)))
+
+		rids = append(rids, sut.RID())
+	}
+
+	// Запись в первом блоке вырастает больше свободного места и переносится, но ее RID не меняется
+	long := strings.Repeat("b", 3000)
+
+	require.NoError(t, sut.MoveToRID(rids[1]))
+	require.NoError(t, sut.SetString("text", long))
+	assert.Equal(t, rids[1], sut.RID())
+
+	size, err := trx.Size(sut.Filename)
+	require.NoError(t, err)
+	assert.Greater(t, size, rids[1].BlockNumber+1)
+
+	require.NoError(t, sut.MoveToRID(rids[1]))
+	assert.EqualValues(t, 1, testutil.Must(sut.GetInt64("id")))
+	assert.Equal(t, long, testutil.Must(sut.GetString("text")))
+
+	// Перенесенная запись растет дальше на новом месте
+	require.NoError(t, sut.SetString("text", long+"c"))
+	require.NoError(t, sut.SetInt64("id", 100))
+
+	// Сканирование видит каждую запись один раз и в прежнем порядке
+	ids := make([]int64, 0, cnt)
+
+	require.NoError(t, scan.ForEach(sut, func() (bool, error) {
+		id, err := sut.GetInt64("id")
+		ids = append(ids, id)
+
+		return false, err
+	}))
+
+	require.Len(t, ids, cnt)
+	assert.EqualValues(t, 0, ids[0])
+	assert.EqualValues(t, 100, ids[1])
+	assert.EqualValues(t, 2, ids[2])
+
+	// Удаление записи удаляет и перенесенную копию
+	require.NoError(t, sut.MoveToRID(rids[1]))
+	require.NoError(t, sut.Delete())
+
+	found := 0
+
+	require.NoError(t, scan.ForEach(sut, func() (bool, error) {
+		found++
+
+		return false, nil
+	}))
+
+	assert.Equal(t, cnt-1, found)
+
+	sizeAfter, err := trx.Size(sut.Filename)
+	require.NoError(t, err)
+	assert.Equal(t, size, sizeAfter)
+
+	sut.Close()
+	require.NoError(t, trx.Rollback())
+
+	// Откат возвращает таблицу к пустому виду
+	trx, err = trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut, err = scan.NewTableScan(trx, "forwarded", layout)
+	require.NoError(t, err)
+
+	ok, err := sut.Next()
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	sut.Close()
+	require.NoError(t, trx.Commit())
+}
+
+func (ts *TableScanTestSuite) TestLargeValues() {
+	t := ts.T()
+
+	trxMan, fm := ts.newTRXManager(defaultLockTimeout, "")
+	defer fm.Close()
+
+	schema := records.NewSchema()
+	schema.AddInt64Field("id")
+	schema.AddTextField("body")
+	schema.AddBlobField("data")
+
+	layout := records.NewLayout(schema)
+
+	trx, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut, err := scan.NewTableScan(trx, "large", layout)
+	require.NoError(t, err)
+
+	overflowFile := scan.OverflowFilename("large")
+
+	short := "short text"
+	long := strings.Repeat("long text ", defaultTestBlockSize)
+	blob := []byte(strings.Repeat("\x00\x01'\\\n", defaultTestBlockSize))
+
+	require.NoError(t, sut.Insert())
+	require.NoError(t, sut.SetInt64("id", 1))
+	require.NoError(t, sut.SetString("body", short))
+	require.NoError(t, sut.SetBytes("data", []byte("bytes")))
+
+	// Короткие значения хранятся в записи
+	assert.EqualValues(t, 0, testutil.Must(trx.Size(overflowFile)))
+
+	rid := sut.RID()
+
+	require.NoError(t, sut.Insert())
+	require.NoError(t, sut.SetInt64("id", 2))
+	require.NoError(t, sut.SetVal("body", scan.NewStringConstant(long)))
+	require.NoError(t, sut.SetVal("data", scan.NewBytesConstant(blob)))
+
+	assert.Positive(t, testutil.Must(trx.Size(overflowFile)))
+
+	require.NoError(t, sut.MoveToRID(rid))
+	assert.Equal(t, short, testutil.Must(sut.GetString("body")))
+	assert.Equal(t, []byte("bytes"), testutil.Must(sut.GetBytes("data")))
+
+	ok, err := sut.Next()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	assert.Equal(t, scan.NewStringConstant(long), testutil.Must(sut.GetVal("body")))
+	assert.Equal(t, scan.NewBytesConstant(blob), testutil.Must(sut.GetVal("data")))
+
+	// Длинное значение можно читать по частям
+	r, length, err := sut.GetLargeReader("data")
+	require.NoError(t, err)
+	assert.EqualValues(t, len(blob), length)
+	assert.Equal(t, blob, testutil.Must(io.ReadAll(r)))
+
+	r, length, err = scan.LargeReader(scan.NewProjectScan(sut, "body"), "body")
+	require.NoError(t, err)
+	assert.EqualValues(t, len(long), length)
+	assert.Equal(t, []byte(long), testutil.Must(io.ReadAll(r)))
+
+	_, _, err = sut.GetLargeReader("id")
+	require.ErrorIs(t, err, scan.ErrScan)
+
+	// Новое значение пишется до освобождения прежнего, поэтому после первого обновления файл переполнения
+	// вмещает оба значения и дальше не растет
+	require.NoError(t, sut.SetString("body", long))
+
+	size := testutil.Must(trx.Size(overflowFile))
+
+	for i := range 10 {
+		value := fmt.Sprintf("%d %s", i, long)
+
+		require.NoError(t, sut.SetString("body", value))
+		assert.Equal(t, value, testutil.Must(sut.GetString("body")))
+	}
+
+	assert.Equal(t, size, testutil.Must(trx.Size(overflowFile)))
+
+	// NULL тоже освобождает страницы
+	require.NoError(t, sut.SetNull("body"))
+	assert.True(t, testutil.Must(sut.IsNull("body")))
+	assert.True(t, scan.IsNull(testutil.Must(sut.GetVal("body"))))
+
+	require.NoError(t, sut.SetString("body", long))
+	assert.Equal(t, size, testutil.Must(trx.Size(overflowFile)))
+
+	// Удаление записи освобождает страницы всех ее полей
+	require.NoError(t, sut.Delete())
+
+	require.NoError(t, sut.Insert())
+	require.NoError(t, sut.SetString("body", long))
+	require.NoError(t, sut.SetBytes("data", blob))
+
+	assert.Equal(t, size, testutil.Must(trx.Size(overflowFile)))
+
+	require.ErrorIs(t, sut.SetString("id", long), scan.ErrScan)
+	_, err = sut.GetBytes("id")
+	require.ErrorIs(t, err, scan.ErrScan)
+
+	sut.Close()
+	require.NoError(t, trx.Commit())
+
+	// Длинные значения переживают перезапуск транзакции
+	trx, err = trxMan.Transaction()
+	require.NoError(t, err)
+
+	sut, err = scan.NewTableScan(trx, "large", layout)
+	require.NoError(t, err)
+
+	values := make([]string, 0, 2)
+
+	require.NoError(t, scan.ForEach(sut, func() (bool, error) {
+		body, err := sut.GetString("body")
+		values = append(values, body)
+
+		return false, err
+	}))
+
+	assert.Equal(t, []string{short, long}, values)
+
+	sut.Close()
+	require.NoError(t, trx.Commit())
+}
+
+func (ts *TableScanTestSuite) TestRowLocks() {
+	t := ts.T()
+
+	trxMan, fm := ts.newTRXManager(defaultLockTimeout, "")
+	defer fm.Close()
+
+	wtx, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	wsut, err := scan.NewTableScan(wtx, testDataTable, ts.testLayout())
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, wsut.Insert())
+		require.NoError(t, wsut.SetInt64("id", int64(i)))
+	}
+
+	wsut.Close()
+	require.NoError(t, wtx.Commit())
+
+	rc := transaction.WithIsolationLevel(concurrency.ReadCommitted)
+
+	tx1, err := trxMan.Transaction(rc)
+	require.NoError(t, err)
+
+	sut, err := scan.NewTableScan(tx1, testDataTable, ts.testLayout(),
+		scan.WithLocking(scan.LockingClause{Strength: scan.LockForShare}))
+	require.NoError(t, err)
+
+	ok, err := sut.Next()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = sut.LockRow()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	rid := sut.RID()
+
+	sut.Close()
+
+	// READ COMMITTED отпускает блок после чтения, а блокировка записи FOR SHARE остается до конца транзакции
+	locks := trxMan.Locks()
+	require.Len(t, locks, 1)
+	assert.Equal(t, concurrency.RowResource(types.Block{Filename: testDataTable + ".tbl", Number: rid.BlockNumber}, rid.Slot), locks[0].Resource)
+	assert.False(t, locks[0].Exclusive)
+
+	tx2, err := trxMan.Transaction(rc)
+	require.NoError(t, err)
+
+	usut, err := scan.NewTableScan(tx2, testDataTable, ts.testLayout())
+	require.NoError(t, err)
+
+	// Другие записи блока можно менять, а запись под FOR SHARE — нет
+	require.NoError(t, usut.MoveToRID(types.RID{BlockNumber: rid.BlockNumber, Slot: rid.Slot + 1}))
+	require.NoError(t, usut.SetInt64("id", 100))
+
+	require.NoError(t, usut.MoveToRID(rid))
+	err = usut.SetInt64("id", 100)
+	assert.ErrorIs(t, err, scan.ErrScan)
+	assert.ErrorContains(t, err, "failed to lock")
+
+	require.NoError(t, tx1.Commit())
+
+	require.NoError(t, usut.SetInt64("id", 100))
+
+	usut.Close()
+	require.NoError(t, tx2.Commit())
+}
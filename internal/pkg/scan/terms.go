@@ -6,6 +6,8 @@ import (
 	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
 )
 
+// Term — условие предиката. Сравнение с NULL по правилам SQL дает UNKNOWN, а не TRUE или FALSE.
+// Запись проходит фильтр, только если условие TRUE, поэтому IsSatisfied возвращает false и для UNKNOWN
 type Term interface {
 	IsSatisfied(Scan) (bool, error)
 	AppliesTo(records.Schema) bool
@@ -38,6 +40,10 @@ func (et EqualTerm) IsSatisfied(s Scan) (bool, error) {
 		return false, err
 	}
 
+	if IsNull(lval) || IsNull(rval) {
+		return false, nil
+	}
+
 	return rval.CompareTo(lval) == CompEqual, nil
 }
 
@@ -81,11 +87,12 @@ func (et EqualTerm) EquatesWithConstant(fieldName string) (Constant, bool) {
 	lv, _ := et.lhs.Value()
 	rv, _ := et.rhs.Value()
 
-	if et.lhs.IsFieldName() && !et.rhs.IsFieldName() && lv.(string) == fieldName {
+	// Поле = NULL не выполняется ни для одной записи, индекс тут не поможет
+	if et.lhs.IsFieldName() && !et.rhs.IsFieldName() && lv.(string) == fieldName && !IsNull(rv.(Constant)) {
 		return rv.(Constant), true
 	}
 
-	if et.rhs.IsFieldName() && !et.lhs.IsFieldName() && rv.(string) == fieldName {
+	if et.rhs.IsFieldName() && !et.lhs.IsFieldName() && rv.(string) == fieldName && !IsNull(lv.(Constant)) {
 		return lv.(Constant), true
 	}
 
@@ -107,3 +114,72 @@ func (et EqualTerm) EquatesWithField(fieldName string) (string, bool) {
 
 	return "", false
 }
+
+// IsNullTerm — условие expr IS [NOT] NULL. В отличие от сравнений, оно всегда TRUE или FALSE
+type IsNullTerm struct {
+	expr Expression
+	not  bool
+}
+
+func NewIsNullTerm(expr Expression) IsNullTerm {
+	return IsNullTerm{
+		expr: expr,
+	}
+}
+
+func NewIsNotNullTerm(expr Expression) IsNullTerm {
+	return IsNullTerm{
+		expr: expr,
+		not:  true,
+	}
+}
+
+func (nt IsNullTerm) IsSatisfied(s Scan) (bool, error) {
+	val, err := nt.expr.Evaluate(s)
+	if err != nil {
+		return false, err
+	}
+
+	return IsNull(val) != nt.not, nil
+}
+
+func (nt IsNullTerm) String() string {
+	if nt.not {
+		return nt.expr.String() + ` is not null`
+	}
+
+	return nt.expr.String() + ` is null`
+}
+
+func (nt IsNullTerm) AppliesTo(s records.Schema) bool {
+	return nt.expr.AppliesTo(s)
+}
+
+// ReductionFactor не знает, сколько в поле NULL. IS NULL оцениваем как равенство одному значению поля,
+// IS NOT NULL считаем невыборочным
+//
+//nolint:forcetypeassert
+func (nt IsNullTerm) ReductionFactor(p Plan) (int64, bool) {
+	if !nt.expr.IsFieldName() {
+		v, _ := nt.expr.Value()
+		if IsNull(v.(Constant)) != nt.not {
+			return 1, true
+		}
+
+		return math.MaxInt64, true
+	}
+
+	if nt.not {
+		return 1, true
+	}
+
+	return nt.expr.ReductionFactor(p)
+}
+
+func (nt IsNullTerm) EquatesWithConstant(string) (Constant, bool) {
+	return nil, false
+}
+
+func (nt IsNullTerm) EquatesWithField(string) (string, bool) {
+	return "", false
+}
@@ -8,10 +8,14 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
 )
 
-var _ scan.Term = scan.EqualTerm{}
+var (
+	_ scan.Term = scan.EqualTerm{}
+	_ scan.Term = scan.IsNullTerm{}
+)
 
 type TermsTestSuite struct {
 	Suite
@@ -188,3 +192,105 @@ func (ts *TermsTestSuite) TestEqualTerm_EquatesWithField() {
 	assert.False(t, ok)
 	assert.Equal(t, "", fieldName)
 }
+
+func (ts *TermsTestSuite) TestEqualTerm_Nulls() {
+	t := ts.T()
+
+	schema := records.NewSchema()
+	schema.AddInt64Field("id")
+	schema.AddInt64Field("parent")
+
+	sc := scan.NewMemoryScan(schema, [][]scan.Constant{
+		{scan.NewInt64Constant(1), scan.NewNullConstant()},
+	})
+
+	ok, err := sc.Next()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	null := scan.NewScalarExpression(scan.NewNullConstant())
+
+	for _, sut := range []scan.Term{
+		scan.NewEqualTerm(scan.NewFieldExpression("parent"), scan.NewScalarExpression(scan.NewInt64Constant(0))),
+		scan.NewEqualTerm(scan.NewFieldExpression("parent"), scan.NewFieldExpression("parent")),
+		scan.NewEqualTerm(scan.NewFieldExpression("id"), null),
+		scan.NewEqualTerm(null, null),
+	} {
+		satisfied, err := sut.IsSatisfied(sc)
+		require.NoError(t, err)
+		assert.False(t, satisfied, sut.String())
+	}
+
+	c, ok := scan.NewEqualTerm(scan.NewFieldExpression("id"), null).EquatesWithConstant("id")
+	assert.False(t, ok)
+	assert.Nil(t, c)
+}
+
+func (ts *TermsTestSuite) TestIsNullTerm() {
+	t := ts.T()
+
+	schema := records.NewSchema()
+	schema.AddInt64Field("id")
+	schema.AddInt64Field("parent")
+
+	sc := scan.NewMemoryScan(schema, [][]scan.Constant{
+		{scan.NewInt64Constant(1), scan.NewNullConstant()},
+	})
+
+	ok, err := sc.Next()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	sut1 := scan.NewIsNullTerm(scan.NewFieldExpression("parent"))
+	assert.Equal(t, "parent is null", sut1.String())
+	assert.True(t, sut1.AppliesTo(schema))
+
+	satisfied, err := sut1.IsSatisfied(sc)
+	require.NoError(t, err)
+	assert.True(t, satisfied)
+
+	sut2 := scan.NewIsNotNullTerm(scan.NewFieldExpression("parent"))
+	assert.Equal(t, "parent is not null", sut2.String())
+
+	satisfied, err = sut2.IsSatisfied(sc)
+	require.NoError(t, err)
+	assert.False(t, satisfied)
+
+	satisfied, err = scan.NewIsNullTerm(scan.NewFieldExpression("id")).IsSatisfied(sc)
+	require.NoError(t, err)
+	assert.False(t, satisfied)
+
+	satisfied, err = scan.NewIsNotNullTerm(scan.NewFieldExpression("id")).IsSatisfied(sc)
+	require.NoError(t, err)
+	assert.True(t, satisfied)
+
+	_, ok = sut1.EquatesWithConstant("parent")
+	assert.False(t, ok)
+
+	_, ok = sut1.EquatesWithField("parent")
+	assert.False(t, ok)
+}
+
+func (ts *TermsTestSuite) TestIsNullTerm_ReductionFactor() {
+	t := ts.T()
+
+	mc := minimock.NewController(t)
+	plan := scan.NewPlanMock(mc)
+	plan.DistinctValuesMock.When("id").Then(1345, true)
+
+	rf, ok := scan.NewIsNullTerm(scan.NewFieldExpression("id")).ReductionFactor(plan)
+	assert.True(t, ok)
+	assert.EqualValues(t, 1345, rf)
+
+	rf, ok = scan.NewIsNotNullTerm(scan.NewFieldExpression("id")).ReductionFactor(plan)
+	assert.True(t, ok)
+	assert.EqualValues(t, 1, rf)
+
+	rf, ok = scan.NewIsNullTerm(scan.NewScalarExpression(scan.NewNullConstant())).ReductionFactor(plan)
+	assert.True(t, ok)
+	assert.EqualValues(t, 1, rf)
+
+	rf, ok = scan.NewIsNullTerm(scan.NewScalarExpression(scan.NewInt8Constant(1))).ReductionFactor(plan)
+	assert.True(t, ok)
+	assert.EqualValues(t, math.MaxInt64, rf)
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// File — открытый файл хранилища. Чтение и запись идут по смещению, запись за концом файла увеличивает его размер
+type File interface {
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+
+	// Stat возвращает сведения о файле, из них берем размер
+	Stat() (fs.FileInfo, error)
+
+	// Sync сбрасывает записанные данные на носитель
+	Sync() error
+}
+
+// Backend — хранилище файлов, с которым работает Manager.
+// Менеджер сам разбивает файлы на блоки, от хранилища нужны только операции с файлами целиком
+type Backend interface {
+	// Path возвращает расположение файлов хранилища
+	Path() string
+
+	// Open открывает файл на чтение и запись и создает его, если файла нет
+	Open(filename string) (File, error)
+
+	// Remove удаляет файл
+	Remove(filename string) error
+
+	// List возвращает имена всех файлов хранилища
+	List() ([]string, error)
+}
+
+// OSBackend хранит файлы в папке на диске
+type OSBackend struct {
+	path string
+}
+
+// NewOSBackend создает хранилище в папке path. Если папки нет, то создает ее
+func NewOSBackend(path string) (*OSBackend, error) {
+	if err := os.MkdirAll(path, defaultFilePermissions); err != nil {
+		return nil, err
+	}
+
+	return &OSBackend{
+		path: path,
+	}, nil
+}
+
+func (b *OSBackend) Path() string {
+	return b.path
+}
+
+func (b *OSBackend) Open(filename string) (File, error) {
+	return b.openFile(filename)
+}
+
+func (b *OSBackend) openFile(filename string) (*os.File, error) {
+	return os.OpenFile(
+		filepath.Join(b.path, filename),
+		os.O_CREATE|os.O_RDWR|os.O_SYNC, // Открываем файл в режим O_SYNC, чтобы выполнялся автоматический флаш данных при чтении и записи
+		syncedFilePermissions,
+	)
+}
+
+func (b *OSBackend) Remove(filename string) error {
+	return os.Remove(filepath.Join(b.path, filename))
+}
+
+func (b *OSBackend) List() ([]string, error) {
+	entries, err := os.ReadDir(b.path)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names, nil
+}
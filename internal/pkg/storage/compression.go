@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"sync"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// Алгоритмы сжатия страниц
+const (
+	CompressionNone = "none"
+	CompressionZstd = "zstd"
+	CompressionS2   = "s2"
+)
+
+// Codec сжимает и распаковывает страницы. Методы можно вызывать параллельно
+type Codec interface {
+	Name() string
+	// Compress дописывает сжатые данные src в dst
+	Compress(dst, src []byte) []byte
+	// Decompress дописывает распакованные данные src в dst
+	Decompress(dst, src []byte) ([]byte, error)
+}
+
+// codecs — поддерживаемые алгоритмы. Номер алгоритма хранится в карте блоков и не должен меняться, 0 — без сжатия
+var codecs = map[uint32]Codec{
+	1: zstdCodec{},
+	2: s2Codec{},
+}
+
+// CodecByName возвращает алгоритм сжатия по имени
+func CodecByName(name string) (Codec, error) {
+	for _, codec := range codecs {
+		if codec.Name() == name {
+			return codec, nil
+		}
+	}
+
+	return nil, errors.WithMessagef(ErrUnknownCodec, "%q", name)
+}
+
+// ValidCompression проверяет имя алгоритма сжатия. Пустое имя и CompressionNone означают хранение без сжатия
+func ValidCompression(name string) error {
+	if name == "" || name == CompressionNone {
+		return nil
+	}
+
+	_, err := CodecByName(name)
+
+	return err
+}
+
+func codecID(codec Codec) uint32 {
+	if codec == nil {
+		return 0
+	}
+
+	for id, c := range codecs {
+		if c.Name() == codec.Name() {
+			return id
+		}
+	}
+
+	return 0
+}
+
+var (
+	zstdEncoder = sync.OnceValue(func() *zstd.Encoder {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+
+		return enc
+	})
+	zstdDecoder = sync.OnceValue(func() *zstd.Decoder {
+		dec, _ := zstd.NewReader(nil)
+
+		return dec
+	})
+)
+
+type zstdCodec struct{}
+
+func (zstdCodec) Name() string {
+	return CompressionZstd
+}
+
+func (zstdCodec) Compress(dst, src []byte) []byte {
+	return zstdEncoder().EncodeAll(src, dst)
+}
+
+func (zstdCodec) Decompress(dst, src []byte) ([]byte, error) {
+	return zstdDecoder().DecodeAll(src, dst)
+}
+
+type s2Codec struct{}
+
+func (s2Codec) Name() string {
+	return CompressionS2
+}
+
+func (s2Codec) Compress(dst, src []byte) []byte {
+	return append(dst, s2.Encode(nil, src)...)
+}
+
+func (s2Codec) Decompress(dst, src []byte) ([]byte, error) {
+	out, err := s2.Decode(nil, src)
+	if err != nil {
+		return dst, err
+	}
+
+	return append(dst, out...), nil
+}
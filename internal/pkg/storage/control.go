@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+const (
+	// ControlFileName — управляющий файл базы: формат данных, размер блока и признак штатного закрытия
+	ControlFileName = "sdb_control"
+
+	// FormatVersion — текущая версия формата данных на диске
+	FormatVersion uint32 = 2
+
+	controlMagic uint32 = 0x53445048 // SDPH
+
+	// Управляющий файл меньше сектора диска, поэтому его запись не может оборваться на середине.
+	// Файл открываем на время чтения или записи, чтобы он не занимал место в кеше открытых файлов
+	controlSize = 64
+
+	controlMagicOffset     = 0
+	controlVersionOffset   = 4
+	controlBlockSizeOffset = 8
+	controlCreatedAtOffset = 12
+	controlCleanOffset     = 20
+	controlRotationOffset  = 21
+	controlChecksumOffset  = 24
+	controlKeyCheckOffset  = 28 // Проверочное значение ключа шифрования, нули — база не зашифрована
+
+	// keyCheckVersion — версия формата, в которой появилось проверочное значение ключа
+	keyCheckVersion uint32 = 2
+)
+
+// Control — содержимое управляющего файла
+type Control struct {
+	Version       uint32
+	BlockSize     uint32
+	CreatedAt     time.Time
+	CleanShutdown bool // База была закрыта штатно, а не упала
+	Encrypted     bool
+	KeyRotation   bool // Смена ключа шифрования начата и не закончена
+
+	keyCheck []byte
+}
+
+// migrations переводят данные с версии формата v на v+1. Ключ — исходная версия
+var migrations = map[uint32]func(fm *Manager, control *Control) error{
+	0: migrateFromLegacy,
+	1: migrateToSlottedPages,
+}
+
+// migrateFromLegacy — папка с данными без управляющего файла. Формат блоков не менялся,
+// поэтому достаточно создать управляющий файл с размером блока, с которым открыли базу
+func migrateFromLegacy(fm *Manager, control *Control) error {
+	control.BlockSize = fm.blockSize
+	control.CreatedAt = time.Now()
+
+	return nil
+}
+
+// migrateToSlottedPages — во второй версии записи таблиц хранятся в страницах с каталогом слотов и картой NULL
+// вместо слотов фиксированного размера, а большие значения TEXT и BLOB — в страницах переполнения.
+// Управляющий файл не знает схем таблиц и не может переписать страницы, поэтому такие базы не открываем:
+// данные нужно выгрузить старой версией и загрузить заново. Зашифрованных баз первой версии не бывает
+func migrateToSlottedPages(*Manager, *Control) error {
+	return errors.New("tables use fixed-size record slots, dump the data with the previous version and load it again")
+}
+
+// Control возвращает содержимое управляющего файла на момент открытия базы
+func (fm *Manager) Control() Control {
+	return fm.control
+}
+
+// openControl читает и проверяет управляющий файл, при необходимости переводит данные на текущую версию формата
+// и отмечает, что база открыта. names — файлы в хранилище
+func (fm *Manager) openControl(names []string) error {
+	var control Control
+
+	switch {
+	case len(names) == 0:
+		control = Control{
+			Version:       FormatVersion,
+			BlockSize:     fm.blockSize,
+			CreatedAt:     time.Now(),
+			CleanShutdown: true,
+		}
+
+		if fm.encrypted() {
+			keyCheck, err := newKeyCheck(fm.keys[0])
+			if err != nil {
+				return err
+			}
+
+			control.Encrypted = true
+			control.keyCheck = keyCheck
+		}
+	case !slices.Contains(names, ControlFileName):
+		if err := fm.checkLegacyBlockSize(names); err != nil {
+			return err
+		}
+
+		control = Control{
+			CleanShutdown: true,
+		}
+	default:
+		var err error
+
+		if control, err = fm.readControl(); err != nil {
+			return err
+		}
+	}
+
+	if control.Version > FormatVersion {
+		return errors.WithMessagef(ErrUnsupportedFormat, "data format version %d, supported up to %d", control.Version, FormatVersion)
+	}
+
+	for control.Version < FormatVersion {
+		migrate, ok := migrations[control.Version]
+		if !ok {
+			return errors.WithMessagef(ErrUnsupportedFormat, "no migration from data format version %d", control.Version)
+		}
+
+		if err := migrate(fm, &control); err != nil {
+			return errors.WithMessagef(ErrUnsupportedFormat, "migration from data format version %d: %s", control.Version, err)
+		}
+
+		control.Version++
+	}
+
+	if control.BlockSize != fm.blockSize {
+		return errors.WithMessagef(ErrBlockSizeMismatch, "data dir block size %d, requested %d", control.BlockSize, fm.blockSize)
+	}
+
+	if err := fm.checkKey(&control); err != nil {
+		return err
+	}
+
+	fm.control = control
+
+	// До штатного закрытия считаем, что база упала
+	control.CleanShutdown = false
+
+	return fm.writeControl(control)
+}
+
+// checkLegacyBlockSize сверяет размер блока, с которым открывают папку без управляющего файла, с размерами файлов в ней.
+// Размер блока такой папки нигде не записан, и база, открытая с другим размером, читала бы блоки со смещением
+func (fm *Manager) checkLegacyBlockSize(names []string) error {
+	for _, name := range names {
+		file, err := fm.backend.Open(name)
+		if err != nil {
+			return errors.WithMessage(ErrFileManagerIO, err.Error())
+		}
+
+		stat, err := file.Stat()
+		_ = file.Close()
+
+		if err != nil {
+			return errors.WithMessage(ErrFileManagerIO, err.Error())
+		}
+
+		if stat.Size()%int64(fm.blockSize) != 0 {
+			return errors.WithMessagef(ErrBlockSizeMismatch, "file \"%s\" size %d is not a multiple of requested block size %d",
+				name, stat.Size(), fm.blockSize)
+		}
+	}
+
+	return nil
+}
+
+// closeControl отмечает в управляющем файле штатное закрытие базы
+func (fm *Manager) closeControl() error {
+	control := fm.control
+	control.CleanShutdown = true
+
+	return fm.writeControl(control)
+}
+
+func (fm *Manager) readControl() (Control, error) {
+	file, err := fm.backend.Open(ControlFileName)
+	if err != nil {
+		return Control{}, errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	defer file.Close()
+
+	data := make([]byte, controlSize)
+
+	if _, err := file.ReadAt(data, 0); err != nil {
+		return Control{}, errors.WithMessagef(ErrBadControlFile, "read: %s", err)
+	}
+
+	order := binary.LittleEndian
+
+	if order.Uint32(data[controlMagicOffset:]) != controlMagic {
+		return Control{}, errors.WithMessage(ErrBadControlFile, "not a sophiadb data dir")
+	}
+
+	if controlChecksum(data, order.Uint32(data[controlVersionOffset:])) != order.Uint32(data[controlChecksumOffset:]) {
+		return Control{}, errors.WithMessage(ErrBadControlFile, "checksum mismatch")
+	}
+
+	control := Control{
+		Version:       order.Uint32(data[controlVersionOffset:]),
+		BlockSize:     order.Uint32(data[controlBlockSizeOffset:]),
+		CreatedAt:     time.Unix(0, int64(order.Uint64(data[controlCreatedAtOffset:]))),
+		CleanShutdown: data[controlCleanOffset] == types.BoolTrueMark,
+		KeyRotation:   data[controlRotationOffset] == types.BoolTrueMark,
+	}
+
+	keyCheck := data[controlKeyCheckOffset : controlKeyCheckOffset+keyCheckSize]
+	if slices.ContainsFunc(keyCheck, func(b byte) bool { return b != 0 }) {
+		control.Encrypted = true
+		control.keyCheck = slices.Clone(keyCheck)
+	}
+
+	return control, nil
+}
+
+func (fm *Manager) writeControl(control Control) error {
+	file, err := fm.backend.Open(ControlFileName)
+	if err != nil {
+		return errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	defer file.Close()
+
+	order := binary.LittleEndian
+
+	clean := types.BoolFalseMark
+	if control.CleanShutdown {
+		clean = types.BoolTrueMark
+	}
+
+	rotation := types.BoolFalseMark
+	if control.KeyRotation {
+		rotation = types.BoolTrueMark
+	}
+
+	data := make([]byte, controlSize)
+	order.PutUint32(data[controlMagicOffset:], controlMagic)
+	order.PutUint32(data[controlVersionOffset:], control.Version)
+	order.PutUint32(data[controlBlockSizeOffset:], control.BlockSize)
+	order.PutUint64(data[controlCreatedAtOffset:], uint64(control.CreatedAt.UnixNano()))
+	data[controlCleanOffset] = clean
+	data[controlRotationOffset] = rotation
+	copy(data[controlKeyCheckOffset:], control.keyCheck)
+	order.PutUint32(data[controlChecksumOffset:], controlChecksum(data, control.Version))
+
+	if _, err := file.WriteAt(data, 0); err != nil {
+		return errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	if err := file.Sync(); err != nil {
+		return errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	return nil
+}
+
+// controlChecksum считает контрольную сумму управляющего файла версии version.
+// Она покрывает все поля, кроме самой суммы: испорченное проверочное значение ключа — это испорченный файл, а не чужой ключ
+func controlChecksum(data []byte, version uint32) uint32 {
+	sum := crc32.ChecksumIEEE(data[:controlChecksumOffset])
+	if version < keyCheckVersion {
+		return sum
+	}
+
+	return crc32.Update(sum, crc32.IEEETable, data[controlKeyCheckOffset:controlSize])
+}
@@ -1,3 +1,5 @@
-// Хранилище на диске для базы данных
+// Хранилище файлов базы данных: на диске или в памяти.
+// Файлы на диске можно читать через отображение в память, см. MmapBackend и Manager.View.
+// Страницы файла можно хранить сжатыми, см. Manager.SetCompression, а все файлы базы — зашифрованными, см. WithEncryptionKey
 
 package storage
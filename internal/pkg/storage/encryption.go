@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+// Зашифрованная база хранит каждый блок, в том числе блоки журнала, в AES-GCM.
+// Nonce блока — 12 случайных байт, которые пишутся перед шифротекстом: блок переписывается много раз,
+// а повторять nonce с тем же ключом в GCM нельзя. Номер блока в nonce не входит: одинаковые номера есть у блоков
+// всех файлов. Имя файла и номер блока входят в дополнительные данные,
+// поэтому блок нельзя незаметно подменить блоком из другого места.
+// Проверочное значение ключа хранится в управляющем файле, неверный ключ обнаруживается при открытии базы
+const (
+	sealNonceSize = 12
+
+	keyCheckText = "sophiadb"
+	keyCheckAAD  = ControlFileName
+	keyCheckSize = sealNonceSize + len(keyCheckText) + 16
+)
+
+// WithEncryptionKey шифрует файлы базы ключом AES длиной 16, 24 или 32 байта.
+// Ключ задается при создании базы и нужен при каждом открытии
+func WithEncryptionKey(key []byte) ManagerOpt {
+	return func(fm *Manager) {
+		fm.encryptionKey = key
+	}
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.WithMessage(ErrBadEncryptionKey, err.Error())
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.WithMessage(ErrBadEncryptionKey, err.Error())
+	}
+
+	return aead, nil
+}
+
+// Encrypted возвращает true, если база зашифрована
+func (fm *Manager) Encrypted() bool {
+	return fm.encrypted()
+}
+
+func (fm *Manager) encrypted() bool {
+	return len(fm.keys) > 0
+}
+
+// sealOverhead — на сколько зашифрованный блок длиннее исходного
+func (fm *Manager) sealOverhead() uint32 {
+	if !fm.encrypted() {
+		return 0
+	}
+
+	return uint32(sealNonceSize + fm.keys[0].Overhead())
+}
+
+func blockAAD(block types.Block) []byte {
+	return binary.LittleEndian.AppendUint32([]byte(block.Filename), uint32(block.Number))
+}
+
+// seal шифрует данные блока первым ключом. Без шифрования возвращает данные как есть
+func (fm *Manager) seal(block types.Block, data []byte) ([]byte, error) {
+	if !fm.encrypted() {
+		return data, nil
+	}
+
+	out := make([]byte, sealNonceSize, len(data)+int(fm.sealOverhead()))
+
+	if _, err := rand.Read(out); err != nil {
+		return nil, errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	return fm.keys[0].Seal(out, out, data, blockAAD(block)), nil
+}
+
+// open расшифровывает данные блока. Ключи перебираются по очереди, см. RotateKey
+func (fm *Manager) open(block types.Block, data []byte) ([]byte, error) {
+	if !fm.encrypted() {
+		return data, nil
+	}
+
+	if len(data) < sealNonceSize {
+		return nil, errors.WithMessagef(ErrCorruptedBlock, "%s: block %d", block.Filename, block.Number)
+	}
+
+	for _, key := range fm.keys {
+		if plain, err := key.Open(nil, data[:sealNonceSize], data[sealNonceSize:], blockAAD(block)); err == nil {
+			return plain, nil
+		}
+	}
+
+	return nil, errors.WithMessagef(ErrCorruptedBlock, "%s: block %d: authentication failed", block.Filename, block.Number)
+}
+
+// newKeyCheck шифрует известный текст, чтобы при открытии базы проверить ключ
+func newKeyCheck(key cipher.AEAD) ([]byte, error) {
+	nonce := make([]byte, sealNonceSize)
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	return key.Seal(nonce, nonce, []byte(keyCheckText), []byte(keyCheckAAD)), nil
+}
+
+func verifyKeyCheck(key cipher.AEAD, check []byte) bool {
+	plain, err := key.Open(nil, check[:sealNonceSize], check[sealNonceSize:], []byte(keyCheckAAD))
+
+	return err == nil && string(plain) == keyCheckText
+}
+
+// checkKey сверяет ключ менеджера с проверочным значением из управляющего файла
+func (fm *Manager) checkKey(control *Control) error {
+	switch {
+	case control.Encrypted && !fm.encrypted():
+		return ErrEncryptionKeyRequired
+	case !control.Encrypted && fm.encrypted():
+		return ErrNotEncrypted
+	case control.Encrypted && !verifyKeyCheck(fm.keys[0], control.keyCheck):
+		return ErrWrongKey
+	}
+
+	return nil
+}
+
+// RotateKey перешифровывает все файлы базы ключом newKey. С базой в это время никто не должен работать.
+// База должна быть закрыта штатно: после сбоя ее сначала открывают, чтобы прошло восстановление.
+// Если перешифровка прервалась, ее надо запустить снова с теми же ключами: пока управляющий файл не переписан,
+// база открывается старым ключом, а блоки читаются и старым, и новым
+func (fm *Manager) RotateKey(newKey []byte) error {
+	if !fm.encrypted() {
+		return ErrNotEncrypted
+	}
+
+	if !fm.control.CleanShutdown && !fm.control.KeyRotation {
+		return ErrRecoveryRequired
+	}
+
+	key, err := newAEAD(newKey)
+	if err != nil {
+		return err
+	}
+
+	names, err := fm.backend.List()
+	if err != nil {
+		return errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	// Отметка о начале смены ключа: до ее конца база открывается только для повторной смены ключа
+	fm.control.KeyRotation = true
+
+	control := fm.control
+	control.CleanShutdown = false
+
+	if err := fm.writeControl(control); err != nil {
+		return err
+	}
+
+	fm.keys = []cipher.AEAD{key, fm.keys[0]}
+
+	page := types.NewPage(fm.blockSize)
+
+	for _, filename := range names {
+		if filename == ControlFileName || filename == LockFileName || strings.HasSuffix(filename, BlockMapSuffix) {
+			continue
+		}
+
+		mf, err := fm.mapped(filename)
+		if err != nil {
+			return err
+		}
+
+		for i := types.BlockID(0); i < mf.length(); i++ {
+			block := types.Block{Filename: filename, Number: i}
+
+			if mf.isZero(i) {
+				continue
+			}
+
+			if err := fm.readMapped(mf, block, page); err != nil {
+				return err
+			}
+
+			if err := fm.writeMapped(mf, block, page); err != nil {
+				return err
+			}
+		}
+
+		if err := fm.syncMapped(mf, filename); err != nil {
+			return err
+		}
+	}
+
+	keyCheck, err := newKeyCheck(key)
+	if err != nil {
+		return err
+	}
+
+	fm.keys = fm.keys[:1]
+	fm.control.keyCheck = keyCheck
+	fm.control.KeyRotation = false
+
+	control = fm.control
+	control.CleanShutdown = false
+
+	if err := fm.writeControl(control); err != nil {
+		return err
+	}
+
+	// Смену ключа начинают только на штатно закрытой базе, поэтому после нее база снова закрывается штатно
+	fm.MarkCleanShutdown()
+
+	return nil
+}
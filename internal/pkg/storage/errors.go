@@ -7,3 +7,57 @@ var ErrStorage error = errors.New("storage error")
 
 // ErrFileManagerIO вызываем при ошибках ввода вывода
 var ErrFileManagerIO error = errors.Wrap(ErrStorage, "file manager io error")
+
+// ErrCrashed возвращает FaultyBackend после имитации сбоя
+var ErrCrashed error = errors.Wrap(ErrStorage, "simulated crash")
+
+// ErrInjectedIO — ошибка ввода-вывода, которую вернул FaultyBackend
+var ErrInjectedIO error = errors.Wrap(ErrStorage, "injected io error")
+
+// ErrBadControlFile — управляющий файл поврежден или папка не похожа на базу
+var ErrBadControlFile error = errors.Wrap(ErrStorage, "bad control file")
+
+// ErrUnsupportedFormat — версию формата данных нельзя открыть этой версией базы
+var ErrUnsupportedFormat error = errors.Wrap(ErrStorage, "unsupported data format")
+
+// ErrBlockSizeMismatch — база создана с другим размером блока
+var ErrBlockSizeMismatch error = errors.Wrap(ErrStorage, "block size mismatch")
+
+// ErrDatabaseLocked — папку с данными уже открыл другой процесс или другой экземпляр базы
+var ErrDatabaseLocked error = errors.Wrap(ErrStorage, "database is locked")
+
+// ErrUnknownCodec — неизвестный алгоритм сжатия
+var ErrUnknownCodec error = errors.Wrap(ErrStorage, "unknown compression codec")
+
+// ErrBadBlockMap — карта блоков сжатого файла повреждена
+var ErrBadBlockMap error = errors.Wrap(ErrStorage, "bad block map")
+
+// ErrFileNotEmpty — сжатие можно включить только у пустого файла
+var ErrFileNotEmpty error = errors.Wrap(ErrStorage, "file is not empty")
+
+// ErrCorruptedBlock — блок не удалось расшифровать или распаковать
+var ErrCorruptedBlock error = errors.Wrap(ErrStorage, "corrupted block")
+
+// ErrBadEncryptionKey — ключ шифрования неподходящей длины
+var ErrBadEncryptionKey error = errors.Wrap(ErrStorage, "bad encryption key")
+
+// ErrEncryptionKeyRequired — база зашифрована, а ключ не задан
+var ErrEncryptionKeyRequired error = errors.Wrap(ErrStorage, "encryption key required")
+
+// ErrWrongKey — база зашифрована другим ключом
+var ErrWrongKey error = errors.Wrap(ErrStorage, "wrong encryption key")
+
+// ErrNotEncrypted — задан ключ шифрования, а база не зашифрована
+var ErrNotEncrypted error = errors.Wrap(ErrStorage, "database is not encrypted")
+
+// ErrMmapNotSupported — на этой платформе нельзя отображать файлы в память
+var ErrMmapNotSupported error = errors.Wrap(ErrStorage, "mmap is not supported")
+
+// ErrUnknownBackend — неизвестное хранилище файлов
+var ErrUnknownBackend error = errors.Wrap(ErrStorage, "unknown storage backend")
+
+// ErrRecoveryRequired — база закрыта не штатно, сначала ее нужно открыть, чтобы прошло восстановление
+var ErrRecoveryRequired error = errors.Wrap(ErrStorage, "database recovery required")
+
+// ErrKeyRotationInterrupted — смена ключа шифрования прервалась, ее нужно запустить снова
+var ErrKeyRotationInterrupted error = errors.Wrap(ErrStorage, "encryption key rotation interrupted")
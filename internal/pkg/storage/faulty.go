@@ -0,0 +1,317 @@
+package storage
+
+import (
+	"io"
+	"io/fs"
+	"math/rand"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// FaultyBackend — обертка над хранилищем для проверки восстановления после сбоев.
+// Умеет «ронять» базу после заданного числа записей, обрывать запись блока на середине,
+// терять записи, которые не сбросили на носитель через Sync, и возвращать ошибки ввода-вывода.
+// После сбоя все операции возвращают ErrCrashed, а на носителе, то есть во вложенном хранилище,
+// остается то, что пережило бы настоящий сбой. Базу после сбоя открываем заново на вложенном хранилище
+type FaultyBackend struct {
+	mu sync.Mutex
+
+	backend Backend
+	rnd     *rand.Rand
+
+	crashAfter   int     // Сколько записей выполнить до сбоя, -1 — не падать
+	tornSector   int     // Размер сектора для оборванной записи, 0 — запись при сбое не выполняется
+	dropUnsynced bool    // Держать записи в памяти до Sync и терять их при сбое
+	ioErrorRate  float64 // Вероятность ошибки чтения, записи или Sync
+
+	writes  int
+	crashed bool
+	pending map[string][]pendingWrite // Несброшенные записи по файлам
+}
+
+type pendingWrite struct {
+	off  int64
+	data []byte
+}
+
+type FaultOpt func(*FaultyBackend)
+
+// WithCrashAfterWrites — сбой на записи номер n+1
+func WithCrashAfterWrites(n int) FaultOpt {
+	return func(b *FaultyBackend) {
+		b.crashAfter = n
+	}
+}
+
+// WithTornWrites — при сбое запись доходит до носителя частично: случайное число секторов от начала блока
+func WithTornWrites(sectorSize int) FaultOpt {
+	return func(b *FaultyBackend) {
+		b.tornSector = sectorSize
+	}
+}
+
+// WithDropUnsynced — записи попадают на носитель только после Sync, а при сбое теряются, как данные в кэше ОС.
+// Хранилище не полагается на флаги открытия файлов и сбрасывает на носитель все, что должно пережить сбой
+func WithDropUnsynced() FaultOpt {
+	return func(b *FaultyBackend) {
+		b.dropUnsynced = true
+	}
+}
+
+// WithIOErrors — операции с файлами завершаются ошибкой ErrInjectedIO с вероятностью rate
+func WithIOErrors(rate float64) FaultOpt {
+	return func(b *FaultyBackend) {
+		b.ioErrorRate = rate
+	}
+}
+
+// WithFaultSeed задает начальное значение генератора случайных чисел, чтобы сбои повторялись
+func WithFaultSeed(seed int64) FaultOpt {
+	return func(b *FaultyBackend) {
+		b.rnd = rand.New(rand.NewSource(seed)) //nolint:gosec
+	}
+}
+
+func NewFaultyBackend(backend Backend, opts ...FaultOpt) *FaultyBackend {
+	b := &FaultyBackend{
+		backend:    backend,
+		rnd:        rand.New(rand.NewSource(1)), //nolint:gosec
+		crashAfter: -1,
+		pending:    make(map[string][]pendingWrite),
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+// Crash имитирует сбой: несброшенные записи теряются, дальнейшие операции возвращают ErrCrashed
+func (b *FaultyBackend) Crash() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.crash()
+}
+
+// Crashed сообщает, был ли сбой
+func (b *FaultyBackend) Crashed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.crashed
+}
+
+// Writes возвращает число выполненных записей
+func (b *FaultyBackend) Writes() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.writes
+}
+
+func (b *FaultyBackend) Path() string {
+	return b.backend.Path()
+}
+
+func (b *FaultyBackend) Open(filename string) (File, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.crashed {
+		return nil, ErrCrashed
+	}
+
+	file, err := b.backend.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return &faultyFile{
+		b:    b,
+		name: filename,
+		file: file,
+	}, nil
+}
+
+func (b *FaultyBackend) Remove(filename string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.crashed {
+		return ErrCrashed
+	}
+
+	delete(b.pending, filename)
+
+	return b.backend.Remove(filename)
+}
+
+func (b *FaultyBackend) List() ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.crashed {
+		return nil, ErrCrashed
+	}
+
+	return b.backend.List()
+}
+
+func (b *FaultyBackend) crash() {
+	b.crashed = true
+	b.pending = make(map[string][]pendingWrite)
+}
+
+// fault проверяет, можно ли выполнить операцию. Вызывается под блокировкой
+func (b *FaultyBackend) fault() error {
+	if b.crashed {
+		return ErrCrashed
+	}
+
+	if b.ioErrorRate > 0 && b.rnd.Float64() < b.ioErrorRate {
+		return ErrInjectedIO
+	}
+
+	return nil
+}
+
+// faultyFile — файл FaultyBackend. Несброшенные записи храним в хранилище, а не в файле,
+// потому что менеджер может закрыть файл и открыть его заново
+type faultyFile struct {
+	b    *FaultyBackend
+	name string
+	file File
+}
+
+func (f *faultyFile) ReadAt(p []byte, off int64) (int, error) {
+	f.b.mu.Lock()
+	defer f.b.mu.Unlock()
+
+	if err := f.b.fault(); err != nil {
+		return 0, err
+	}
+
+	size, err := f.size()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := f.file.ReadAt(p, off)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, err
+	}
+
+	clear(p[n:])
+
+	for _, w := range f.b.pending[f.name] {
+		if w.off < off+int64(len(p)) && w.off+int64(len(w.data)) > off {
+			if w.off >= off {
+				copy(p[w.off-off:], w.data)
+			} else {
+				copy(p, w.data[off-w.off:])
+			}
+		}
+	}
+
+	n = int(max(0, min(size-off, int64(len(p)))))
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (f *faultyFile) WriteAt(p []byte, off int64) (int, error) {
+	f.b.mu.Lock()
+	defer f.b.mu.Unlock()
+
+	if err := f.b.fault(); err != nil {
+		return 0, err
+	}
+
+	if f.b.crashAfter >= 0 && f.b.writes >= f.b.crashAfter {
+		if f.b.tornSector > 0 {
+			// Часть блока успела дойти до носителя
+			torn := f.b.rnd.Intn(len(p)/f.b.tornSector+1) * f.b.tornSector
+			_, _ = f.file.WriteAt(p[:torn], off)
+		}
+
+		f.b.crash()
+
+		return 0, ErrCrashed
+	}
+
+	f.b.writes++
+
+	if f.b.dropUnsynced {
+		f.b.pending[f.name] = append(f.b.pending[f.name], pendingWrite{
+			off:  off,
+			data: append([]byte(nil), p...),
+		})
+
+		return len(p), nil
+	}
+
+	return f.file.WriteAt(p, off)
+}
+
+func (f *faultyFile) Stat() (fs.FileInfo, error) {
+	f.b.mu.Lock()
+	defer f.b.mu.Unlock()
+
+	if f.b.crashed {
+		return nil, ErrCrashed
+	}
+
+	size, err := f.size()
+	if err != nil {
+		return nil, err
+	}
+
+	return memoryFileInfo{
+		name: f.name,
+		size: size,
+	}, nil
+}
+
+func (f *faultyFile) Sync() error {
+	f.b.mu.Lock()
+	defer f.b.mu.Unlock()
+
+	if err := f.b.fault(); err != nil {
+		return err
+	}
+
+	for _, w := range f.b.pending[f.name] {
+		if _, err := f.file.WriteAt(w.data, w.off); err != nil {
+			return err
+		}
+	}
+
+	delete(f.b.pending, f.name)
+
+	return f.file.Sync()
+}
+
+func (f *faultyFile) Close() error {
+	return f.file.Close()
+}
+
+// size возвращает размер файла с учетом несброшенных записей. Вызывается под блокировкой
+func (f *faultyFile) size() (int64, error) {
+	stat, err := f.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	size := stat.Size()
+
+	for _, w := range f.b.pending[f.name] {
+		size = max(size, w.off+int64(len(w.data)))
+	}
+
+	return size, nil
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"container/list"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxOpenFiles — сколько файлов менеджер держит открытыми по умолчанию
+const DefaultMaxOpenFiles = 256
+
+// WithMaxOpenFiles ограничивает число открытых файлов. Давно не использованные файлы закрываются
+// и открываются снова при следующем обращении. Ноль снимает ограничение
+func WithMaxOpenFiles(n int) ManagerOpt {
+	return func(fm *Manager) {
+		fm.files.max = max(0, n)
+	}
+}
+
+// FilesStats — статистика кеша открытых файлов
+type FilesStats struct {
+	Open    int   // Открыто сейчас
+	Opens   int64 // Сколько раз открывали файлы
+	Reopens int64 // Сколько раз открывали файлы, которые до этого закрыли из-за ограничения
+	Closes  int64 // Сколько раз закрыли файл из-за ограничения
+}
+
+// fileCache — LRU открытых файлов. Файл, с которым идет ввод-вывод, не закрывается,
+// поэтому открытых файлов может ненадолго стать больше max
+type fileCache struct {
+	max     int
+	lru     *list.List // Имена файлов, в начале — последние использованные
+	entries map[string]*fileEntry
+	closed  map[string]struct{} // Закрытые из-за ограничения файлы, которые еще не открыли снова
+	stats   FilesStats
+}
+
+type fileEntry struct {
+	elem *list.Element
+	refs int
+}
+
+func newFileCache(maxOpen int) fileCache {
+	return fileCache{
+		max:     maxOpen,
+		lru:     list.New(),
+		entries: make(map[string]*fileEntry),
+		closed:  make(map[string]struct{}),
+	}
+}
+
+func (c *fileCache) reset() {
+	c.lru.Init()
+	c.entries = make(map[string]*fileEntry)
+}
+
+// MaxOpenFiles возвращает ограничение на число открытых файлов
+func (fm *Manager) MaxOpenFiles() int {
+	return fm.files.max
+}
+
+// FilesStats возвращает статистику кеша открытых файлов
+func (fm *Manager) FilesStats() FilesStats {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	stats := fm.files.stats
+	stats.Open = len(fm.openFiles)
+
+	return stats
+}
+
+// acquire возвращает открытый файл и не дает закрыть его до вызова release
+func (fm *Manager) acquire(filename string) (File, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	file, ok := fm.openFiles[filename]
+	if !ok {
+		var err error
+
+		file, err = fm.backend.Open(filename)
+		if err != nil {
+			return nil, errors.WithMessage(ErrFileManagerIO, err.Error())
+		}
+
+		fm.openFiles[filename] = file
+
+		fm.files.stats.Opens++
+		if _, ok := fm.files.closed[filename]; ok {
+			fm.files.stats.Reopens++
+
+			delete(fm.files.closed, filename)
+		}
+	}
+
+	entry, ok := fm.files.entries[filename]
+	if !ok {
+		entry = &fileEntry{
+			elem: fm.files.lru.PushFront(filename),
+		}
+		fm.files.entries[filename] = entry
+	} else {
+		fm.files.lru.MoveToFront(entry.elem)
+	}
+
+	entry.refs++
+
+	fm.closeUnused()
+
+	return file, nil
+}
+
+// release отмечает, что ввод-вывод с файлом закончился
+func (fm *Manager) release(filename string) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	if entry, ok := fm.files.entries[filename]; ok {
+		entry.refs--
+	}
+
+	fm.closeUnused()
+}
+
+// closeUnused закрывает давно не использованные файлы сверх ограничения. Вызывается под блокировкой менеджера
+func (fm *Manager) closeUnused() {
+	if fm.files.max == 0 {
+		return
+	}
+
+	for e := fm.files.lru.Back(); e != nil && len(fm.openFiles) > fm.files.max; {
+		prev := e.Prev()
+
+		filename, _ := e.Value.(string)
+		if entry := fm.files.entries[filename]; entry.refs == 0 {
+			// Ошибка закрытия не мешает работе: файл откроется снова при следующем обращении
+			_ = fm.openFiles[filename].Close()
+
+			delete(fm.openFiles, filename)
+			delete(fm.files.entries, filename)
+			fm.files.lru.Remove(e)
+
+			fm.files.closed[filename] = struct{}{}
+			fm.files.stats.Closes++
+		}
+
+		e = prev
+	}
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// LockFileName — файл блокировки папки с данными. Пока база открыта, процесс держит на нем flock
+// и хранит в нем свой pid, чтобы другой процесс мог сообщить, кем занята база
+const LockFileName = "sdb_lock"
+
+type dirLock struct {
+	file *os.File
+}
+
+// lockDir захватывает папку с данными. Если она уже захвачена, то возвращает ErrDatabaseLocked
+func lockDir(path string) (*dirLock, error) {
+	file, err := os.OpenFile(filepath.Join(path, LockFileName), os.O_CREATE|os.O_RDWR, syncedFilePermissions)
+	if err != nil {
+		return nil, errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	if err := lockFile(file); err != nil {
+		defer file.Close()
+
+		if isLockedError(err) {
+			return nil, lockedError(file)
+		}
+
+		return nil, errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	if err := writePid(file); err != nil {
+		_ = file.Close()
+
+		return nil, errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	return &dirLock{
+		file: file,
+	}, nil
+}
+
+// release снимает блокировку. Файл блокировки не удаляем: иначе другой процесс может захватить уже удаленный файл
+func (l *dirLock) release() error {
+	if err := unlockFile(l.file); err != nil {
+		_ = l.file.Close()
+
+		return errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	if err := l.file.Close(); err != nil {
+		return errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	return nil
+}
+
+func writePid(file *os.File) error {
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+
+	_, err := file.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+
+	return err
+}
+
+func lockedError(file *os.File) error {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return errors.WithMessage(ErrDatabaseLocked, "database is in use by another process")
+	}
+
+	pid, err := strconv.Atoi(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return errors.WithMessage(ErrDatabaseLocked, "database is in use by another process")
+	}
+
+	return errors.WithMessagef(ErrDatabaseLocked, "database is in use by pid %d", pid)
+}
@@ -0,0 +1,19 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package storage
+
+import "os"
+
+// На платформах без flock папку с данными не блокируем
+
+func lockFile(*os.File) error {
+	return nil
+}
+
+func unlockFile(*os.File) error {
+	return nil
+}
+
+func isLockedError(error) bool {
+	return false
+}
@@ -1,11 +1,12 @@
 package storage
 
 import (
+	"crypto/cipher"
 	"io"
-	"os"
-	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/pkg/errors"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
@@ -20,40 +21,116 @@ const (
 	syncedFilePermissions  = 0o755
 )
 
-type OpenFilesMap map[string]*os.File
+type OpenFilesMap map[string]File
 
-// Manager управляет чтением и записью блоков на диске
+// Manager управляет чтением и записью блоков в файлах хранилища Backend.
+// Блоки читаются и пишутся через ReadAt и WriteAt, поэтому операции с разными блоками идут параллельно.
+// mu защищает только кеш открытых файлов, а рост файла в Append упорядочивает блокировка файла
 type Manager struct {
 	mu sync.Mutex
 
 	IsNew bool
 
-	path      string
-	blockSize uint32
-	openFiles OpenFilesMap
+	backend     Backend
+	blockSize   uint32
+	openFiles   OpenFilesMap
+	appendLocks map[string]*sync.Mutex
+	files       fileCache
+	control     Control
+	closeOnce   sync.Once
+	lock        *dirLock
+
+	// cleanShutdown — Close отметит в управляющем файле штатное закрытие, см. MarkCleanShutdown
+	cleanShutdown atomic.Bool
+
+	// mappedFiles — файлы с картой блоков: сжатые и все файлы зашифрованной базы
+	mappedFiles map[string]*mappedFile
+
+	encryptionKey []byte
+	keys          []cipher.AEAD // Первым ключом шифруются блоки, остальные нужны только при смене ключа
+}
+
+type ManagerOpt func(*Manager)
+
+// NewFileManager создает менеджер для файлов в папке path на диске.
+// Менеджер захватывает папку до Close, поэтому второй менеджер для той же папки вернет ErrDatabaseLocked
+func NewFileManager(path string, blockSize uint32, opts ...ManagerOpt) (*Manager, error) {
+	backend, err := NewOSBackend(path)
+	if err != nil {
+		return nil, errors.WithMessagef(ErrFileManagerIO, "cannot create data dir \"%s\": %v", path, err)
+	}
+
+	return newDirManager(path, backend, blockSize, opts...)
+}
+
+// newDirManager создает менеджер для хранилища в папке path и захватывает папку
+func newDirManager(path string, backend Backend, blockSize uint32, opts ...ManagerOpt) (*Manager, error) {
+	lock, err := lockDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fm, err := NewManager(backend, blockSize, opts...)
+	if err != nil {
+		_ = lock.release()
+
+		return nil, err
+	}
+
+	fm.lock = lock
+
+	return fm, nil
 }
 
-// NewFileManager создает новый объект FileManager
-func NewFileManager(path string, blockSize uint32) (*Manager, error) {
+// NewManager создает менеджер для файлов в хранилище backend и удаляет из него временные файлы.
+// База считается новой, если в хранилище нет файлов. У новой базы создает управляющий файл,
+// у существующей — проверяет его, см. Control
+func NewManager(backend Backend, blockSize uint32, opts ...ManagerOpt) (*Manager, error) {
 	fm := &Manager{
-		path:      path,
-		blockSize: blockSize,
-		openFiles: make(OpenFilesMap),
-		IsNew:     true,
+		backend:     backend,
+		blockSize:   blockSize,
+		openFiles:   make(OpenFilesMap),
+		appendLocks: make(map[string]*sync.Mutex),
+		files:       newFileCache(DefaultMaxOpenFiles),
+
+		mappedFiles: make(map[string]*mappedFile),
 	}
 
-	if lstat, err := os.Lstat(path); err == nil && lstat.IsDir() {
-		fm.IsNew = false
+	for _, opt := range opts {
+		opt(fm)
 	}
 
-	if err := os.MkdirAll(path, defaultFilePermissions); err != nil {
-		return nil, errors.WithMessagef(ErrFileManagerIO, "cannot create data dir \"%s\": %v", path, err)
+	if len(fm.encryptionKey) > 0 {
+		key, err := newAEAD(fm.encryptionKey)
+		if err != nil {
+			return nil, err
+		}
+
+		fm.keys = []cipher.AEAD{key}
 	}
 
 	if err := fm.cleanTemporaryFiles(); err != nil {
 		return nil, err
 	}
 
+	names, err := backend.List()
+	if err != nil {
+		return nil, errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	// Файл блокировки создается до менеджера и не говорит о том, что в папке есть база
+	names = slices.DeleteFunc(names, func(name string) bool {
+		return name == LockFileName
+	})
+
+	fm.IsNew = len(names) == 0
+
+	fm.registerMapped(names)
+
+	if err := fm.openControl(names); err != nil {
+		return nil, err
+	}
+
 	return fm, nil
 }
 
@@ -61,23 +138,27 @@ func (fm *Manager) OpenFiles() OpenFilesMap {
 	return fm.openFiles
 }
 
-// cleanTemporaryFiles
+// Backend возвращает хранилище файлов
+func (fm *Manager) Backend() Backend {
+	return fm.backend
+}
+
+// cleanTemporaryFiles удаляет временные файлы, оставшиеся от прошлого запуска
 func (fm *Manager) cleanTemporaryFiles() error {
-	return filepath.Walk(
-		fm.path,
-		func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if !info.IsDir() && strings.HasPrefix(info.Name(), TempFilesPrefix) {
-				err := os.Remove(path)
+	names, err := fm.backend.List()
+	if err != nil {
+		return errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
 
-				return err
+	for _, name := range names {
+		if strings.HasPrefix(name, TempFilesPrefix) {
+			if err := fm.backend.Remove(name); err != nil {
+				return errors.WithMessage(ErrFileManagerIO, err.Error())
 			}
+		}
+	}
 
-			return nil
-		},
-	)
+	return nil
 }
 
 // BlockSize возвращает размер блока
@@ -87,12 +168,42 @@ func (fm *Manager) BlockSize() uint32 {
 
 // Path возвращает путь к папке с данными
 func (fm *Manager) Path() string {
-	return fm.path
+	return fm.backend.Path()
 }
 
-// Close pаскрывает файлы открытые менеджером
+// MarkCleanShutdown разрешает Close отметить в управляющем файле штатное закрытие базы.
+// Вызывается, когда база открыта и в ней не осталось незавершенных транзакций.
+// Без этого база после Close считается упавшей, и при следующем открытии ее нужно восстанавливать
+func (fm *Manager) MarkCleanShutdown() {
+	fm.cleanShutdown.Store(true)
+}
+
+// Close закрывает файлы открытые менеджером и освобождает папку с данными.
+// После MarkCleanShutdown отмечает в управляющем файле штатное закрытие базы
 func (fm *Manager) Close() error {
-	errs := make([]error, 0, len(fm.openFiles))
+	// Карты блоков сбрасываем до отметки о штатном закрытии
+	mappedErr := fm.syncMappedFiles()
+
+	var controlErr error
+
+	fm.closeOnce.Do(func() {
+		if mappedErr == nil && fm.cleanShutdown.Load() {
+			controlErr = fm.closeControl()
+		}
+	})
+
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	errs := make([]error, 0, len(fm.openFiles)+2)
+
+	if mappedErr != nil {
+		errs = append(errs, mappedErr)
+	}
+
+	if controlErr != nil {
+		errs = append(errs, controlErr)
+	}
 
 	var err error
 	for k, v := range fm.openFiles {
@@ -104,6 +215,16 @@ func (fm *Manager) Close() error {
 		delete(fm.openFiles, k)
 	}
 
+	fm.files.reset()
+
+	if fm.lock != nil {
+		if err := fm.lock.release(); err != nil {
+			errs = append(errs, err)
+		}
+
+		fm.lock = nil
+	}
+
 	if len(errs) > 0 {
 		return errors.WithMessagef(ErrFileManagerIO, "errors on close files: %s", utils.JoinErrors(errs, ", "))
 	}
@@ -111,45 +232,96 @@ func (fm *Manager) Close() error {
 	return nil
 }
 
-// Read читает блок из файла в страницу page
+// Read читает блок из файла в страницу page.
+// Если файл кончается посреди блока, то недостающая часть страницы заполняется нулями
 func (fm *Manager) Read(block types.Block, page *types.Page) error {
-	fm.mu.Lock()
-	defer fm.mu.Unlock()
+	if mf, err := fm.mapped(block.Filename); err != nil || mf != nil {
+		if err != nil {
+			return err
+		}
+
+		return fm.readMapped(mf, block, page)
+	}
 
-	file, err := fm.getFile(block.Filename)
+	file, err := fm.acquire(block.Filename)
 	if err != nil {
 		return err
 	}
 
-	_, err = file.Seek(int64(block.Number)*int64(fm.blockSize), io.SeekStart)
-	if err != nil {
+	defer fm.release(block.Filename)
+
+	content := page.Content()
+
+	n, err := file.ReadAt(content, fm.offset(block))
+	if err != nil && !(errors.Is(err, io.EOF) && n > 0) {
 		return errors.WithMessage(ErrFileManagerIO, err.Error())
 	}
 
-	_, err = file.Read(page.Content())
+	clear(content[n:])
+
+	return nil
+}
+
+// ReadBlocks читает len(pages) блоков подряд, начиная с first, одним чтением с диска.
+// Если файл кончается посреди диапазона, то недостающие блоки заполняются нулями.
+// Блоки файла с картой блоков читаются по одному
+func (fm *Manager) ReadBlocks(first types.Block, pages []*types.Page) error {
+	if mf, err := fm.mapped(first.Filename); err != nil || mf != nil {
+		if err != nil {
+			return err
+		}
+
+		for i, page := range pages {
+			block := types.Block{Filename: first.Filename, Number: first.Number + types.BlockID(int32(i))}
+
+			if err := fm.readMapped(mf, block, page); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	file, err := fm.acquire(first.Filename)
 	if err != nil {
+		return err
+	}
+
+	defer fm.release(first.Filename)
+
+	data := make([]byte, len(pages)*int(fm.blockSize))
+
+	// Как и в Read, всё, что лежит за концом файла, читается нулями
+	n, err := file.ReadAt(data, fm.offset(first))
+	if err != nil && !(errors.Is(err, io.EOF) && n > 0) {
 		return errors.WithMessage(ErrFileManagerIO, err.Error())
 	}
 
+	for i, page := range pages {
+		copy(page.Content(), data[i*int(fm.blockSize):])
+	}
+
 	return nil
 }
 
 // Write записывает блок в файл из страницы page
 func (fm *Manager) Write(block types.Block, page *types.Page) error {
-	fm.mu.Lock()
-	defer fm.mu.Unlock()
+	if mf, err := fm.mapped(block.Filename); err != nil || mf != nil {
+		if err != nil {
+			return err
+		}
 
-	file, err := fm.getFile(block.Filename)
-	if err != nil {
-		return err
+		return fm.writeMapped(mf, block, page)
 	}
 
-	_, err = file.Seek(int64(block.Number)*int64(fm.blockSize), io.SeekStart)
+	file, err := fm.acquire(block.Filename)
 	if err != nil {
-		return errors.WithMessage(ErrFileManagerIO, err.Error())
+		return err
 	}
 
-	_, err = file.Write(page.Content())
+	defer fm.release(block.Filename)
+
+	_, err = file.WriteAt(page.Content(), fm.offset(block))
 	if err != nil {
 		return errors.WithMessage(ErrFileManagerIO, err.Error())
 	}
@@ -157,33 +329,46 @@ func (fm *Manager) Write(block types.Block, page *types.Page) error {
 	return nil
 }
 
-// Append добавляет новый блок в файл
+// Append добавляет новый блок в файл. Параллельные Append одного файла выполняются по очереди,
+// чтобы два вызова не получили один и тот же номер блока
 func (fm *Manager) Append(filename string) (types.Block, error) {
-	fm.mu.Lock()
-	defer fm.mu.Unlock()
+	mf, err := fm.mapped(filename)
+	if err != nil {
+		return types.Block{}, err
+	}
 
-	block := types.Block{}
+	lock := fm.appendLock(filename)
 
-	blkNum, err := fm.Length(filename)
-	if err != nil {
-		return block, err
+	lock.Lock()
+	defer lock.Unlock()
+
+	if mf != nil {
+		return fm.appendMapped(mf, filename)
 	}
 
-	block.Filename = filename
-	block.Number = blkNum
+	file, err := fm.acquire(filename)
+	if err != nil {
+		return types.Block{}, err
+	}
 
-	blockData := make([]byte, fm.blockSize)
+	defer fm.release(filename)
 
-	file, err := fm.getFile(block.Filename)
+	blkNum, err := fm.Length(filename)
 	if err != nil {
-		return block, err
+		return types.Block{}, err
+	}
+
+	block := types.Block{
+		Filename: filename,
+		Number:   blkNum,
 	}
 
-	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+	if _, err := file.WriteAt(make([]byte, fm.blockSize), fm.offset(block)); err != nil {
 		return block, errors.WithMessage(ErrFileManagerIO, err.Error())
 	}
 
-	if _, err := file.Write(blockData); err != nil {
+	// Новый блок сразу сбрасываем на носитель: записи журнала об изменениях блока не должны пережить сбой без самого блока
+	if err := file.Sync(); err != nil {
 		return block, errors.WithMessage(ErrFileManagerIO, err.Error())
 	}
 
@@ -192,11 +377,21 @@ func (fm *Manager) Append(filename string) (types.Block, error) {
 
 // Length возвращает размер файла в блоках
 func (fm *Manager) Length(filename string) (types.BlockID, error) {
-	file, err := fm.getFile(filename)
+	if mf, err := fm.mapped(filename); err != nil || mf != nil {
+		if err != nil {
+			return 0, err
+		}
+
+		return mf.length(), nil
+	}
+
+	file, err := fm.acquire(filename)
 	if err != nil {
 		return 0, err
 	}
 
+	defer fm.release(filename)
+
 	stat, err := file.Stat()
 	if err != nil {
 		return 0, errors.WithMessage(ErrFileManagerIO, err.Error())
@@ -205,23 +400,87 @@ func (fm *Manager) Length(filename string) (types.BlockID, error) {
 	return types.BlockID(int32(stat.Size() / int64(fm.blockSize))), nil
 }
 
-// getFile возвращает файл из списка открытых или
-func (fm *Manager) getFile(filename string) (*os.File, error) {
-	file, ok := fm.openFiles[filename]
-	if !ok {
-		var err error
-		// Создаем файл без локов. Локи нужно делать в вызывающих методах
-		file, err = os.OpenFile(
-			filepath.Join(fm.path, filename),
-			os.O_CREATE|os.O_RDWR|os.O_SYNC, // Открываем файл в режим O_SYNC, чтобы выполнялся автоматический флаш данных при чтении и записи
-			syncedFilePermissions,
-		)
+// Sync сбрасывает записанные в файл данные на носитель. У файла с картой блоков
+// новые версии блоков становятся видны после сбоя только после Sync, см. syncMapped
+func (fm *Manager) Sync(filename string) error {
+	if mf, err := fm.mapped(filename); err != nil || mf != nil {
 		if err != nil {
-			return nil, errors.WithMessage(ErrFileManagerIO, err.Error())
+			return err
 		}
 
-		fm.openFiles[filename] = file
+		return fm.syncMapped(mf, filename)
+	}
+
+	file, err := fm.acquire(filename)
+	if err != nil {
+		return err
+	}
+
+	defer fm.release(filename)
+
+	if err := file.Sync(); err != nil {
+		return errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	return nil
+}
+
+// Remove закрывает и удаляет файл, а у файла с картой блоков — и карту блоков.
+// Вызывающий отвечает за то, чтобы с файлом больше никто не работал
+func (fm *Manager) Remove(filename string) error {
+	fm.mu.Lock()
+	_, mapped := fm.mappedFiles[filename]
+	delete(fm.mappedFiles, filename)
+	fm.mu.Unlock()
+
+	if mapped {
+		if err := fm.remove(blockMapName(filename)); err != nil {
+			return err
+		}
+	}
+
+	return fm.remove(filename)
+}
+
+func (fm *Manager) remove(filename string) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	if file, ok := fm.openFiles[filename]; ok {
+		_ = file.Close()
+
+		delete(fm.openFiles, filename)
+
+		if entry, ok := fm.files.entries[filename]; ok {
+			fm.files.lru.Remove(entry.elem)
+			delete(fm.files.entries, filename)
+		}
+	}
+
+	delete(fm.appendLocks, filename)
+	delete(fm.files.closed, filename)
+
+	if err := fm.backend.Remove(filename); err != nil {
+		return errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	return nil
+}
+
+func (fm *Manager) offset(block types.Block) int64 {
+	return int64(block.Number) * int64(fm.blockSize)
+}
+
+// appendLock возвращает блокировку роста файла
+func (fm *Manager) appendLock(filename string) *sync.Mutex {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	lock, ok := fm.appendLocks[filename]
+	if !ok {
+		lock = new(sync.Mutex)
+		fm.appendLocks[filename] = lock
 	}
 
-	return file, nil
+	return lock
 }
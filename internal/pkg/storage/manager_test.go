@@ -1,9 +1,15 @@
 package storage_test
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"hash/crc32"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/suite"
@@ -57,8 +63,9 @@ func (ts *FileManagerTestSuite) TestCreateFileManagerOnExistsFolder() {
 		testutil.CreateTestTemporaryDir(ts),
 		"data",
 	)
-	_, err := storage.NewFileManager(path, 400)
+	fm, err := storage.NewFileManager(path, 400)
 	ts.Require().NoError(err)
+	ts.Require().NoError(fm.Close())
 
 	ts.Require().DirExists(path)
 	sut, err := storage.NewFileManager(path, 400)
@@ -67,8 +74,16 @@ func (ts *FileManagerTestSuite) TestCreateFileManagerOnExistsFolder() {
 	ts.False(sut.IsNew)
 }
 
+// initDataDir создает в папке управляющий файл, чтобы файлы, которые положит тест, не выглядели старой базой
+func (ts *FileManagerTestSuite) initDataDir(path string, blockSize uint32) {
+	fm, err := storage.NewFileManager(path, blockSize)
+	ts.Require().NoError(err)
+	ts.Require().NoError(fm.Close())
+}
+
 func (ts *FileManagerTestSuite) TestRemoveTemporaryFiles() {
 	path := filepath.Join(testutil.CreateTestTemporaryDir(ts))
+	ts.initDataDir(path, 400)
 
 	// Создаем временные файлы в папке с тестом
 	for i := 0; i < 5; i++ {
@@ -186,11 +201,11 @@ func (ts *FileManagerTestSuite) TestReadAndWriteBlocks() {
 	emptyPage := types.NewPage(blockSize)
 
 	p1 := types.NewPage(blockSize)
-	p1.SetString(0, "Первый блок")
+	ts.Require().NoError(p1.SetString(0, "Первый блок"))
 	ts.Require().NotEqual(emptyPage.Content(), p1.Content())
 
 	p2 := types.NewPage(blockSize)
-	p2.SetString(0, "Второй блок")
+	ts.Require().NoError(p2.SetString(0, "Второй блок"))
 	ts.Require().NotEqual(emptyPage.Content(), p2.Content())
 
 	// Записываем странички в файлы
@@ -225,3 +240,1074 @@ func (ts *FileManagerTestSuite) TestReadAndWriteBlocks() {
 	ts.Equal(emptyPage.Content(), fc[3*blockSize:4*blockSize])
 	ts.Equal(emptyPage.Content(), fc[4*blockSize:5*blockSize])
 }
+
+func (ts *FileManagerTestSuite) TestReadBlocks() {
+	path := testutil.CreateTestTemporaryDir(ts)
+
+	var blockSize uint32 = 100
+
+	fm, err := storage.NewFileManager(path, blockSize)
+	ts.Require().NoError(err)
+
+	defer fm.Close()
+
+	for i := 0; i < 5; i++ {
+		block, err := fm.Append("rb.dat")
+		ts.Require().NoError(err)
+
+		page := types.NewPage(blockSize)
+		ts.Require().NoError(page.SetInt64(0, int64(i)))
+		ts.Require().NoError(fm.Write(block, page))
+	}
+
+	pages := []*types.Page{types.NewPage(blockSize), types.NewPage(blockSize), types.NewPage(blockSize)}
+	ts.Require().NoError(fm.ReadBlocks(types.Block{Filename: "rb.dat", Number: 1}, pages))
+
+	for i, page := range pages {
+		ts.EqualValues(i+1, testutil.Must(page.GetInt64(0)))
+	}
+
+	// Диапазон, который пересекает конец файла, дочитывается нулями
+	for _, page := range pages {
+		copy(page.Content(), bytes.Repeat([]byte{0xff}, int(blockSize)))
+	}
+
+	ts.Require().NoError(fm.ReadBlocks(types.Block{Filename: "rb.dat", Number: 3}, pages))
+	ts.EqualValues(3, testutil.Must(pages[0].GetInt64(0)))
+	ts.EqualValues(4, testutil.Must(pages[1].GetInt64(0)))
+	ts.Equal(make([]byte, blockSize), pages[2].Content())
+
+	// Блоки целиком за концом файла прочитать нельзя
+	ts.ErrorIs(fm.ReadBlocks(types.Block{Filename: "rb.dat", Number: 5}, pages), storage.ErrFileManagerIO)
+}
+
+func (ts *FileManagerTestSuite) TestRead_ShortBlock() {
+	path := testutil.CreateTestTemporaryDir(ts)
+
+	var blockSize uint32 = 100
+
+	ts.initDataDir(path, blockSize)
+
+	// Последний блок файла записан наполовину
+	data := make([]byte, 150)
+	for i := range data {
+		data[i] = 1
+	}
+
+	ts.Require().NoError(os.WriteFile(filepath.Join(path, "short.dat"), data, 0o600))
+
+	fm, err := storage.NewFileManager(path, blockSize)
+	ts.Require().NoError(err)
+
+	defer fm.Close()
+
+	page := types.NewPage(blockSize)
+	copy(page.Content(), bytes.Repeat([]byte{0xff}, int(blockSize)))
+
+	ts.Require().NoError(fm.Read(types.Block{Filename: "short.dat", Number: 1}, page))
+	ts.Equal(bytes.Repeat([]byte{1}, 50), page.Content()[:50])
+	ts.Equal(make([]byte, 50), page.Content()[50:])
+
+	ts.ErrorIs(fm.Read(types.Block{Filename: "short.dat", Number: 2}, page), storage.ErrFileManagerIO)
+}
+
+func (ts *FileManagerTestSuite) TestConcurrentIO() {
+	path := testutil.CreateTestTemporaryDir(ts)
+
+	var blockSize uint32 = 100
+
+	fm, err := storage.NewFileManager(path, blockSize)
+	ts.Require().NoError(err)
+
+	defer fm.Close()
+
+	const (
+		workers = 8
+		blocks  = 20
+	)
+
+	var wg sync.WaitGroup
+
+	// Параллельные Append одного файла получают разные блоки
+	appended := make(chan types.Block, workers*blocks)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < blocks; i++ {
+				block, err := fm.Append(fmt.Sprintf("c_%d.dat", i%2))
+				ts.NoError(err)
+
+				appended <- block
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(appended)
+
+	seen := make(map[types.Block]bool)
+	for block := range appended {
+		ts.False(seen[block], block.String())
+		seen[block] = true
+	}
+
+	for _, filename := range []string{"c_0.dat", "c_1.dat"} {
+		length, err := fm.Length(filename)
+		ts.Require().NoError(err)
+		ts.EqualValues(workers*blocks/2, length)
+	}
+
+	// Параллельно пишем и читаем разные блоки
+	for block := range seen {
+		wg.Add(1)
+
+		go func(block types.Block) {
+			defer wg.Done()
+
+			page := types.NewPage(blockSize)
+			ts.NoError(page.SetString(0, block.String()))
+			ts.NoError(fm.Write(block, page))
+
+			read := types.NewPage(blockSize)
+			ts.NoError(fm.Read(block, read))
+			ts.Equal(block.String(), testutil.Must(read.GetString(0)))
+		}(block)
+	}
+
+	wg.Wait()
+}
+
+func (ts *FileManagerTestSuite) TestMaxOpenFiles() {
+	path := testutil.CreateTestTemporaryDir(ts)
+
+	var blockSize uint32 = 100
+
+	fm, err := storage.NewFileManager(path, blockSize, storage.WithMaxOpenFiles(2))
+	ts.Require().NoError(err)
+
+	defer fm.Close()
+
+	ts.Equal(2, fm.MaxOpenFiles())
+
+	for i := 0; i < 3; i++ {
+		block, err := fm.Append(fmt.Sprintf("f_%d.dat", i))
+		ts.Require().NoError(err)
+
+		page := types.NewPage(blockSize)
+		ts.Require().NoError(page.SetInt64(0, int64(i)))
+		ts.Require().NoError(fm.Write(block, page))
+	}
+
+	// Третий файл вытеснил первый
+	ts.Len(fm.OpenFiles(), 2)
+	ts.NotContains(fm.OpenFiles(), "f_0.dat")
+	ts.Equal(storage.FilesStats{Open: 2, Opens: 3, Closes: 1}, fm.FilesStats())
+
+	// Закрытый файл открывается снова при обращении, а вытесняется давно не использованный f_1.dat
+	page := types.NewPage(blockSize)
+	ts.Require().NoError(fm.Read(types.Block{Filename: "f_0.dat", Number: 0}, page))
+	ts.EqualValues(0, testutil.Must(page.GetInt64(0)))
+
+	ts.Contains(fm.OpenFiles(), "f_0.dat")
+	ts.Contains(fm.OpenFiles(), "f_2.dat")
+	ts.Equal(storage.FilesStats{Open: 2, Opens: 4, Reopens: 1, Closes: 2}, fm.FilesStats())
+
+	ts.Require().NoError(fm.Read(types.Block{Filename: "f_1.dat", Number: 0}, page))
+	ts.EqualValues(1, testutil.Must(page.GetInt64(0)))
+	ts.EqualValues(2, fm.FilesStats().Reopens)
+
+	// Удаленный файл забывается: новый файл с тем же именем открывается впервые
+	ts.NotContains(fm.OpenFiles(), "f_2.dat")
+	ts.Require().NoError(fm.Remove("f_2.dat"))
+
+	_, err = fm.Append("f_2.dat")
+	ts.Require().NoError(err)
+	ts.Equal(storage.FilesStats{Open: 2, Opens: 6, Reopens: 2, Closes: 4}, fm.FilesStats())
+}
+
+func (ts *FileManagerTestSuite) TestMaxOpenFiles_Concurrent() {
+	path := testutil.CreateTestTemporaryDir(ts)
+
+	var blockSize uint32 = 100
+
+	fm, err := storage.NewFileManager(path, blockSize, storage.WithMaxOpenFiles(1))
+	ts.Require().NoError(err)
+
+	defer fm.Close()
+
+	const (
+		files  = 4
+		blocks = 10
+	)
+
+	var wg sync.WaitGroup
+
+	// Файлы вытесняют друг друга, но открытый файл не закрывается посреди чтения или записи
+	for f := 0; f < files; f++ {
+		wg.Add(1)
+
+		go func(f int) {
+			defer wg.Done()
+
+			filename := fmt.Sprintf("m_%d.dat", f)
+
+			for i := 0; i < blocks; i++ {
+				block, err := fm.Append(filename)
+				ts.NoError(err)
+
+				page := types.NewPage(blockSize)
+				ts.NoError(page.SetInt64(0, int64(f*blocks+i)))
+				ts.NoError(fm.Write(block, page))
+				ts.NoError(fm.Read(block, page))
+				ts.EqualValues(f*blocks+i, testutil.Must(page.GetInt64(0)))
+			}
+		}(f)
+	}
+
+	wg.Wait()
+
+	ts.LessOrEqual(len(fm.OpenFiles()), 1)
+	ts.EqualValues(fm.FilesStats().Opens, fm.FilesStats().Closes+int64(len(fm.OpenFiles())))
+}
+
+func (ts *FileManagerTestSuite) TestMemoryBackend() {
+	var blockSize uint32 = 100
+
+	backend := storage.NewMemoryBackend()
+
+	// Временные файлы удаляются при создании менеджера
+	_, err := backend.Open(storage.TempFilesPrefix + "1.dat")
+	ts.Require().NoError(err)
+
+	fm, err := storage.NewManager(backend, blockSize)
+	ts.Require().NoError(err)
+
+	defer fm.Close()
+
+	ts.True(fm.IsNew)
+	ts.Equal(storage.MemoryPath, fm.Path())
+
+	names, err := backend.List()
+	ts.Require().NoError(err)
+	ts.Equal([]string{storage.ControlFileName}, names)
+
+	for i := 0; i < 3; i++ {
+		block, err := fm.Append("mem.dat")
+		ts.Require().NoError(err)
+		ts.EqualValues(i, block.Number)
+
+		page := types.NewPage(blockSize)
+		ts.Require().NoError(page.SetInt64(0, int64(i)))
+		ts.Require().NoError(fm.Write(block, page))
+	}
+
+	length, err := fm.Length("mem.dat")
+	ts.Require().NoError(err)
+	ts.EqualValues(3, length)
+
+	pages := []*types.Page{types.NewPage(blockSize), types.NewPage(blockSize)}
+	ts.Require().NoError(fm.ReadBlocks(types.Block{Filename: "mem.dat", Number: 1}, pages))
+	ts.EqualValues(1, testutil.Must(pages[0].GetInt64(0)))
+	ts.EqualValues(2, testutil.Must(pages[1].GetInt64(0)))
+
+	ts.Require().NoError(fm.Sync("mem.dat"))
+
+	// Данные переживают закрытие файла
+	ts.Require().NoError(fm.Close())
+
+	page := types.NewPage(blockSize)
+	ts.Require().NoError(fm.Read(types.Block{Filename: "mem.dat", Number: 2}, page))
+	ts.EqualValues(2, testutil.Must(page.GetInt64(0)))
+
+	ts.ErrorIs(fm.Read(types.Block{Filename: "mem.dat", Number: 3}, page), storage.ErrFileManagerIO)
+
+	ts.Require().NoError(fm.Remove("mem.dat"))
+	ts.ErrorIs(fm.Remove("mem.dat"), storage.ErrFileManagerIO)
+
+	length, err = fm.Length("mem.dat")
+	ts.Require().NoError(err)
+	ts.Zero(length)
+}
+
+func (ts *FileManagerTestSuite) TestRemove() {
+	path := testutil.CreateTestTemporaryDir(ts)
+
+	fm, err := storage.NewFileManager(path, 100)
+	ts.Require().NoError(err)
+
+	defer fm.Close()
+
+	_, err = fm.Append("removed.dat")
+	ts.Require().NoError(err)
+	ts.FileExists(filepath.Join(path, "removed.dat"))
+
+	ts.Require().NoError(fm.Remove("removed.dat"))
+	ts.NoFileExists(filepath.Join(path, "removed.dat"))
+	ts.NotContains(fm.OpenFiles(), "removed.dat")
+}
+
+func (ts *FileManagerTestSuite) TestFaultyBackend_CrashAfterWrites() {
+	disk := storage.NewMemoryBackend()
+	faulty := storage.NewFaultyBackend(disk, storage.WithCrashAfterWrites(2), storage.WithTornWrites(10))
+
+	file, err := faulty.Open("f.dat")
+	ts.Require().NoError(err)
+
+	data := bytes.Repeat([]byte{1}, 100)
+
+	for i := 0; i < 2; i++ {
+		_, err = file.WriteAt(data, int64(i*100))
+		ts.Require().NoError(err)
+	}
+
+	// Третья запись обрывается на границе сектора, после нее все операции завершаются ошибкой
+	_, err = file.WriteAt(bytes.Repeat([]byte{2}, 100), 200)
+	ts.ErrorIs(err, storage.ErrCrashed)
+	ts.True(faulty.Crashed())
+	ts.Equal(2, faulty.Writes())
+
+	_, err = file.ReadAt(data, 0)
+	ts.ErrorIs(err, storage.ErrCrashed)
+
+	_, err = faulty.Open("f.dat")
+	ts.ErrorIs(err, storage.ErrCrashed)
+
+	diskFile, err := disk.Open("f.dat")
+	ts.Require().NoError(err)
+
+	stat, err := diskFile.Stat()
+	ts.Require().NoError(err)
+	ts.GreaterOrEqual(stat.Size(), int64(200))
+	ts.Zero(stat.Size() % 10)
+}
+
+func (ts *FileManagerTestSuite) TestFaultyBackend_DropUnsynced() {
+	disk := storage.NewMemoryBackend()
+	faulty := storage.NewFaultyBackend(disk, storage.WithDropUnsynced())
+
+	file, err := faulty.Open("f.dat")
+	ts.Require().NoError(err)
+
+	_, err = file.WriteAt([]byte{1, 2, 3}, 0)
+	ts.Require().NoError(err)
+	ts.Require().NoError(file.Sync())
+
+	_, err = file.WriteAt([]byte{4, 5}, 2)
+	ts.Require().NoError(err)
+
+	// До сбоя несброшенные записи видны
+	buf := make([]byte, 5)
+	n, err := file.ReadAt(buf, 0)
+	ts.ErrorIs(err, io.EOF)
+	ts.Equal([]byte{1, 2, 4, 5}, buf[:n])
+
+	stat, err := file.Stat()
+	ts.Require().NoError(err)
+	ts.EqualValues(4, stat.Size())
+
+	faulty.Crash()
+
+	diskFile, err := disk.Open("f.dat")
+	ts.Require().NoError(err)
+
+	n, err = diskFile.ReadAt(buf, 0)
+	ts.ErrorIs(err, io.EOF)
+	ts.Equal([]byte{1, 2, 3}, buf[:n])
+}
+
+func (ts *FileManagerTestSuite) TestFaultyBackend_IOErrors() {
+	faulty := storage.NewFaultyBackend(storage.NewMemoryBackend(), storage.WithIOErrors(1))
+
+	// Не удается записать даже управляющий файл
+	_, err := storage.NewManager(faulty, 100)
+	ts.ErrorIs(err, storage.ErrFileManagerIO)
+	ts.False(faulty.Crashed())
+}
+
+func (ts *FileManagerTestSuite) TestControlFile() {
+	disk := storage.NewMemoryBackend()
+
+	fm, err := storage.NewManager(disk, 100)
+	ts.Require().NoError(err)
+	ts.True(fm.IsNew)
+
+	control := fm.Control()
+	ts.Equal(storage.FormatVersion, control.Version)
+	ts.EqualValues(100, control.BlockSize)
+	ts.False(control.CreatedAt.IsZero())
+	ts.True(control.CleanShutdown)
+
+	// Базу не закрыли, как будто она упала
+	fm, err = storage.NewManager(disk, 100)
+	ts.Require().NoError(err)
+	ts.False(fm.IsNew)
+	ts.False(fm.Control().CleanShutdown)
+	ts.True(control.CreatedAt.Equal(fm.Control().CreatedAt))
+
+	// Закрытие без MarkCleanShutdown штатным не считается
+	ts.Require().NoError(fm.Close())
+
+	fm, err = storage.NewManager(disk, 100)
+	ts.Require().NoError(err)
+	ts.False(fm.Control().CleanShutdown)
+	fm.MarkCleanShutdown()
+	ts.Require().NoError(fm.Close())
+
+	fm, err = storage.NewManager(disk, 100)
+	ts.Require().NoError(err)
+	ts.True(fm.Control().CleanShutdown)
+	ts.Require().NoError(fm.Close())
+
+	_, err = storage.NewManager(disk, 200)
+	ts.ErrorIs(err, storage.ErrBlockSizeMismatch)
+}
+
+func (ts *FileManagerTestSuite) TestControlFile_Errors() {
+	writeControl := func(data []byte) *storage.MemoryBackend {
+		disk := storage.NewMemoryBackend()
+
+		file, err := disk.Open(storage.ControlFileName)
+		ts.Require().NoError(err)
+
+		_, err = file.WriteAt(data, 0)
+		ts.Require().NoError(err)
+
+		return disk
+	}
+
+	validControl := func() []byte {
+		disk := storage.NewMemoryBackend()
+
+		fm, err := storage.NewManager(disk, 100)
+		ts.Require().NoError(err)
+		ts.Require().NoError(fm.Close())
+
+		file, err := disk.Open(storage.ControlFileName)
+		ts.Require().NoError(err)
+
+		data := make([]byte, 64)
+		_, err = file.ReadAt(data, 0)
+		ts.Require().NoError(err)
+
+		return data
+	}
+
+	_, err := storage.NewManager(writeControl([]byte("not a control file")), 100)
+	ts.ErrorIs(err, storage.ErrBadControlFile)
+
+	corrupted := validControl()
+	corrupted[8]++
+	_, err = storage.NewManager(writeControl(corrupted), 100)
+	ts.ErrorIs(err, storage.ErrBadControlFile)
+	ts.Contains(err.Error(), "checksum mismatch")
+
+	checksum := func(data []byte) uint32 {
+		return crc32.Update(crc32.ChecksumIEEE(data[:24]), crc32.IEEETable, data[28:])
+	}
+
+	// Версию формата из будущего не открываем
+	future := validControl()
+	binary.LittleEndian.PutUint32(future[4:], storage.FormatVersion+1)
+	binary.LittleEndian.PutUint32(future[24:], checksum(future))
+	_, err = storage.NewManager(writeControl(future), 100)
+	ts.ErrorIs(err, storage.ErrUnsupportedFormat)
+
+	// В первой версии записи лежат в слотах фиксированного размера, страницы не переводятся в новый формат.
+	// Проверочного значения ключа в ней нет, и контрольная сумма его не покрывает
+	fixedSlots := validControl()
+	binary.LittleEndian.PutUint32(fixedSlots[4:], 1)
+	binary.LittleEndian.PutUint32(fixedSlots[24:], crc32.ChecksumIEEE(fixedSlots[:24]))
+	_, err = storage.NewManager(writeControl(fixedSlots), 100)
+	ts.ErrorIs(err, storage.ErrUnsupportedFormat)
+	ts.Contains(err.Error(), "fixed-size record slots")
+
+	// Испорченное проверочное значение ключа — испорченный файл, а не неверный ключ
+	key := bytes.Repeat([]byte{1}, 32)
+	encrypted := storage.NewMemoryBackend()
+
+	fm, err := storage.NewManager(encrypted, 100, storage.WithEncryptionKey(key))
+	ts.Require().NoError(err)
+	ts.Require().NoError(fm.Close())
+
+	file, err := encrypted.Open(storage.ControlFileName)
+	ts.Require().NoError(err)
+
+	badKeyCheck := make([]byte, 64)
+	_, err = file.ReadAt(badKeyCheck, 0)
+	ts.Require().NoError(err)
+
+	badKeyCheck[40]++
+	_, err = storage.NewManager(writeControl(badKeyCheck), 100, storage.WithEncryptionKey(key))
+	ts.ErrorIs(err, storage.ErrBadControlFile)
+	ts.Contains(err.Error(), "checksum mismatch")
+}
+
+func (ts *FileManagerTestSuite) TestControlFile_LegacyDataDir() {
+	path := testutil.CreateTestTemporaryDir(ts)
+
+	// Папка с данными, созданная до появления управляющего файла
+	ts.Require().NoError(os.WriteFile(filepath.Join(path, "table.tbl"), make([]byte, 200), 0o600))
+
+	// Размер блока такой папки берется из параметров, поэтому сверяется с размерами файлов
+	_, err := storage.NewFileManager(path, 300)
+	ts.ErrorIs(err, storage.ErrBlockSizeMismatch)
+	ts.Contains(err.Error(), `file "table.tbl" size 200 is not a multiple of requested block size 300`)
+
+	// В ней записи лежат в слотах фиксированного размера, такой формат больше не открываем
+	_, err = storage.NewFileManager(path, 100)
+	ts.ErrorIs(err, storage.ErrUnsupportedFormat)
+	ts.NoFileExists(filepath.Join(path, storage.ControlFileName))
+}
+
+func (ts *FileManagerTestSuite) TestDataDirLock() {
+	path := testutil.CreateTestTemporaryDir(ts)
+
+	fm, err := storage.NewFileManager(path, 100)
+	ts.Require().NoError(err)
+	ts.True(fm.IsNew)
+
+	_, err = storage.NewFileManager(path, 100)
+	ts.Require().ErrorIs(err, storage.ErrDatabaseLocked)
+	ts.Contains(err.Error(), fmt.Sprintf("database is in use by pid %d", os.Getpid()))
+
+	ts.Require().NoError(fm.Close())
+
+	fm, err = storage.NewFileManager(path, 100)
+	ts.Require().NoError(err)
+	ts.False(fm.IsNew)
+	ts.Require().NoError(fm.Close())
+}
+
+func (ts *FileManagerTestSuite) TestCompression() {
+	var blockSize uint32 = 1024
+
+	for _, codec := range []string{storage.CompressionZstd, storage.CompressionS2} {
+		backend := storage.NewMemoryBackend()
+
+		fm, err := storage.NewManager(backend, blockSize)
+		ts.Require().NoError(err)
+
+		ts.Require().NoError(fm.SetCompression("c.dat", codec))
+
+		compression, err := fm.Compression("c.dat")
+		ts.Require().NoError(err)
+		ts.Equal(codec, compression)
+
+		// Несжимаемая страница хранится как есть
+		random := types.NewPage(blockSize)
+		for i := uint32(0); i < blockSize; i += 4 {
+			ts.Require().NoError(random.SetUint32(i, crc32.ChecksumIEEE(binary.LittleEndian.AppendUint32(nil, i))))
+		}
+
+		for i := 0; i < 4; i++ {
+			block, err := fm.Append("c.dat")
+			ts.Require().NoError(err)
+			ts.EqualValues(i, block.Number)
+		}
+
+		// Страницы переписываем много раз: освобожденные экстенты используются снова
+		for round := int64(0); round < 10; round++ {
+			for i := int32(0); i < 3; i++ {
+				page := types.NewPage(blockSize)
+				ts.Require().NoError(page.SetInt64(0, round*10+int64(i)))
+				ts.Require().NoError(page.SetString(100, strings.Repeat("sophia", int(round+1))))
+				ts.Require().NoError(fm.Write(types.Block{Filename: "c.dat", Number: types.BlockID(i)}, page))
+			}
+		}
+
+		ts.Require().NoError(fm.Write(types.Block{Filename: "c.dat", Number: 3}, random))
+
+		ts.Require().NoError(fm.Close())
+
+		dataFile, err := backend.Open("c.dat")
+		ts.Require().NoError(err)
+
+		stat, err := dataFile.Stat()
+		ts.Require().NoError(err)
+		ts.Less(stat.Size(), int64(4*blockSize), codec)
+
+		// Карта блоков переживает переоткрытие
+		fm, err = storage.NewManager(backend, blockSize)
+		ts.Require().NoError(err)
+
+		length, err := fm.Length("c.dat")
+		ts.Require().NoError(err)
+		ts.EqualValues(4, length)
+
+		pages := []*types.Page{types.NewPage(blockSize), types.NewPage(blockSize), types.NewPage(blockSize), types.NewPage(blockSize)}
+		ts.Require().NoError(fm.ReadBlocks(types.Block{Filename: "c.dat", Number: 0}, pages))
+
+		for i := 0; i < 3; i++ {
+			ts.EqualValues(90+i, testutil.Must(pages[i].GetInt64(0)))
+			ts.Equal(strings.Repeat("sophia", 10), testutil.Must(pages[i].GetString(100)))
+		}
+
+		ts.Equal(random.Content(), pages[3].Content())
+
+		ts.Require().NoError(fm.Remove("c.dat"))
+
+		names, err := backend.List()
+		ts.Require().NoError(err)
+		ts.Equal([]string{storage.ControlFileName}, names)
+
+		compression, err = fm.Compression("c.dat")
+		ts.Require().NoError(err)
+		ts.Equal(storage.CompressionNone, compression)
+
+		ts.Require().NoError(fm.Close())
+	}
+}
+
+func (ts *FileManagerTestSuite) TestCompression_Errors() {
+	fm, err := storage.NewManager(storage.NewMemoryBackend(), 100)
+	ts.Require().NoError(err)
+
+	defer fm.Close()
+
+	ts.ErrorIs(fm.SetCompression("c.dat", "lzma"), storage.ErrUnknownCodec)
+	ts.Require().NoError(fm.SetCompression("c.dat", storage.CompressionNone))
+
+	_, err = fm.Append("c.dat")
+	ts.Require().NoError(err)
+
+	ts.ErrorIs(fm.SetCompression("c.dat", storage.CompressionZstd), storage.ErrFileNotEmpty)
+
+	compression, err := fm.Compression("c.dat")
+	ts.Require().NoError(err)
+	ts.Equal(storage.CompressionNone, compression)
+}
+
+// TestCompression_Crash проверяет, что после сбоя во время записи блок сжатого файла читается целиком в старой или новой версии
+func (ts *FileManagerTestSuite) TestCompression_Crash() {
+	var blockSize uint32 = 512
+
+	for crashAfter := 0; crashAfter < 8; crashAfter++ {
+		disk := storage.NewMemoryBackend()
+
+		fm, err := storage.NewManager(disk, blockSize)
+		ts.Require().NoError(err)
+		ts.Require().NoError(fm.SetCompression("c.dat", storage.CompressionZstd))
+
+		page := types.NewPage(blockSize)
+		ts.Require().NoError(page.SetInt64(0, 1))
+
+		block, err := fm.Append("c.dat")
+		ts.Require().NoError(err)
+		ts.Require().NoError(fm.Write(block, page))
+		ts.Require().NoError(fm.Close())
+
+		faulty := storage.NewFaultyBackend(disk,
+			storage.WithCrashAfterWrites(crashAfter),
+			storage.WithTornWrites(128),
+			storage.WithDropUnsynced(),
+		)
+
+		fm, err = storage.NewManager(faulty, blockSize)
+		if err == nil {
+			for i := int64(2); i < 5; i++ {
+				ts.Require().NoError(page.SetInt64(0, i))
+				ts.Require().NoError(page.SetString(8, strings.Repeat("x", int(i*20))))
+
+				if fm.Write(block, page) != nil || fm.Sync("c.dat") != nil {
+					break
+				}
+			}
+		}
+
+		faulty.Crash()
+
+		fm, err = storage.NewManager(disk, blockSize)
+		ts.Require().NoError(err)
+
+		ts.Require().NoError(fm.Read(block, page))
+
+		val := testutil.Must(page.GetInt64(0))
+		ts.True(val >= 1 && val < 5, "crash after %d writes: %d", crashAfter, val)
+
+		if val > 1 {
+			ts.Equal(strings.Repeat("x", int(val*20)), testutil.Must(page.GetString(8)))
+		}
+
+		ts.Require().NoError(fm.Close())
+	}
+}
+
+// TestCompression_SyncMap проверяет, что ссылки на новые экстенты попадают в карту одной записью при Sync,
+// а до этого после сбоя читаются прежние версии блоков
+func (ts *FileManagerTestSuite) TestCompression_SyncMap() {
+	var blockSize uint32 = 512
+
+	disk := storage.NewMemoryBackend()
+	faulty := storage.NewFaultyBackend(disk)
+
+	fm, err := storage.NewManager(faulty, blockSize)
+	ts.Require().NoError(err)
+	ts.Require().NoError(fm.SetCompression("c.dat", storage.CompressionZstd))
+
+	page := types.NewPage(blockSize)
+
+	for i := int64(0); i < 3; i++ {
+		block, err := fm.Append("c.dat")
+		ts.Require().NoError(err)
+
+		ts.Require().NoError(page.SetInt64(0, i+1))
+		ts.Require().NoError(fm.Write(block, page))
+	}
+
+	ts.Require().NoError(fm.Sync("c.dat"))
+
+	// Три блока переписываем дважды: шесть записей данных и одна запись карты
+	writes := faulty.Writes()
+
+	for round := int64(1); round <= 2; round++ {
+		for i := int32(0); i < 3; i++ {
+			ts.Require().NoError(page.SetInt64(0, round*10+int64(i)))
+			ts.Require().NoError(fm.Write(types.Block{Filename: "c.dat", Number: types.BlockID(i)}, page))
+		}
+	}
+
+	ts.Equal(writes+6, faulty.Writes())
+
+	ts.Require().NoError(fm.Sync("c.dat"))
+	ts.Equal(writes+7, faulty.Writes())
+
+	// Записи без Sync после сбоя не видны
+	ts.Require().NoError(page.SetInt64(0, 100))
+	ts.Require().NoError(fm.Write(types.Block{Filename: "c.dat", Number: 0}, page))
+
+	faulty.Crash()
+
+	fm, err = storage.NewManager(disk, blockSize)
+	ts.Require().NoError(err)
+
+	for i := int32(0); i < 3; i++ {
+		ts.Require().NoError(fm.Read(types.Block{Filename: "c.dat", Number: types.BlockID(i)}, page))
+		ts.EqualValues(20+i, testutil.Must(page.GetInt64(0)))
+	}
+
+	ts.Require().NoError(fm.Close())
+}
+
+func (ts *FileManagerTestSuite) TestEncryption() {
+	var blockSize uint32 = 512
+
+	key := bytes.Repeat([]byte{1}, 32)
+	backend := storage.NewMemoryBackend()
+
+	fm, err := storage.NewManager(backend, blockSize, storage.WithEncryptionKey(key))
+	ts.Require().NoError(err)
+	ts.True(fm.Encrypted())
+	ts.True(fm.Control().Encrypted)
+
+	ts.Require().NoError(fm.SetCompression("c.dat", storage.CompressionS2))
+
+	for _, filename := range []string{"e.dat", "c.dat"} {
+		for i := 0; i < 3; i++ {
+			block, err := fm.Append(filename)
+			ts.Require().NoError(err)
+
+			page := types.NewPage(blockSize)
+			ts.Require().NoError(page.SetString(0, fmt.Sprintf("secret %d", i)))
+			ts.Require().NoError(fm.Write(block, page))
+		}
+	}
+
+	ts.Require().NoError(fm.Close())
+
+	// На диске нет открытого текста
+	for _, filename := range []string{"e.dat", "c.dat"} {
+		file, err := backend.Open(filename)
+		ts.Require().NoError(err)
+
+		stat, err := file.Stat()
+		ts.Require().NoError(err)
+
+		data := make([]byte, stat.Size())
+		_, err = file.ReadAt(data, 0)
+		ts.Require().NoError(err)
+		ts.NotContains(string(data), "secret")
+	}
+
+	_, err = storage.NewManager(backend, blockSize)
+	ts.ErrorIs(err, storage.ErrEncryptionKeyRequired)
+
+	_, err = storage.NewManager(backend, blockSize, storage.WithEncryptionKey(bytes.Repeat([]byte{2}, 32)))
+	ts.ErrorIs(err, storage.ErrWrongKey)
+
+	_, err = storage.NewManager(backend, blockSize, storage.WithEncryptionKey([]byte("short")))
+	ts.ErrorIs(err, storage.ErrBadEncryptionKey)
+
+	fm, err = storage.NewManager(backend, blockSize, storage.WithEncryptionKey(key))
+	ts.Require().NoError(err)
+
+	compression, err := fm.Compression("c.dat")
+	ts.Require().NoError(err)
+	ts.Equal(storage.CompressionS2, compression)
+
+	page := types.NewPage(blockSize)
+
+	for _, filename := range []string{"e.dat", "c.dat"} {
+		ts.Require().NoError(fm.Read(types.Block{Filename: filename, Number: 2}, page))
+		ts.Equal("secret 2", testutil.Must(page.GetString(0)))
+	}
+
+	// Измененный на диске блок не расшифровывается
+	file, err := backend.Open("e.dat")
+	ts.Require().NoError(err)
+
+	data := make([]byte, 64)
+	_, err = file.ReadAt(data, 0)
+	ts.Require().NoError(err)
+
+	data[20] ^= 0xff
+	_, err = file.WriteAt(data, 0)
+	ts.Require().NoError(err)
+
+	ts.ErrorIs(fm.Read(types.Block{Filename: "e.dat", Number: 0}, page), storage.ErrCorruptedBlock)
+
+	ts.Require().NoError(fm.Close())
+
+	plain := storage.NewMemoryBackend()
+
+	fm, err = storage.NewManager(plain, blockSize)
+	ts.Require().NoError(err)
+	ts.Require().NoError(fm.Close())
+
+	_, err = storage.NewManager(plain, blockSize, storage.WithEncryptionKey(key))
+	ts.ErrorIs(err, storage.ErrNotEncrypted)
+}
+
+func (ts *FileManagerTestSuite) TestRotateKey() {
+	var blockSize uint32 = 512
+
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 16)
+
+	for crashAfter := 0; crashAfter < 8; crashAfter += 2 {
+		disk := storage.NewMemoryBackend()
+
+		fm, err := storage.NewManager(disk, blockSize, storage.WithEncryptionKey(oldKey))
+		ts.Require().NoError(err)
+
+		for i := 0; i < 4; i++ {
+			block, err := fm.Append("r.dat")
+			ts.Require().NoError(err)
+
+			page := types.NewPage(blockSize)
+			ts.Require().NoError(page.SetInt64(0, int64(i+1)))
+			ts.Require().NoError(fm.Write(block, page))
+		}
+
+		fm.MarkCleanShutdown()
+		ts.Require().NoError(fm.Close())
+
+		// Прерванная смена ключа
+		faulty := storage.NewFaultyBackend(disk, storage.WithCrashAfterWrites(crashAfter))
+
+		fm, err = storage.NewManager(faulty, blockSize, storage.WithEncryptionKey(oldKey))
+		if err == nil {
+			ts.Require().Error(fm.RotateKey(newKey))
+		}
+
+		faulty.Crash()
+
+		// Повторный запуск доводит смену ключа до конца
+		fm, err = storage.NewManager(disk, blockSize, storage.WithEncryptionKey(oldKey))
+		ts.Require().NoError(err)
+		ts.Require().NoError(fm.RotateKey(newKey))
+		ts.False(fm.Control().KeyRotation)
+		ts.Require().NoError(fm.Close())
+
+		_, err = storage.NewManager(disk, blockSize, storage.WithEncryptionKey(oldKey))
+		ts.ErrorIs(err, storage.ErrWrongKey)
+
+		fm, err = storage.NewManager(disk, blockSize, storage.WithEncryptionKey(newKey))
+		ts.Require().NoError(err)
+
+		page := types.NewPage(blockSize)
+
+		for i := int32(0); i < 4; i++ {
+			ts.Require().NoError(fm.Read(types.Block{Filename: "r.dat", Number: types.BlockID(i)}, page))
+			ts.EqualValues(i+1, testutil.Must(page.GetInt64(0)))
+		}
+
+		ts.Require().NoError(fm.Close())
+	}
+
+	fm, err := storage.NewManager(storage.NewMemoryBackend(), blockSize)
+	ts.Require().NoError(err)
+	ts.ErrorIs(fm.RotateKey(newKey), storage.ErrNotEncrypted)
+
+	// После сбоя базу сначала открывают для восстановления
+	disk := storage.NewMemoryBackend()
+	faulty := storage.NewFaultyBackend(disk)
+
+	_, err = storage.NewManager(faulty, blockSize, storage.WithEncryptionKey(oldKey))
+	ts.Require().NoError(err)
+
+	faulty.Crash()
+
+	fm, err = storage.NewManager(disk, blockSize, storage.WithEncryptionKey(oldKey))
+	ts.Require().NoError(err)
+	ts.ErrorIs(fm.RotateKey(newKey), storage.ErrRecoveryRequired)
+
+	// Восстановление прошло, база закрыта штатно
+	fm.MarkCleanShutdown()
+	ts.Require().NoError(fm.Close())
+
+	fm, err = storage.NewManager(disk, blockSize, storage.WithEncryptionKey(oldKey))
+	ts.Require().NoError(err)
+	ts.Require().NoError(fm.RotateKey(newKey))
+	ts.Require().NoError(fm.Close())
+}
+
+func (ts *FileManagerTestSuite) TestMmap() {
+	var blockSize uint32 = 4096
+
+	path := testutil.CreateTestTemporaryDir(ts)
+
+	fm, err := storage.NewMmapFileManager(path, blockSize)
+	ts.Require().NoError(err)
+
+	ts.Require().NoError(fm.SetCompression("c.dat", storage.CompressionS2))
+
+	block, err := fm.Append("m.dat")
+	ts.Require().NoError(err)
+
+	page := types.NewPage(blockSize)
+	ts.Require().NoError(page.SetString(0, "first"))
+	ts.Require().NoError(fm.Write(block, page))
+
+	view, release, err := fm.View(block)
+	ts.Require().NoError(err)
+	ts.Require().NotNil(view)
+	ts.Equal("first", testutil.Must(view.GetString(0)))
+
+	// Запись в файл сразу видна в отображении
+	ts.Require().NoError(page.SetString(0, "second"))
+	ts.Require().NoError(fm.Write(block, page))
+	ts.Equal("second", testutil.Must(view.GetString(0)))
+
+	// Файл растет и отображается заново, а выданная страница остается доступной
+	for i := 1; i < 64; i++ {
+		block, err := fm.Append("m.dat")
+		ts.Require().NoError(err)
+
+		ts.Require().NoError(page.SetInt64(0, int64(i)))
+		ts.Require().NoError(fm.Write(block, page))
+	}
+
+	ts.Equal("second", testutil.Must(view.GetString(0)))
+
+	for i := 1; i < 64; i++ {
+		ts.Require().NoError(fm.Read(types.Block{Filename: "m.dat", Number: types.BlockID(int32(i))}, page))
+		ts.EqualValues(i, testutil.Must(page.GetInt64(0)))
+	}
+
+	last, release2, err := fm.View(types.Block{Filename: "m.dat", Number: 63})
+	ts.Require().NoError(err)
+	ts.Require().NotNil(last)
+	ts.EqualValues(63, testutil.Must(last.GetInt64(0)))
+
+	// Блока нет в файле, у сжатого файла нет отображения
+	for _, block := range []types.Block{{Filename: "m.dat", Number: 64}, {Filename: "c.dat", Number: 0}} {
+		view, _, err := fm.View(block)
+		ts.Require().NoError(err)
+		ts.Nil(view)
+	}
+
+	ts.Require().NoError(fm.Close())
+
+	// Страницы живут и после закрытия файла, пока их не освободили
+	ts.Equal("second", testutil.Must(view.GetString(0)))
+	ts.EqualValues(63, testutil.Must(last.GetInt64(0)))
+
+	release()
+	release2()
+
+	fm, err = storage.NewFileManager(path, blockSize)
+	ts.Require().NoError(err)
+
+	ts.Require().NoError(fm.Read(types.Block{Filename: "m.dat", Number: 0}, page))
+	ts.Equal("second", testutil.Must(page.GetString(0)))
+
+	view, _, err = fm.View(types.Block{Filename: "m.dat", Number: 0})
+	ts.Require().NoError(err)
+	ts.Nil(view)
+
+	ts.Require().NoError(fm.Close())
+}
+
+// BenchmarkRead — чтение блоков в случайном порядке через Read и View с файлами на диске и в отображении
+func BenchmarkRead(b *testing.B) {
+	const (
+		blockSize uint32 = 4096
+		blocks           = 1024
+	)
+
+	newManagers := map[string]func(path string) (*storage.Manager, error){
+		storage.BackendFile: func(path string) (*storage.Manager, error) {
+			return storage.NewFileManager(path, blockSize)
+		},
+		storage.BackendMmap: func(path string) (*storage.Manager, error) {
+			return storage.NewMmapFileManager(path, blockSize)
+		},
+	}
+
+	for _, backend := range []string{storage.BackendFile, storage.BackendMmap} {
+		fm, err := newManagers[backend](b.TempDir())
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		page := types.NewPage(blockSize)
+
+		for i := 0; i < blocks; i++ {
+			block, err := fm.Append("bench.dat")
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			if err := page.SetInt64(0, int64(i)); err != nil {
+				b.Fatal(err)
+			}
+
+			if err := fm.Write(block, page); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		b.Run(backend+"/read", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				block := types.Block{Filename: "bench.dat", Number: types.BlockID(int32(i * 7919 % blocks))}
+
+				if err := fm.Read(block, page); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		if backend == storage.BackendMmap {
+			b.Run(backend+"/view", func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					block := types.Block{Filename: "bench.dat", Number: types.BlockID(int32(i * 7919 % blocks))}
+
+					view, release, err := fm.View(block)
+					if err != nil || view == nil {
+						b.Fatal(err)
+					}
+
+					release()
+				}
+			})
+		}
+
+		if err := fm.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
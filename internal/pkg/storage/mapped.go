@@ -0,0 +1,639 @@
+package storage
+
+import (
+	"cmp"
+	"encoding/binary"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+// Сжатые файлы и все файлы зашифрованной базы хранят страницы в экстентах переменного размера,
+// а карта блоков в файле с суффиксом BlockMapSuffix указывает, где лежит каждый блок.
+// Измененная страница пишется в свободный экстент, а ссылки на новые экстенты попадают в карту пачкой при Sync:
+// сначала на носитель сбрасывается файл данных, потом пишется и сбрасывается карта. До этого прежние экстенты
+// не занимаются, поэтому после сбоя блок читается либо в старой, либо в новой версии. Запись карты занимает 16 байт
+// и не пересекает границу сектора. Экстенты, на которые не ссылается карта, при открытии файла считаются свободными
+const (
+	// BlockMapSuffix — суффикс файла карты блоков
+	BlockMapSuffix = ".bmap"
+
+	blockMapMagic   uint32 = 0x53444d50 // SDMP
+	blockMapVersion uint32 = 1
+
+	blockMapHeaderSize    = 16
+	blockMapMagicOffset   = 0
+	blockMapVersionOffset = 4
+	blockMapCodecOffset   = 8
+	blockMapFlagsOffset   = 12
+
+	blockMapEncrypted uint32 = 1
+
+	blockMapEntrySize      = 16
+	blockMapOffsetOffset   = 0
+	blockMapLengthOffset   = 8
+	blockMapCapacityOffset = 12
+
+	// extentUnit — размер экстента кратен extentUnit
+	extentUnit = 64
+)
+
+// extent — место блока в файле данных. Нулевая длина — блок из нулей, который не занимает места
+type extent struct {
+	offset   int64
+	length   uint32
+	capacity uint32
+}
+
+// mappedFile — файл с картой блоков. Страница, которая не сжалась, хранится как есть
+type mappedFile struct {
+	mu sync.RWMutex
+
+	loaded  bool
+	codec   Codec // nil — без сжатия
+	entries []extent
+	free    map[uint32][]int64 // Свободные экстенты по размеру
+	end     int64              // Конец занятой части файла данных
+
+	// dirty — блоки, ссылки на которые еще не записаны в карту. true — карта на носителе
+	// не ссылается на текущий экстент блока, и его можно освободить при следующей записи блока
+	dirty   map[types.BlockID]bool
+	retired []extent   // Прежние экстенты блоков из dirty, на них еще ссылается карта на носителе
+	syncMu  sync.Mutex // Карта сбрасывается на носитель по одному вызову за раз, см. syncMapped
+}
+
+// reset заполняет файл содержимым карты блоков. Свободные экстенты собирает loadBlockMap
+func (mf *mappedFile) reset(codec Codec, entries []extent) {
+	mf.codec = codec
+	mf.entries = entries
+	mf.free = make(map[uint32][]int64)
+	mf.end = 0
+	mf.dirty = make(map[types.BlockID]bool)
+	mf.retired = nil
+	mf.loaded = true
+}
+
+func blockMapName(filename string) string {
+	return filename + BlockMapSuffix
+}
+
+// registerMapped запоминает файлы с картами блоков из хранилища. Карты читаются при первом обращении к файлу
+func (fm *Manager) registerMapped(names []string) {
+	for _, name := range names {
+		if filename, ok := strings.CutSuffix(name, BlockMapSuffix); ok {
+			fm.mappedFiles[filename] = new(mappedFile)
+		}
+	}
+}
+
+// mapped возвращает файл с картой блоков или nil, если файл хранится блоками подряд.
+// В зашифрованной базе карта блоков есть у всех файлов, новая создается при первом обращении
+func (fm *Manager) mapped(filename string) (*mappedFile, error) {
+	fm.mu.Lock()
+	mf := fm.mappedFiles[filename]
+
+	if mf == nil && fm.encrypted() && !strings.HasSuffix(filename, BlockMapSuffix) {
+		mf = new(mappedFile)
+		fm.mappedFiles[filename] = mf
+	}
+	fm.mu.Unlock()
+
+	if mf == nil {
+		return nil, nil //nolint:nilnil
+	}
+
+	mf.mu.RLock()
+	loaded := mf.loaded
+	mf.mu.RUnlock()
+
+	if loaded {
+		return mf, nil
+	}
+
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if !mf.loaded {
+		if err := fm.loadBlockMap(filename, mf); err != nil {
+			return nil, err
+		}
+	}
+
+	return mf, nil
+}
+
+// SetCompression включает сжатие страниц файла алгоритмом codecName. Файл должен быть пустым.
+// CompressionNone и пустое имя ничего не меняют
+func (fm *Manager) SetCompression(filename string, codecName string) error {
+	if codecName == "" || codecName == CompressionNone {
+		return nil
+	}
+
+	codec, err := CodecByName(codecName)
+	if err != nil {
+		return err
+	}
+
+	length, err := fm.Length(filename)
+	if err != nil {
+		return err
+	}
+
+	if length > 0 {
+		return errors.WithMessagef(ErrFileNotEmpty, "%s has %d blocks", filename, length)
+	}
+
+	if err := fm.writeBlockMapHeader(filename, codec); err != nil {
+		return err
+	}
+
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	mf := new(mappedFile)
+	mf.reset(codec, nil)
+
+	fm.mappedFiles[filename] = mf
+
+	return nil
+}
+
+// ResetCompression отменяет SetCompression при откате транзакции: удаляет файл вместе с картой блоков.
+// Сжатие включается только у пустого файла, поэтому после отката содержимое файла не нужно
+func (fm *Manager) ResetCompression(filename string) error {
+	mf, err := fm.mapped(filename)
+	if err != nil {
+		return err
+	}
+
+	if mf == nil || (fm.encrypted() && mf.codec == nil) {
+		return nil
+	}
+
+	return fm.Remove(filename)
+}
+
+// Compression возвращает алгоритм сжатия файла или CompressionNone
+func (fm *Manager) Compression(filename string) (string, error) {
+	mf, err := fm.mapped(filename)
+	if err != nil {
+		return "", err
+	}
+
+	if mf == nil || mf.codec == nil {
+		return CompressionNone, nil
+	}
+
+	return mf.codec.Name(), nil
+}
+
+func (fm *Manager) writeBlockMapHeader(filename string, codec Codec) error {
+	var flags uint32
+	if fm.encrypted() {
+		flags |= blockMapEncrypted
+	}
+
+	order := binary.LittleEndian
+
+	data := make([]byte, blockMapHeaderSize)
+	order.PutUint32(data[blockMapMagicOffset:], blockMapMagic)
+	order.PutUint32(data[blockMapVersionOffset:], blockMapVersion)
+	order.PutUint32(data[blockMapCodecOffset:], codecID(codec))
+	order.PutUint32(data[blockMapFlagsOffset:], flags)
+
+	return fm.writeAndSync(blockMapName(filename), data, 0)
+}
+
+// loadBlockMap читает карту блоков и собирает свободные экстенты из промежутков между занятыми.
+// Пустую карту создает заново, если файл данных тоже пуст
+func (fm *Manager) loadBlockMap(filename string, mf *mappedFile) error {
+	mapName := blockMapName(filename)
+
+	data, err := fm.readAll(mapName)
+	if err != nil {
+		return err
+	}
+
+	if len(data) == 0 {
+		return fm.createBlockMap(filename, mf)
+	}
+
+	if len(data) < blockMapHeaderSize {
+		return errors.WithMessagef(ErrBadBlockMap, "%s: too short", mapName)
+	}
+
+	order := binary.LittleEndian
+
+	if order.Uint32(data[blockMapMagicOffset:]) != blockMapMagic {
+		return errors.WithMessagef(ErrBadBlockMap, "%s: not a block map", mapName)
+	}
+
+	if version := order.Uint32(data[blockMapVersionOffset:]); version != blockMapVersion {
+		return errors.WithMessagef(ErrUnsupportedFormat, "%s: block map version %d", mapName, version)
+	}
+
+	var codec Codec
+
+	if id := order.Uint32(data[blockMapCodecOffset:]); id != 0 {
+		var ok bool
+
+		if codec, ok = codecs[id]; !ok {
+			return errors.WithMessagef(ErrUnknownCodec, "%s: codec %d", mapName, id)
+		}
+	}
+
+	if encrypted := order.Uint32(data[blockMapFlagsOffset:])&blockMapEncrypted != 0; encrypted != fm.encrypted() {
+		return errors.WithMessagef(ErrBadBlockMap, "%s: encrypted %t, database encrypted %t", mapName, encrypted, fm.encrypted())
+	}
+
+	count := (len(data) - blockMapHeaderSize) / blockMapEntrySize
+	entries := make([]extent, count)
+
+	for i := range entries {
+		entry := data[blockMapHeaderSize+i*blockMapEntrySize:]
+
+		entries[i] = extent{
+			offset:   int64(order.Uint64(entry[blockMapOffsetOffset:])),
+			length:   order.Uint32(entry[blockMapLengthOffset:]),
+			capacity: order.Uint32(entry[blockMapCapacityOffset:]),
+		}
+	}
+
+	used := slices.DeleteFunc(slices.Clone(entries), func(e extent) bool {
+		return e.capacity == 0
+	})
+
+	slices.SortFunc(used, func(a, b extent) int {
+		return cmp.Compare(a.offset, b.offset)
+	})
+
+	mf.reset(codec, entries)
+
+	maxCapacity := fm.extentCapacity(fm.blockSize + fm.sealOverhead())
+
+	for _, e := range used {
+		for gap := e.offset - mf.end; gap > 0; {
+			capacity := uint32(min(gap, int64(maxCapacity)))
+			mf.free[capacity] = append(mf.free[capacity], mf.end)
+			mf.end += int64(capacity)
+			gap -= int64(capacity)
+		}
+
+		mf.end = max(mf.end, e.offset+int64(e.capacity))
+	}
+
+	return nil
+}
+
+// createBlockMap создает карту блоков без сжатия. Файл данных должен быть пуст:
+// блоки, записанные подряд без карты, в зашифрованной базе прочитать нельзя
+func (fm *Manager) createBlockMap(filename string, mf *mappedFile) error {
+	data, err := fm.readAll(filename)
+	if err != nil {
+		return err
+	}
+
+	if len(data) > 0 {
+		return errors.WithMessagef(ErrBadBlockMap, "%s: file has data but no block map", filename)
+	}
+
+	if err := fm.writeBlockMapHeader(filename, nil); err != nil {
+		return err
+	}
+
+	mf.reset(nil, nil)
+
+	return nil
+}
+
+// readAll читает файл целиком
+func (fm *Manager) readAll(filename string) ([]byte, error) {
+	file, err := fm.acquire(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer fm.release(filename)
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	data := make([]byte, stat.Size())
+
+	if _, err := file.ReadAt(data, 0); err != nil && !errors.Is(err, io.EOF) {
+		return nil, errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	return data, nil
+}
+
+// extentCapacity округляет длину до размера экстента
+func (fm *Manager) extentCapacity(length uint32) uint32 {
+	return (length + extentUnit - 1) / extentUnit * extentUnit
+}
+
+// allocate возвращает свободный экстент не меньше capacity. Вызывается под блокировкой файла
+func (mf *mappedFile) allocate(capacity uint32) extent {
+	best := uint32(0)
+
+	for c, offsets := range mf.free {
+		if c >= capacity && len(offsets) > 0 && (best == 0 || c < best) {
+			best = c
+		}
+	}
+
+	if best == 0 {
+		e := extent{offset: mf.end, capacity: capacity}
+		mf.end += int64(capacity)
+
+		return e
+	}
+
+	offsets := mf.free[best]
+	e := extent{offset: offsets[len(offsets)-1], capacity: best}
+	mf.free[best] = offsets[:len(offsets)-1]
+
+	return e
+}
+
+// releaseExtent возвращает экстент в список свободных. Вызывается под блокировкой файла
+func (mf *mappedFile) releaseExtent(e extent) {
+	if e.capacity > 0 {
+		mf.free[e.capacity] = append(mf.free[e.capacity], e.offset)
+	}
+}
+
+// readMapped читает блок, расшифровывает и распаковывает его в страницу
+func (fm *Manager) readMapped(mf *mappedFile, block types.Block, page *types.Page) error {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+
+	if int(block.Number) >= len(mf.entries) || block.Number < 0 {
+		return errors.WithMessagef(ErrFileManagerIO, "%s: block %d out of range", block.Filename, block.Number)
+	}
+
+	e := mf.entries[block.Number]
+	content := page.Content()
+
+	if e.length == 0 {
+		clear(content)
+
+		return nil
+	}
+
+	file, err := fm.acquire(block.Filename)
+	if err != nil {
+		return err
+	}
+
+	defer fm.release(block.Filename)
+
+	data := make([]byte, e.length)
+
+	if _, err := file.ReadAt(data, e.offset); err != nil {
+		return errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	if data, err = fm.open(block, data); err != nil {
+		return err
+	}
+
+	if len(data) == len(content) {
+		copy(content, data)
+
+		return nil
+	}
+
+	if mf.codec == nil {
+		return errors.WithMessagef(ErrCorruptedBlock, "%s: block %d", block.Filename, block.Number)
+	}
+
+	out, err := mf.codec.Decompress(content[:0], data)
+	if err != nil || len(out) != len(content) {
+		return errors.WithMessagef(ErrCorruptedBlock, "%s: block %d", block.Filename, block.Number)
+	}
+
+	copy(content, out)
+
+	return nil
+}
+
+// writeMapped сжимает и шифрует страницу и пишет ее в свободный экстент. Ссылку на него в карту запишет syncMapped
+func (fm *Manager) writeMapped(mf *mappedFile, block types.Block, page *types.Page) error {
+	data := page.Content()
+
+	if mf.codec != nil {
+		if compressed := mf.codec.Compress(make([]byte, 0, fm.blockSize), data); len(compressed) < len(data) {
+			data = compressed
+		}
+	}
+
+	data, err := fm.seal(block, data)
+	if err != nil {
+		return err
+	}
+
+	mf.mu.Lock()
+	e := mf.allocate(fm.extentCapacity(uint32(len(data))))
+	mf.mu.Unlock()
+
+	e.length = uint32(len(data))
+
+	if err := fm.writeAt(block.Filename, data, e.offset); err != nil {
+		mf.mu.Lock()
+		mf.releaseExtent(e)
+		mf.mu.Unlock()
+
+		return err
+	}
+
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	for int(block.Number) >= len(mf.entries) {
+		mf.entries = append(mf.entries, extent{})
+	}
+
+	old := mf.entries[block.Number]
+	mf.entries[block.Number] = e
+
+	if mf.dirty[block.Number] {
+		mf.releaseExtent(old)
+	} else {
+		mf.retired = append(mf.retired, old)
+	}
+
+	mf.dirty[block.Number] = true
+
+	return nil
+}
+
+// syncMapped сбрасывает на носитель файл данных, а затем пишет в карту ссылки на экстенты, записанные после прошлого вызова.
+// Прежние экстенты этих блоков освобождаются только после записи карты
+func (fm *Manager) syncMapped(mf *mappedFile, filename string) error {
+	mf.syncMu.Lock()
+	defer mf.syncMu.Unlock()
+
+	mf.mu.Lock()
+
+	if len(mf.dirty) == 0 {
+		mf.mu.Unlock()
+
+		return nil
+	}
+
+	first, last := types.BlockID(len(mf.entries)), types.BlockID(0)
+
+	for number := range mf.dirty {
+		first = min(first, number)
+		last = max(last, number)
+	}
+
+	entries := slices.Clone(mf.entries[first : last+1])
+	dirty, retired := mf.dirty, mf.retired
+	mf.dirty, mf.retired = make(map[types.BlockID]bool), nil
+
+	mf.mu.Unlock()
+
+	err := fm.syncFile(filename)
+	if err == nil {
+		err = fm.writeBlockMapEntries(filename, first, entries)
+	}
+
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if err != nil {
+		// Неизвестно, на какие экстенты указывает карта, поэтому прежние не освобождаем до переоткрытия файла
+		for number := range dirty {
+			if _, ok := mf.dirty[number]; !ok {
+				mf.dirty[number] = false
+			}
+		}
+
+		return err
+	}
+
+	for _, e := range retired {
+		mf.releaseExtent(e)
+	}
+
+	return nil
+}
+
+// syncMappedFiles сбрасывает на носитель карты всех файлов
+func (fm *Manager) syncMappedFiles() error {
+	fm.mu.Lock()
+	files := maps.Clone(fm.mappedFiles)
+	fm.mu.Unlock()
+
+	for filename, mf := range files {
+		if err := fm.syncMapped(mf, filename); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// appendMapped добавляет в карту блок из нулей. Вызывается под блокировкой роста файла
+func (fm *Manager) appendMapped(mf *mappedFile, filename string) (types.Block, error) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	block := types.Block{
+		Filename: filename,
+		Number:   types.BlockID(int32(len(mf.entries))),
+	}
+
+	if err := fm.writeBlockMapEntry(block, extent{}); err != nil {
+		return block, err
+	}
+
+	mf.entries = append(mf.entries, extent{})
+
+	return block, nil
+}
+
+// isZero возвращает true для блока из нулей, который не занимает места
+func (mf *mappedFile) isZero(number types.BlockID) bool {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+
+	return mf.entries[number].length == 0
+}
+
+func (mf *mappedFile) length() types.BlockID {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+
+	return types.BlockID(int32(len(mf.entries)))
+}
+
+func (fm *Manager) writeBlockMapEntry(block types.Block, e extent) error {
+	return fm.writeBlockMapEntries(block.Filename, block.Number, []extent{e})
+}
+
+// writeBlockMapEntries одной записью пишет в карту ссылки на блоки подряд, начиная с first
+func (fm *Manager) writeBlockMapEntries(filename string, first types.BlockID, entries []extent) error {
+	order := binary.LittleEndian
+
+	data := make([]byte, len(entries)*blockMapEntrySize)
+
+	for i, e := range entries {
+		entry := data[i*blockMapEntrySize:]
+		order.PutUint64(entry[blockMapOffsetOffset:], uint64(e.offset))
+		order.PutUint32(entry[blockMapLengthOffset:], e.length)
+		order.PutUint32(entry[blockMapCapacityOffset:], e.capacity)
+	}
+
+	offset := int64(blockMapHeaderSize) + int64(first)*blockMapEntrySize
+
+	return fm.writeAndSync(blockMapName(filename), data, offset)
+}
+
+// writeAndSync пишет данные в файл и сбрасывает их на носитель
+func (fm *Manager) writeAndSync(filename string, data []byte, offset int64) error {
+	if err := fm.writeAt(filename, data, offset); err != nil {
+		return err
+	}
+
+	return fm.syncFile(filename)
+}
+
+func (fm *Manager) writeAt(filename string, data []byte, offset int64) error {
+	file, err := fm.acquire(filename)
+	if err != nil {
+		return err
+	}
+
+	defer fm.release(filename)
+
+	if _, err := file.WriteAt(data, offset); err != nil {
+		return errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	return nil
+}
+
+func (fm *Manager) syncFile(filename string) error {
+	file, err := fm.acquire(filename)
+	if err != nil {
+		return err
+	}
+
+	defer fm.release(filename)
+
+	if err := file.Sync(); err != nil {
+		return errors.WithMessage(ErrFileManagerIO, err.Error())
+	}
+
+	return nil
+}
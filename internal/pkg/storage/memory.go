@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"io"
+	"io/fs"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryPath — путь хранилища в памяти
+const MemoryPath = ":memory:"
+
+// MemoryBackend хранит файлы в памяти. Данные пропадают вместе с объектом хранилища,
+// поэтому он подходит для тестов и временных баз
+type MemoryBackend struct {
+	mu    sync.Mutex
+	files map[string]*memoryFile
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		files: make(map[string]*memoryFile),
+	}
+}
+
+func (b *MemoryBackend) Path() string {
+	return MemoryPath
+}
+
+func (b *MemoryBackend) Open(filename string) (File, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	file, ok := b.files[filename]
+	if !ok {
+		file = &memoryFile{
+			name: filename,
+		}
+		b.files[filename] = file
+	}
+
+	return file, nil
+}
+
+func (b *MemoryBackend) Remove(filename string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.files[filename]; !ok {
+		return &fs.PathError{Op: "remove", Path: filename, Err: fs.ErrNotExist}
+	}
+
+	delete(b.files, filename)
+
+	return nil
+}
+
+func (b *MemoryBackend) List() ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	names := make([]string, 0, len(b.files))
+	for name := range b.files {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names, nil
+}
+
+// memoryFile — файл в памяти. Закрытие файла не теряет данные, их удаляет только Remove
+type memoryFile struct {
+	mu   sync.RWMutex
+	name string
+	data []byte
+}
+
+func (f *memoryFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (f *memoryFile) WriteAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if end := int(off) + len(p); end > len(f.data) {
+		f.data = append(f.data, make([]byte, end-len(f.data))...)
+	}
+
+	return copy(f.data[off:], p), nil
+}
+
+func (f *memoryFile) Stat() (fs.FileInfo, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return memoryFileInfo{
+		name: f.name,
+		size: int64(len(f.data)),
+	}, nil
+}
+
+func (f *memoryFile) Sync() error {
+	return nil
+}
+
+func (f *memoryFile) Close() error {
+	return nil
+}
+
+type memoryFileInfo struct {
+	name string
+	size int64
+}
+
+func (i memoryFileInfo) Name() string       { return i.name }
+func (i memoryFileInfo) Size() int64        { return i.size }
+func (i memoryFileInfo) Mode() fs.FileMode  { return syncedFilePermissions }
+func (i memoryFileInfo) ModTime() time.Time { return time.Time{} }
+func (i memoryFileInfo) IsDir() bool        { return false }
+func (i memoryFileInfo) Sys() any           { return nil }
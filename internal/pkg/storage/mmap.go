@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"github.com/pkg/errors"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+// Хранилища файлов на диске
+const (
+	BackendFile = "file"
+	BackendMmap = "mmap"
+)
+
+// ValidBackend проверяет имя хранилища файлов. Пустое имя означает BackendFile
+func ValidBackend(name string) error {
+	switch name {
+	case "", BackendFile, BackendMmap:
+		return nil
+	}
+
+	return errors.WithMessagef(ErrUnknownBackend, "%q", name)
+}
+
+// viewer — файл, блоки которого можно читать без копирования, см. MmapBackend
+type viewer interface {
+	// view возвращает n байт файла по смещению off. Память остается доступной до вызова release,
+	// даже если файл за это время закроют или отобразят заново. ok == false, если таких байт в файле нет
+	view(off int64, n int) (data []byte, release func(), ok bool)
+}
+
+// NewMmapFileManager создает менеджер для файлов в папке path, которые читаются через отображение в память, см. MmapBackend
+func NewMmapFileManager(path string, blockSize uint32, opts ...ManagerOpt) (*Manager, error) {
+	backend, err := NewMmapBackend(path)
+	if err != nil {
+		if errors.Is(err, ErrMmapNotSupported) {
+			return nil, err
+		}
+
+		return nil, errors.WithMessagef(ErrFileManagerIO, "cannot create data dir \"%s\": %v", path, err)
+	}
+
+	return newDirManager(path, backend, blockSize, opts...)
+}
+
+// View возвращает страницу блока, которая смотрит прямо в отображенный в память файл. Страницу нельзя менять,
+// а после release ее нельзя читать. Если хранилище не умеет отображать файлы, файл сжат или зашифрован
+// или блока еще нет в файле, возвращает nil, и блок надо читать через Read
+func (fm *Manager) View(block types.Block) (*types.Page, func(), error) {
+	if mf, err := fm.mapped(block.Filename); err != nil || mf != nil {
+		return nil, nil, err
+	}
+
+	file, err := fm.acquire(block.Filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer fm.release(block.Filename)
+
+	v, ok := file.(viewer)
+	if !ok {
+		return nil, nil, nil
+	}
+
+	data, release, ok := v.view(fm.offset(block), int(fm.blockSize))
+	if !ok {
+		return nil, nil, nil
+	}
+
+	return types.NewPageFromBytes(data), release, nil
+}
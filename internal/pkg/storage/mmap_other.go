@@ -0,0 +1,15 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package storage
+
+// На платформах без mmap хранилище MmapBackend не создается
+
+// MmapBackend хранит файлы в папке на диске и читает их через отображение в память
+type MmapBackend struct {
+	OSBackend
+}
+
+// NewMmapBackend на этой платформе всегда возвращает ErrMmapNotSupported
+func NewMmapBackend(string) (*MmapBackend, error) {
+	return nil, ErrMmapNotSupported
+}
@@ -0,0 +1,185 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package storage
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// MmapBackend хранит файлы в папке на диске, как OSBackend, но читает их через отображение в память:
+// чтение блока — копирование из отображения без системного вызова, а View отдает страницу вообще без копирования.
+// Запись идет обычным pwrite, отображение MAP_SHARED видит ее сразу.
+// Когда файл растет, он отображается заново с запасом. Старое отображение живет, пока на него есть ссылки из View
+type MmapBackend struct {
+	OSBackend
+}
+
+// NewMmapBackend создает хранилище в папке path. Если папки нет, то создает ее
+func NewMmapBackend(path string) (*MmapBackend, error) {
+	backend, err := NewOSBackend(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MmapBackend{
+		OSBackend: *backend,
+	}, nil
+}
+
+func (b *MmapBackend) Open(filename string) (File, error) {
+	file, err := b.openFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+
+		return nil, err
+	}
+
+	f := &mmapFile{
+		File:   file,
+		region: &mmapRegion{},
+	}
+
+	f.region.refs.Store(1)
+
+	f.grow(stat.Size())
+
+	return f, nil
+}
+
+// mmapFile — файл, отображенный в память целиком. size — размер файла, отображение может быть длиннее:
+// обращаться к памяти за концом файла нельзя, это SIGBUS
+type mmapFile struct {
+	*os.File
+
+	mu     sync.RWMutex
+	region *mmapRegion
+	size   int64
+}
+
+// mmapRegion — одно отображение файла. Ссылку держат файл и каждая страница, выданная view
+type mmapRegion struct {
+	data []byte
+	refs atomic.Int32
+}
+
+func (r *mmapRegion) release() {
+	if r.refs.Add(-1) == 0 && len(r.data) > 0 {
+		_ = syscall.Munmap(r.data)
+	}
+}
+
+func (f *mmapFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.region == nil {
+		return 0, os.ErrClosed
+	}
+
+	if off < 0 {
+		return f.File.ReadAt(p, off)
+	}
+
+	if off >= f.size {
+		return 0, io.EOF
+	}
+
+	end := min(off+int64(len(p)), f.size)
+	if end > int64(len(f.region.data)) {
+		return f.File.ReadAt(p, off)
+	}
+
+	n := copy(p, f.region.data[off:end])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (f *mmapFile) WriteAt(p []byte, off int64) (int, error) {
+	n, err := f.File.WriteAt(p, off)
+	if err != nil {
+		return n, err
+	}
+
+	f.grow(off + int64(n))
+
+	return n, nil
+}
+
+func (f *mmapFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.region != nil {
+		f.region.release()
+		f.region = nil
+	}
+
+	return f.File.Close()
+}
+
+func (f *mmapFile) view(off int64, n int) ([]byte, func(), bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	end := off + int64(n)
+	if f.region == nil || off < 0 || end > f.size || end > int64(len(f.region.data)) {
+		return nil, nil, false
+	}
+
+	region := f.region
+	region.refs.Add(1)
+
+	return region.data[off:end:end], sync.OnceFunc(region.release), true
+}
+
+// grow запоминает новый размер файла и, если файл перерос отображение, отображает его заново
+// с запасом в размер файла, чтобы при росте по блоку не отображать файл на каждом Append.
+// Если отобразить не удалось, ReadAt читает хвост файла с диска, а view его не отдает
+func (f *mmapFile) grow(size int64) {
+	f.mu.RLock()
+	grown := size > f.size
+	f.mu.RUnlock()
+
+	if !grown {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.region == nil || size <= f.size {
+		return
+	}
+
+	f.size = size
+
+	if size <= int64(len(f.region.data)) {
+		return
+	}
+
+	pageSize := int64(os.Getpagesize())
+	length := (2*size + pageSize - 1) / pageSize * pageSize
+
+	data, err := syscall.Mmap(int(f.File.Fd()), 0, int(length), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return
+	}
+
+	region := &mmapRegion{data: data}
+	region.refs.Store(1)
+
+	f.region.release()
+	f.region = region
+}
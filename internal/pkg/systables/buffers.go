@@ -0,0 +1,62 @@
+package systables
+
+import (
+	"github.com/unhandled-exception/sophiadb/internal/pkg/buffers"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
+)
+
+const BuffersTableName = "sdb_buffers"
+
+type buffersSource interface {
+	Frames() []buffers.FrameInfo
+}
+
+// BuffersTable — представление sdb_buffers: буферы пула и блоки в них.
+// У свободного буфера пустое имя файла и блок -1
+type BuffersTable struct {
+	src    buffersSource
+	schema records.Schema
+}
+
+func NewBuffersTable(src buffersSource) *BuffersTable {
+	schema := records.NewSchema()
+	schema.AddInt64Field("frame")
+	schema.AddStringField("filename", maxNameLen)
+	schema.AddInt64Field("block")
+	schema.AddInt64Field("pins")
+	schema.AddInt64Field("dirty_trx")
+	schema.AddInt64Field("lsn")
+
+	return &BuffersTable{
+		src:    src,
+		schema: schema,
+	}
+}
+
+func (t *BuffersTable) Schema() records.Schema {
+	return t.schema
+}
+
+func (t *BuffersTable) Rows() ([][]scan.Constant, error) {
+	frames := t.src.Frames()
+	rows := make([][]scan.Constant, 0, len(frames))
+
+	for _, frame := range frames {
+		block := int64(frame.Block.Number)
+		if frame.Block.Filename == "" {
+			block = -1
+		}
+
+		rows = append(rows, []scan.Constant{
+			scan.NewInt64Constant(int64(frame.Frame)),
+			scan.NewStringConstant(frame.Block.Filename),
+			scan.NewInt64Constant(block),
+			scan.NewInt64Constant(int64(frame.Pins)),
+			scan.NewInt64Constant(int64(frame.ModifyingTX)),
+			scan.NewInt64Constant(int64(frame.LSN)),
+		})
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,11 @@
+package systables
+
+// Длины строковых полей нужны только для схемы, записи виртуальных таблиц не хранятся на диске
+const (
+	maxNameLen   = 256
+	maxListLen   = 1024
+	modeLen      = 5
+	stateLen     = 16
+	isolationLen = 16
+	timeLen      = 35
+)
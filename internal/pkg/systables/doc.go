@@ -0,0 +1,3 @@
+// Системные представления — виртуальные таблицы только для чтения с состоянием СУБД
+
+package systables
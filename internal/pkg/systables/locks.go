@@ -0,0 +1,94 @@
+package systables
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/transaction"
+)
+
+const LocksTableName = "sdb_locks"
+
+const (
+	slockMode = "slock"
+	xlockMode = "xlock"
+)
+
+type locksSource interface {
+	Locks() []transaction.LockInfo
+}
+
+// LocksTable — представление sdb_locks: блокировки блоков, записей и ключей индексов активных транзакций и транзакции, которые их ждут
+type LocksTable struct {
+	src    locksSource
+	schema records.Schema
+}
+
+func NewLocksTable(src locksSource) *LocksTable {
+	schema := records.NewSchema()
+	schema.AddStringField("filename", maxNameLen)
+	schema.AddInt64Field("block")
+	schema.AddInt64Field("slot")
+	schema.AddStringField("key", maxNameLen)
+	schema.AddStringField("mode", modeLen)
+	schema.AddInt64Field("trx")
+	schema.AddStringField("waiters", maxListLen)
+	schema.AddInt64Field("wait_ms")
+
+	return &LocksTable{
+		src:    src,
+		schema: schema,
+	}
+}
+
+func (t *LocksTable) Schema() records.Schema {
+	return t.schema
+}
+
+func (t *LocksTable) Rows() ([][]scan.Constant, error) {
+	locks := t.src.Locks()
+	rows := make([][]scan.Constant, 0, len(locks))
+
+	for _, lock := range locks {
+		mode := slockMode
+		if lock.Exclusive {
+			mode = xlockMode
+		}
+
+		waiters := make([]string, 0, len(lock.Waiters))
+		for _, w := range lock.Waiters {
+			waiters = append(waiters, strconv.FormatInt(int64(w), 10))
+		}
+
+		// Блокировка всего блока не относится к записи, слот у нее NULL.
+		// У блокировки значения ключа filename — имя индекса, а блока и слота нет
+		var (
+			block scan.Constant = scan.NewInt64Constant(int64(lock.Resource.Block.Number))
+			slot  scan.Constant = scan.NewNullConstant()
+			key   scan.Constant = scan.NewNullConstant()
+		)
+
+		switch {
+		case lock.Resource.IsRow():
+			slot = scan.NewInt64Constant(int64(lock.Resource.Slot))
+		case lock.Resource.IsKey():
+			block = scan.NewNullConstant()
+			key = scan.NewStringConstant(lock.Resource.Key)
+		}
+
+		rows = append(rows, []scan.Constant{
+			scan.NewStringConstant(lock.Resource.Block.Filename),
+			block,
+			slot,
+			key,
+			scan.NewStringConstant(mode),
+			scan.NewInt64Constant(int64(lock.Holder)),
+			scan.NewStringConstant(strings.Join(waiters, ",")),
+			scan.NewInt64Constant(lock.WaitTime.Milliseconds()),
+		})
+	}
+
+	return rows, nil
+}
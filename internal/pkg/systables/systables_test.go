@@ -0,0 +1,194 @@
+package systables_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/buffers"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/storage"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/systables"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/concurrency"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/transaction"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/wal"
+)
+
+const (
+	testDataFile  = "data.dat"
+	testWALFile   = "wal.dat"
+	testBlockSize = 400
+)
+
+type SystemTablesTestSuite struct {
+	suite.Suite
+}
+
+func TestSystemTablesTestSuite(t *testing.T) {
+	suite.Run(t, new(SystemTablesTestSuite))
+}
+
+func (ts *SystemTablesTestSuite) newTRXManager() (*transaction.TRXManager, *storage.Manager) {
+	fm, err := storage.NewManager(storage.NewMemoryBackend(), testBlockSize)
+	ts.Require().NoError(err)
+
+	lm, err := wal.NewManager(fm, testWALFile)
+	ts.Require().NoError(err)
+
+	bm := buffers.NewManager(fm, lm, 10)
+
+	return transaction.NewTRXManager(fm, bm, lm, transaction.WithLockTimeout(5*time.Second)), fm
+}
+
+// values переводит строки представления в значения Go, чтобы их было удобно сравнивать
+func (ts *SystemTablesTestSuite) values(rows [][]scan.Constant, err error) [][]any {
+	ts.Require().NoError(err)
+
+	result := make([][]any, 0, len(rows))
+
+	for _, row := range rows {
+		values := make([]any, 0, len(row))
+		for _, c := range row {
+			values = append(values, c.Value())
+		}
+
+		result = append(result, values)
+	}
+
+	return result
+}
+
+func (ts *SystemTablesTestSuite) TestLocksTable() {
+	trxMan, fm := ts.newTRXManager()
+	defer fm.Close()
+
+	block1, err := fm.Append(testDataFile)
+	ts.Require().NoError(err)
+
+	block2, err := fm.Append(testDataFile)
+	ts.Require().NoError(err)
+
+	sut := systables.NewLocksTable(trxMan)
+	ts.Equal([]string{"filename", "block", "slot", "key", "mode", "trx", "waiters", "wait_ms"}, sut.Schema().Fields())
+	ts.Empty(ts.values(sut.Rows()))
+
+	tx1, err := trxMan.Transaction()
+	ts.Require().NoError(err)
+
+	tx2, err := trxMan.Transaction()
+	ts.Require().NoError(err)
+
+	ts.Require().NoError(tx1.SLock(block1, false))
+	ts.Require().NoError(tx1.XLock(block2, false))
+
+	ts.Equal([][]any{
+		{testDataFile, int64(0), nil, nil, "slock", int64(tx1.TXNum()), "", int64(0)},
+		{testDataFile, int64(1), nil, nil, "xlock", int64(tx1.TXNum()), "", int64(0)},
+	}, ts.values(sut.Rows()))
+
+	// Вторая транзакция ждёт эксклюзивную блокировку первого блока
+	done := make(chan error)
+
+	go func() {
+		done <- tx2.XLock(block1, false)
+	}()
+
+	ts.Require().Eventually(func() bool {
+		rows, err := sut.Rows()
+
+		return err == nil && len(rows) == 3 && rows[0][6].Value() != ""
+	}, time.Second, time.Millisecond)
+
+	time.Sleep(20 * time.Millisecond)
+
+	rows := ts.values(sut.Rows())
+	ts.Equal([]any{testDataFile, int64(0), nil, nil, "slock", int64(tx1.TXNum()), strconv.FormatInt(int64(tx2.TXNum()), 10)}, rows[0][:7])
+	ts.GreaterOrEqual(rows[0][7], int64(20))
+
+	// Перед эксклюзивной блокировкой вторая транзакция взяла разделяемую
+	ts.Equal([]any{testDataFile, int64(0), nil, nil, "slock", int64(tx2.TXNum()), "", int64(0)}, rows[1])
+	ts.Equal([]any{testDataFile, int64(1), nil, nil, "xlock", int64(tx1.TXNum()), "", int64(0)}, rows[2])
+
+	ts.Require().NoError(tx1.Commit())
+	ts.Require().NoError(<-done)
+
+	ts.Equal([][]any{
+		{testDataFile, int64(0), nil, nil, "xlock", int64(tx2.TXNum()), "", int64(0)},
+	}, ts.values(sut.Rows()))
+
+	// Блокировка записи показывает слот
+	ts.Require().NoError(tx2.SLockRow(block2, 3, false))
+
+	ts.Equal([][]any{
+		{testDataFile, int64(0), nil, nil, "xlock", int64(tx2.TXNum()), "", int64(0)},
+		{testDataFile, int64(1), int64(3), nil, "slock", int64(tx2.TXNum()), "", int64(0)},
+	}, ts.values(sut.Rows()))
+
+	// Блокировка значения индекса показывает ключ вместо блока
+	ts.Require().NoError(tx2.XLockKey(concurrency.KeyResource("data_idx", "42")))
+
+	rows = ts.values(sut.Rows())
+	ts.Require().Len(rows, 3)
+	ts.Equal([]any{"data_idx", nil, nil, "42", "xlock", int64(tx2.TXNum()), "", int64(0)}, rows[2])
+
+	ts.Require().NoError(tx2.Rollback())
+	ts.Empty(ts.values(sut.Rows()))
+}
+
+func (ts *SystemTablesTestSuite) TestTransactionsTable() {
+	trxMan, fm := ts.newTRXManager()
+	defer fm.Close()
+
+	block, err := fm.Append(testDataFile)
+	ts.Require().NoError(err)
+
+	sut := systables.NewTransactionsTable(trxMan)
+	ts.Equal([]string{"trx", "started_at", "state", "isolation", "read_only", "pinned_buffers", "locks"}, sut.Schema().Fields())
+	ts.Empty(ts.values(sut.Rows()))
+
+	tx1, err := trxMan.Transaction()
+	ts.Require().NoError(err)
+
+	tx2, err := trxMan.Transaction(
+		transaction.WithIsolationLevel(concurrency.ReadCommitted),
+		transaction.WithReadOnly(),
+	)
+	ts.Require().NoError(err)
+
+	tx3, err := trxMan.Transaction(transaction.WithIsolationLevel(concurrency.RepeatableRead))
+	ts.Require().NoError(err)
+
+	ts.Require().NoError(tx1.Pin(block))
+	ts.Require().NoError(tx1.XLock(block, false))
+
+	// Читающая транзакция ждёт, пока первая снимет эксклюзивную блокировку
+	done := make(chan error)
+
+	go func() {
+		done <- tx2.SLock(block, false)
+	}()
+
+	ts.Require().Eventually(func() bool {
+		rows, err := sut.Rows()
+
+		return err == nil && rows[1][2].Value() == "waiting"
+	}, time.Second, time.Millisecond)
+
+	startedAt := func(trx *transaction.Transaction) string {
+		return trx.StartedAt().Format(time.RFC3339Nano)
+	}
+
+	ts.Equal([][]any{
+		{int64(tx1.TXNum()), startedAt(tx1), "active", "serializable", int8(0), int64(1), int64(1)},
+		{int64(tx2.TXNum()), startedAt(tx2), "waiting", "read committed", int8(1), int64(0), int64(0)},
+		{int64(tx3.TXNum()), startedAt(tx3), "active", "repeatable read", int8(0), int64(0), int64(0)},
+	}, ts.values(sut.Rows()))
+
+	ts.Require().NoError(tx1.Commit())
+	ts.Require().NoError(<-done)
+	ts.Require().NoError(tx2.Commit())
+	ts.Require().NoError(tx3.Commit())
+
+	ts.Empty(ts.values(sut.Rows()))
+}
@@ -0,0 +1,66 @@
+package systables
+
+import (
+	"time"
+
+	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/transaction"
+)
+
+const TransactionsTableName = "sdb_transactions"
+
+type transactionsSource interface {
+	Transactions() []transaction.TransactionInfo
+}
+
+// TransactionsTable — представление sdb_transactions: активные транзакции.
+// read_only — 1 у транзакции только для чтения, иначе 0
+type TransactionsTable struct {
+	src    transactionsSource
+	schema records.Schema
+}
+
+func NewTransactionsTable(src transactionsSource) *TransactionsTable {
+	schema := records.NewSchema()
+	schema.AddInt64Field("trx")
+	schema.AddStringField("started_at", timeLen)
+	schema.AddStringField("state", stateLen)
+	schema.AddStringField("isolation", isolationLen)
+	schema.AddInt8Field("read_only")
+	schema.AddInt64Field("pinned_buffers")
+	schema.AddInt64Field("locks")
+
+	return &TransactionsTable{
+		src:    src,
+		schema: schema,
+	}
+}
+
+func (t *TransactionsTable) Schema() records.Schema {
+	return t.schema
+}
+
+func (t *TransactionsTable) Rows() ([][]scan.Constant, error) {
+	trxs := t.src.Transactions()
+	rows := make([][]scan.Constant, 0, len(trxs))
+
+	for _, trx := range trxs {
+		var readOnly int8
+		if trx.ReadOnly {
+			readOnly = 1
+		}
+
+		rows = append(rows, []scan.Constant{
+			scan.NewInt64Constant(int64(trx.TXNum)),
+			scan.NewStringConstant(trx.StartedAt.Format(time.RFC3339Nano)),
+			scan.NewStringConstant(string(trx.State)),
+			scan.NewStringConstant(trx.Isolation.String()),
+			scan.NewInt8Constant(readOnly),
+			scan.NewInt64Constant(int64(trx.PinnedBuffers)),
+			scan.NewInt64Constant(int64(trx.Locks)),
+		})
+	}
+
+	return rows, nil
+}
@@ -67,3 +67,13 @@ func GetFileSize(ts testSuite, name string) int64 {
 
 	return stat.Size()
 }
+
+// Must возвращает значение, если ошибки нет, и паникует, если есть. Нужна, чтобы проверять в одну строку значения,
+// которые возвращаются вместе с ошибкой, например поля страницы
+func Must[T any](value T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+
+	return value
+}
@@ -1,3 +1,30 @@
 // Менеджер конкуренции
 
+// Фантомы.
+// Для уровня serializable защита от фантомов строится на блокировках блоков и псевдоблока конца файла (EndOfFileBlock):
+//   - сканирование таблицы до конца берёт slock на каждый прочитанный блок и на конец файла (TableScan.Next вызывает Size);
+//   - вставка в свободный слот прочитанного блока требует xlock на этот блок;
+//   - вставка в новый блок требует xlock на конец файла (Transaction.Append).
+// Блокировки держатся до конца транзакции, поэтому другая транзакция не сможет вставить, удалить или изменить запись,
+// которая попадает под предикат уже выполненного запроса. Это грубая предикатная блокировка: она закрывает всю таблицу.
+//
+// Repeatable read снимает блокировку конца файла сразу после чтения размера, поэтому новые записи в новых блоках
+// видны как фантомы. Read committed снимает все разделяемые блокировки после чтения.
+
+// Блокировки ключей индексов.
+// Страницы индексов читаются под короткими блокировками (ReleaseRead), а поиск по индексу блокирует значения ключей
+// (KeyResource) до конца транзакции:
+//   - поиск в хеш-индексе блокирует искомое значение, вставка и удаление берут на значение xlock;
+//   - поиск по диапазону в B-дереве блокирует каждое прочитанное значение, а на уровне serializable и следующее
+//     за диапазоном значение или SupremumKey, если диапазон дошел до конца индекса;
+//   - вставка в B-дерево берет xlock на новое значение и, пока вставляет его, на следующее (XLockNextKey),
+//     поэтому ждет транзакции, которые прочитали промежуток, куда попадает новое значение.
+// Так закрываются только прочитанные значения и промежутки между ними, а вставки в другие части индекса проходят.
+// Read committed ключи при чтении не блокирует, repeatable read не блокирует следующее за диапазоном значение.
+
+// Блокировки записей.
+// SELECT ... FOR UPDATE | FOR SHARE блокирует записи по RID (RowResource), изменение записи берет на нее xlock.
+// Блокировки записей держатся до конца транзакции на любом уровне изоляции, EndRead их не снимает.
+// Блокировки блоков и записей не конфликтуют друг с другом: запись в блок по-прежнему требует xlock на сам блок.
+
 package concurrency
@@ -5,3 +5,5 @@ import "github.com/pkg/errors"
 var ErrConcurrency = errors.New("concurrency error")
 
 var ErrLockAbort = errors.Wrap(ErrConcurrency, "failed to lock block")
+
+var ErrLockNotAvailable = errors.Wrap(ErrLockAbort, "lock is not available")
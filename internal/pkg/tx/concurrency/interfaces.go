@@ -3,13 +3,26 @@ package concurrency
 import "github.com/unhandled-exception/sophiadb/internal/pkg/types"
 
 type Lockers interface {
-	SLock(block types.Block) error
-	XLock(block types.Block) error
-	Unlock(block types.Block)
+	SLock(res Resource) error
+	XLock(res Resource) error
+	TrySLock(res Resource) error
+	TryXLock(res Resource) error
+	Unlock(res Resource)
 }
 
 type ConcurrencyManager interface {
 	SLock(block types.Block) error
 	XLock(block types.Block) error
+	SLockNoWait(block types.Block) error
+	XLockNoWait(block types.Block) error
+	SLockRow(block types.Block, slot types.SlotID, noWait bool) error
+	XLockRow(block types.Block, slot types.SlotID, noWait bool) error
+	SLockKey(key Resource) error
+	XLockKey(key Resource) error
+	XLockNextKey(key Resource) error
+	ReleaseNextKey(key Resource)
+	Hold(block types.Block)
+	EndRead(block types.Block)
+	ReleaseRead(block types.Block)
 	Release()
 }
@@ -0,0 +1,32 @@
+package concurrency
+
+import "github.com/unhandled-exception/sophiadb/internal/pkg/types"
+
+// IsolationLevel — уровень изоляции транзакции
+type IsolationLevel uint8
+
+const (
+	// Serializable — разделяемые блокировки держим до конца транзакции, включая псевдоблок конца файла
+	Serializable IsolationLevel = iota
+	// RepeatableRead — блокировки на блоки держим до конца транзакции, а блокировку на конец файла снимаем сразу после чтения,
+	// поэтому возможны фантомы
+	RepeatableRead
+	// ReadCommitted — разделяемые блокировки снимаем сразу после чтения
+	ReadCommitted
+)
+
+// EndOfFileBlock — номер псевдоблока, которым защищаем размер файла
+const EndOfFileBlock types.BlockID = -1
+
+func (l IsolationLevel) String() string {
+	switch l {
+	case Serializable:
+		return "serializable"
+	case RepeatableRead:
+		return "repeatable read"
+	case ReadCommitted:
+		return "read committed"
+	}
+
+	return "unknown"
+}
@@ -5,7 +5,6 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
-	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/utils"
 )
 
@@ -14,7 +13,7 @@ var defaultMaxLockWaitTime time.Duration = 10 * time.Second
 const XLockValue int32 = -1
 
 type LockTable struct {
-	locks map[types.Block]int32
+	locks map[Resource]int32
 
 	L               sync.RWMutex
 	locksCond       *utils.Cond
@@ -25,7 +24,7 @@ type lockTableOpt func(lt *LockTable)
 
 func NewLockTable(opts ...lockTableOpt) *LockTable {
 	lt := &LockTable{
-		locks: make(map[types.Block]int32),
+		locks: make(map[Resource]int32),
 
 		locksCond:       utils.NewCond(&sync.Mutex{}),
 		lockWaitTimeout: defaultMaxLockWaitTime,
@@ -46,23 +45,23 @@ func WithLockWaitTimeout(timeout time.Duration) lockTableOpt {
 	}
 }
 
-func (lt *LockTable) LocksCount(block types.Block) int32 {
-	lCount := lt.locks[block]
+func (lt *LockTable) LocksCount(res Resource) int32 {
+	lCount := lt.locks[res]
 
 	return lCount
 }
 
-func (lt *LockTable) HasXLock(block types.Block) bool {
-	return lt.LocksCount(block) == XLockValue
+func (lt *LockTable) HasXLock(res Resource) bool {
+	return lt.LocksCount(res) == XLockValue
 }
 
-func (lt *LockTable) HasOtherSLock(block types.Block) bool {
+func (lt *LockTable) HasOtherSLock(res Resource) bool {
 	// Менеджер конкуренции берет slock перед xlock, поэтому единица равна ровно одной блокировке и можно брать xlock
-	return lt.LocksCount(block) > 1
+	return lt.LocksCount(res) > 1
 }
 
-// SLock устанавливает разделеяемую блокировку для блока (shared lock)
-func (lt *LockTable) SLock(block types.Block) error {
+// SLock устанавливает разделеяемую блокировку на блок или запись (shared lock)
+func (lt *LockTable) SLock(res Resource) error {
 	deadline := time.Now().Add(lt.lockWaitTimeout)
 
 	lt.locksCond.L.Lock()
@@ -72,7 +71,7 @@ func (lt *LockTable) SLock(block types.Block) error {
 
 	for {
 		lt.L.Lock()
-		err = lt.tryToSLock(block)
+		err = lt.tryToSLock(res)
 		lt.L.Unlock()
 
 		if err == nil {
@@ -89,19 +88,31 @@ func (lt *LockTable) SLock(block types.Block) error {
 	return err
 }
 
-func (lt *LockTable) tryToSLock(block types.Block) error {
-	if lt.HasXLock(block) {
-		return errors.WithMessagef(ErrLockAbort, "slock: block %s has xlock", block)
+// TrySLock пытается взять разделяемую блокировку без ожидания
+func (lt *LockTable) TrySLock(res Resource) error {
+	lt.L.Lock()
+	defer lt.L.Unlock()
+
+	if err := lt.tryToSLock(res); err != nil {
+		return errors.WithMessage(ErrLockNotAvailable, err.Error())
+	}
+
+	return nil
+}
+
+func (lt *LockTable) tryToSLock(res Resource) error {
+	if lt.HasXLock(res) {
+		return errors.WithMessagef(ErrLockAbort, "slock: %s has xlock", res)
 	}
 
-	lCount := lt.locks[block]
-	lt.locks[block] = lCount + 1
+	lCount := lt.locks[res]
+	lt.locks[res] = lCount + 1
 
 	return nil
 }
 
-// XLock устанавливает эксклюзивную блокировку для блока (shared lock)
-func (lt *LockTable) XLock(block types.Block) error {
+// XLock устанавливает эксклюзивную блокировку на блок или запись (exclusive lock)
+func (lt *LockTable) XLock(res Resource) error {
 	deadline := time.Now().Add(lt.lockWaitTimeout)
 
 	lt.locksCond.L.Lock()
@@ -111,7 +122,7 @@ func (lt *LockTable) XLock(block types.Block) error {
 
 	for {
 		lt.L.Lock()
-		err = lt.tryToXLock(block)
+		err = lt.tryToXLock(res)
 		lt.L.Unlock()
 
 		if err == nil {
@@ -128,24 +139,36 @@ func (lt *LockTable) XLock(block types.Block) error {
 	return err
 }
 
-func (lt *LockTable) tryToXLock(block types.Block) error {
-	if lt.HasOtherSLock(block) {
-		return errors.WithMessagef(ErrLockAbort, "xlock: block %s has other %d slock", block, lt.LocksCount(block))
+// TryXLock пытается взять эксклюзивную блокировку без ожидания
+func (lt *LockTable) TryXLock(res Resource) error {
+	lt.L.Lock()
+	defer lt.L.Unlock()
+
+	if err := lt.tryToXLock(res); err != nil {
+		return errors.WithMessage(ErrLockNotAvailable, err.Error())
+	}
+
+	return nil
+}
+
+func (lt *LockTable) tryToXLock(res Resource) error {
+	if lt.HasOtherSLock(res) {
+		return errors.WithMessagef(ErrLockAbort, "xlock: %s has other %d slock", res, lt.LocksCount(res))
 	}
 
-	lt.locks[block] = XLockValue
+	lt.locks[res] = XLockValue
 
 	return nil
 }
 
-// Unlock снимает блокировку для блока
-func (lt *LockTable) Unlock(block types.Block) {
+// Unlock снимает блокировку с блока или записи
+func (lt *LockTable) Unlock(res Resource) {
 	lt.L.Lock()
 
-	if lCount := lt.locks[block]; lCount > 1 {
-		lt.locks[block] = lCount - 1
+	if lCount := lt.locks[res]; lCount > 1 {
+		lt.locks[res] = lCount - 1
 	} else {
-		delete(lt.locks, block)
+		delete(lt.locks, res)
 	}
 
 	lt.L.Unlock()
@@ -1,6 +1,11 @@
 package concurrency
 
-import "github.com/unhandled-exception/sophiadb/internal/pkg/types"
+import (
+	"sync"
+	"time"
+
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
 
 type lockType uint8
 
@@ -11,71 +16,416 @@ const (
 
 type Manager struct {
 	lockTable Lockers
-	locks     map[types.Block]lockType
+	locks     map[Resource]lockType
+	isolation IsolationLevel
+
+	// held — блокировки, которые запросили явно, например SELECT ... FOR SHARE. EndRead их не снимает
+	held map[Resource]struct{}
+
+	// mu защищает locks, held и состояние ожидания от чтения из других горутин (системные представления)
+	mu          sync.Mutex
+	waitFor     *Resource
+	waitStarted time.Time
 }
 
 var _ ConcurrencyManager = new(Manager)
 
-func NewManager(lockTable Lockers) *Manager {
-	return &Manager{
+type ManagerOpt func(*Manager)
+
+// HeldLock — блокировка, которую держит транзакция
+type HeldLock struct {
+	Resource  Resource
+	Exclusive bool
+}
+
+// LockWaiting — блокировка, которую транзакция ждёт
+type LockWaiting struct {
+	Resource Resource
+	Started  time.Time
+}
+
+func NewManager(lockTable Lockers, opts ...ManagerOpt) *Manager {
+	m := &Manager{
 		lockTable: lockTable,
-		locks:     make(map[types.Block]lockType),
+		locks:     make(map[Resource]lockType),
+		held:      make(map[Resource]struct{}),
+		isolation: Serializable,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+func WithIsolationLevel(level IsolationLevel) ManagerOpt {
+	return func(m *Manager) {
+		m.isolation = level
 	}
 }
 
+func (m *Manager) IsolationLevel() IsolationLevel {
+	return m.isolation
+}
+
 func (m *Manager) SLock(block types.Block) error {
-	if _, ok := m.locks[block]; ok {
+	return m.slock(BlockResource(block))
+}
+
+func (m *Manager) XLock(block types.Block) error {
+	return m.xlock(BlockResource(block))
+}
+
+// SLockNoWait берет разделяемую блокировку без ожидания.
+// Если блок заблокирован другой транзакцией, то сразу возвращает ErrLockNotAvailable
+func (m *Manager) SLockNoWait(block types.Block) error {
+	return m.slockNoWait(BlockResource(block))
+}
+
+// XLockNoWait берет эксклюзивную блокировку без ожидания.
+// Если блокировку взять не удалось, то разделяемую блокировку, взятую по пути, сразу отпускаем
+func (m *Manager) XLockNoWait(block types.Block) error {
+	return m.xlockNoWait(BlockResource(block))
+}
+
+// SLockRow берет разделяемую блокировку на запись в слоте slot блока block.
+// Блокировки записей держатся до конца транзакции на любом уровне изоляции
+func (m *Manager) SLockRow(block types.Block, slot types.SlotID, noWait bool) error {
+	res := RowResource(block, slot)
+
+	var err error
+
+	if noWait {
+		err = m.slockNoWait(res)
+	} else {
+		err = m.slock(res)
+	}
+
+	if err == nil {
+		m.hold(res)
+	}
+
+	return err
+}
+
+// XLockRow берет эксклюзивную блокировку на запись в слоте slot блока block
+func (m *Manager) XLockRow(block types.Block, slot types.SlotID, noWait bool) error {
+	res := RowResource(block, slot)
+
+	var err error
+
+	if noWait {
+		err = m.xlockNoWait(res)
+	} else {
+		err = m.xlock(res)
+	}
+
+	if err == nil {
+		m.hold(res)
+	}
+
+	return err
+}
+
+// SLockKey берет разделяемую блокировку на значение ключа индекса (KeyResource, SupremumResource).
+// Блокировки ключей держатся до конца транзакции на любом уровне изоляции
+func (m *Manager) SLockKey(key Resource) error {
+	err := m.slock(key)
+	if err == nil {
+		m.hold(key)
+	}
+
+	return err
+}
+
+// XLockKey берет эксклюзивную блокировку на значение ключа, которое транзакция вставляет в индекс или удаляет из него
+func (m *Manager) XLockKey(key Resource) error {
+	err := m.xlock(key)
+	if err == nil {
+		m.hold(key)
+	}
+
+	return err
+}
+
+// XLockNextKey берет эксклюзивную блокировку на следующий ключ перед вставкой в индекс. Так вставка ждет транзакции,
+// которые прочитали промежуток перед этим ключом. Блокировка нужна только на время вставки, ее снимает ReleaseNextKey
+func (m *Manager) XLockNextKey(key Resource) error {
+	hadLock := m.hasLock(key)
+
+	err := m.xlock(key)
+	if err != nil && !hadLock && m.hasLock(key) {
+		// Разделяемую блокировку, взятую по пути к эксклюзивной, не оставляем
+		m.unlock(key)
+	}
+
+	return err
+}
+
+// ReleaseNextKey снимает блокировку, взятую XLockNextKey. Блокировку ключа, которую транзакция держит
+// до конца по другой причине, например после чтения, не снимает
+func (m *Manager) ReleaseNextKey(key Resource) {
+	if m.hasLock(key) && !m.isHeld(key) {
+		m.unlock(key)
+	}
+}
+
+// Hold оставляет блокировку блока до конца транзакции на любом уровне изоляции.
+// Так держатся блокировки, которые запрос взял явно, например для SELECT ... FOR UPDATE
+func (m *Manager) Hold(block types.Block) {
+	m.hold(BlockResource(block))
+}
+
+// EndRead сообщает менеджеру, что чтение блока закончено.
+// В зависимости от уровня изоляции снимает разделяемую блокировку раньше конца транзакции.
+// Блокировки, отмеченные Hold, не снимаются
+func (m *Manager) EndRead(block types.Block) {
+	res := BlockResource(block)
+
+	if !m.hasSlock(res) || m.isHeld(res) {
+		return
+	}
+
+	switch m.isolation {
+	case ReadCommitted:
+	case RepeatableRead:
+		if block.Number != EndOfFileBlock {
+			return
+		}
+	default:
+		return
+	}
+
+	m.unlock(res)
+}
+
+// ReleaseRead снимает разделяемую блокировку блока сразу после чтения на любом уровне изоляции.
+// Так читаются страницы индексов: от фантомов индекс защищают блокировки ключей, а не блоков.
+// Эксклюзивные блокировки и блокировки, отмеченные Hold, не снимаются
+func (m *Manager) ReleaseRead(block types.Block) {
+	res := BlockResource(block)
+
+	if !m.hasSlock(res) || m.isHeld(res) {
+		return
+	}
+
+	m.unlock(res)
+}
+
+func (m *Manager) Release() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for res := range m.locks {
+		m.lockTable.Unlock(res)
+	}
+
+	m.locks = make(map[Resource]lockType)
+	m.held = make(map[Resource]struct{})
+}
+
+func (m *Manager) HasXlock(block types.Block) bool {
+	return m.hasXlock(BlockResource(block))
+}
+
+func (m *Manager) HasSlock(block types.Block) bool {
+	return m.hasSlock(BlockResource(block))
+}
+
+// Locks возвращает снимок блокировок, которые держит транзакция
+func (m *Manager) Locks() []HeldLock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	locks := make([]HeldLock, 0, len(m.locks))
+
+	for res, lock := range m.locks {
+		locks = append(locks, HeldLock{
+			Resource:  res,
+			Exclusive: lock == xlockType,
+		})
+	}
+
+	return locks
+}
+
+// LocksCount возвращает число блокировок, которые держит транзакция
+func (m *Manager) LocksCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.locks)
+}
+
+// Waiting возвращает блокировку, которую сейчас ждёт транзакция
+func (m *Manager) Waiting() (LockWaiting, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.waitFor == nil {
+		return LockWaiting{}, false
+	}
+
+	return LockWaiting{
+		Resource: *m.waitFor,
+		Started:  m.waitStarted,
+	}, true
+}
+
+func (m *Manager) slock(res Resource) error {
+	if m.hasLock(res) {
 		return nil
 	}
 
-	err := m.lockTable.SLock(block)
+	m.startWaiting(res)
+	err := m.lockTable.SLock(res)
+	m.stopWaiting()
+
 	if err != nil {
 		return err
 	}
 
-	m.locks[block] = slockType
+	m.setLock(res, slockType)
 
 	return nil
 }
 
-func (m *Manager) XLock(block types.Block) error {
-	if m.HasXlock(block) {
+func (m *Manager) xlock(res Resource) error {
+	if m.hasXlock(res) {
 		return nil
 	}
 
 	var err error
 
-	err = m.SLock(block)
+	err = m.slock(res)
 	if err != nil {
 		return err
 	}
 
-	err = m.lockTable.XLock(block)
+	m.startWaiting(res)
+	err = m.lockTable.XLock(res)
+	m.stopWaiting()
+
 	if err != nil {
 		return err
 	}
 
-	m.locks[block] = xlockType
+	m.setLock(res, xlockType)
 
 	return nil
 }
 
-func (m *Manager) Release() {
-	for block := range m.locks {
-		m.lockTable.Unlock(block)
+func (m *Manager) slockNoWait(res Resource) error {
+	if m.hasLock(res) {
+		return nil
 	}
 
-	m.locks = make(map[types.Block]lockType)
+	if err := m.lockTable.TrySLock(res); err != nil {
+		return err
+	}
+
+	m.setLock(res, slockType)
+
+	return nil
 }
 
-func (m *Manager) HasXlock(block types.Block) bool {
-	lock, ok := m.locks[block]
+func (m *Manager) xlockNoWait(res Resource) error {
+	if m.hasXlock(res) {
+		return nil
+	}
+
+	hadSLock := m.hasSlock(res)
+
+	if err := m.slockNoWait(res); err != nil {
+		return err
+	}
+
+	if err := m.lockTable.TryXLock(res); err != nil {
+		if !hadSLock {
+			m.unlock(res)
+		}
+
+		return err
+	}
+
+	m.setLock(res, xlockType)
+
+	return nil
+}
+
+func (m *Manager) hold(res Resource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.locks[res]; ok {
+		m.held[res] = struct{}{}
+	}
+}
+
+func (m *Manager) hasXlock(res Resource) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lock, ok := m.locks[res]
 
 	return ok && lock == xlockType
 }
 
-func (m *Manager) HasSlock(block types.Block) bool {
-	lock, ok := m.locks[block]
+func (m *Manager) hasSlock(res Resource) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lock, ok := m.locks[res]
 
 	return ok && lock == slockType
 }
+
+func (m *Manager) hasLock(res Resource) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.locks[res]
+
+	return ok
+}
+
+func (m *Manager) isHeld(res Resource) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.held[res]
+
+	return ok
+}
+
+func (m *Manager) setLock(res Resource, lock lockType) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.locks[res] = lock
+}
+
+func (m *Manager) unlock(res Resource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lockTable.Unlock(res)
+	delete(m.locks, res)
+	delete(m.held, res)
+}
+
+func (m *Manager) startWaiting(res Resource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.waitFor = &res
+	m.waitStarted = time.Now()
+}
+
+func (m *Manager) stopWaiting() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.waitFor = nil
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/concurrency"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
@@ -43,7 +44,7 @@ func (ts *ConcurrencyManagerTestSute) TestSLock_Fail() {
 	sut, lt := ts.newManager()
 
 	block1 := types.Block{Filename: testBlockFilename, Number: 1}
-	_ = lt.XLock(block1)
+	_ = lt.XLock(concurrency.BlockResource(block1))
 
 	assert.ErrorIs(t, sut.SLock(block1), concurrency.ErrLockAbort)
 	assert.False(t, sut.HasSlock(block1))
@@ -70,11 +71,11 @@ func (ts *ConcurrencyManagerTestSute) TestXLock_Fail() {
 
 	block1 := types.Block{Filename: testBlockFilename, Number: 1}
 
-	_ = lt.XLock(block1)
+	_ = lt.XLock(concurrency.BlockResource(block1))
 	assert.ErrorIs(t, sut.XLock(block1), concurrency.ErrLockAbort)
 
-	lt.Unlock(block1)
-	_ = lt.SLock(block1)
+	lt.Unlock(concurrency.BlockResource(block1))
+	_ = lt.SLock(concurrency.BlockResource(block1))
 	assert.ErrorIs(t, sut.XLock(block1), concurrency.ErrLockAbort)
 	assert.False(t, sut.HasXlock(block1))
 }
@@ -95,3 +96,253 @@ func (ts *ConcurrencyManagerTestSute) TestRelease() {
 	assert.False(t, sut.HasXlock(block1))
 	assert.False(t, sut.HasSlock(block2))
 }
+
+func (ts *ConcurrencyManagerTestSute) TestEndRead_IsolationLevels() {
+	t := ts.T()
+
+	lt := concurrency.NewLockTable(
+		concurrency.WithLockWaitTimeout(10 * time.Millisecond),
+	)
+
+	block1 := types.Block{Filename: testBlockFilename, Number: 1}
+	eofBlock := types.Block{Filename: testBlockFilename, Number: concurrency.EndOfFileBlock}
+
+	testCases := []struct {
+		level        concurrency.IsolationLevel
+		holdsBlock   bool
+		holdsEOFLock bool
+	}{
+		{level: concurrency.Serializable, holdsBlock: true, holdsEOFLock: true},
+		{level: concurrency.RepeatableRead, holdsBlock: true, holdsEOFLock: false},
+		{level: concurrency.ReadCommitted, holdsBlock: false, holdsEOFLock: false},
+	}
+
+	for _, tc := range testCases {
+		sut := concurrency.NewManager(lt, concurrency.WithIsolationLevel(tc.level))
+		assert.Equal(t, tc.level, sut.IsolationLevel())
+
+		require.NoError(t, sut.SLock(block1))
+		require.NoError(t, sut.SLock(eofBlock))
+
+		sut.EndRead(block1)
+		sut.EndRead(eofBlock)
+
+		assert.Equal(t, tc.holdsBlock, sut.HasSlock(block1), tc.level.String())
+		assert.Equal(t, tc.holdsEOFLock, sut.HasSlock(eofBlock), tc.level.String())
+
+		// Если блокировка снята, то другая транзакция может взять xlock
+		other := concurrency.NewManager(lt)
+		assert.Equal(t, !tc.holdsBlock, other.XLock(block1) == nil, tc.level.String())
+		assert.Equal(t, !tc.holdsEOFLock, other.XLock(eofBlock) == nil, tc.level.String())
+
+		other.Release()
+		sut.Release()
+	}
+}
+
+func (ts *ConcurrencyManagerTestSute) TestEndRead_KeepsXLock() {
+	t := ts.T()
+
+	lt := concurrency.NewLockTable(
+		concurrency.WithLockWaitTimeout(10 * time.Millisecond),
+	)
+
+	sut := concurrency.NewManager(lt, concurrency.WithIsolationLevel(concurrency.ReadCommitted))
+
+	block1 := types.Block{Filename: testBlockFilename, Number: 1}
+
+	require.NoError(t, sut.XLock(block1))
+	require.NoError(t, sut.SLock(block1))
+
+	sut.EndRead(block1)
+
+	assert.True(t, sut.HasXlock(block1))
+}
+
+func (ts *ConcurrencyManagerTestSute) TestEndRead_KeepsHeldLock() {
+	t := ts.T()
+
+	lt := concurrency.NewLockTable(
+		concurrency.WithLockWaitTimeout(10 * time.Millisecond),
+	)
+
+	sut := concurrency.NewManager(lt, concurrency.WithIsolationLevel(concurrency.ReadCommitted))
+
+	block1 := types.Block{Filename: testBlockFilename, Number: 1}
+	block2 := types.Block{Filename: testBlockFilename, Number: 2}
+
+	// Без блокировки отметка ничего не делает
+	sut.Hold(block2)
+
+	require.NoError(t, sut.SLock(block1))
+	sut.Hold(block1)
+
+	require.NoError(t, sut.SLock(block2))
+
+	sut.EndRead(block1)
+	sut.EndRead(block2)
+
+	assert.True(t, sut.HasSlock(block1))
+	assert.False(t, sut.HasSlock(block2))
+
+	other := concurrency.NewManager(lt)
+	assert.ErrorIs(t, other.XLock(block1), concurrency.ErrLockAbort)
+
+	// После конца транзакции отметка не остается
+	sut.Release()
+
+	require.NoError(t, sut.SLock(block1))
+	sut.EndRead(block1)
+	assert.False(t, sut.HasSlock(block1))
+}
+
+func (ts *ConcurrencyManagerTestSute) TestLockNoWait() {
+	t := ts.T()
+
+	lt := concurrency.NewLockTable(
+		concurrency.WithLockWaitTimeout(time.Second),
+	)
+
+	sut := concurrency.NewManager(lt)
+	other := concurrency.NewManager(lt)
+
+	block1 := types.Block{Filename: testBlockFilename, Number: 1}
+
+	require.NoError(t, other.SLock(block1))
+
+	// Не ждём таймаута блокировки
+	started := time.Now()
+
+	assert.ErrorIs(t, sut.XLockNoWait(block1), concurrency.ErrLockNotAvailable)
+	assert.Less(t, time.Since(started), 100*time.Millisecond)
+
+	// Разделяемая блокировка, взятая по пути, не остаётся у транзакции
+	assert.False(t, sut.HasSlock(block1))
+	assert.EqualValues(t, 1, lt.LocksCount(concurrency.BlockResource(block1)))
+
+	require.NoError(t, sut.SLockNoWait(block1))
+	assert.True(t, sut.HasSlock(block1))
+
+	other.Release()
+
+	require.NoError(t, sut.XLockNoWait(block1))
+	assert.True(t, sut.HasXlock(block1))
+
+	assert.ErrorIs(t, other.SLockNoWait(block1), concurrency.ErrLockNotAvailable)
+	assert.ErrorIs(t, other.XLockNoWait(block1), concurrency.ErrLockNotAvailable)
+
+	sut.Release()
+}
+
+func (ts *ConcurrencyManagerTestSute) TestRowLocks() {
+	t := ts.T()
+
+	lt := concurrency.NewLockTable(
+		concurrency.WithLockWaitTimeout(10 * time.Millisecond),
+	)
+
+	sut := concurrency.NewManager(lt, concurrency.WithIsolationLevel(concurrency.ReadCommitted))
+	other := concurrency.NewManager(lt, concurrency.WithIsolationLevel(concurrency.ReadCommitted))
+
+	block1 := types.Block{Filename: testBlockFilename, Number: 1}
+
+	require.NoError(t, sut.XLockRow(block1, 0, false))
+	require.NoError(t, sut.SLockRow(block1, 1, true))
+
+	// Блокировки записей не мешают другим записям и самому блоку
+	require.NoError(t, other.XLockRow(block1, 2, true))
+	require.NoError(t, other.XLock(block1))
+	require.NoError(t, other.SLockRow(block1, 1, false))
+
+	assert.ErrorIs(t, other.SLockRow(block1, 0, true), concurrency.ErrLockNotAvailable)
+	assert.ErrorIs(t, other.SLockRow(block1, 0, false), concurrency.ErrLockAbort)
+	assert.ErrorIs(t, other.XLockRow(block1, 1, true), concurrency.ErrLockNotAvailable)
+
+	// EndRead снимает только блокировки блоков, записи остаются заблокированными до конца транзакции
+	sut.EndRead(block1)
+
+	assert.ElementsMatch(t, []concurrency.HeldLock{
+		{Resource: concurrency.RowResource(block1, 0), Exclusive: true},
+		{Resource: concurrency.RowResource(block1, 1)},
+	}, sut.Locks())
+
+	sut.Release()
+
+	require.NoError(t, other.XLockRow(block1, 0, true))
+	assert.EqualValues(t, 4, other.LocksCount())
+
+	other.Release()
+	assert.Zero(t, lt.LocksCount(concurrency.RowResource(block1, 0)))
+}
+
+func (ts *ConcurrencyManagerTestSute) TestKeyLocks() {
+	t := ts.T()
+
+	lt := concurrency.NewLockTable(
+		concurrency.WithLockWaitTimeout(10 * time.Millisecond),
+	)
+
+	sut := concurrency.NewManager(lt, concurrency.WithIsolationLevel(concurrency.ReadCommitted))
+	other := concurrency.NewManager(lt)
+
+	key5 := concurrency.KeyResource(testBlockFilename, "5")
+	key7 := concurrency.KeyResource(testBlockFilename, "7")
+	supremum := concurrency.SupremumResource(testBlockFilename)
+
+	require.NoError(t, sut.SLockKey(key5))
+	require.NoError(t, sut.XLockKey(key7))
+
+	assert.ErrorIs(t, other.XLockNextKey(key5), concurrency.ErrLockAbort)
+	assert.ErrorIs(t, other.SLockKey(key7), concurrency.ErrLockAbort)
+
+	// Блокировка, взятая по пути к эксклюзивной, не остается
+	assert.Zero(t, other.LocksCount())
+
+	// Блокировка следующего ключа держится только до ReleaseNextKey
+	require.NoError(t, other.XLockNextKey(supremum))
+	assert.ErrorIs(t, sut.SLockKey(supremum), concurrency.ErrLockAbort)
+
+	other.ReleaseNextKey(supremum)
+	assert.Zero(t, other.LocksCount())
+
+	// ReleaseNextKey не снимает ключ, который транзакция прочитала раньше
+	require.NoError(t, sut.XLockNextKey(key5))
+	sut.ReleaseNextKey(key5)
+
+	assert.ElementsMatch(t, []concurrency.HeldLock{
+		{Resource: key5, Exclusive: true},
+		{Resource: key7, Exclusive: true},
+	}, sut.Locks())
+
+	sut.Release()
+
+	require.NoError(t, other.SLockKey(key7))
+	other.Release()
+}
+
+func (ts *ConcurrencyManagerTestSute) TestReleaseRead() {
+	t := ts.T()
+
+	sut, lt := ts.newManager()
+
+	block1 := types.Block{Filename: testBlockFilename, Number: 1}
+	block2 := types.Block{Filename: testBlockFilename, Number: 2}
+	block3 := types.Block{Filename: testBlockFilename, Number: 3}
+
+	require.NoError(t, sut.SLock(block1))
+	require.NoError(t, sut.XLock(block2))
+	require.NoError(t, sut.SLock(block3))
+	sut.Hold(block3)
+
+	// Даже на уровне serializable
+	sut.ReleaseRead(block1)
+	sut.ReleaseRead(block2)
+	sut.ReleaseRead(block3)
+
+	assert.False(t, sut.HasSlock(block1))
+	assert.Zero(t, lt.LocksCount(concurrency.BlockResource(block1)))
+	assert.True(t, sut.HasXlock(block2))
+	assert.True(t, sut.HasSlock(block3))
+
+	sut.Release()
+}
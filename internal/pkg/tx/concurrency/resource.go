@@ -0,0 +1,75 @@
+package concurrency
+
+import (
+	"fmt"
+
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+const (
+	// BlockSlot — слот ресурса, которым блокируется блок целиком
+	BlockSlot types.SlotID = -1
+	// KeySlot — слот ресурса, которым блокируется значение ключа индекса
+	KeySlot types.SlotID = -2
+)
+
+// SupremumKey — ключ «больше всех значений индекса». Его блокирует чтение диапазона, который доходит до конца индекса.
+// Ключи строятся из scan.Constant.String(), поэтому значение индекса с таким ключом не совпадет
+const SupremumKey = "supremum"
+
+// Resource — то, что блокирует транзакция: блок файла, отдельная запись в нем или значение ключа индекса
+type Resource struct {
+	Block types.Block
+	// Slot — слот записи в блоке, для блокировки всего блока — BlockSlot, для ключа индекса — KeySlot
+	Slot types.SlotID
+	// Key — значение ключа индекса, имя индекса хранится в Block.Filename
+	Key string
+}
+
+func BlockResource(block types.Block) Resource {
+	return Resource{
+		Block: block,
+		Slot:  BlockSlot,
+	}
+}
+
+func RowResource(block types.Block, slot types.SlotID) Resource {
+	return Resource{
+		Block: block,
+		Slot:  slot,
+	}
+}
+
+func KeyResource(index string, key string) Resource {
+	return Resource{
+		Block: types.Block{Filename: index},
+		Slot:  KeySlot,
+		Key:   key,
+	}
+}
+
+// SupremumResource — ресурс ключа SupremumKey индекса index
+func SupremumResource(index string) Resource {
+	return KeyResource(index, SupremumKey)
+}
+
+// IsRow проверяет, что ресурс — запись, а не блок
+func (r Resource) IsRow() bool {
+	return r.Slot >= 0
+}
+
+// IsKey проверяет, что ресурс — значение ключа индекса
+func (r Resource) IsKey() bool {
+	return r.Slot == KeySlot
+}
+
+func (r Resource) String() string {
+	switch {
+	case r.IsRow():
+		return fmt.Sprintf("row [file %s, block %d, slot %d]", r.Block.Filename, r.Block.Number, r.Slot)
+	case r.IsKey():
+		return fmt.Sprintf("key %s [index %s]", r.Key, r.Block.Filename)
+	default:
+		return "block " + r.Block.String()
+	}
+}
@@ -1,3 +1,11 @@
 // Менеджер восстановления
 
+// Оборванные записи.
+// Восстановление только откатывает незафиксированные транзакции, а фиксация сначала пишет на носитель страницы
+// транзакции и только потом запись COMMIT. Перед записью страницы журнал сбрасывается до LSN ее последнего изменения.
+// Поэтому все байты оборванной страницы, которые отличаются от прежней версии на носителе, изменила незафиксированная
+// транзакция, записи отката для них уже в журнале, и восстановление возвращает прежние значения.
+// Блок журнала защищен от обрыва двойной записью со старой и новой границей, см. wal.Manager.
+// Сжатые и зашифрованные файлы пишут страницы в свободные экстенты, и оборванная запись не видна вовсе
+
 package recovery
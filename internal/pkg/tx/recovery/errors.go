@@ -8,5 +8,6 @@ var (
 	ErrUnknownLogRecord = errors.Wrap(ErrLogRecord, "unknown log record")
 	ErrBadLogRecord     = errors.Wrap(ErrLogRecord, "bad log record")
 
-	ErrOpError = errors.New("recovery operation failed")
+	ErrOpError           = errors.New("recovery operation failed")
+	ErrSavepointNotFound = errors.Wrap(ErrOpError, "savepoint not found")
 )
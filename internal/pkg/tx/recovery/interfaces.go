@@ -9,9 +9,13 @@ type RecoveryManager interface {
 	Commit() error
 	Rollback() error
 	Recover() error
+	Savepoint(id int32, name string) error
+	RollbackToSavepoint(id int32) error
 	SetInt64(buf buffer, offset uint32, value int64) (types.LSN, error)
 	SetInt8(buf buffer, offset uint32, value int8) (types.LSN, error)
 	SetString(buf buffer, offset uint32, value string) (types.LSN, error)
+	PutBytes(buf buffer, offset uint32, value []byte) (types.LSN, error)
+	SetCompression(filename string) error
 }
 
 type trxInt interface {
@@ -20,6 +24,8 @@ type trxInt interface {
 	SetString(block types.Block, offset uint32, value string, okToLog bool) error
 	SetInt64(block types.Block, offset uint32, value int64, okToLog bool) error
 	SetInt8(block types.Block, offset uint32, value int8, okToLog bool) error
+	PutBytes(block types.Block, offset uint32, value []byte, okToLog bool) error
+	ResetCompression(filename string) error
 	TXNum() types.TRX
 }
 
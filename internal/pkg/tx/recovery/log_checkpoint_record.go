@@ -1,7 +1,5 @@
 package recovery
 
-import "github.com/unhandled-exception/sophiadb/internal/pkg/types"
-
 type CheckpointLogRecord struct {
 	BaseLogRecord
 }
@@ -29,20 +27,12 @@ func (lr CheckpointLogRecord) String() string {
 }
 
 func (lr CheckpointLogRecord) MarshalBytes() []byte {
-	oppos := uint32(0)
-	recLen := oppos + int32Size
-
-	p := types.NewPage(recLen)
-
-	p.SetUint32(oppos, lr.op)
-
-	return p.Content()
+	return byteOrder.AppendUint32(make([]byte, 0, int32Size), lr.op)
 }
 
 func (lr *CheckpointLogRecord) unmarshalBytes(rawRecord []byte) error {
-	p := types.NewPageFromBytes(rawRecord)
-
-	lr.op = p.GetUint32(0)
+	r := newRecordReader(rawRecord)
+	lr.op = r.uint32()
 
-	return nil
+	return r.err
 }
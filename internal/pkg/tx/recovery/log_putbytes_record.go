@@ -0,0 +1,82 @@
+package recovery
+
+import (
+	"fmt"
+
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+// PutBytesLogRecord хранит прежнее содержимое участка страницы. Участок пишется без длины, как в types.Page.PutBytes
+type PutBytesLogRecord struct {
+	BaseLogRecord
+
+	offset uint32
+	value  []byte
+	block  types.Block
+}
+
+func NewPutBytesLogRecord(txnum types.TRX, block types.Block, offset uint32, value []byte) PutBytesLogRecord {
+	return PutBytesLogRecord{
+		BaseLogRecord: BaseLogRecord{
+			op:    PutBytesOp,
+			txnum: txnum,
+		},
+		offset: offset,
+		value:  value,
+		block:  block,
+	}
+}
+
+func NewPutBytesLogRecordFromBytes(rawRecord []byte) (PutBytesLogRecord, error) {
+	r := PutBytesLogRecord{}
+
+	if err := r.unmarshalBytes(rawRecord); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+func (lr PutBytesLogRecord) Undo(tx trxInt) error {
+	if err := tx.Pin(lr.block); err != nil {
+		return err
+	}
+
+	if err := tx.PutBytes(lr.block, lr.offset, lr.value, false); err != nil {
+		return err
+	}
+
+	tx.Unpin(lr.block)
+
+	return nil
+}
+
+func (lr PutBytesLogRecord) String() string {
+	return fmt.Sprintf(
+		`<PUT_BYTES, %d, block: %s, offset: %d, value: %x>`,
+		lr.TXNum(),
+		lr.block.String(),
+		lr.offset,
+		lr.value,
+	)
+}
+
+func (lr PutBytesLogRecord) MarshalBytes() []byte {
+	rec := lr.appendBytes(nil)
+	rec = appendBlock(rec, lr.block)
+	rec = byteOrder.AppendUint32(rec, lr.offset)
+	rec = appendString(rec, string(lr.value))
+
+	return rec
+}
+
+func (lr *PutBytesLogRecord) unmarshalBytes(rawRecord []byte) error {
+	r := newRecordReader(rawRecord)
+
+	lr.readFrom(r)
+	lr.block = r.block()
+	lr.offset = r.uint32()
+	lr.value = []byte(r.string())
+
+	return r.err
+}
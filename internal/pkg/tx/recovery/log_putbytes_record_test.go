@@ -0,0 +1,85 @@
+package recovery_test
+
+import (
+	"testing"
+
+	"github.com/gojuno/minimock/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/recovery"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+var testPutBytesLogRecord = recovery.NewPutBytesLogRecord(
+	0x1234,
+	types.Block{Filename: "testlogfile", Number: 0x0789},
+	0x0145,
+	[]byte{0x00, 0x01, 0xfe, 0xff},
+)
+
+var testRawPutBytesLogRecord = []byte{
+	0x8, 0x0, 0x0, 0x0, // op == 8
+	0x34, 0x12, 0x0, 0x0, // txnum == 0x1234
+	0xb, 0x0, 0x0, 0x0, // filename length == 11
+	0x74, 0x65, 0x73, 0x74, 0x6c, 0x6f, 0x67, 0x66, 0x69, 0x6c, 0x65, // filename "testlogfile"
+	0x89, 0x07, 0x0, 0x0, // block numer == 0x0789
+	0x45, 0x01, 0x0, 0x0, // offset == 0x0145
+	0x4, 0x0, 0x0, 0x0, // value len = 4
+	0x00, 0x01, 0xfe, 0xff, // value
+}
+
+type PutBytesLogRecordTestSuite struct {
+	suite.Suite
+}
+
+func TestPutBytesLogRecordTestSuite(t *testing.T) {
+	suite.Run(t, new(PutBytesLogRecordTestSuite))
+}
+
+func (ts *PutBytesLogRecordTestSuite) TestNewPutBytesLogRecord() {
+	t := ts.T()
+
+	assert.Equal(t, "<PUT_BYTES, 4660, block: [file testlogfile, block 1929], offset: 325, value: 0001feff>", testPutBytesLogRecord.String())
+	assert.EqualValues(t, recovery.PutBytesOp, testPutBytesLogRecord.Op())
+	assert.EqualValues(t, 0x1234, testPutBytesLogRecord.TXNum())
+}
+
+func (ts *PutBytesLogRecordTestSuite) TestNewPutBytesLogRecordFromBytes() {
+	t := ts.T()
+
+	r, err := recovery.NewPutBytesLogRecordFromBytes(testRawPutBytesLogRecord)
+	require.NoError(t, err)
+
+	assert.Equal(t, testPutBytesLogRecord, r)
+
+	lr, err := recovery.NewLogRecordFromBytes(testRawPutBytesLogRecord)
+	require.NoError(t, err)
+	assert.Equal(t, testPutBytesLogRecord, lr)
+
+	_, err = recovery.NewPutBytesLogRecordFromBytes(testRawPutBytesLogRecord[:len(testRawPutBytesLogRecord)-1])
+	require.ErrorIs(t, err, recovery.ErrBadLogRecord)
+}
+
+func (ts *PutBytesLogRecordTestSuite) TestMarshalBytes() {
+	t := ts.T()
+
+	assert.EqualValues(t,
+		testRawPutBytesLogRecord,
+		testPutBytesLogRecord.MarshalBytes(),
+	)
+}
+
+func (ts *PutBytesLogRecordTestSuite) TestUndo() {
+	t := ts.T()
+
+	mc := minimock.NewController(t)
+
+	trxIntMock := recovery.NewTrxIntMock(mc).
+		PinMock.Return(nil).
+		UnpinMock.Return().
+		PutBytesMock.Expect(types.Block{Filename: "testlogfile", Number: 0x0789}, 0x0145, []byte{0x00, 0x01, 0xfe, 0xff}, false).Return(nil)
+
+	err := testPutBytesLogRecord.Undo(trxIntMock)
+	require.NoError(t, err)
+}
@@ -3,6 +3,8 @@
 package recovery
 
 import (
+	"encoding/binary"
+
 	"github.com/pkg/errors"
 
 	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
@@ -30,6 +32,10 @@ const (
 	SetInt64Op   uint32 = 4
 	SetStringOp  uint32 = 5
 	SetInt8Op    uint32 = 6
+	SavepointOp  uint32 = 7
+	PutBytesOp   uint32 = 8
+
+	SetCompressionOp uint32 = 9
 )
 
 func NewLogRecordFromBytes(rawRecord []byte) (LogRecord, error) {
@@ -37,8 +43,12 @@ func NewLogRecordFromBytes(rawRecord []byte) (LogRecord, error) {
 		return nil, ErrEmptyLogRecord
 	}
 
-	p := types.NewPageFromBytes(rawRecord)
-	op := p.GetUint32(0)
+	r := newRecordReader(rawRecord)
+	op := r.uint32()
+
+	if r.err != nil {
+		return nil, r.err
+	}
 
 	switch op {
 	case CheckpointOp:
@@ -55,6 +65,12 @@ func NewLogRecordFromBytes(rawRecord []byte) (LogRecord, error) {
 		return NewSetInt64LogRecordFromBytes(rawRecord)
 	case SetInt8Op:
 		return NewSetInt8LogRecordFromBytes(rawRecord)
+	case SavepointOp:
+		return NewSavepointLogRecordFromBytes(rawRecord)
+	case PutBytesOp:
+		return NewPutBytesLogRecordFromBytes(rawRecord)
+	case SetCompressionOp:
+		return NewSetCompressionLogRecordFromBytes(rawRecord)
 	default:
 		return nil, errors.WithMessagef(ErrUnknownLogRecord, "%d is an unknown op", op)
 	}
@@ -82,23 +98,129 @@ func (lr BaseLogRecord) Undo(tx trxInt) error {
 }
 
 func (lr BaseLogRecord) MarshalBytes() []byte {
-	oppos := uint32(0)
-	txpos := oppos + int32Size
-	recLen := txpos + int32Size
-
-	p := types.NewPage(recLen)
+	return lr.appendBytes(make([]byte, 0, 2*int32Size)) //nolint:mnd
+}
 
-	p.SetUint32(oppos, lr.op)
-	p.SetInt32(txpos, int32(lr.txnum))
+// appendBytes дописывает к rec операцию и номер транзакции, с которых начинается каждая запись
+func (lr BaseLogRecord) appendBytes(rec []byte) []byte {
+	rec = byteOrder.AppendUint32(rec, lr.op)
+	rec = byteOrder.AppendUint32(rec, uint32(lr.txnum))
 
-	return p.Content()
+	return rec
 }
 
 func (lr *BaseLogRecord) unmarshalBytes(rawRecord []byte) error {
-	p := types.NewPageFromBytes(rawRecord)
+	r := newRecordReader(rawRecord)
+	lr.readFrom(r)
+
+	return r.err
+}
+
+func (lr *BaseLogRecord) readFrom(r *recordReader) {
+	lr.op = r.uint32()
+	lr.txnum = types.TRX(r.int32())
+}
 
-	lr.op = p.GetUint32(0)
-	lr.txnum = types.TRX(p.GetInt32(int32Size))
+// Поля записей хранятся в том же порядке байтов, что и значения на страницах, см. types.Page
+var byteOrder = binary.LittleEndian
 
-	return nil
+func appendString(rec []byte, value string) []byte {
+	rec = byteOrder.AppendUint32(rec, uint32(len(value)))
+
+	return append(rec, value...)
+}
+
+func appendBlock(rec []byte, block types.Block) []byte {
+	rec = appendString(rec, block.Filename)
+
+	return byteOrder.AppendUint32(rec, uint32(block.Number))
+}
+
+// recordReader читает поля записи по порядку. После первой ошибки чтения возвращает нулевые значения, а ошибка остается в err
+type recordReader struct {
+	p   *types.Page
+	pos uint32
+	err error
+}
+
+func newRecordReader(rawRecord []byte) *recordReader {
+	return &recordReader{
+		p: types.NewPageFromBytes(rawRecord),
+	}
+}
+
+func (r *recordReader) fail(err error) {
+	if r.err == nil {
+		r.err = errors.WithMessage(ErrBadLogRecord, err.Error())
+	}
+}
+
+func (r *recordReader) uint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+
+	value, err := r.p.GetUint32(r.pos)
+	if err != nil {
+		r.fail(err)
+	}
+
+	r.pos += int32Size
+
+	return value
+}
+
+func (r *recordReader) int32() int32 {
+	return int32(r.uint32())
+}
+
+func (r *recordReader) int64() int64 {
+	if r.err != nil {
+		return 0
+	}
+
+	value, err := r.p.GetInt64(r.pos)
+	if err != nil {
+		r.fail(err)
+	}
+
+	r.pos += int64Size
+
+	return value
+}
+
+func (r *recordReader) int8() int8 {
+	if r.err != nil {
+		return 0
+	}
+
+	value, err := r.p.GetInt8(r.pos)
+	if err != nil {
+		r.fail(err)
+	}
+
+	r.pos += int8Size
+
+	return value
+}
+
+func (r *recordReader) string() string {
+	if r.err != nil {
+		return ""
+	}
+
+	value, err := r.p.GetString(r.pos)
+	if err != nil {
+		r.fail(err)
+	}
+
+	r.pos += int32Size + uint32(len(value))
+
+	return value
+}
+
+func (r *recordReader) block() types.Block {
+	filename := r.string()
+
+	return types.Block{Filename: filename, Number: types.BlockID(r.int32())}
 }
@@ -32,6 +32,9 @@ func (ts *LogRecordTestSuite) TestNewLogRecordFromBytes_OK() {
 
 		{Name: "SetStringRecord", RawRecord: testRawSetStringLogRecord, Record: testSetStringLogRecord},
 		{Name: "SetInt64Record", RawRecord: testRawSetInt64LogRecord, Record: testSetInt64LogRecord},
+
+		{Name: "SavepointRecord", RawRecord: testRawSavepointLogRecord, Record: testSavepointLogRecord},
+		{Name: "SetCompressionRecord", RawRecord: testRawSetCompressionLogRecord, Record: testSetCompressionLogRecord},
 	}
 
 	for _, tc := range testCases {
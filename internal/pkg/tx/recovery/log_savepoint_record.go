@@ -0,0 +1,66 @@
+package recovery
+
+import (
+	"fmt"
+
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+// SavepointLogRecord — точка сохранения транзакции. До неё откатывает RollbackToSavepoint
+type SavepointLogRecord struct {
+	BaseLogRecord
+
+	id   int32
+	name string
+}
+
+func NewSavepointLogRecord(txnum types.TRX, id int32, name string) SavepointLogRecord {
+	return SavepointLogRecord{
+		BaseLogRecord: BaseLogRecord{
+			op:    SavepointOp,
+			txnum: txnum,
+		},
+		id:   id,
+		name: name,
+	}
+}
+
+func NewSavepointLogRecordFromBytes(rawRecord []byte) (SavepointLogRecord, error) {
+	r := SavepointLogRecord{}
+
+	if err := r.unmarshalBytes(rawRecord); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+func (lr SavepointLogRecord) ID() int32 {
+	return lr.id
+}
+
+func (lr SavepointLogRecord) Name() string {
+	return lr.name
+}
+
+func (lr SavepointLogRecord) String() string {
+	return fmt.Sprintf(`<SAVEPOINT, %d, id: %d, name: "%s">`, lr.TXNum(), lr.id, lr.name)
+}
+
+func (lr SavepointLogRecord) MarshalBytes() []byte {
+	rec := lr.appendBytes(nil)
+	rec = byteOrder.AppendUint32(rec, uint32(lr.id))
+	rec = appendString(rec, lr.name)
+
+	return rec
+}
+
+func (lr *SavepointLogRecord) unmarshalBytes(rawRecord []byte) error {
+	r := newRecordReader(rawRecord)
+
+	lr.readFrom(r)
+	lr.id = r.int32()
+	lr.name = r.string()
+
+	return r.err
+}
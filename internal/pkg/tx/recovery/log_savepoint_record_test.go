@@ -0,0 +1,68 @@
+package recovery_test
+
+import (
+	"testing"
+
+	"github.com/gojuno/minimock/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/recovery"
+)
+
+var testSavepointLogRecord = recovery.NewSavepointLogRecord(0x1234, 0x0102, "sp_1")
+
+var testRawSavepointLogRecord = []byte{
+	0x7, 0x0, 0x0, 0x0, // op == 7
+	0x34, 0x12, 0x0, 0x0, // txnum == 0x1234
+	0x02, 0x01, 0x0, 0x0, // id == 0x0102
+	0x4, 0x0, 0x0, 0x0, // name length == 4
+	0x73, 0x70, 0x5f, 0x31, // name "sp_1"
+}
+
+type SavepointLogRecordTestSuite struct {
+	suite.Suite
+}
+
+func TestSavepointLogRecordTestSuite(t *testing.T) {
+	suite.Run(t, new(SavepointLogRecordTestSuite))
+}
+
+func (ts *SavepointLogRecordTestSuite) TestNewSavepointLogRecord() {
+	t := ts.T()
+
+	r := recovery.NewSavepointLogRecord(12345, 3, "before_update")
+
+	assert.Equal(t, `<SAVEPOINT, 12345, id: 3, name: "before_update">`, r.String())
+	assert.EqualValues(t, recovery.SavepointOp, r.Op())
+	assert.EqualValues(t, 12345, r.TXNum())
+	assert.EqualValues(t, 3, r.ID())
+	assert.Equal(t, "before_update", r.Name())
+}
+
+func (ts *SavepointLogRecordTestSuite) TestNewSavepointLogRecordFromBytes() {
+	t := ts.T()
+
+	r, err := recovery.NewSavepointLogRecordFromBytes(testRawSavepointLogRecord)
+	require.NoError(t, err)
+
+	assert.Equal(t, testSavepointLogRecord, r)
+}
+
+func (ts *SavepointLogRecordTestSuite) TestMarshalBytes() {
+	t := ts.T()
+
+	assert.EqualValues(t,
+		testRawSavepointLogRecord,
+		testSavepointLogRecord.MarshalBytes(),
+	)
+}
+
+func (ts *SavepointLogRecordTestSuite) TestUndo() {
+	t := ts.T()
+
+	mc := minimock.NewController(t)
+	trxIntMock := recovery.NewTrxIntMock(mc)
+
+	require.NoError(t, testSavepointLogRecord.Undo(trxIntMock))
+}
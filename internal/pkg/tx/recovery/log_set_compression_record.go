@@ -0,0 +1,58 @@
+package recovery
+
+import (
+	"fmt"
+
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+// SetCompressionLogRecord — включение сжатия пустого файла. Откат удаляет файл вместе с картой блоков
+type SetCompressionLogRecord struct {
+	BaseLogRecord
+
+	filename string
+}
+
+func NewSetCompressionLogRecord(txnum types.TRX, filename string) SetCompressionLogRecord {
+	return SetCompressionLogRecord{
+		BaseLogRecord: BaseLogRecord{
+			op:    SetCompressionOp,
+			txnum: txnum,
+		},
+		filename: filename,
+	}
+}
+
+func NewSetCompressionLogRecordFromBytes(rawRecord []byte) (SetCompressionLogRecord, error) {
+	r := SetCompressionLogRecord{}
+
+	if err := r.unmarshalBytes(rawRecord); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+func (lr SetCompressionLogRecord) Undo(tx trxInt) error {
+	return tx.ResetCompression(lr.filename)
+}
+
+func (lr SetCompressionLogRecord) String() string {
+	return fmt.Sprintf(`<SET_COMPRESSION, %d, file: "%s">`, lr.TXNum(), lr.filename)
+}
+
+func (lr SetCompressionLogRecord) MarshalBytes() []byte {
+	rec := lr.appendBytes(nil)
+	rec = appendString(rec, lr.filename)
+
+	return rec
+}
+
+func (lr *SetCompressionLogRecord) unmarshalBytes(rawRecord []byte) error {
+	r := newRecordReader(rawRecord)
+
+	lr.readFrom(r)
+	lr.filename = r.string()
+
+	return r.err
+}
@@ -0,0 +1,68 @@
+package recovery_test
+
+import (
+	"testing"
+
+	"github.com/gojuno/minimock/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/recovery"
+)
+
+var testSetCompressionLogRecord = recovery.NewSetCompressionLogRecord(0x1234, "table.tbl")
+
+var testRawSetCompressionLogRecord = []byte{
+	0x9, 0x0, 0x0, 0x0, // op == 9
+	0x34, 0x12, 0x0, 0x0, // txnum == 0x1234
+	0x9, 0x0, 0x0, 0x0, // filename length == 9
+	0x74, 0x61, 0x62, 0x6c, 0x65, 0x2e, 0x74, 0x62, 0x6c, // filename "table.tbl"
+}
+
+type SetCompressionLogRecordTestSuite struct {
+	suite.Suite
+}
+
+func TestSetCompressionLogRecordTestSuite(t *testing.T) {
+	suite.Run(t, new(SetCompressionLogRecordTestSuite))
+}
+
+func (ts *SetCompressionLogRecordTestSuite) TestNewSetCompressionLogRecord() {
+	t := ts.T()
+
+	assert.Equal(t, `<SET_COMPRESSION, 4660, file: "table.tbl">`, testSetCompressionLogRecord.String())
+	assert.EqualValues(t, recovery.SetCompressionOp, testSetCompressionLogRecord.Op())
+	assert.EqualValues(t, 0x1234, testSetCompressionLogRecord.TXNum())
+}
+
+func (ts *SetCompressionLogRecordTestSuite) TestNewSetCompressionLogRecordFromBytes() {
+	t := ts.T()
+
+	r, err := recovery.NewSetCompressionLogRecordFromBytes(testRawSetCompressionLogRecord)
+	require.NoError(t, err)
+	assert.Equal(t, testSetCompressionLogRecord, r)
+
+	lr, err := recovery.NewLogRecordFromBytes(testRawSetCompressionLogRecord)
+	require.NoError(t, err)
+	assert.Equal(t, testSetCompressionLogRecord, lr)
+
+	_, err = recovery.NewSetCompressionLogRecordFromBytes(testRawSetCompressionLogRecord[:len(testRawSetCompressionLogRecord)-1])
+	require.ErrorIs(t, err, recovery.ErrBadLogRecord)
+}
+
+func (ts *SetCompressionLogRecordTestSuite) TestMarshalBytes() {
+	t := ts.T()
+
+	assert.EqualValues(t, testRawSetCompressionLogRecord, testSetCompressionLogRecord.MarshalBytes())
+}
+
+func (ts *SetCompressionLogRecordTestSuite) TestUndo() {
+	t := ts.T()
+
+	mc := minimock.NewController(t)
+
+	trxIntMock := recovery.NewTrxIntMock(mc).
+		ResetCompressionMock.Expect("table.tbl").Return(nil)
+
+	require.NoError(t, testSetCompressionLogRecord.Undo(trxIntMock))
+}
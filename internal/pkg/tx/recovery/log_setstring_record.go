@@ -61,47 +61,21 @@ func (lr SetStringLogRecord) String() string {
 }
 
 func (lr SetStringLogRecord) MarshalBytes() []byte {
-	blockFilename := lr.block.Filename
-
-	oppos := uint32(0)
-	txpos := oppos + int32Size
-	fpos := txpos + int32Size
-	bpos := fpos + int32Size + uint32(len(blockFilename))
-	ofpos := bpos + int32Size
-	vpos := ofpos + int32Size
-	recLen := vpos + int32Size + uint32(len(lr.value))
-
-	p := types.NewPage(recLen)
-
-	p.SetUint32(oppos, lr.op)
-	p.SetInt32(txpos, int32(lr.txnum))
-	p.SetString(fpos, blockFilename)
-	p.SetInt32(bpos, int32(lr.block.Number))
-	p.SetUint32(ofpos, lr.offset)
-	p.SetString(vpos, lr.value)
-
-	return p.Content()
+	rec := lr.appendBytes(nil)
+	rec = appendBlock(rec, lr.block)
+	rec = byteOrder.AppendUint32(rec, lr.offset)
+	rec = appendString(rec, lr.value)
+
+	return rec
 }
 
 func (lr *SetStringLogRecord) unmarshalBytes(rawRecord []byte) error {
-	p := types.NewPageFromBytes(rawRecord)
-
-	lr.op = p.GetUint32(0)
-	lr.txnum = types.TRX(p.GetInt32(int32Size))
-
-	fpos := uint32(2 * int32Size) //nolint:mnd
-	blockFilename := p.GetString(fpos)
-
-	bpos := fpos + uint32(int32Size+len(blockFilename))
-	blockNum := types.BlockID(p.GetUint32(bpos))
+	r := newRecordReader(rawRecord)
 
-	lr.block = types.Block{Filename: blockFilename, Number: blockNum}
+	lr.readFrom(r)
+	lr.block = r.block()
+	lr.offset = r.uint32()
+	lr.value = r.string()
 
-	ofpos := bpos + int32Size
-	lr.offset = p.GetUint32(ofpos)
-
-	vpos := ofpos + int32Size
-	lr.value = p.GetString(vpos)
-
-	return nil
+	return r.err
 }
@@ -1,21 +1,35 @@
 package recovery
 
 import (
+	"slices"
+
 	"github.com/pkg/errors"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
 )
 
 type Manager struct {
-	trx trxInt
-	bm  BuffersManager
-	lm  LogManager
+	trx      trxInt
+	bm       BuffersManager
+	lm       LogManager
+	readOnly bool
+
+	// savepoints — LSN записей точек сохранения транзакции по их номерам
+	savepoints map[int32]types.LSN
 }
 
-func NewManager(trx trxInt, lm LogManager, bm BuffersManager) (*Manager, error) {
+type ManagerOpt func(*Manager)
+
+func NewManager(trx trxInt, lm LogManager, bm BuffersManager, opts ...ManagerOpt) (*Manager, error) {
 	m := Manager{
 		trx: trx,
 		bm:  bm,
 		lm:  lm,
+
+		savepoints: make(map[int32]types.LSN),
+	}
+
+	for _, opt := range opts {
+		opt(&m)
 	}
 
 	if err := m.start(); err != nil {
@@ -25,7 +39,19 @@ func NewManager(trx trxInt, lm LogManager, bm BuffersManager) (*Manager, error)
 	return &m, nil
 }
 
+// WithReadOnly — менеджер для читающей транзакции. Читающая транзакция ничего не меняет,
+// поэтому не пишет в журнал ни начало, ни завершение
+func WithReadOnly() ManagerOpt {
+	return func(m *Manager) {
+		m.readOnly = true
+	}
+}
+
 func (m *Manager) start() error {
+	if m.readOnly {
+		return nil
+	}
+
 	txnum := m.trx.TXNum()
 
 	if err := m.bm.FlushAll(txnum); err != nil {
@@ -47,6 +73,10 @@ func (m *Manager) start() error {
 }
 
 func (m *Manager) Commit() error {
+	if m.readOnly {
+		return nil
+	}
+
 	txnum := m.trx.TXNum()
 
 	if err := m.bm.FlushAll(txnum); err != nil {
@@ -68,6 +98,10 @@ func (m *Manager) Commit() error {
 }
 
 func (m *Manager) Rollback() error {
+	if m.readOnly {
+		return nil
+	}
+
 	txnum := m.trx.TXNum()
 
 	if err := m.doRollback(); err != nil {
@@ -120,7 +154,11 @@ func (m *Manager) Recover() error {
 func (m *Manager) SetInt64(buf buffer, offset uint32, value int64) (types.LSN, error) {
 	txnum := m.trx.TXNum()
 
-	oldValue := buf.Content().GetInt64(offset)
+	oldValue, err := buf.Content().GetInt64(offset)
+	if err != nil {
+		return -1, errors.WithMessage(ErrOpError, err.Error())
+	}
+
 	block := buf.Block()
 
 	lr := NewSetInt64LogRecord(txnum, block, offset, oldValue)
@@ -131,7 +169,11 @@ func (m *Manager) SetInt64(buf buffer, offset uint32, value int64) (types.LSN, e
 func (m *Manager) SetInt8(buf buffer, offset uint32, value int8) (types.LSN, error) {
 	txnum := m.trx.TXNum()
 
-	oldValue := buf.Content().GetInt8(offset)
+	oldValue, err := buf.Content().GetInt8(offset)
+	if err != nil {
+		return -1, errors.WithMessage(ErrOpError, err.Error())
+	}
+
 	block := buf.Block()
 
 	lr := NewSetInt8LogRecord(txnum, block, offset, oldValue)
@@ -142,7 +184,11 @@ func (m *Manager) SetInt8(buf buffer, offset uint32, value int8) (types.LSN, err
 func (m *Manager) SetString(buf buffer, offset uint32, value string) (types.LSN, error) {
 	txnum := m.trx.TXNum()
 
-	oldValue := buf.Content().GetString(offset)
+	oldValue, err := buf.Content().GetString(offset)
+	if err != nil {
+		return -1, errors.WithMessage(ErrOpError, err.Error())
+	}
+
 	block := buf.Block()
 
 	lr := NewSetStringLogRecord(txnum, block, offset, oldValue)
@@ -150,38 +196,138 @@ func (m *Manager) SetString(buf buffer, offset uint32, value string) (types.LSN,
 	return m.writeRecordToLog(lr)
 }
 
+// PutBytes пишет в журнал прежнее содержимое участка страницы длиной len(value)
+func (m *Manager) PutBytes(buf buffer, offset uint32, value []byte) (types.LSN, error) {
+	txnum := m.trx.TXNum()
+
+	oldValue, err := buf.Content().FetchBytes(offset, len(value))
+	if err != nil {
+		return -1, errors.WithMessage(ErrOpError, err.Error())
+	}
+
+	block := buf.Block()
+
+	lr := NewPutBytesLogRecord(txnum, block, offset, slices.Clone(oldValue))
+
+	return m.writeRecordToLog(lr)
+}
+
+// SetCompression пишет в журнал включение сжатия файла и сразу сбрасывает журнал на диск:
+// карта блоков создается вне буферов, и запись для ее отката должна попасть на диск раньше нее
+func (m *Manager) SetCompression(filename string) error {
+	lr := NewSetCompressionLogRecord(m.trx.TXNum(), filename)
+
+	lsn, err := m.writeRecordToLog(lr)
+	if err != nil {
+		return err
+	}
+
+	if err := m.lm.Flush(lsn, false); err != nil {
+		return errors.WithMessage(ErrOpError, err.Error())
+	}
+
+	return nil
+}
+
+// Savepoint пишет в журнал точку сохранения транзакции
+func (m *Manager) Savepoint(id int32, name string) error {
+	if m.readOnly {
+		return nil
+	}
+
+	lr := NewSavepointLogRecord(m.trx.TXNum(), id, name)
+
+	lsn, err := m.writeRecordToLog(lr)
+	if err != nil {
+		return err
+	}
+
+	m.savepoints[id] = lsn
+
+	return nil
+}
+
+// RollbackToSavepoint откатывает изменения транзакции, сделанные после точки сохранения.
+// Блокировки транзакции остаются, а записи журнала после точки сохранения не удаляются:
+// при полном откате или восстановлении они откатятся еще раз в обратном порядке и дадут то же состояние.
+// Если точку сохранения не записывали, то ничего не откатывается
+func (m *Manager) RollbackToSavepoint(id int32) error {
+	if m.readOnly {
+		return nil
+	}
+
+	spLSN, ok := m.savepoints[id]
+	if !ok {
+		return errors.WithMessagef(ErrSavepointNotFound, "savepoint id %d", id)
+	}
+
+	found, err := m.undoUntil(func(lr LogRecord) bool {
+		sp, ok := lr.(SavepointLogRecord)
+
+		return ok && sp.ID() == id
+	})
+	if err != nil {
+		return errors.WithMessage(ErrOpError, err.Error())
+	}
+
+	if !found {
+		return errors.WithMessagef(ErrSavepointNotFound, "savepoint id %d", id)
+	}
+
+	// Точки сохранения после отката больше не действуют
+	for spID, lsn := range m.savepoints {
+		if lsn > spLSN {
+			delete(m.savepoints, spID)
+		}
+	}
+
+	return nil
+}
+
 func (m *Manager) doRollback() error {
+	_, err := m.undoUntil(func(LogRecord) bool {
+		return false
+	})
+
+	return err
+}
+
+// undoUntil откатывает записи транзакции от конца журнала до записи, на которой stop вернет true, или до начала транзакции.
+// Возвращает true, если остановились на stop
+func (m *Manager) undoUntil(stop func(lr LogRecord) bool) (bool, error) {
 	txnum := m.trx.TXNum()
 
 	it, err := m.lm.Iterator()
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	for it.HasNext() {
 		raw, err := it.Next()
 		if err != nil {
-			return err
+			return false, err
 		}
 
 		lr, err := NewLogRecordFromBytes(raw)
 		if err != nil {
-			return err
+			return false, err
 		}
 
 		switch {
-		case lr.TXNum() != txnum:
+		case lr.TXNum() != txnum || lr.Op() == CheckpointOp:
 			continue
+		case stop(lr):
+			return true, nil
 		case lr.Op() == StartOp:
-			break
+			return false, nil
 		default:
 			if err := lr.Undo(m.trx); err != nil {
-				return err
+				return false, err
 			}
 		}
 	}
 
-	return nil
+	return false, nil
 }
 
 func (m *Manager) doRecover() error {
@@ -123,7 +123,7 @@ func (ts *RecoveryManagerTestSuite) TestSetInt64_LogOk() {
 		newValue int64  = 837509348275
 	)
 
-	buf.Content().SetInt64(offset, oldValue)
+	ts.Require().NoError(buf.Content().SetInt64(offset, oldValue))
 
 	lsn, err := sut.SetInt64(buf, offset, newValue)
 	require.NoError(t, err)
@@ -162,7 +162,7 @@ func (ts *RecoveryManagerTestSuite) TestSetString_LogOk() {
 		newValue string = "837509348275"
 	)
 
-	buf.Content().SetString(offset, oldValue)
+	ts.Require().NoError(buf.Content().SetString(offset, oldValue))
 
 	lsn, err := sut.SetString(buf, offset, newValue)
 	require.NoError(t, err)
@@ -180,6 +180,38 @@ func (ts *RecoveryManagerTestSuite) TestSetString_LogOk() {
 	)
 }
 
+func (ts *RecoveryManagerTestSuite) TestPutBytes_LogOk() {
+	t := ts.T()
+
+	mc := minimock.NewController(t)
+	sut, _, wal, bm := ts.newRecoveryManager(mc, nil, true)
+
+	defer wal.StorageManager().Close()
+
+	block, err := bm.StorageManager().Append(testDataFile)
+	require.NoError(t, err)
+
+	buf, err := bm.Pin(block)
+	require.NoError(t, err)
+
+	var offset uint32 = 25
+
+	ts.Require().NoError(buf.Content().PutBytes(offset, []byte{0xca, 0xfe}))
+
+	lsn, err := sut.PutBytes(buf, offset, []byte{0x01, 0x02, 0x03})
+	require.NoError(t, err)
+
+	require.EqualValues(t, 2, lsn)
+
+	assert.Equal(t,
+		[]string{
+			"<START, 56743>",
+			"<PUT_BYTES, 56743, block: [file data.dat, block 0], offset: 25, value: cafe00>",
+		},
+		ts.fetchWAL(t, wal),
+	)
+}
+
 func (ts *RecoveryManagerTestSuite) TestRollback_LogOk() {
 	t := ts.T()
 
@@ -223,7 +255,7 @@ func (ts *RecoveryManagerTestSuite) TestRollback_RollbackDataOk() {
 	value3 := int64(3000333)
 
 	trx.SetInt64Mock.Inspect(func(block types.Block, offset uint32, value int64, okToLog bool) {
-		buf.Content().SetInt64(offset, value)
+		ts.Require().NoError(buf.Content().SetInt64(offset, value))
 	}).Return(nil)
 
 	logRecords := []recovery.LogRecord{
@@ -239,14 +271,14 @@ func (ts *RecoveryManagerTestSuite) TestRollback_RollbackDataOk() {
 		_, _ = wal.Append(lr.MarshalBytes())
 	}
 
-	buf.Content().SetInt64(offset, value0)
+	ts.Require().NoError(buf.Content().SetInt64(offset, value0))
 
 	require.NoError(t, sut.Rollback())
 
 	// tx2 реально в базе не фиксировалась, поэтому мы ожидаем,
 	// что в базе будет value1 от tx1 как результат отката tx1.
 	// Начальное value0 в базе быть не должно
-	assert.Equal(t, value1, buf.Content().GetInt64(offset))
+	assert.Equal(t, value1, testutil.Must(buf.Content().GetInt64(offset)))
 
 	log := ts.fetchWAL(t, wal)
 	assert.Equal(t,
@@ -294,7 +326,7 @@ func (ts *RecoveryManagerTestSuite) TestRecovery_RecoveryDataOk() {
 	require.NoError(t, err)
 
 	trx.SetInt64Mock.Inspect(func(block types.Block, offset uint32, value int64, okToLog bool) {
-		buf.Content().SetInt64(offset, value)
+		ts.Require().NoError(buf.Content().SetInt64(offset, value))
 	}).Return(nil)
 
 	offset := uint32(40)
@@ -326,12 +358,12 @@ func (ts *RecoveryManagerTestSuite) TestRecovery_RecoveryDataOk() {
 		require.NoError(t, err)
 	}
 
-	buf.Content().SetInt64(offset, 100)
+	ts.Require().NoError(buf.Content().SetInt64(offset, 100))
 
 	require.NoError(t, sut.Recover())
 
 	// Должно вернуться значение из trxIDS[3]
-	assert.EqualValues(t, -4345, buf.Content().GetInt64(offset))
+	assert.EqualValues(t, -4345, testutil.Must(buf.Content().GetInt64(offset)))
 
 	log := ts.fetchWAL(t, wal)
 	assert.Equal(t,
@@ -341,3 +373,90 @@ func (ts *RecoveryManagerTestSuite) TestRecovery_RecoveryDataOk() {
 		log[len(log)-1:],
 	)
 }
+
+func (ts *RecoveryManagerTestSuite) TestRollbackToSavepoint_RollbackDataOk() {
+	t := ts.T()
+
+	mc := minimock.NewController(t)
+	sut, trx, wal, bm := ts.newRecoveryManager(mc, nil, false)
+
+	defer wal.StorageManager().Close()
+
+	block, err := bm.StorageManager().Append(testDataFile)
+	require.NoError(t, err)
+
+	buf, err := bm.Pin(block)
+	require.NoError(t, err)
+
+	tx1id := trx.TXNum()
+	offset := uint32(25)
+	value1 := int64(1000333)
+	value2 := int64(2000333)
+	value3 := int64(3000333)
+
+	trx.SetInt64Mock.Inspect(func(block types.Block, offset uint32, value int64, okToLog bool) {
+		ts.Require().NoError(buf.Content().SetInt64(offset, value))
+	}).Return(nil)
+
+	_, _ = wal.Append(recovery.NewSetInt64LogRecord(tx1id, block, offset, value1).MarshalBytes())
+	require.NoError(t, sut.Savepoint(1, "sp1"))
+	_, _ = wal.Append(recovery.NewSetInt64LogRecord(tx1id, block, offset, value2).MarshalBytes())
+	require.NoError(t, sut.Savepoint(2, "sp2"))
+	_, _ = wal.Append(recovery.NewSetInt64LogRecord(tx1id, block, offset, value3).MarshalBytes())
+
+	ts.Require().NoError(buf.Content().SetInt64(offset, 0))
+
+	require.NoError(t, sut.RollbackToSavepoint(2))
+	assert.Equal(t, value3, testutil.Must(buf.Content().GetInt64(offset)))
+
+	require.NoError(t, sut.RollbackToSavepoint(1))
+	assert.Equal(t, value2, testutil.Must(buf.Content().GetInt64(offset)))
+
+	// Точки сохранения нет или она пропала после отката к более ранней — изменения транзакции остаются на месте
+	require.ErrorIs(t, sut.RollbackToSavepoint(3), recovery.ErrSavepointNotFound)
+	assert.Equal(t, value2, testutil.Must(buf.Content().GetInt64(offset)))
+
+	require.ErrorIs(t, sut.RollbackToSavepoint(2), recovery.ErrSavepointNotFound)
+	assert.Equal(t, value2, testutil.Must(buf.Content().GetInt64(offset)))
+
+	log := ts.fetchWAL(t, wal)
+	assert.Equal(t,
+		[]string{
+			`<SAVEPOINT, 56743, id: 1, name: "sp1">`,
+		},
+		log[2:3],
+	)
+}
+
+func (ts *RecoveryManagerTestSuite) TestRollback_StopsAtStart() {
+	t := ts.T()
+
+	mc := minimock.NewController(t)
+	sut, trx, wal, bm := ts.newRecoveryManager(mc, nil, false)
+
+	defer wal.StorageManager().Close()
+
+	block, err := bm.StorageManager().Append(testDataFile)
+	require.NoError(t, err)
+
+	buf, err := bm.Pin(block)
+	require.NoError(t, err)
+
+	txid := trx.TXNum()
+	offset := uint32(25)
+
+	trx.SetInt64Mock.Inspect(func(block types.Block, offset uint32, value int64, okToLog bool) {
+		ts.Require().NoError(buf.Content().SetInt64(offset, value))
+	}).Return(nil)
+
+	// Транзакция с тем же номером из прошлого запуска базы уже зафиксирована,
+	// ее изменения откатывать нельзя
+	_, _ = wal.Append(recovery.NewSetInt64LogRecord(txid, block, offset, 100).MarshalBytes())
+	_, _ = wal.Append(recovery.NewStartLogRecord(txid).MarshalBytes())
+	_, _ = wal.Append(recovery.NewSetInt64LogRecord(txid, block, offset, 200).MarshalBytes())
+
+	ts.Require().NoError(buf.Content().SetInt64(offset, 300))
+
+	require.NoError(t, sut.Rollback())
+	assert.EqualValues(t, 200, testutil.Must(buf.Content().GetInt64(offset)))
+}
@@ -24,6 +24,18 @@ type TrxIntMock struct {
 	beforePinCounter uint64
 	PinMock          mTrxIntMockPin
 
+	funcPutBytes          func(block types.Block, offset uint32, value []byte, okToLog bool) (err error)
+	inspectFuncPutBytes   func(block types.Block, offset uint32, value []byte, okToLog bool)
+	afterPutBytesCounter  uint64
+	beforePutBytesCounter uint64
+	PutBytesMock          mTrxIntMockPutBytes
+
+	funcResetCompression          func(filename string) (err error)
+	inspectFuncResetCompression   func(filename string)
+	afterResetCompressionCounter  uint64
+	beforeResetCompressionCounter uint64
+	ResetCompressionMock          mTrxIntMockResetCompression
+
 	funcSetInt64          func(block types.Block, offset uint32, value int64, okToLog bool) (err error)
 	inspectFuncSetInt64   func(block types.Block, offset uint32, value int64, okToLog bool)
 	afterSetInt64Counter  uint64
@@ -66,6 +78,12 @@ func NewTrxIntMock(t minimock.Tester) *TrxIntMock {
 	m.PinMock = mTrxIntMockPin{mock: m}
 	m.PinMock.callArgs = []*TrxIntMockPinParams{}
 
+	m.PutBytesMock = mTrxIntMockPutBytes{mock: m}
+	m.PutBytesMock.callArgs = []*TrxIntMockPutBytesParams{}
+
+	m.ResetCompressionMock = mTrxIntMockResetCompression{mock: m}
+	m.ResetCompressionMock.callArgs = []*TrxIntMockResetCompressionParams{}
+
 	m.SetInt64Mock = mTrxIntMockSetInt64{mock: m}
 	m.SetInt64Mock.callArgs = []*TrxIntMockSetInt64Params{}
 
@@ -377,6 +395,674 @@ func (m *TrxIntMock) MinimockPinInspect() {
 	}
 }
 
+type mTrxIntMockPutBytes struct {
+	optional           bool
+	mock               *TrxIntMock
+	defaultExpectation *TrxIntMockPutBytesExpectation
+	expectations       []*TrxIntMockPutBytesExpectation
+
+	callArgs []*TrxIntMockPutBytesParams
+	mutex    sync.RWMutex
+
+	expectedInvocations uint64
+}
+
+// TrxIntMockPutBytesExpectation specifies expectation struct of the trxInt.PutBytes
+type TrxIntMockPutBytesExpectation struct {
+	mock      *TrxIntMock
+	params    *TrxIntMockPutBytesParams
+	paramPtrs *TrxIntMockPutBytesParamPtrs
+	results   *TrxIntMockPutBytesResults
+	Counter   uint64
+}
+
+// TrxIntMockPutBytesParams contains parameters of the trxInt.PutBytes
+type TrxIntMockPutBytesParams struct {
+	block   types.Block
+	offset  uint32
+	value   []byte
+	okToLog bool
+}
+
+// TrxIntMockPutBytesParamPtrs contains pointers to parameters of the trxInt.PutBytes
+type TrxIntMockPutBytesParamPtrs struct {
+	block   *types.Block
+	offset  *uint32
+	value   *[]byte
+	okToLog *bool
+}
+
+// TrxIntMockPutBytesResults contains results of the trxInt.PutBytes
+type TrxIntMockPutBytesResults struct {
+	err error
+}
+
+// Marks this method to be optional. The default behavior of any method with Return() is '1 or more', meaning
+// the test will fail minimock's automatic final call check if the mocked method was not called at least once.
+// Optional() makes method check to work in '0 or more' mode.
+// It is NOT RECOMMENDED to use this option unless you really need it, as default behaviour helps to
+// catch the problems when the expected method call is totally skipped during test run.
+func (mmPutBytes *mTrxIntMockPutBytes) Optional() *mTrxIntMockPutBytes {
+	mmPutBytes.optional = true
+	return mmPutBytes
+}
+
+// Expect sets up expected params for trxInt.PutBytes
+func (mmPutBytes *mTrxIntMockPutBytes) Expect(block types.Block, offset uint32, value []byte, okToLog bool) *mTrxIntMockPutBytes {
+	if mmPutBytes.mock.funcPutBytes != nil {
+		mmPutBytes.mock.t.Fatalf("TrxIntMock.PutBytes mock is already set by Set")
+	}
+
+	if mmPutBytes.defaultExpectation == nil {
+		mmPutBytes.defaultExpectation = &TrxIntMockPutBytesExpectation{}
+	}
+
+	if mmPutBytes.defaultExpectation.paramPtrs != nil {
+		mmPutBytes.mock.t.Fatalf("TrxIntMock.PutBytes mock is already set by ExpectParams functions")
+	}
+
+	mmPutBytes.defaultExpectation.params = &TrxIntMockPutBytesParams{block, offset, value, okToLog}
+	for _, e := range mmPutBytes.expectations {
+		if minimock.Equal(e.params, mmPutBytes.defaultExpectation.params) {
+			mmPutBytes.mock.t.Fatalf("Expectation set by When has same params: %#v", *mmPutBytes.defaultExpectation.params)
+		}
+	}
+
+	return mmPutBytes
+}
+
+// ExpectBlockParam1 sets up expected param block for trxInt.PutBytes
+func (mmPutBytes *mTrxIntMockPutBytes) ExpectBlockParam1(block types.Block) *mTrxIntMockPutBytes {
+	if mmPutBytes.mock.funcPutBytes != nil {
+		mmPutBytes.mock.t.Fatalf("TrxIntMock.PutBytes mock is already set by Set")
+	}
+
+	if mmPutBytes.defaultExpectation == nil {
+		mmPutBytes.defaultExpectation = &TrxIntMockPutBytesExpectation{}
+	}
+
+	if mmPutBytes.defaultExpectation.params != nil {
+		mmPutBytes.mock.t.Fatalf("TrxIntMock.PutBytes mock is already set by Expect")
+	}
+
+	if mmPutBytes.defaultExpectation.paramPtrs == nil {
+		mmPutBytes.defaultExpectation.paramPtrs = &TrxIntMockPutBytesParamPtrs{}
+	}
+	mmPutBytes.defaultExpectation.paramPtrs.block = &block
+
+	return mmPutBytes
+}
+
+// ExpectOffsetParam2 sets up expected param offset for trxInt.PutBytes
+func (mmPutBytes *mTrxIntMockPutBytes) ExpectOffsetParam2(offset uint32) *mTrxIntMockPutBytes {
+	if mmPutBytes.mock.funcPutBytes != nil {
+		mmPutBytes.mock.t.Fatalf("TrxIntMock.PutBytes mock is already set by Set")
+	}
+
+	if mmPutBytes.defaultExpectation == nil {
+		mmPutBytes.defaultExpectation = &TrxIntMockPutBytesExpectation{}
+	}
+
+	if mmPutBytes.defaultExpectation.params != nil {
+		mmPutBytes.mock.t.Fatalf("TrxIntMock.PutBytes mock is already set by Expect")
+	}
+
+	if mmPutBytes.defaultExpectation.paramPtrs == nil {
+		mmPutBytes.defaultExpectation.paramPtrs = &TrxIntMockPutBytesParamPtrs{}
+	}
+	mmPutBytes.defaultExpectation.paramPtrs.offset = &offset
+
+	return mmPutBytes
+}
+
+// ExpectValueParam3 sets up expected param value for trxInt.PutBytes
+func (mmPutBytes *mTrxIntMockPutBytes) ExpectValueParam3(value []byte) *mTrxIntMockPutBytes {
+	if mmPutBytes.mock.funcPutBytes != nil {
+		mmPutBytes.mock.t.Fatalf("TrxIntMock.PutBytes mock is already set by Set")
+	}
+
+	if mmPutBytes.defaultExpectation == nil {
+		mmPutBytes.defaultExpectation = &TrxIntMockPutBytesExpectation{}
+	}
+
+	if mmPutBytes.defaultExpectation.params != nil {
+		mmPutBytes.mock.t.Fatalf("TrxIntMock.PutBytes mock is already set by Expect")
+	}
+
+	if mmPutBytes.defaultExpectation.paramPtrs == nil {
+		mmPutBytes.defaultExpectation.paramPtrs = &TrxIntMockPutBytesParamPtrs{}
+	}
+	mmPutBytes.defaultExpectation.paramPtrs.value = &value
+
+	return mmPutBytes
+}
+
+// ExpectOkToLogParam4 sets up expected param okToLog for trxInt.PutBytes
+func (mmPutBytes *mTrxIntMockPutBytes) ExpectOkToLogParam4(okToLog bool) *mTrxIntMockPutBytes {
+	if mmPutBytes.mock.funcPutBytes != nil {
+		mmPutBytes.mock.t.Fatalf("TrxIntMock.PutBytes mock is already set by Set")
+	}
+
+	if mmPutBytes.defaultExpectation == nil {
+		mmPutBytes.defaultExpectation = &TrxIntMockPutBytesExpectation{}
+	}
+
+	if mmPutBytes.defaultExpectation.params != nil {
+		mmPutBytes.mock.t.Fatalf("TrxIntMock.PutBytes mock is already set by Expect")
+	}
+
+	if mmPutBytes.defaultExpectation.paramPtrs == nil {
+		mmPutBytes.defaultExpectation.paramPtrs = &TrxIntMockPutBytesParamPtrs{}
+	}
+	mmPutBytes.defaultExpectation.paramPtrs.okToLog = &okToLog
+
+	return mmPutBytes
+}
+
+// Inspect accepts an inspector function that has same arguments as the trxInt.PutBytes
+func (mmPutBytes *mTrxIntMockPutBytes) Inspect(f func(block types.Block, offset uint32, value []byte, okToLog bool)) *mTrxIntMockPutBytes {
+	if mmPutBytes.mock.inspectFuncPutBytes != nil {
+		mmPutBytes.mock.t.Fatalf("Inspect function is already set for TrxIntMock.PutBytes")
+	}
+
+	mmPutBytes.mock.inspectFuncPutBytes = f
+
+	return mmPutBytes
+}
+
+// Return sets up results that will be returned by trxInt.PutBytes
+func (mmPutBytes *mTrxIntMockPutBytes) Return(err error) *TrxIntMock {
+	if mmPutBytes.mock.funcPutBytes != nil {
+		mmPutBytes.mock.t.Fatalf("TrxIntMock.PutBytes mock is already set by Set")
+	}
+
+	if mmPutBytes.defaultExpectation == nil {
+		mmPutBytes.defaultExpectation = &TrxIntMockPutBytesExpectation{mock: mmPutBytes.mock}
+	}
+	mmPutBytes.defaultExpectation.results = &TrxIntMockPutBytesResults{err}
+	return mmPutBytes.mock
+}
+
+// Set uses given function f to mock the trxInt.PutBytes method
+func (mmPutBytes *mTrxIntMockPutBytes) Set(f func(block types.Block, offset uint32, value []byte, okToLog bool) (err error)) *TrxIntMock {
+	if mmPutBytes.defaultExpectation != nil {
+		mmPutBytes.mock.t.Fatalf("Default expectation is already set for the trxInt.PutBytes method")
+	}
+
+	if len(mmPutBytes.expectations) > 0 {
+		mmPutBytes.mock.t.Fatalf("Some expectations are already set for the trxInt.PutBytes method")
+	}
+
+	mmPutBytes.mock.funcPutBytes = f
+	return mmPutBytes.mock
+}
+
+// When sets expectation for the trxInt.PutBytes which will trigger the result defined by the following
+// Then helper
+func (mmPutBytes *mTrxIntMockPutBytes) When(block types.Block, offset uint32, value []byte, okToLog bool) *TrxIntMockPutBytesExpectation {
+	if mmPutBytes.mock.funcPutBytes != nil {
+		mmPutBytes.mock.t.Fatalf("TrxIntMock.PutBytes mock is already set by Set")
+	}
+
+	expectation := &TrxIntMockPutBytesExpectation{
+		mock:   mmPutBytes.mock,
+		params: &TrxIntMockPutBytesParams{block, offset, value, okToLog},
+	}
+	mmPutBytes.expectations = append(mmPutBytes.expectations, expectation)
+	return expectation
+}
+
+// Then sets up trxInt.PutBytes return parameters for the expectation previously defined by the When method
+func (e *TrxIntMockPutBytesExpectation) Then(err error) *TrxIntMock {
+	e.results = &TrxIntMockPutBytesResults{err}
+	return e.mock
+}
+
+// Times sets number of times trxInt.PutBytes should be invoked
+func (mmPutBytes *mTrxIntMockPutBytes) Times(n uint64) *mTrxIntMockPutBytes {
+	if n == 0 {
+		mmPutBytes.mock.t.Fatalf("Times of TrxIntMock.PutBytes mock can not be zero")
+	}
+	mm_atomic.StoreUint64(&mmPutBytes.expectedInvocations, n)
+	return mmPutBytes
+}
+
+func (mmPutBytes *mTrxIntMockPutBytes) invocationsDone() bool {
+	if len(mmPutBytes.expectations) == 0 && mmPutBytes.defaultExpectation == nil && mmPutBytes.mock.funcPutBytes == nil {
+		return true
+	}
+
+	totalInvocations := mm_atomic.LoadUint64(&mmPutBytes.mock.afterPutBytesCounter)
+	expectedInvocations := mm_atomic.LoadUint64(&mmPutBytes.expectedInvocations)
+
+	return totalInvocations > 0 && (expectedInvocations == 0 || expectedInvocations == totalInvocations)
+}
+
+// PutBytes implements trxInt
+func (mmPutBytes *TrxIntMock) PutBytes(block types.Block, offset uint32, value []byte, okToLog bool) (err error) {
+	mm_atomic.AddUint64(&mmPutBytes.beforePutBytesCounter, 1)
+	defer mm_atomic.AddUint64(&mmPutBytes.afterPutBytesCounter, 1)
+
+	if mmPutBytes.inspectFuncPutBytes != nil {
+		mmPutBytes.inspectFuncPutBytes(block, offset, value, okToLog)
+	}
+
+	mm_params := TrxIntMockPutBytesParams{block, offset, value, okToLog}
+
+	// Record call args
+	mmPutBytes.PutBytesMock.mutex.Lock()
+	mmPutBytes.PutBytesMock.callArgs = append(mmPutBytes.PutBytesMock.callArgs, &mm_params)
+	mmPutBytes.PutBytesMock.mutex.Unlock()
+
+	for _, e := range mmPutBytes.PutBytesMock.expectations {
+		if minimock.Equal(*e.params, mm_params) {
+			mm_atomic.AddUint64(&e.Counter, 1)
+			return e.results.err
+		}
+	}
+
+	if mmPutBytes.PutBytesMock.defaultExpectation != nil {
+		mm_atomic.AddUint64(&mmPutBytes.PutBytesMock.defaultExpectation.Counter, 1)
+		mm_want := mmPutBytes.PutBytesMock.defaultExpectation.params
+		mm_want_ptrs := mmPutBytes.PutBytesMock.defaultExpectation.paramPtrs
+
+		mm_got := TrxIntMockPutBytesParams{block, offset, value, okToLog}
+
+		if mm_want_ptrs != nil {
+
+			if mm_want_ptrs.block != nil && !minimock.Equal(*mm_want_ptrs.block, mm_got.block) {
+				mmPutBytes.t.Errorf("TrxIntMock.PutBytes got unexpected parameter block, want: %#v, got: %#v%s\n", *mm_want_ptrs.block, mm_got.block, minimock.Diff(*mm_want_ptrs.block, mm_got.block))
+			}
+
+			if mm_want_ptrs.offset != nil && !minimock.Equal(*mm_want_ptrs.offset, mm_got.offset) {
+				mmPutBytes.t.Errorf("TrxIntMock.PutBytes got unexpected parameter offset, want: %#v, got: %#v%s\n", *mm_want_ptrs.offset, mm_got.offset, minimock.Diff(*mm_want_ptrs.offset, mm_got.offset))
+			}
+
+			if mm_want_ptrs.value != nil && !minimock.Equal(*mm_want_ptrs.value, mm_got.value) {
+				mmPutBytes.t.Errorf("TrxIntMock.PutBytes got unexpected parameter value, want: %#v, got: %#v%s\n", *mm_want_ptrs.value, mm_got.value, minimock.Diff(*mm_want_ptrs.value, mm_got.value))
+			}
+
+			if mm_want_ptrs.okToLog != nil && !minimock.Equal(*mm_want_ptrs.okToLog, mm_got.okToLog) {
+				mmPutBytes.t.Errorf("TrxIntMock.PutBytes got unexpected parameter okToLog, want: %#v, got: %#v%s\n", *mm_want_ptrs.okToLog, mm_got.okToLog, minimock.Diff(*mm_want_ptrs.okToLog, mm_got.okToLog))
+			}
+
+		} else if mm_want != nil && !minimock.Equal(*mm_want, mm_got) {
+			mmPutBytes.t.Errorf("TrxIntMock.PutBytes got unexpected parameters, want: %#v, got: %#v%s\n", *mm_want, mm_got, minimock.Diff(*mm_want, mm_got))
+		}
+
+		mm_results := mmPutBytes.PutBytesMock.defaultExpectation.results
+		if mm_results == nil {
+			mmPutBytes.t.Fatal("No results are set for the TrxIntMock.PutBytes")
+		}
+		return (*mm_results).err
+	}
+	if mmPutBytes.funcPutBytes != nil {
+		return mmPutBytes.funcPutBytes(block, offset, value, okToLog)
+	}
+	mmPutBytes.t.Fatalf("Unexpected call to TrxIntMock.PutBytes. %v %v %v %v", block, offset, value, okToLog)
+	return
+}
+
+// PutBytesAfterCounter returns a count of finished TrxIntMock.PutBytes invocations
+func (mmPutBytes *TrxIntMock) PutBytesAfterCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmPutBytes.afterPutBytesCounter)
+}
+
+// PutBytesBeforeCounter returns a count of TrxIntMock.PutBytes invocations
+func (mmPutBytes *TrxIntMock) PutBytesBeforeCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmPutBytes.beforePutBytesCounter)
+}
+
+// Calls returns a list of arguments used in each call to TrxIntMock.PutBytes.
+// The list is in the same order as the calls were made (i.e. recent calls have a higher index)
+func (mmPutBytes *mTrxIntMockPutBytes) Calls() []*TrxIntMockPutBytesParams {
+	mmPutBytes.mutex.RLock()
+
+	argCopy := make([]*TrxIntMockPutBytesParams, len(mmPutBytes.callArgs))
+	copy(argCopy, mmPutBytes.callArgs)
+
+	mmPutBytes.mutex.RUnlock()
+
+	return argCopy
+}
+
+// MinimockPutBytesDone returns true if the count of the PutBytes invocations corresponds
+// the number of defined expectations
+func (m *TrxIntMock) MinimockPutBytesDone() bool {
+	if m.PutBytesMock.optional {
+		// Optional methods provide '0 or more' call count restriction.
+		return true
+	}
+
+	for _, e := range m.PutBytesMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			return false
+		}
+	}
+
+	return m.PutBytesMock.invocationsDone()
+}
+
+// MinimockPutBytesInspect logs each unmet expectation
+func (m *TrxIntMock) MinimockPutBytesInspect() {
+	for _, e := range m.PutBytesMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			m.t.Errorf("Expected call to TrxIntMock.PutBytes with params: %#v", *e.params)
+		}
+	}
+
+	afterPutBytesCounter := mm_atomic.LoadUint64(&m.afterPutBytesCounter)
+	// if default expectation was set then invocations count should be greater than zero
+	if m.PutBytesMock.defaultExpectation != nil && afterPutBytesCounter < 1 {
+		if m.PutBytesMock.defaultExpectation.params == nil {
+			m.t.Error("Expected call to TrxIntMock.PutBytes")
+		} else {
+			m.t.Errorf("Expected call to TrxIntMock.PutBytes with params: %#v", *m.PutBytesMock.defaultExpectation.params)
+		}
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcPutBytes != nil && afterPutBytesCounter < 1 {
+		m.t.Error("Expected call to TrxIntMock.PutBytes")
+	}
+
+	if !m.PutBytesMock.invocationsDone() && afterPutBytesCounter > 0 {
+		m.t.Errorf("Expected %d calls to TrxIntMock.PutBytes but found %d calls",
+			mm_atomic.LoadUint64(&m.PutBytesMock.expectedInvocations), afterPutBytesCounter)
+	}
+}
+
+type mTrxIntMockResetCompression struct {
+	optional           bool
+	mock               *TrxIntMock
+	defaultExpectation *TrxIntMockResetCompressionExpectation
+	expectations       []*TrxIntMockResetCompressionExpectation
+
+	callArgs []*TrxIntMockResetCompressionParams
+	mutex    sync.RWMutex
+
+	expectedInvocations uint64
+}
+
+// TrxIntMockResetCompressionExpectation specifies expectation struct of the trxInt.ResetCompression
+type TrxIntMockResetCompressionExpectation struct {
+	mock      *TrxIntMock
+	params    *TrxIntMockResetCompressionParams
+	paramPtrs *TrxIntMockResetCompressionParamPtrs
+	results   *TrxIntMockResetCompressionResults
+	Counter   uint64
+}
+
+// TrxIntMockResetCompressionParams contains parameters of the trxInt.ResetCompression
+type TrxIntMockResetCompressionParams struct {
+	filename string
+}
+
+// TrxIntMockResetCompressionParamPtrs contains pointers to parameters of the trxInt.ResetCompression
+type TrxIntMockResetCompressionParamPtrs struct {
+	filename *string
+}
+
+// TrxIntMockResetCompressionResults contains results of the trxInt.ResetCompression
+type TrxIntMockResetCompressionResults struct {
+	err error
+}
+
+// Marks this method to be optional. The default behavior of any method with Return() is '1 or more', meaning
+// the test will fail minimock's automatic final call check if the mocked method was not called at least once.
+// Optional() makes method check to work in '0 or more' mode.
+// It is NOT RECOMMENDED to use this option unless you really need it, as default behaviour helps to
+// catch the problems when the expected method call is totally skipped during test run.
+func (mmResetCompression *mTrxIntMockResetCompression) Optional() *mTrxIntMockResetCompression {
+	mmResetCompression.optional = true
+	return mmResetCompression
+}
+
+// Expect sets up expected params for trxInt.ResetCompression
+func (mmResetCompression *mTrxIntMockResetCompression) Expect(filename string) *mTrxIntMockResetCompression {
+	if mmResetCompression.mock.funcResetCompression != nil {
+		mmResetCompression.mock.t.Fatalf("TrxIntMock.ResetCompression mock is already set by Set")
+	}
+
+	if mmResetCompression.defaultExpectation == nil {
+		mmResetCompression.defaultExpectation = &TrxIntMockResetCompressionExpectation{}
+	}
+
+	if mmResetCompression.defaultExpectation.paramPtrs != nil {
+		mmResetCompression.mock.t.Fatalf("TrxIntMock.ResetCompression mock is already set by ExpectParams functions")
+	}
+
+	mmResetCompression.defaultExpectation.params = &TrxIntMockResetCompressionParams{filename}
+	for _, e := range mmResetCompression.expectations {
+		if minimock.Equal(e.params, mmResetCompression.defaultExpectation.params) {
+			mmResetCompression.mock.t.Fatalf("Expectation set by When has same params: %#v", *mmResetCompression.defaultExpectation.params)
+		}
+	}
+
+	return mmResetCompression
+}
+
+// ExpectFilenameParam1 sets up expected param filename for trxInt.ResetCompression
+func (mmResetCompression *mTrxIntMockResetCompression) ExpectFilenameParam1(filename string) *mTrxIntMockResetCompression {
+	if mmResetCompression.mock.funcResetCompression != nil {
+		mmResetCompression.mock.t.Fatalf("TrxIntMock.ResetCompression mock is already set by Set")
+	}
+
+	if mmResetCompression.defaultExpectation == nil {
+		mmResetCompression.defaultExpectation = &TrxIntMockResetCompressionExpectation{}
+	}
+
+	if mmResetCompression.defaultExpectation.params != nil {
+		mmResetCompression.mock.t.Fatalf("TrxIntMock.ResetCompression mock is already set by Expect")
+	}
+
+	if mmResetCompression.defaultExpectation.paramPtrs == nil {
+		mmResetCompression.defaultExpectation.paramPtrs = &TrxIntMockResetCompressionParamPtrs{}
+	}
+	mmResetCompression.defaultExpectation.paramPtrs.filename = &filename
+
+	return mmResetCompression
+}
+
+// Inspect accepts an inspector function that has same arguments as the trxInt.ResetCompression
+func (mmResetCompression *mTrxIntMockResetCompression) Inspect(f func(filename string)) *mTrxIntMockResetCompression {
+	if mmResetCompression.mock.inspectFuncResetCompression != nil {
+		mmResetCompression.mock.t.Fatalf("Inspect function is already set for TrxIntMock.ResetCompression")
+	}
+
+	mmResetCompression.mock.inspectFuncResetCompression = f
+
+	return mmResetCompression
+}
+
+// Return sets up results that will be returned by trxInt.ResetCompression
+func (mmResetCompression *mTrxIntMockResetCompression) Return(err error) *TrxIntMock {
+	if mmResetCompression.mock.funcResetCompression != nil {
+		mmResetCompression.mock.t.Fatalf("TrxIntMock.ResetCompression mock is already set by Set")
+	}
+
+	if mmResetCompression.defaultExpectation == nil {
+		mmResetCompression.defaultExpectation = &TrxIntMockResetCompressionExpectation{mock: mmResetCompression.mock}
+	}
+	mmResetCompression.defaultExpectation.results = &TrxIntMockResetCompressionResults{err}
+	return mmResetCompression.mock
+}
+
+// Set uses given function f to mock the trxInt.ResetCompression method
+func (mmResetCompression *mTrxIntMockResetCompression) Set(f func(filename string) (err error)) *TrxIntMock {
+	if mmResetCompression.defaultExpectation != nil {
+		mmResetCompression.mock.t.Fatalf("Default expectation is already set for the trxInt.ResetCompression method")
+	}
+
+	if len(mmResetCompression.expectations) > 0 {
+		mmResetCompression.mock.t.Fatalf("Some expectations are already set for the trxInt.ResetCompression method")
+	}
+
+	mmResetCompression.mock.funcResetCompression = f
+	return mmResetCompression.mock
+}
+
+// When sets expectation for the trxInt.ResetCompression which will trigger the result defined by the following
+// Then helper
+func (mmResetCompression *mTrxIntMockResetCompression) When(filename string) *TrxIntMockResetCompressionExpectation {
+	if mmResetCompression.mock.funcResetCompression != nil {
+		mmResetCompression.mock.t.Fatalf("TrxIntMock.ResetCompression mock is already set by Set")
+	}
+
+	expectation := &TrxIntMockResetCompressionExpectation{
+		mock:   mmResetCompression.mock,
+		params: &TrxIntMockResetCompressionParams{filename},
+	}
+	mmResetCompression.expectations = append(mmResetCompression.expectations, expectation)
+	return expectation
+}
+
+// Then sets up trxInt.ResetCompression return parameters for the expectation previously defined by the When method
+func (e *TrxIntMockResetCompressionExpectation) Then(err error) *TrxIntMock {
+	e.results = &TrxIntMockResetCompressionResults{err}
+	return e.mock
+}
+
+// Times sets number of times trxInt.ResetCompression should be invoked
+func (mmResetCompression *mTrxIntMockResetCompression) Times(n uint64) *mTrxIntMockResetCompression {
+	if n == 0 {
+		mmResetCompression.mock.t.Fatalf("Times of TrxIntMock.ResetCompression mock can not be zero")
+	}
+	mm_atomic.StoreUint64(&mmResetCompression.expectedInvocations, n)
+	return mmResetCompression
+}
+
+func (mmResetCompression *mTrxIntMockResetCompression) invocationsDone() bool {
+	if len(mmResetCompression.expectations) == 0 && mmResetCompression.defaultExpectation == nil && mmResetCompression.mock.funcResetCompression == nil {
+		return true
+	}
+
+	totalInvocations := mm_atomic.LoadUint64(&mmResetCompression.mock.afterResetCompressionCounter)
+	expectedInvocations := mm_atomic.LoadUint64(&mmResetCompression.expectedInvocations)
+
+	return totalInvocations > 0 && (expectedInvocations == 0 || expectedInvocations == totalInvocations)
+}
+
+// ResetCompression implements trxInt
+func (mmResetCompression *TrxIntMock) ResetCompression(filename string) (err error) {
+	mm_atomic.AddUint64(&mmResetCompression.beforeResetCompressionCounter, 1)
+	defer mm_atomic.AddUint64(&mmResetCompression.afterResetCompressionCounter, 1)
+
+	if mmResetCompression.inspectFuncResetCompression != nil {
+		mmResetCompression.inspectFuncResetCompression(filename)
+	}
+
+	mm_params := TrxIntMockResetCompressionParams{filename}
+
+	// Record call args
+	mmResetCompression.ResetCompressionMock.mutex.Lock()
+	mmResetCompression.ResetCompressionMock.callArgs = append(mmResetCompression.ResetCompressionMock.callArgs, &mm_params)
+	mmResetCompression.ResetCompressionMock.mutex.Unlock()
+
+	for _, e := range mmResetCompression.ResetCompressionMock.expectations {
+		if minimock.Equal(*e.params, mm_params) {
+			mm_atomic.AddUint64(&e.Counter, 1)
+			return e.results.err
+		}
+	}
+
+	if mmResetCompression.ResetCompressionMock.defaultExpectation != nil {
+		mm_atomic.AddUint64(&mmResetCompression.ResetCompressionMock.defaultExpectation.Counter, 1)
+		mm_want := mmResetCompression.ResetCompressionMock.defaultExpectation.params
+		mm_want_ptrs := mmResetCompression.ResetCompressionMock.defaultExpectation.paramPtrs
+
+		mm_got := TrxIntMockResetCompressionParams{filename}
+
+		if mm_want_ptrs != nil {
+
+			if mm_want_ptrs.filename != nil && !minimock.Equal(*mm_want_ptrs.filename, mm_got.filename) {
+				mmResetCompression.t.Errorf("TrxIntMock.ResetCompression got unexpected parameter filename, want: %#v, got: %#v%s\n", *mm_want_ptrs.filename, mm_got.filename, minimock.Diff(*mm_want_ptrs.filename, mm_got.filename))
+			}
+
+		} else if mm_want != nil && !minimock.Equal(*mm_want, mm_got) {
+			mmResetCompression.t.Errorf("TrxIntMock.ResetCompression got unexpected parameters, want: %#v, got: %#v%s\n", *mm_want, mm_got, minimock.Diff(*mm_want, mm_got))
+		}
+
+		mm_results := mmResetCompression.ResetCompressionMock.defaultExpectation.results
+		if mm_results == nil {
+			mmResetCompression.t.Fatal("No results are set for the TrxIntMock.ResetCompression")
+		}
+		return (*mm_results).err
+	}
+	if mmResetCompression.funcResetCompression != nil {
+		return mmResetCompression.funcResetCompression(filename)
+	}
+	mmResetCompression.t.Fatalf("Unexpected call to TrxIntMock.ResetCompression. %v", filename)
+	return
+}
+
+// ResetCompressionAfterCounter returns a count of finished TrxIntMock.ResetCompression invocations
+func (mmResetCompression *TrxIntMock) ResetCompressionAfterCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmResetCompression.afterResetCompressionCounter)
+}
+
+// ResetCompressionBeforeCounter returns a count of TrxIntMock.ResetCompression invocations
+func (mmResetCompression *TrxIntMock) ResetCompressionBeforeCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmResetCompression.beforeResetCompressionCounter)
+}
+
+// Calls returns a list of arguments used in each call to TrxIntMock.ResetCompression.
+// The list is in the same order as the calls were made (i.e. recent calls have a higher index)
+func (mmResetCompression *mTrxIntMockResetCompression) Calls() []*TrxIntMockResetCompressionParams {
+	mmResetCompression.mutex.RLock()
+
+	argCopy := make([]*TrxIntMockResetCompressionParams, len(mmResetCompression.callArgs))
+	copy(argCopy, mmResetCompression.callArgs)
+
+	mmResetCompression.mutex.RUnlock()
+
+	return argCopy
+}
+
+// MinimockResetCompressionDone returns true if the count of the ResetCompression invocations corresponds
+// the number of defined expectations
+func (m *TrxIntMock) MinimockResetCompressionDone() bool {
+	if m.ResetCompressionMock.optional {
+		// Optional methods provide '0 or more' call count restriction.
+		return true
+	}
+
+	for _, e := range m.ResetCompressionMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			return false
+		}
+	}
+
+	return m.ResetCompressionMock.invocationsDone()
+}
+
+// MinimockResetCompressionInspect logs each unmet expectation
+func (m *TrxIntMock) MinimockResetCompressionInspect() {
+	for _, e := range m.ResetCompressionMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			m.t.Errorf("Expected call to TrxIntMock.ResetCompression with params: %#v", *e.params)
+		}
+	}
+
+	afterResetCompressionCounter := mm_atomic.LoadUint64(&m.afterResetCompressionCounter)
+	// if default expectation was set then invocations count should be greater than zero
+	if m.ResetCompressionMock.defaultExpectation != nil && afterResetCompressionCounter < 1 {
+		if m.ResetCompressionMock.defaultExpectation.params == nil {
+			m.t.Error("Expected call to TrxIntMock.ResetCompression")
+		} else {
+			m.t.Errorf("Expected call to TrxIntMock.ResetCompression with params: %#v", *m.ResetCompressionMock.defaultExpectation.params)
+		}
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcResetCompression != nil && afterResetCompressionCounter < 1 {
+		m.t.Error("Expected call to TrxIntMock.ResetCompression")
+	}
+
+	if !m.ResetCompressionMock.invocationsDone() && afterResetCompressionCounter > 0 {
+		m.t.Errorf("Expected %d calls to TrxIntMock.ResetCompression but found %d calls",
+			mm_atomic.LoadUint64(&m.ResetCompressionMock.expectedInvocations), afterResetCompressionCounter)
+	}
+}
+
 type mTrxIntMockSetInt64 struct {
 	optional           bool
 	mock               *TrxIntMock
@@ -1954,6 +2640,10 @@ func (m *TrxIntMock) MinimockFinish() {
 		if !m.minimockDone() {
 			m.MinimockPinInspect()
 
+			m.MinimockPutBytesInspect()
+
+			m.MinimockResetCompressionInspect()
+
 			m.MinimockSetInt64Inspect()
 
 			m.MinimockSetInt8Inspect()
@@ -1987,6 +2677,8 @@ func (m *TrxIntMock) minimockDone() bool {
 	done := true
 	return done &&
 		m.MinimockPinDone() &&
+		m.MinimockPutBytesDone() &&
+		m.MinimockResetCompressionDone() &&
 		m.MinimockSetInt64Done() &&
 		m.MinimockSetInt8Done() &&
 		m.MinimockSetStringDone() &&
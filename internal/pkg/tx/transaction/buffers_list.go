@@ -1,6 +1,11 @@
 package transaction
 
 import (
+	"context"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
 	"github.com/unhandled-exception/sophiadb/internal/pkg/buffers"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
 )
@@ -9,13 +14,20 @@ type BufferList struct {
 	bm      buffersManager
 	buffers map[types.Block]*buffers.Buffer
 	pins    map[types.Block]int
+
+	// quota — сколько разных блоков транзакция может закрепить одновременно, 0 — без ограничений
+	quota int
+
+	// pinned — число закреплённых блоков, можно читать из других горутин
+	pinned atomic.Int64
 }
 
-func NewBuffersList(bm buffersManager) *BufferList {
+func NewBuffersList(bm buffersManager, quota int) *BufferList {
 	return &BufferList{
 		bm:      bm,
 		buffers: make(map[types.Block]*buffers.Buffer),
 		pins:    make(map[types.Block]int),
+		quota:   quota,
 	}
 }
 
@@ -25,8 +37,12 @@ func (bl *BufferList) GetBuffer(block types.Block) *buffers.Buffer {
 	return buf
 }
 
-func (bl *BufferList) Pin(block types.Block) error {
-	buf, err := bl.bm.Pin(block)
+func (bl *BufferList) Pin(ctx context.Context, block types.Block) error {
+	if _, ok := bl.buffers[block]; !ok && bl.quota > 0 && len(bl.buffers) >= bl.quota {
+		return errors.WithMessagef(ErrPinQuotaExceeded, "%d buffers", bl.quota)
+	}
+
+	buf, err := bl.bm.PinContext(ctx, block)
 	if err != nil {
 		return err
 	}
@@ -34,6 +50,7 @@ func (bl *BufferList) Pin(block types.Block) error {
 	bl.buffers[block] = buf
 
 	bl.pins[block] = bl.pins[block] + 1
+	bl.pinned.Store(int64(len(bl.buffers)))
 
 	return nil
 }
@@ -50,6 +67,8 @@ func (bl *BufferList) Unpin(block types.Block) {
 	if bl.pins[block] <= 0 {
 		delete(bl.buffers, block)
 	}
+
+	bl.pinned.Store(int64(len(bl.buffers)))
 }
 
 func (bl *BufferList) UnpinAll() {
@@ -63,4 +82,10 @@ func (bl *BufferList) UnpinAll() {
 
 	bl.buffers = make(map[types.Block]*buffers.Buffer)
 	bl.pins = make(map[types.Block]int)
+	bl.pinned.Store(0)
+}
+
+// Pinned возвращает число закреплённых блоков
+func (bl *BufferList) Pinned() int {
+	return int(bl.pinned.Load())
 }
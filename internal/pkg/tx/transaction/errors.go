@@ -3,3 +3,9 @@ package transaction
 import "github.com/pkg/errors"
 
 var ErrTransactionFailed error = errors.New("transaction failed")
+
+var ErrReadOnlyTransaction = errors.Wrap(ErrTransactionFailed, "cannot write in a read-only transaction")
+
+var ErrSavepointNotFound = errors.Wrap(ErrTransactionFailed, "savepoint not found")
+
+var ErrPinQuotaExceeded = errors.Wrap(ErrTransactionFailed, "transaction pin quota exceeded")
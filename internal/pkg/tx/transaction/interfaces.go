@@ -1,6 +1,8 @@
 package transaction
 
 import (
+	"context"
+
 	"github.com/unhandled-exception/sophiadb/internal/pkg/buffers"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/concurrency"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/recovery"
@@ -15,17 +17,25 @@ type storageManager interface {
 	BlockSize() uint32
 	Length(filename string) (types.BlockID, error)
 	Append(filename string) (types.Block, error)
+	SetCompression(filename string, codecName string) error
+	ResetCompression(filename string) error
 }
 
 type buffersManager interface {
 	recovery.BuffersManager
 	Pin(block types.Block) (*buffers.Buffer, error)
+	PinContext(ctx context.Context, block types.Block) (*buffers.Buffer, error)
 	Unpin(buf *buffers.Buffer)
 	Available() int
+	ReadAheadWindow() int
+	Prefetch(first types.Block, count int)
 }
 
 type concurrencyManager interface {
 	concurrency.ConcurrencyManager
+	Locks() []concurrency.HeldLock
+	LocksCount() int
+	Waiting() (concurrency.LockWaiting, bool)
 }
 
 type recoveryManager interface {
@@ -34,7 +44,8 @@ type recoveryManager interface {
 
 type bufferList interface {
 	GetBuffer(block types.Block) *buffers.Buffer
-	Pin(block types.Block) error
+	Pin(ctx context.Context, block types.Block) error
 	Unpin(block types.Block)
 	UnpinAll()
+	Pinned() int
 }
@@ -0,0 +1,164 @@
+package transaction
+
+import (
+	"sort"
+	"time"
+
+	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/concurrency"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
+)
+
+// TransactionState — состояние активной транзакции
+type TransactionState string
+
+const (
+	TransactionActive  TransactionState = "active"
+	TransactionWaiting TransactionState = "waiting"
+)
+
+// TransactionInfo — снимок состояния активной транзакции для системного представления sdb_transactions
+type TransactionInfo struct {
+	TXNum         types.TRX
+	StartedAt     time.Time
+	State         TransactionState
+	Isolation     concurrency.IsolationLevel
+	ReadOnly      bool
+	PinnedBuffers int
+	Locks         int
+}
+
+// LockInfo — снимок блокировки для системного представления sdb_locks
+type LockInfo struct {
+	Resource  concurrency.Resource
+	Exclusive bool
+	Holder    types.TRX
+	Waiters   []types.TRX
+	// WaitTime — сколько ждёт самая давняя ожидающая транзакция
+	WaitTime time.Duration
+}
+
+func (t *Transaction) Info() TransactionInfo {
+	state := TransactionActive
+	if _, ok := t.cm.Waiting(); ok {
+		state = TransactionWaiting
+	}
+
+	return TransactionInfo{
+		TXNum:         t.txNum,
+		StartedAt:     t.startedAt,
+		State:         state,
+		Isolation:     t.isolation,
+		ReadOnly:      t.readOnly,
+		PinnedBuffers: t.buffers.Pinned(),
+		Locks:         t.cm.LocksCount(),
+	}
+}
+
+// Transactions возвращает снимок активных транзакций, упорядоченный по номеру транзакции
+func (m *TRXManager) Transactions() []TransactionInfo {
+	active := m.activeTransactions()
+
+	result := make([]TransactionInfo, 0, len(active))
+
+	for _, t := range active {
+		result = append(result, t.Info())
+	}
+
+	return result
+}
+
+// Locks возвращает снимок блокировок активных транзакций вместе с транзакциями, которые их ждут
+func (m *TRXManager) Locks() []LockInfo {
+	active := m.activeTransactions()
+	now := time.Now()
+
+	type waiter struct {
+		txNum   types.TRX
+		started time.Time
+	}
+
+	waiters := make(map[concurrency.Resource][]waiter)
+
+	for _, t := range active {
+		if w, ok := t.cm.Waiting(); ok {
+			waiters[w.Resource] = append(waiters[w.Resource], waiter{txNum: t.txNum, started: w.Started})
+		}
+	}
+
+	result := make([]LockInfo, 0)
+
+	for _, t := range active {
+		for _, lock := range t.cm.Locks() {
+			li := LockInfo{
+				Resource:  lock.Resource,
+				Exclusive: lock.Exclusive,
+				Holder:    t.txNum,
+			}
+
+			for _, w := range waiters[lock.Resource] {
+				// Транзакция ждёт повышения своей же блокировки до эксклюзивной
+				if w.txNum == t.txNum {
+					continue
+				}
+
+				li.Waiters = append(li.Waiters, w.txNum)
+
+				if waitTime := now.Sub(w.started); waitTime > li.WaitTime {
+					li.WaitTime = waitTime
+				}
+			}
+
+			result = append(result, li)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i].Resource, result[j].Resource
+
+		switch {
+		case a.Block.Filename != b.Block.Filename:
+			return a.Block.Filename < b.Block.Filename
+		case a.Block.Number != b.Block.Number:
+			return a.Block.Number < b.Block.Number
+		case a.Slot != b.Slot:
+			return a.Slot < b.Slot
+		case a.Key != b.Key:
+			return a.Key < b.Key
+		default:
+			return result[i].Holder < result[j].Holder
+		}
+	})
+
+	return result
+}
+
+func (m *TRXManager) register(t *Transaction) {
+	m.activeMu.Lock()
+	defer m.activeMu.Unlock()
+
+	m.active[t.txNum] = t
+}
+
+func (m *TRXManager) unregister(t *Transaction) {
+	m.activeMu.Lock()
+	defer m.activeMu.Unlock()
+
+	delete(m.active, t.txNum)
+}
+
+func (m *TRXManager) activeTransactions() []*Transaction {
+	m.activeMu.Lock()
+
+	active := make([]*Transaction, 0, len(m.active))
+	for _, t := range m.active {
+		active = append(active, t)
+	}
+
+	m.activeMu.Unlock()
+
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].txNum < active[j].txNum
+	})
+
+	return active
+}
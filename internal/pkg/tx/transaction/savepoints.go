@@ -0,0 +1,66 @@
+package transaction
+
+import "github.com/pkg/errors"
+
+type savepoint struct {
+	id   int32
+	name string
+}
+
+// Savepoint ставит точку сохранения с именем name.
+// Имена могут повторяться: откат и освобождение работают с последней точкой с таким именем
+func (t *Transaction) Savepoint(name string) error {
+	t.lastSavepointID++
+
+	sp := savepoint{
+		id:   t.lastSavepointID,
+		name: name,
+	}
+
+	if err := t.rm.Savepoint(sp.id, sp.name); err != nil {
+		return t.wrapTransactionError(err)
+	}
+
+	t.savepoints = append(t.savepoints, sp)
+
+	return nil
+}
+
+// RollbackToSavepoint откатывает изменения, сделанные после точки сохранения.
+// Сама точка и все блокировки транзакции остаются, точки сохранения после неё удаляются
+func (t *Transaction) RollbackToSavepoint(name string) error {
+	i, err := t.findSavepoint(name)
+	if err != nil {
+		return err
+	}
+
+	if err := t.rm.RollbackToSavepoint(t.savepoints[i].id); err != nil {
+		return t.wrapTransactionError(err)
+	}
+
+	t.savepoints = t.savepoints[:i+1]
+
+	return nil
+}
+
+// ReleaseSavepoint удаляет точку сохранения и все точки, поставленные после неё. Изменения остаются в транзакции
+func (t *Transaction) ReleaseSavepoint(name string) error {
+	i, err := t.findSavepoint(name)
+	if err != nil {
+		return err
+	}
+
+	t.savepoints = t.savepoints[:i]
+
+	return nil
+}
+
+func (t *Transaction) findSavepoint(name string) (int, error) {
+	for i := len(t.savepoints) - 1; i >= 0; i-- {
+		if t.savepoints[i].name == name {
+			return i, nil
+		}
+	}
+
+	return -1, errors.WithMessagef(ErrSavepointNotFound, "trx_id %d: savepoint %q", t.txNum, name)
+}
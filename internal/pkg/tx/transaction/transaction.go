@@ -1,38 +1,73 @@
 package transaction
 
 import (
+	"context"
+	"slices"
+	"time"
+
 	"github.com/pkg/errors"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/buffers"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/storage"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/concurrency"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/recovery"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
 )
 
-const endOfFileBlock types.BlockID = -1
+const endOfFileBlock = concurrency.EndOfFileBlock
 
 type Transaction struct {
-	txNum   types.TRX
-	buffers bufferList
-	rm      recoveryManager
-	cm      concurrencyManager
+	txNum     types.TRX
+	buffers   bufferList
+	rm        recoveryManager
+	cm        concurrencyManager
+	isolation concurrency.IsolationLevel
+	readOnly  bool
+	startedAt time.Time
+	pinQuota  int
+
+	// ctx — контекст текущей команды. По его отмене прерывается ожидание свободного буфера.
+	// Ожидание блокировок контекст не прерывает, его ограничивает только таймаут блокировок
+	ctx context.Context
+
+	savepoints      []savepoint
+	lastSavepointID int32
+
+	// onFinish вызывается после коммита или отката, чтобы менеджер транзакций убрал транзакцию из списка активных
+	onFinish func(t *Transaction)
 
 	fm storageManager
 	lm logManager
 	bm buffersManager
 }
 
-func NewTransaction(nextTRX func() types.TRX, fm storageManager, lm logManager, bm buffersManager, lt concurrency.Lockers) (*Transaction, error) {
+type TransactionOpt func(*Transaction)
+
+func NewTransaction(nextTRX func() types.TRX, fm storageManager, lm logManager, bm buffersManager, lt concurrency.Lockers, opts ...TransactionOpt) (*Transaction, error) {
 	txNum := nextTRX()
 
 	t := &Transaction{
-		txNum:   txNum,
-		buffers: NewBuffersList(bm),
-		cm:      concurrency.NewManager(lt),
-		fm:      fm,
-		lm:      lm,
-		bm:      bm,
+		txNum:     txNum,
+		isolation: concurrency.Serializable,
+		ctx:       context.Background(),
+		startedAt: time.Now(),
+		fm:        fm,
+		lm:        lm,
+		bm:        bm,
+	}
+
+	for _, opt := range opts {
+		opt(t)
 	}
 
-	rm, err := recovery.NewManager(t, lm, bm)
+	t.buffers = NewBuffersList(bm, t.pinQuota)
+	t.cm = concurrency.NewManager(lt, concurrency.WithIsolationLevel(t.isolation))
+
+	var rmOpts []recovery.ManagerOpt
+	if t.readOnly {
+		rmOpts = append(rmOpts, recovery.WithReadOnly())
+	}
+
+	rm, err := recovery.NewManager(t, lm, bm, rmOpts...)
 	if err != nil {
 		return nil, t.wrapTransactionError(err)
 	}
@@ -42,10 +77,50 @@ func NewTransaction(nextTRX func() types.TRX, fm storageManager, lm logManager,
 	return t, nil
 }
 
+// WithIsolationLevel задаёт уровень изоляции транзакции. По умолчанию — serializable
+func WithIsolationLevel(level concurrency.IsolationLevel) TransactionOpt {
+	return func(t *Transaction) {
+		t.isolation = level
+	}
+}
+
+// WithReadOnly делает транзакцию только для чтения
+func WithReadOnly() TransactionOpt {
+	return func(t *Transaction) {
+		t.readOnly = true
+	}
+}
+
+// WithPinQuota ограничивает число блоков, которые транзакция может закрепить одновременно,
+// чтобы одна транзакция не заняла весь пул буферов. 0 — без ограничений
+func WithPinQuota(quota int) TransactionOpt {
+	return func(t *Transaction) {
+		t.pinQuota = quota
+	}
+}
+
 func (t *Transaction) TXNum() types.TRX {
 	return t.txNum
 }
 
+func (t *Transaction) IsolationLevel() concurrency.IsolationLevel {
+	return t.isolation
+}
+
+func (t *Transaction) ReadOnly() bool {
+	return t.readOnly
+}
+
+func (t *Transaction) StartedAt() time.Time {
+	return t.startedAt
+}
+
+func (t *Transaction) finish() {
+	if t.onFinish != nil {
+		t.onFinish(t)
+	}
+}
+
 func (t *Transaction) Commit() error {
 	if err := t.rm.Commit(); err != nil {
 		return t.wrapTransactionError(err)
@@ -53,6 +128,7 @@ func (t *Transaction) Commit() error {
 
 	t.cm.Release()
 	t.buffers.UnpinAll()
+	t.finish()
 
 	return nil
 }
@@ -64,6 +140,7 @@ func (t *Transaction) Rollback() error {
 
 	t.cm.Release()
 	t.buffers.UnpinAll()
+	t.finish()
 
 	return nil
 }
@@ -80,8 +157,37 @@ func (t *Transaction) Recover() error {
 	return nil
 }
 
+// SetContext задает контекст для следующих команд транзакции. Нужен сканам, которые закрепляют блоки через Pin
+func (t *Transaction) SetContext(ctx context.Context) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	t.ctx = ctx
+}
+
+func (t *Transaction) Context() context.Context {
+	return t.ctx
+}
+
+// Pin закрепляет блок с контекстом транзакции
 func (t *Transaction) Pin(block types.Block) error {
-	return t.wrapTransactionError(t.buffers.Pin(block))
+	return t.PinContext(t.ctx, block)
+}
+
+// PinContext закрепляет блок. Ожидание свободного буфера прерывается по отмене ctx
+func (t *Transaction) PinContext(ctx context.Context, block types.Block) error {
+	return t.wrapPinError(t.buffers.Pin(ctx, block))
+}
+
+// ReadAheadWindow возвращает окно упреждающего чтения в блоках
+func (t *Transaction) ReadAheadWindow() int {
+	return t.bm.ReadAheadWindow()
+}
+
+// Prefetch заранее читает блоки в пул буферов, не закрепляя их за транзакцией
+func (t *Transaction) Prefetch(first types.Block, count int) {
+	t.bm.Prefetch(first, count)
 }
 
 func (t *Transaction) Unpin(block types.Block) {
@@ -93,12 +199,23 @@ func (t *Transaction) GetInt8(block types.Block, offset uint32) (int8, error) {
 		return 0, t.wrapTransactionError(err)
 	}
 
+	defer t.cm.EndRead(block)
+
 	buf := t.buffers.GetBuffer(block)
 
-	return buf.Content().GetInt8(offset), nil
+	value, err := buf.Content().GetInt8(offset)
+	if err != nil {
+		return 0, t.wrapTransactionError(err)
+	}
+
+	return value, nil
 }
 
 func (t *Transaction) SetInt8(block types.Block, offset uint32, value int8, okToLog bool) error {
+	if t.readOnly {
+		return t.readOnlyError()
+	}
+
 	if err := t.cm.XLock(block); err != nil {
 		return t.wrapTransactionError(err)
 	}
@@ -115,7 +232,10 @@ func (t *Transaction) SetInt8(block types.Block, offset uint32, value int8, okTo
 		}
 	}
 
-	buf.Content().SetInt8(offset, value)
+	if err := buf.WritableContent().SetInt8(offset, value); err != nil {
+		return t.wrapTransactionError(err)
+	}
+
 	buf.SetModified(t.txNum, lsn)
 
 	return nil
@@ -126,12 +246,23 @@ func (t *Transaction) GetInt64(block types.Block, offset uint32) (int64, error)
 		return 0, t.wrapTransactionError(err)
 	}
 
+	defer t.cm.EndRead(block)
+
 	buf := t.buffers.GetBuffer(block)
 
-	return buf.Content().GetInt64(offset), nil
+	value, err := buf.Content().GetInt64(offset)
+	if err != nil {
+		return 0, t.wrapTransactionError(err)
+	}
+
+	return value, nil
 }
 
 func (t *Transaction) SetInt64(block types.Block, offset uint32, value int64, okToLog bool) error {
+	if t.readOnly {
+		return t.readOnlyError()
+	}
+
 	if err := t.cm.XLock(block); err != nil {
 		return t.wrapTransactionError(err)
 	}
@@ -148,7 +279,10 @@ func (t *Transaction) SetInt64(block types.Block, offset uint32, value int64, ok
 		}
 	}
 
-	buf.Content().SetInt64(offset, value)
+	if err := buf.WritableContent().SetInt64(offset, value); err != nil {
+		return t.wrapTransactionError(err)
+	}
+
 	buf.SetModified(t.txNum, lsn)
 
 	return nil
@@ -159,12 +293,23 @@ func (t *Transaction) GetString(block types.Block, offset uint32) (string, error
 		return "", t.wrapTransactionError(err)
 	}
 
+	defer t.cm.EndRead(block)
+
 	buf := t.buffers.GetBuffer(block)
 
-	return buf.Content().GetString(offset), nil
+	value, err := buf.Content().GetString(offset)
+	if err != nil {
+		return "", t.wrapTransactionError(err)
+	}
+
+	return value, nil
 }
 
 func (t *Transaction) SetString(block types.Block, offset uint32, value string, okToLog bool) error {
+	if t.readOnly {
+		return t.readOnlyError()
+	}
+
 	if err := t.cm.XLock(block); err != nil {
 		return t.wrapTransactionError(err)
 	}
@@ -181,12 +326,184 @@ func (t *Transaction) SetString(block types.Block, offset uint32, value string,
 		}
 	}
 
-	buf.Content().SetString(offset, value)
+	if err := buf.WritableContent().SetString(offset, value); err != nil {
+		return t.wrapTransactionError(err)
+	}
+
 	buf.SetModified(t.txNum, lsn)
 
 	return nil
 }
 
+// FetchBytes возвращает копию участка блока длиной size
+func (t *Transaction) FetchBytes(block types.Block, offset uint32, size int) ([]byte, error) {
+	if err := t.cm.SLock(block); err != nil {
+		return nil, t.wrapTransactionError(err)
+	}
+
+	defer t.cm.EndRead(block)
+
+	buf := t.buffers.GetBuffer(block)
+
+	value, err := buf.Content().FetchBytes(offset, size)
+	if err != nil {
+		return nil, t.wrapTransactionError(err)
+	}
+
+	return slices.Clone(value), nil
+}
+
+// ReadPage берет разделяемую блокировку и возвращает страницу закрепленного блока без копирования.
+// Страницу можно читать только до EndRead: потом блокировка может быть снята, а срезы страницы — измениться
+func (t *Transaction) ReadPage(block types.Block) (*types.Page, error) {
+	if err := t.cm.SLock(block); err != nil {
+		return nil, t.wrapTransactionError(err)
+	}
+
+	return t.buffers.GetBuffer(block).Content(), nil
+}
+
+// EndRead заканчивает чтение страницы, полученной через ReadPage
+func (t *Transaction) EndRead(block types.Block) {
+	t.cm.EndRead(block)
+}
+
+// PutBytes записывает участок блока без длины. В журнал попадает прежнее содержимое участка
+func (t *Transaction) PutBytes(block types.Block, offset uint32, value []byte, okToLog bool) error {
+	if t.readOnly {
+		return t.readOnlyError()
+	}
+
+	if err := t.cm.XLock(block); err != nil {
+		return t.wrapTransactionError(err)
+	}
+
+	buf := t.buffers.GetBuffer(block)
+	lsn := types.LSN(-1)
+
+	if okToLog {
+		var err error
+
+		lsn, err = t.rm.PutBytes(buf, offset, value)
+		if err != nil {
+			return t.wrapTransactionError(err)
+		}
+	}
+
+	if err := buf.WritableContent().PutBytes(offset, value); err != nil {
+		return t.wrapTransactionError(err)
+	}
+
+	buf.SetModified(t.txNum, lsn)
+
+	return nil
+}
+
+// SLock заранее берет разделяемую блокировку на блок.
+// С noWait не ждет освобождения блока, а сразу возвращает ошибку с concurrency.ErrLockNotAvailable.
+// Блокировка держится до конца транзакции и на уровне READ COMMITTED
+func (t *Transaction) SLock(block types.Block, noWait bool) error {
+	var err error
+
+	if noWait {
+		err = t.cm.SLockNoWait(block)
+	} else {
+		err = t.cm.SLock(block)
+	}
+
+	if err == nil {
+		t.cm.Hold(block)
+	}
+
+	return t.wrapLockError(err)
+}
+
+// XLock заранее берет эксклюзивную блокировку на блок, например для SELECT ... FOR UPDATE,
+// чтобы потом не повышать разделяемую блокировку до эксклюзивной
+func (t *Transaction) XLock(block types.Block, noWait bool) error {
+	if t.readOnly {
+		return t.readOnlyError()
+	}
+
+	var err error
+
+	if noWait {
+		err = t.cm.XLockNoWait(block)
+	} else {
+		err = t.cm.XLock(block)
+	}
+
+	if err == nil {
+		t.cm.Hold(block)
+	}
+
+	return t.wrapLockError(err)
+}
+
+// BeginRead берет разделяемую блокировку на блок, как ее берет чтение, и снимается так же — через EndRead
+// по правилам уровня изоляции. С noWait не ждет, если блок меняет другая транзакция
+func (t *Transaction) BeginRead(block types.Block, noWait bool) error {
+	var err error
+
+	if noWait {
+		err = t.cm.SLockNoWait(block)
+	} else {
+		err = t.cm.SLock(block)
+	}
+
+	return t.wrapLockError(err)
+}
+
+// SLockRow берет разделяемую блокировку на запись в слоте slot блока block, например для SELECT ... FOR SHARE.
+// Блокировка записи держится до конца транзакции на любом уровне изоляции и не мешает читать и менять
+// другие записи блока
+func (t *Transaction) SLockRow(block types.Block, slot types.SlotID, noWait bool) error {
+	return t.wrapLockError(t.cm.SLockRow(block, slot, noWait))
+}
+
+// XLockRow берет эксклюзивную блокировку на запись, например для SELECT ... FOR UPDATE и перед изменением записи
+func (t *Transaction) XLockRow(block types.Block, slot types.SlotID, noWait bool) error {
+	if t.readOnly {
+		return t.readOnlyError()
+	}
+
+	return t.wrapLockError(t.cm.XLockRow(block, slot, noWait))
+}
+
+// SLockKey берет разделяемую блокировку на значение ключа индекса, см. concurrency.KeyResource.
+// Ее берет чтение из индекса, чтобы другие транзакции не вставили в прочитанный диапазон новые значения
+func (t *Transaction) SLockKey(key concurrency.Resource) error {
+	return t.wrapLockError(t.cm.SLockKey(key))
+}
+
+// XLockKey берет эксклюзивную блокировку на значение ключа, которое транзакция вставляет в индекс или удаляет из него
+func (t *Transaction) XLockKey(key concurrency.Resource) error {
+	if t.readOnly {
+		return t.readOnlyError()
+	}
+
+	return t.wrapLockError(t.cm.XLockKey(key))
+}
+
+// XLockNextKey берет эксклюзивную блокировку на следующий ключ на время вставки в индекс, снимает ее ReleaseNextKey
+func (t *Transaction) XLockNextKey(key concurrency.Resource) error {
+	if t.readOnly {
+		return t.readOnlyError()
+	}
+
+	return t.wrapLockError(t.cm.XLockNextKey(key))
+}
+
+func (t *Transaction) ReleaseNextKey(key concurrency.Resource) {
+	t.cm.ReleaseNextKey(key)
+}
+
+// ReleaseRead заканчивает чтение страницы, полученной через ReadPage, и сразу снимает разделяемую блокировку
+// на любом уровне изоляции. Так читаются страницы индексов, которые защищены блокировками ключей
+func (t *Transaction) ReleaseRead(block types.Block) {
+	t.cm.ReleaseRead(block)
+}
+
 func (t *Transaction) BlockSize() uint32 {
 	return t.fm.BlockSize()
 }
@@ -202,10 +519,16 @@ func (t *Transaction) Size(filename string) (types.BlockID, error) {
 		return 0, t.wrapTransactionError(err)
 	}
 
+	defer t.cm.EndRead(dummyBlock)
+
 	return t.fm.Length(filename)
 }
 
 func (t *Transaction) Append(filename string) (types.Block, error) {
+	if t.readOnly {
+		return types.Block{}, t.readOnlyError()
+	}
+
 	dummyBlock := types.Block{Filename: filename, Number: endOfFileBlock}
 
 	if err := t.cm.XLock(dummyBlock); err != nil {
@@ -215,6 +538,48 @@ func (t *Transaction) Append(filename string) (types.Block, error) {
 	return t.fm.Append(filename)
 }
 
+// SetCompression включает сжатие страниц пустого файла, см. storage.Manager.SetCompression.
+// При откате транзакции сжатие выключается, а файл удаляется
+func (t *Transaction) SetCompression(filename string, codecName string) error {
+	if t.readOnly {
+		return t.readOnlyError()
+	}
+
+	dummyBlock := types.Block{Filename: filename, Number: endOfFileBlock}
+
+	if err := t.cm.XLock(dummyBlock); err != nil {
+		return t.wrapTransactionError(err)
+	}
+
+	if err := storage.ValidCompression(codecName); err != nil {
+		return t.wrapTransactionError(err)
+	}
+
+	if codecName == "" || codecName == storage.CompressionNone {
+		return nil
+	}
+
+	if err := t.rm.SetCompression(filename); err != nil {
+		return t.wrapTransactionError(err)
+	}
+
+	if err := t.fm.SetCompression(filename, codecName); err != nil {
+		return t.wrapTransactionError(err)
+	}
+
+	return nil
+}
+
+// ResetCompression отменяет SetCompression при откате транзакции, см. storage.Manager.ResetCompression
+func (t *Transaction) ResetCompression(filename string) error {
+	// Откаченные страницы файла пишем до его удаления, иначе при завершении отката они создадут файл заново
+	if err := t.bm.FlushAll(t.txNum); err != nil {
+		return t.wrapTransactionError(err)
+	}
+
+	return t.fm.ResetCompression(filename)
+}
+
 func (t *Transaction) wrapTransactionError(err error) error {
 	if err == nil {
 		return nil
@@ -222,3 +587,25 @@ func (t *Transaction) wrapTransactionError(err error) error {
 
 	return errors.WithMessagef(ErrTransactionFailed, "trx_id %d: %s", t.txNum, err)
 }
+
+func (t *Transaction) readOnlyError() error {
+	return errors.WithMessagef(ErrReadOnlyTransaction, "trx_id %d", t.txNum)
+}
+
+// wrapPinError сохраняет в цепочке ошибок нехватку буферов и превышение квоты
+func (t *Transaction) wrapPinError(err error) error {
+	if errors.Is(err, buffers.ErrNoAvailableBuffers) || errors.Is(err, ErrPinQuotaExceeded) {
+		return errors.WithMessagef(err, "trx_id %d", t.txNum)
+	}
+
+	return t.wrapTransactionError(err)
+}
+
+// wrapLockError сохраняет ErrLockNotAvailable в цепочке ошибок, чтобы по нему можно было пропускать занятые блоки и записи
+func (t *Transaction) wrapLockError(err error) error {
+	if errors.Is(err, concurrency.ErrLockNotAvailable) {
+		return errors.WithMessagef(err, "trx_id %d", t.txNum)
+	}
+
+	return t.wrapTransactionError(err)
+}
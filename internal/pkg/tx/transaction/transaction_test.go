@@ -1,6 +1,7 @@
 package transaction_test
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 	"sync"
@@ -13,6 +14,7 @@ import (
 	"github.com/unhandled-exception/sophiadb/internal/pkg/buffers"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/storage"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/testutil"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/concurrency"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/recovery"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/transaction"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
@@ -190,8 +192,8 @@ func (ts *TransactionTestSuite) TestSequenceCase() {
 	page := types.NewPage(defaultTestBlockSize)
 	require.NoError(t, fm.Read(block1, page))
 
-	assert.EqualValues(t, iVal, page.GetInt64(iOffset))
-	assert.EqualValues(t, sVal, page.GetString(sOffset))
+	assert.EqualValues(t, iVal, testutil.Must(page.GetInt64(iOffset)))
+	assert.EqualValues(t, sVal, testutil.Must(page.GetString(sOffset)))
 }
 
 func (ts *TransactionTestSuite) TestRecovery() {
@@ -204,6 +206,7 @@ func (ts *TransactionTestSuite) TestRecovery() {
 
 	iOffset := uint32(80)
 	sOffset := uint32(40)
+	bOffset := uint32(120)
 
 	block1 := types.Block{Filename: testDataFile, Number: 0}
 
@@ -223,6 +226,7 @@ func (ts *TransactionTestSuite) TestRecovery() {
 		recovery.NewStartLogRecord(trxIDS[3]),
 		recovery.NewSetInt64LogRecord(trxIDS[3], block1, iOffset, -3345),
 		recovery.NewSetStringLogRecord(trxIDS[3], block1, sOffset, "invisible string 3"),
+		recovery.NewPutBytesLogRecord(trxIDS[3], block1, bOffset, []byte{0x01, 0x02, 0x03}),
 
 		recovery.NewStartLogRecord(trxIDS[1]),
 		recovery.NewSetInt64LogRecord(trxIDS[1], block1, iOffset, -1345),
@@ -256,8 +260,9 @@ func (ts *TransactionTestSuite) TestRecovery() {
 	page := types.NewPage(defaultTestBlockSize)
 	require.NoError(t, fm.Read(block1, page))
 
-	assert.EqualValues(t, -3345, page.GetInt64(iOffset))
-	assert.EqualValues(t, "invisible string 3", page.GetString(sOffset))
+	assert.EqualValues(t, -3345, testutil.Must(page.GetInt64(iOffset)))
+	assert.EqualValues(t, "invisible string 3", testutil.Must(page.GetString(sOffset)))
+	assert.EqualValues(t, []byte{0x01, 0x02, 0x03}, testutil.Must(page.FetchBytes(bOffset, 3)))
 
 	// Проверяем, что в WAL не попали лишние записи
 	assert.Equal(t, ts.fetchWAL(t, trxMan)[len(logRecords):],
@@ -268,6 +273,43 @@ func (ts *TransactionTestSuite) TestRecovery() {
 	)
 }
 
+func (ts *TransactionTestSuite) TestSetCompression_Rollback() {
+	t := ts.T()
+
+	trxMan, fm := ts.newTRXManager(defaultLockTimeout)
+	defer fm.Close()
+
+	for i := 0; i < 2; i++ {
+		trx, err := trxMan.Transaction()
+		require.NoError(t, err)
+
+		require.NoError(t, trx.SetCompression(testDataFile, storage.CompressionZstd))
+
+		block, err := trx.Append(testDataFile)
+		require.NoError(t, err)
+
+		require.NoError(t, trx.Pin(block))
+		require.NoError(t, trx.SetInt64(block, 0, 42, true))
+
+		compression, err := fm.Compression(testDataFile)
+		require.NoError(t, err)
+		assert.Equal(t, storage.CompressionZstd, compression)
+
+		require.NoError(t, trx.Rollback())
+
+		// После отката файла нет, и сжатие можно включить снова
+		compression, err = fm.Compression(testDataFile)
+		require.NoError(t, err)
+		assert.Equal(t, storage.CompressionNone, compression)
+
+		length, err := fm.Length(testDataFile)
+		require.NoError(t, err)
+		assert.EqualValues(t, 0, length)
+	}
+
+	assert.Contains(t, ts.fetchWAL(t, trxMan), `<SET_COMPRESSION, 1001, file: "`+testDataFile+`">`)
+}
+
 func (ts *TransactionTestSuite) TestConcurrentCase() {
 	t := ts.T()
 
@@ -357,6 +399,419 @@ func (ts *TransactionTestSuite) TestConcurrentCase() {
 	page := types.NewPage(defaultTestBlockSize)
 	require.NoError(t, fm.Read(block1, page))
 
-	assert.EqualValues(t, iVal+1, page.GetInt64(iOffset))
-	assert.EqualValues(t, sVal+" 1", page.GetString(sOffset))
+	assert.EqualValues(t, iVal+1, testutil.Must(page.GetInt64(iOffset)))
+	assert.EqualValues(t, sVal+" 1", testutil.Must(page.GetString(sOffset)))
+}
+
+func (ts *TransactionTestSuite) TestReadOnly() {
+	t := ts.T()
+
+	trxMan, fm := ts.newTRXManager(defaultLockTimeout)
+	defer fm.Close()
+
+	iOffset := uint32(80)
+
+	block1, err := fm.Append(testDataFile)
+	require.NoError(t, err)
+
+	sut, err := trxMan.Transaction(transaction.WithReadOnly())
+	require.NoError(t, err)
+	assert.True(t, sut.ReadOnly())
+
+	require.NoError(t, sut.Pin(block1))
+
+	_, err = sut.GetInt64(block1, iOffset)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, sut.SetInt64(block1, iOffset, 1, true), transaction.ErrReadOnlyTransaction)
+	assert.ErrorIs(t, sut.SetInt8(block1, iOffset, 1, true), transaction.ErrReadOnlyTransaction)
+	assert.ErrorIs(t, sut.SetString(block1, iOffset, "s", true), transaction.ErrReadOnlyTransaction)
+	assert.ErrorIs(t, sut.PutBytes(block1, iOffset, []byte{1}, true), transaction.ErrReadOnlyTransaction)
+
+	_, err = sut.Append(testDataFile)
+	assert.ErrorIs(t, err, transaction.ErrReadOnlyTransaction)
+
+	require.NoError(t, sut.Commit())
+
+	sut, err = trxMan.Transaction(transaction.WithReadOnly())
+	require.NoError(t, err)
+	require.NoError(t, sut.Rollback())
+
+	// Читающие транзакции не пишут в WAL
+	assert.Empty(t, ts.fetchWAL(t, trxMan))
+}
+
+func (ts *TransactionTestSuite) TestIsolationLevel() {
+	t := ts.T()
+
+	trxMan, fm := ts.newTRXManager(defaultLockTimeout)
+	defer fm.Close()
+
+	iOffset := uint32(80)
+
+	block1, err := fm.Append(testDataFile)
+	require.NoError(t, err)
+
+	reader, err := trxMan.Transaction(transaction.WithIsolationLevel(concurrency.ReadCommitted))
+	require.NoError(t, err)
+	assert.Equal(t, concurrency.ReadCommitted, reader.IsolationLevel())
+
+	require.NoError(t, reader.Pin(block1))
+
+	_, err = reader.GetInt64(block1, iOffset)
+	require.NoError(t, err)
+
+	// Read committed не держит разделяемую блокировку после чтения
+	writer, err := trxMan.Transaction()
+	require.NoError(t, err)
+	assert.Equal(t, concurrency.Serializable, writer.IsolationLevel())
+
+	require.NoError(t, writer.Pin(block1))
+	require.NoError(t, writer.SetInt64(block1, iOffset, 10, true))
+	require.NoError(t, writer.Commit())
+
+	v, err := reader.GetInt64(block1, iOffset)
+	require.NoError(t, err)
+	assert.EqualValues(t, 10, v)
+
+	require.NoError(t, reader.Commit())
+}
+
+func (ts *TransactionTestSuite) TestReadPage() {
+	t := ts.T()
+
+	trxMan, fm := ts.newTRXManager(defaultLockTimeout)
+	defer fm.Close()
+
+	iOffset := uint32(80)
+
+	block1, err := fm.Append(testDataFile)
+	require.NoError(t, err)
+
+	reader, err := trxMan.Transaction(transaction.WithIsolationLevel(concurrency.ReadCommitted))
+	require.NoError(t, err)
+
+	require.NoError(t, reader.Pin(block1))
+
+	page, err := reader.ReadPage(block1)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, testutil.Must(page.GetInt64(iOffset)))
+
+	// Пока страницу читают, другая транзакция не может ее изменить
+	writer, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	require.NoError(t, writer.Pin(block1))
+	require.ErrorContains(t, writer.SetInt64(block1, iOffset, 10, true), "failed to lock block")
+
+	reader.EndRead(block1)
+
+	require.NoError(t, writer.SetInt64(block1, iOffset, 10, true))
+	require.NoError(t, writer.Commit())
+
+	page, err = reader.ReadPage(block1)
+	require.NoError(t, err)
+	assert.EqualValues(t, 10, testutil.Must(page.GetInt64(iOffset)))
+	reader.EndRead(block1)
+
+	require.NoError(t, reader.Commit())
+}
+
+func (ts *TransactionTestSuite) TestIntrospection() {
+	t := ts.T()
+
+	trxMan, fm := ts.newTRXManager(5 * time.Second)
+	defer fm.Close()
+
+	block1, err := fm.Append(testDataFile)
+	require.NoError(t, err)
+
+	tx1, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	tx2, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	require.NoError(t, tx1.Pin(block1))
+
+	_, err = tx1.GetInt64(block1, 0)
+	require.NoError(t, err)
+
+	infos := trxMan.Transactions()
+	require.Len(t, infos, 2)
+
+	assert.Equal(t, tx1.TXNum(), infos[0].TXNum)
+	assert.Equal(t, transaction.TransactionActive, infos[0].State)
+	assert.Equal(t, 1, infos[0].PinnedBuffers)
+	assert.Equal(t, 1, infos[0].Locks)
+	assert.Equal(t, tx1.StartedAt(), infos[0].StartedAt)
+
+	assert.Equal(t, tx2.TXNum(), infos[1].TXNum)
+	assert.Equal(t, 0, infos[1].PinnedBuffers)
+	assert.Equal(t, 0, infos[1].Locks)
+
+	// Вторая транзакция ждёт эксклюзивную блокировку на блок, который читала первая
+	done := make(chan error)
+
+	go func() {
+		done <- tx2.XLock(block1, false)
+	}()
+
+	require.Eventually(t, func() bool {
+		locks := trxMan.Locks()
+
+		return len(locks) == 2 && len(locks[0].Waiters) > 0
+	}, time.Second, time.Millisecond)
+
+	locks := trxMan.Locks()
+
+	assert.Equal(t, block1, locks[0].Resource.Block)
+	assert.Equal(t, tx1.TXNum(), locks[0].Holder)
+	assert.False(t, locks[0].Exclusive)
+	assert.Equal(t, []types.TRX{tx2.TXNum()}, locks[0].Waiters)
+
+	// У второй транзакции уже есть разделяемая блокировка, взятая перед эксклюзивной
+	assert.Equal(t, tx2.TXNum(), locks[1].Holder)
+	assert.Empty(t, locks[1].Waiters)
+
+	infos = trxMan.Transactions()
+	assert.Equal(t, transaction.TransactionWaiting, infos[1].State)
+
+	require.NoError(t, tx1.Commit())
+	require.NoError(t, <-done)
+
+	locks = trxMan.Locks()
+	require.Len(t, locks, 1)
+	assert.True(t, locks[0].Exclusive)
+	assert.Equal(t, tx2.TXNum(), locks[0].Holder)
+
+	require.NoError(t, tx2.Rollback())
+
+	assert.Empty(t, trxMan.Transactions())
+	assert.Empty(t, trxMan.Locks())
+}
+
+func (ts *TransactionTestSuite) TestSavepoints() {
+	t := ts.T()
+
+	trxMan, fm := ts.newTRXManager(defaultLockTimeout)
+	defer fm.Close()
+
+	iOffset := uint32(80)
+
+	block1, err := fm.Append(testDataFile)
+	require.NoError(t, err)
+
+	sut, err := trxMan.Transaction()
+	require.NoError(t, err)
+	require.NoError(t, sut.Pin(block1))
+
+	require.NoError(t, sut.SetInt64(block1, iOffset, 1, true))
+	require.NoError(t, sut.Savepoint("sp1"))
+	require.NoError(t, sut.SetInt64(block1, iOffset, 2, true))
+	require.NoError(t, sut.Savepoint("sp2"))
+	require.NoError(t, sut.SetInt64(block1, iOffset, 3, true))
+
+	require.NoError(t, sut.RollbackToSavepoint("sp1"))
+
+	v, err := sut.GetInt64(block1, iOffset)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, v)
+
+	// Блокировки после частичного отката остаются у транзакции
+	other, err := trxMan.Transaction()
+	require.NoError(t, err)
+	assert.ErrorIs(t, other.SLock(block1, true), concurrency.ErrLockNotAvailable)
+	require.NoError(t, other.Rollback())
+
+	// sp2 поставлена после sp1 и удалена откатом
+	assert.ErrorIs(t, sut.RollbackToSavepoint("sp2"), transaction.ErrSavepointNotFound)
+
+	// К sp1 можно откатиться повторно
+	require.NoError(t, sut.SetInt64(block1, iOffset, 4, true))
+	require.NoError(t, sut.RollbackToSavepoint("sp1"))
+
+	v, err = sut.GetInt64(block1, iOffset)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, v)
+
+	require.NoError(t, sut.ReleaseSavepoint("sp1"))
+	assert.ErrorIs(t, sut.ReleaseSavepoint("sp1"), transaction.ErrSavepointNotFound)
+
+	require.NoError(t, sut.Commit())
+
+	reader, err := trxMan.Transaction()
+	require.NoError(t, err)
+	require.NoError(t, reader.Pin(block1))
+
+	v, err = reader.GetInt64(block1, iOffset)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, v)
+
+	require.NoError(t, reader.Commit())
+
+	assert.Contains(t, ts.fetchWAL(t, trxMan), `<SAVEPOINT, 1001, id: 2, name: "sp2">`)
+}
+
+func (ts *TransactionTestSuite) TestPinQuotaAndContext() {
+	t := ts.T()
+
+	path := ts.CreateTestTemporaryDir()
+
+	fm, err := storage.NewFileManager(path, defaultTestBlockSize)
+	require.NoError(t, err)
+
+	defer fm.Close()
+
+	lm, err := wal.NewManager(fm, testWALFile)
+	require.NoError(t, err)
+
+	bm := buffers.NewManager(fm, lm, 2, buffers.WithPinLockTimeout(time.Second)) //nolint:mnd
+	trxMan := transaction.NewTRXManager(fm, bm, lm, transaction.WithDefaultPinQuota(2))
+
+	blocks := make([]types.Block, 3)
+	for i := range blocks {
+		blocks[i], err = fm.Append(testDataFile)
+		require.NoError(t, err)
+	}
+
+	sut, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	require.NoError(t, sut.Pin(blocks[0]))
+	require.NoError(t, sut.Pin(blocks[1]))
+
+	// Повторное закрепление уже закреплённого блока квоту не расходует
+	require.NoError(t, sut.Pin(blocks[0]))
+	assert.ErrorIs(t, sut.Pin(blocks[2]), transaction.ErrPinQuotaExceeded)
+
+	// Все буферы пула заняты — отмена контекста прерывает ожидание, не дожидаясь таймаута
+	other, err := trxMan.Transaction()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	other.SetContext(ctx)
+
+	started := time.Now()
+
+	assert.ErrorIs(t, other.Pin(blocks[2]), buffers.ErrNoAvailableBuffers)
+	assert.Less(t, time.Since(started), bm.PinLockTimeout)
+
+	require.NoError(t, other.Rollback())
+	require.NoError(t, sut.Commit())
+}
+
+// TestTornCommit роняет базу на каждой записи фиксации: оборванная запись страницы данных или блока журнала
+// и потерянные несброшенные записи не должны оставить после восстановления страницу, в которой смешаны старые
+// и новые значения
+func (ts *TransactionTestSuite) TestTornCommit() {
+	t := ts.T()
+
+	// Значения лежат в разных секторах блока
+	const tornSector = defaultTestBlockSize / 4
+
+	offsets := []uint32{0, tornSector, 2 * tornSector, 3 * tornSector}
+	block := types.Block{Filename: testDataFile, Number: 0}
+
+	open := func(backend storage.Backend) (*transaction.TRXManager, *storage.Manager) {
+		fm, err := storage.NewManager(backend, defaultTestBlockSize)
+		require.NoError(t, err)
+
+		lm, err := wal.NewManager(fm, testWALFile)
+		require.NoError(t, err)
+
+		return transaction.NewTRXManager(fm, buffers.NewManager(fm, lm, defaultTestBuffersPoolLen), lm), fm
+	}
+
+	// write записывает во все значения блока value и фиксирует транзакцию
+	write := func(trxMan *transaction.TRXManager, value int64, appendBlock bool) error {
+		trx, err := trxMan.Transaction()
+		if err != nil {
+			return err
+		}
+
+		if appendBlock {
+			if _, err = trx.Append(testDataFile); err != nil {
+				return err
+			}
+		}
+
+		if err = trx.Pin(block); err != nil {
+			return err
+		}
+
+		for _, offset := range offsets {
+			if err = trx.SetInt64(block, offset, value, true); err != nil {
+				return err
+			}
+		}
+
+		return trx.Commit()
+	}
+
+	// run готовит блок со значениями 1 и фиксирует транзакцию, которая пишет 2, на хранилище со сбоями.
+	// Возвращает хранилище после сбоя и число записей до фиксации и после нее
+	run := func(opts ...storage.FaultOpt) (storage.Backend, int, int, error) {
+		disk := storage.NewMemoryBackend()
+
+		trxMan, fm := open(disk)
+		require.NoError(t, write(trxMan, 1, true))
+		require.NoError(t, fm.Close())
+
+		faulty := storage.NewFaultyBackend(disk, opts...)
+		trxMan, _ = open(faulty)
+
+		before := faulty.Writes()
+		err := write(trxMan, 2, false)
+		after := faulty.Writes()
+
+		faulty.Crash()
+
+		return disk, before, after, err
+	}
+
+	_, before, after, err := run()
+	require.NoError(t, err)
+	require.Greater(t, after, before)
+
+	for crashAt := before; crashAt < after; crashAt++ {
+		for seed := int64(0); seed < 5; seed++ {
+			for _, dropUnsynced := range []bool{false, true} {
+				opts := []storage.FaultOpt{
+					storage.WithCrashAfterWrites(crashAt),
+					storage.WithTornWrites(tornSector),
+					storage.WithFaultSeed(seed),
+				}
+
+				if dropUnsynced {
+					opts = append(opts, storage.WithDropUnsynced())
+				}
+
+				disk, _, _, err := run(opts...)
+				require.ErrorContains(t, err, storage.ErrCrashed.Error())
+
+				trxMan, fm := open(disk)
+
+				trx, err := trxMan.Transaction()
+				require.NoError(t, err)
+				require.NoError(t, trx.Recover())
+
+				page := types.NewPage(defaultTestBlockSize)
+				require.NoError(t, fm.Read(block, page))
+
+				values := make([]int64, 0, len(offsets))
+				for _, offset := range offsets {
+					values = append(values, testutil.Must(page.GetInt64(offset)))
+				}
+
+				// Если запись о фиксации успела попасть в журнал, видны новые значения, иначе — старые
+				assert.Contains(t, [][]int64{{1, 1, 1, 1}, {2, 2, 2, 2}}, values,
+					"crash at %d, seed %d, drop unsynced %v", crashAt, seed, dropUnsynced)
+
+				require.NoError(t, fm.Close())
+			}
+		}
+	}
 }
@@ -1,6 +1,7 @@
 package transaction
 
 import (
+	"sync"
 	"time"
 
 	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/concurrency"
@@ -13,8 +14,12 @@ type TRXManager struct {
 	lm logManager
 
 	LockTimeout time.Duration
+	PinQuota    int
 	lockTable   concurrency.Lockers
 	trxGen      *TRXGenerator
+
+	activeMu sync.Mutex
+	active   map[types.TRX]*Transaction
 }
 
 type trxManagerOpt func(*TRXManager)
@@ -25,6 +30,7 @@ func NewTRXManager(fm storageManager, bm buffersManager, lm logManager, opts ...
 		bm:     bm,
 		lm:     lm,
 		trxGen: NewTRXGenerator(),
+		active: make(map[types.TRX]*Transaction),
 	}
 
 	for _, opt := range opts {
@@ -44,8 +50,27 @@ func WithLockTimeout(timoout time.Duration) trxManagerOpt {
 	}
 }
 
-func (m *TRXManager) Transaction() (*Transaction, error) {
-	return NewTransaction(m.trxGen.NextTRX, m.fm, m.lm, m.bm, m.lockTable)
+// WithDefaultPinQuota задает квоту закрепленных блоков для всех транзакций менеджера
+func WithDefaultPinQuota(quota int) trxManagerOpt {
+	return func(m *TRXManager) {
+		m.PinQuota = quota
+	}
+}
+
+func (m *TRXManager) Transaction(opts ...TransactionOpt) (*Transaction, error) {
+	if m.PinQuota > 0 {
+		opts = append([]TransactionOpt{WithPinQuota(m.PinQuota)}, opts...)
+	}
+
+	t, err := NewTransaction(m.trxGen.NextTRX, m.fm, m.lm, m.bm, m.lockTable, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	t.onFinish = m.unregister
+	m.register(t)
+
+	return t, nil
 }
 
 func (m *TRXManager) TRXGen() *TRXGenerator {
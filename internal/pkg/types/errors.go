@@ -0,0 +1,9 @@
+package types
+
+import "github.com/pkg/errors"
+
+// ErrTypes базовая ошибка пакета types
+var ErrTypes error = errors.New("types error")
+
+// ErrPageOutOfBounds — чтение или запись за границей страницы, например из-за поврежденной длины строки
+var ErrPageOutOfBounds error = errors.Wrap(ErrTypes, "page out of bounds")
@@ -3,6 +3,8 @@ package types
 import (
 	"encoding/binary"
 	"math"
+
+	"github.com/pkg/errors"
 )
 
 const (
@@ -60,123 +62,200 @@ func (p *Page) Content() []byte {
 	return p.bb
 }
 
-// PutBytes записывает массив байтов по смещению в страницу
-func (p *Page) PutBytes(offset uint32, value []byte) {
-	for i, v := range value {
-		p.bb[int(offset)+i] = v
+// Методы чтения и записи проверяют границы страницы и вместо паники возвращают ErrPageOutOfBounds.
+// Значения читаются и пишутся прямо в срезе страницы, без промежуточных буферов
+
+// slice возвращает size байт страницы по смещению offset
+func (p *Page) slice(offset uint32, size int) ([]byte, error) {
+	end := uint64(offset) + uint64(size)
+	if size < 0 || end > uint64(len(p.bb)) {
+		return nil, errors.WithMessagef(ErrPageOutOfBounds, "offset %d, size %d, page size %d", offset, size, len(p.bb))
 	}
+
+	return p.bb[offset:end:end], nil
 }
 
-// FetchBytes возвращает массив байтов из страницы по смещению offset длинной size
-func (p *Page) FetchBytes(offset uint32, size int) []byte {
-	result := make([]byte, size)
-	for i := 0; i < size; i++ {
-		result[i] = p.bb[int(offset)+i]
+// PutBytes записывает массив байтов по смещению в страницу
+func (p *Page) PutBytes(offset uint32, value []byte) error {
+	buf, err := p.slice(offset, len(value))
+	if err != nil {
+		return err
 	}
 
-	return result
+	copy(buf, value)
+
+	return nil
+}
+
+// FetchBytes возвращает size байт страницы по смещению offset. Срез смотрит в страницу:
+// он меняется вместе со страницей, и его надо скопировать, если он нужен дольше
+func (p *Page) FetchBytes(offset uint32, size int) ([]byte, error) {
+	return p.slice(offset, size)
 }
 
 // GetInt32 возвращает значение int32 по смещению offset
-func (p *Page) GetInt32(offset uint32) int32 {
-	buf := p.FetchBytes(offset, Int32Size)
-	value := int32(p.order.Uint32(buf))
+func (p *Page) GetInt32(offset uint32) (int32, error) {
+	buf, err := p.slice(offset, Int32Size)
+	if err != nil {
+		return 0, err
+	}
 
-	return value
+	return int32(p.order.Uint32(buf)), nil
 }
 
 // SetInt32 записывает значение int32 по смещению offset
-func (p *Page) SetInt32(offset uint32, value int32) {
-	buf := make([]byte, Int32Size)
-	p.order.PutUint32(buf, uint32(value))
-	p.PutBytes(offset, buf)
+func (p *Page) SetInt32(offset uint32, value int32) error {
+	return p.SetUint32(offset, uint32(value))
 }
 
 // GetUint32 возвращает значение uint32 по смещению offset
-func (p *Page) GetUint32(offset uint32) uint32 {
-	return uint32(p.GetInt32(offset))
+func (p *Page) GetUint32(offset uint32) (uint32, error) {
+	buf, err := p.slice(offset, Int32Size)
+	if err != nil {
+		return 0, err
+	}
+
+	return p.order.Uint32(buf), nil
 }
 
 // SetUint32 записывает значение uint32 по смещению offset
-func (p *Page) SetUint32(offset uint32, value uint32) {
-	p.SetInt32(offset, int32(value))
+func (p *Page) SetUint32(offset uint32, value uint32) error {
+	buf, err := p.slice(offset, Int32Size)
+	if err != nil {
+		return err
+	}
+
+	p.order.PutUint32(buf, value)
+
+	return nil
 }
 
 // GetInt64 возвращает значение int64 по смещению offset
-func (p *Page) GetInt64(offset uint32) int64 {
-	buf := p.FetchBytes(offset, Int64Size)
-	value := int64(p.order.Uint64(buf))
+func (p *Page) GetInt64(offset uint32) (int64, error) {
+	buf, err := p.slice(offset, Int64Size)
+	if err != nil {
+		return 0, err
+	}
 
-	return value
+	return int64(p.order.Uint64(buf)), nil
 }
 
 // SetInt64 записывает значение int64 по смещению offset
-func (p *Page) SetInt64(offset uint32, value int64) {
-	buf := make([]byte, Int64Size)
+func (p *Page) SetInt64(offset uint32, value int64) error {
+	buf, err := p.slice(offset, Int64Size)
+	if err != nil {
+		return err
+	}
+
 	p.order.PutUint64(buf, uint64(value))
-	p.PutBytes(offset, buf)
+
+	return nil
 }
 
-// GetBytes возвращает байтовый массив по смещению offset
-func (p *Page) GetBytes(offset uint32) []byte {
-	length := p.GetInt32(offset)
+// GetBytes возвращает байтовый массив по смещению offset. Как и FetchBytes, срез смотрит в страницу
+func (p *Page) GetBytes(offset uint32) ([]byte, error) {
+	length, err := p.GetInt32(offset)
+	if err != nil {
+		return nil, err
+	}
 
-	return p.FetchBytes(offset+Int32Size, int(length))
+	return p.slice(offset+Int32Size, int(length))
 }
 
 // SetBytes записывает байтовый массив по смещению offset
-func (p *Page) SetBytes(offset uint32, value []byte) {
-	length := len(value)
-	p.SetInt32(offset, int32(length))
-	p.PutBytes(offset+Int32Size, value)
+func (p *Page) SetBytes(offset uint32, value []byte) error {
+	buf, err := p.slice(offset, Int32Size+len(value))
+	if err != nil {
+		return err
+	}
+
+	p.order.PutUint32(buf, uint32(len(value)))
+	copy(buf[Int32Size:], value)
+
+	return nil
 }
 
-// GetString возвращает строку по смещению offset
-func (p *Page) GetString(offset uint32) string {
-	return string(p.GetBytes(offset))
+// GetString возвращает строку по смещению offset. Байты копируются из страницы один раз, прямо в строку
+func (p *Page) GetString(offset uint32) (string, error) {
+	buf, err := p.GetBytes(offset)
+	if err != nil {
+		return "", err
+	}
+
+	return string(buf), nil
 }
 
 // SetString записывает строку по смещению offset
-func (p *Page) SetString(offset uint32, value string) {
-	p.SetBytes(offset, []byte(value))
+func (p *Page) SetString(offset uint32, value string) error {
+	buf, err := p.slice(offset, Int32Size+len(value))
+	if err != nil {
+		return err
+	}
+
+	p.order.PutUint32(buf, uint32(len(value)))
+	copy(buf[Int32Size:], value)
+
+	return nil
 }
 
 // GetFloat32 возвращает значение float32 по смещению offset
-func (p *Page) GetFloat32(offset uint32) float32 {
-	buf := p.FetchBytes(offset, Int32Size)
-	value := math.Float32frombits(p.order.Uint32(buf))
+func (p *Page) GetFloat32(offset uint32) (float32, error) {
+	value, err := p.GetUint32(offset)
+	if err != nil {
+		return 0, err
+	}
 
-	return value
+	return math.Float32frombits(value), nil
 }
 
 // SetFloat32 записывает значение float32 по смещению offset
-func (p *Page) SetFloat32(offset uint32, value float32) {
-	buf := make([]byte, Int32Size)
-	p.order.PutUint32(buf, math.Float32bits(value))
-	p.PutBytes(offset, buf)
+func (p *Page) SetFloat32(offset uint32, value float32) error {
+	return p.SetUint32(offset, math.Float32bits(value))
 }
 
 // GetBool возвращает значение bool по смещению offset
-func (p *Page) GetBool(offset uint32) bool {
-	return (p.bb[offset] == BoolTrueMark)
+func (p *Page) GetBool(offset uint32) (bool, error) {
+	buf, err := p.slice(offset, BoolSize)
+	if err != nil {
+		return false, err
+	}
+
+	return buf[0] == BoolTrueMark, nil
 }
 
 // SetBool записывает значение bool по смещению offset
-func (p *Page) SetBool(offset uint32, value bool) {
-	bValue := BoolFalseMark
+func (p *Page) SetBool(offset uint32, value bool) error {
+	buf, err := p.slice(offset, BoolSize)
+	if err != nil {
+		return err
+	}
+
+	buf[0] = BoolFalseMark
 	if value {
-		bValue = BoolTrueMark
+		buf[0] = BoolTrueMark
 	}
 
-	p.bb[offset] = bValue
+	return nil
 }
 
 // GetInt8 возвращает значение int8 по смещению offset
-func (p *Page) GetInt8(offset uint32) int8 {
-	return int8(p.bb[offset])
+func (p *Page) GetInt8(offset uint32) (int8, error) {
+	buf, err := p.slice(offset, Int8Size)
+	if err != nil {
+		return 0, err
+	}
+
+	return int8(buf[0]), nil
 }
 
 // SetInt8 записывает значение int8 по смещению offset
-func (p *Page) SetInt8(offset uint32, value int8) {
-	p.bb[offset] = byte(value)
+func (p *Page) SetInt8(offset uint32, value int8) error {
+	buf, err := p.slice(offset, Int8Size)
+	if err != nil {
+		return err
+	}
+
+	buf[0] = byte(value)
+
+	return nil
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/testutil"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
 )
 
@@ -37,50 +38,50 @@ func (ts *PageTestSuite) TestNewPageFromBytes() {
 	p := types.NewPageFromBytes(raw)
 	require.EqualValues(t, 8, p.Len())
 
-	assert.EqualValues(t, 12345678, p.GetInt64(0))
+	assert.EqualValues(t, 12345678, testutil.Must(p.GetInt64(0)))
 }
 
 func (ts *PageTestSuite) TestPutAndFetchBytes() {
 	p := types.NewPage(20)
-	p.PutBytes(0, []byte{0x13, 0x14, 0x00, 0x15})
-	p.PutBytes(8, []byte{0x23, 0x24, 0x00, 0x25})
+	ts.Require().NoError(p.PutBytes(0, []byte{0x13, 0x14, 0x00, 0x15}))
+	ts.Require().NoError(p.PutBytes(8, []byte{0x23, 0x24, 0x00, 0x25}))
 
 	ts.Equal(
 		[]byte{0x13, 0x14, 0x00, 0x15, 0x00, 0x00, 0x00, 0x00},
-		p.FetchBytes(0, 8),
+		testutil.Must(p.FetchBytes(0, 8)),
 	)
 	ts.Equal(
 		[]byte{0x23, 0x24, 0x00, 0x25, 0x00, 0x00, 0x00, 0x00},
-		p.FetchBytes(8, 8),
+		testutil.Must(p.FetchBytes(8, 8)),
 	)
 }
 
 func (ts *PageTestSuite) TestGetAndSetInt32() {
 	p := types.NewPage(24)
-	p.SetInt32(0, 12345)
-	p.SetInt32(4, -12345)
-	p.SetInt32(16, 0x7fffffff)
-	ts.Equal(int32(12345), p.GetInt32(0))
-	ts.Equal(int32(-12345), p.GetInt32(4))
-	ts.Equal(int32(0x7fffffff), p.GetInt32(16))
+	ts.Require().NoError(p.SetInt32(0, 12345))
+	ts.Require().NoError(p.SetInt32(4, -12345))
+	ts.Require().NoError(p.SetInt32(16, 0x7fffffff))
+	ts.Equal(int32(12345), testutil.Must(p.GetInt32(0)))
+	ts.Equal(int32(-12345), testutil.Must(p.GetInt32(4)))
+	ts.Equal(int32(0x7fffffff), testutil.Must(p.GetInt32(16)))
 }
 
 func (ts *PageTestSuite) TestGetAndSetUint32() {
 	p := types.NewPage(24)
-	p.SetUint32(0, 12345)
-	p.SetUint32(16, 0xffffffff)
-	ts.Equal(uint32(12345), p.GetUint32(0))
-	ts.Equal(uint32(0xffffffff), p.GetUint32(16))
+	ts.Require().NoError(p.SetUint32(0, 12345))
+	ts.Require().NoError(p.SetUint32(16, 0xffffffff))
+	ts.Equal(uint32(12345), testutil.Must(p.GetUint32(0)))
+	ts.Equal(uint32(0xffffffff), testutil.Must(p.GetUint32(16)))
 }
 
 func (ts *PageTestSuite) TestGetAndSetInt64() {
 	p := types.NewPage(24)
-	p.SetInt64(0, 12345)
-	p.SetInt64(8, -12345)
-	p.SetInt64(16, 0x7fffffffffffffff)
-	ts.Equal(int64(12345), p.GetInt64(0))
-	ts.Equal(int64(-12345), p.GetInt64(8))
-	ts.Equal(int64(0x7fffffffffffffff), p.GetInt64(16))
+	ts.Require().NoError(p.SetInt64(0, 12345))
+	ts.Require().NoError(p.SetInt64(8, -12345))
+	ts.Require().NoError(p.SetInt64(16, 0x7fffffffffffffff))
+	ts.Equal(int64(12345), testutil.Must(p.GetInt64(0)))
+	ts.Equal(int64(-12345), testutil.Must(p.GetInt64(8)))
+	ts.Equal(int64(0x7fffffffffffffff), testutil.Must(p.GetInt64(16)))
 }
 
 func (ts *PageTestSuite) TestGetAndSetString() {
@@ -94,35 +95,35 @@ func (ts *PageTestSuite) TestGetAndSetString() {
 	var offset uint32
 
 	for _, s := range cases {
-		p.SetString(offset, s)
+		ts.Require().NoError(p.SetString(offset, s))
 		offset += uint32(len(s) + 4)
 	}
 
 	offset = 0
 	for _, s := range cases {
-		ts.Equal(s, p.GetString(offset))
+		ts.Equal(s, testutil.Must(p.GetString(offset)))
 		offset += uint32(len(s) + 4)
 	}
 }
 
 func (ts *PageTestSuite) TestGetAndSetFloat32() {
 	p := types.NewPage(24)
-	p.SetFloat32(0, 12345.245)
-	p.SetFloat32(4, -12345.245)
-	p.SetFloat32(16, 0x7fffffff)
-	ts.Equal(float32(12345.245), p.GetFloat32(0))
-	ts.Equal(float32(-12345.245), p.GetFloat32(4))
-	ts.Equal(float32(0x7fffffff), p.GetFloat32(16))
+	ts.Require().NoError(p.SetFloat32(0, 12345.245))
+	ts.Require().NoError(p.SetFloat32(4, -12345.245))
+	ts.Require().NoError(p.SetFloat32(16, 0x7fffffff))
+	ts.Equal(float32(12345.245), testutil.Must(p.GetFloat32(0)))
+	ts.Equal(float32(-12345.245), testutil.Must(p.GetFloat32(4)))
+	ts.Equal(float32(0x7fffffff), testutil.Must(p.GetFloat32(16)))
 }
 
 func (ts *PageTestSuite) TestGetAndSetBool() {
 	p := types.NewPage(4)
-	p.SetBool(0, true)
-	p.SetBool(1, false)
-	p.SetBool(2, true)
-	ts.Equal(true, p.GetBool(0))
-	ts.Equal(false, p.GetBool(1))
-	ts.Equal(true, p.GetBool(2))
+	ts.Require().NoError(p.SetBool(0, true))
+	ts.Require().NoError(p.SetBool(1, false))
+	ts.Require().NoError(p.SetBool(2, true))
+	ts.Equal(true, testutil.Must(p.GetBool(0)))
+	ts.Equal(false, testutil.Must(p.GetBool(1)))
+	ts.Equal(true, testutil.Must(p.GetBool(2)))
 	ts.Equal([]byte{types.BoolTrueMark, types.BoolFalseMark, types.BoolTrueMark, 0x0}, p.Content())
 }
 
@@ -136,8 +137,84 @@ func (ts *PageTestSuite) TestPageBytesLenFunctions() {
 
 func (ts *PageTestSuite) TestGetAndSetInt8() {
 	p := types.NewPage(24)
-	p.SetInt8(0, 127)
-	p.SetInt8(1, -127)
-	ts.Equal(int8(127), p.GetInt8(0))
-	ts.Equal(int8(-127), p.GetInt8(1))
+	ts.Require().NoError(p.SetInt8(0, 127))
+	ts.Require().NoError(p.SetInt8(1, -127))
+	ts.Equal(int8(127), testutil.Must(p.GetInt8(0)))
+	ts.Equal(int8(-127), testutil.Must(p.GetInt8(1)))
+}
+
+func (ts *PageTestSuite) TestOutOfBounds() {
+	p := types.NewPage(16)
+
+	_, err := p.GetInt64(12)
+	ts.ErrorIs(err, types.ErrPageOutOfBounds)
+
+	_, err = p.GetInt32(0xfffffffe)
+	ts.ErrorIs(err, types.ErrPageOutOfBounds)
+
+	_, err = p.GetInt8(16)
+	ts.ErrorIs(err, types.ErrPageOutOfBounds)
+
+	_, err = p.GetBool(16)
+	ts.ErrorIs(err, types.ErrPageOutOfBounds)
+
+	_, err = p.FetchBytes(10, 7)
+	ts.ErrorIs(err, types.ErrPageOutOfBounds)
+
+	ts.ErrorIs(p.SetInt64(9, 1), types.ErrPageOutOfBounds)
+	ts.ErrorIs(p.SetUint32(13, 1), types.ErrPageOutOfBounds)
+	ts.ErrorIs(p.SetString(0, "too long for this page"), types.ErrPageOutOfBounds)
+	ts.ErrorIs(p.PutBytes(15, []byte{1, 2}), types.ErrPageOutOfBounds)
+
+	// Неудачная запись не меняет страницу
+	ts.Equal(make([]byte, 16), p.Content())
+
+	// Длина строки на странице повреждена
+	ts.Require().NoError(p.SetInt32(0, 100))
+
+	_, err = p.GetString(0)
+	ts.ErrorIs(err, types.ErrPageOutOfBounds)
+
+	ts.Require().NoError(p.SetInt32(0, -1))
+
+	_, err = p.GetBytes(0)
+	ts.ErrorIs(err, types.ErrPageOutOfBounds)
+}
+
+func (ts *PageTestSuite) TestFetchBytesIsView() {
+	p := types.NewPage(8)
+	ts.Require().NoError(p.SetBytes(0, []byte{1, 2}))
+
+	view := testutil.Must(p.GetBytes(0))
+	ts.Equal([]byte{1, 2}, view)
+	ts.Equal(2, cap(view))
+
+	ts.Require().NoError(p.PutBytes(4, []byte{7}))
+	ts.Equal([]byte{7, 2}, view)
+}
+
+func (ts *PageTestSuite) TestNoAllocations() {
+	p := types.NewPage(64)
+	ts.Require().NoError(p.SetString(16, "sophiadb"))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = p.SetInt32(0, 1)
+		_ = p.SetInt64(4, 2)
+		_ = p.SetInt8(12, 3)
+		_ = p.SetBool(13, true)
+		_ = p.SetBytes(32, []byte("page"))
+
+		_, _ = p.GetInt32(0)
+		_, _ = p.GetInt64(4)
+		_, _ = p.GetInt8(12)
+		_, _ = p.GetBool(13)
+		_, _ = p.GetBytes(16)
+	})
+
+	ts.Zero(allocs)
+
+	// Строка копируется со страницы не больше одного раза
+	ts.LessOrEqual(testing.AllocsPerRun(100, func() {
+		_, _ = p.GetString(16)
+	}), 1.0)
 }
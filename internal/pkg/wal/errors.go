@@ -13,3 +13,6 @@ var ErrFailedToAppendNewRecord = errors.Wrap(ErrWAL, "failed to add new record t
 
 // ErrFailedToCreateNewIterator — ошибка при создании нового итератора
 var ErrFailedToCreateNewIterator = errors.Wrap(ErrWAL, "failed to create a new wal iterator")
+
+// ErrBadLogBlock — блок журнала поврежден: граница или длина записи выходят за блок
+var ErrBadLogBlock = errors.Wrap(ErrWAL, "bad log block")
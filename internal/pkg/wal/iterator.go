@@ -1,6 +1,8 @@
 package wal
 
 import (
+	"slices"
+
 	"github.com/pkg/errors"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/storage"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
@@ -46,10 +48,15 @@ func (it *Iterator) Next() ([]byte, error) {
 		}
 	}
 
-	rec := it.p.GetBytes(it.currentPos)
+	rec, err := it.p.GetBytes(it.currentPos)
+	if err != nil {
+		return nil, errors.WithMessagef(ErrBadLogBlock, "%s: %s", it.blk, err)
+	}
+
 	it.currentPos += int32Size + uint32(len(rec))
 
-	return rec, nil
+	// Страница итератора переиспользуется для следующего блока, поэтому запись копируем
+	return slices.Clone(rec), nil
 }
 
 // Перемещаем итератор на следующий блок
@@ -58,7 +65,17 @@ func (it *Iterator) moveToBlock(blk types.Block) error {
 		return err
 	}
 
-	it.boundary = it.p.GetUint32(blockStart)
+	boundary, err := it.p.GetUint32(blockStart)
+	if err != nil {
+		return errors.WithMessagef(ErrBadLogBlock, "%s: %s", blk, err)
+	}
+
+	it.boundary = boundary
+
+	// Нулевая граница у блока, заголовок которого не успели записать, — в нем нет записей
+	if it.boundary == 0 {
+		it.boundary = it.fm.BlockSize()
+	}
 	it.currentPos = it.boundary
 
 	return nil
@@ -24,6 +24,9 @@ type Manager struct {
 	currentBlock types.Block
 	latestLSN    types.LSN
 	lastSavedLSN types.LSN
+
+	// savedBoundary — граница записей текущего блока на диске
+	savedBoundary uint32
 }
 
 // NewManager создает новый объект LogManager
@@ -50,6 +53,18 @@ func NewManager(fm *storage.Manager, logFileName string) (*Manager, error) {
 		if err != nil {
 			return nil, errors.WithMessage(ErrFailedToCreateNewManager, err.Error())
 		}
+
+		lm.savedBoundary, err = lm.logPage.GetUint32(blockStart)
+		if err != nil {
+			return nil, errors.WithMessage(ErrFailedToCreateNewManager, err.Error())
+		}
+
+		// Сбой после добавления блока, но до записи его заголовка, оставляет пустой блок с нулевой границей
+		if lm.savedBoundary == 0 {
+			if err := lm.logPage.SetUint32(blockStart, fm.BlockSize()); err != nil {
+				return nil, errors.WithMessage(ErrFailedToCreateNewManager, err.Error())
+			}
+		}
 	}
 
 	return lm, nil
@@ -77,15 +92,56 @@ func (lm *Manager) flush(lsn types.LSN, force bool, skipLock bool) error {
 	}
 
 	if lsn >= lm.lastSavedLSN || force {
-		err := lm.fm.Write(lm.currentBlock, lm.logPage)
-		if err != nil {
-			return err
-		}
+		return lm.writeCurrentBlock()
 	}
 
 	return nil
 }
 
+// writeCurrentBlock пишет на диск новые записи текущего блока.
+// Блок пишется дважды: сначала новые записи со старой границей, затем новая граница.
+// Если запись блока оборвется, то на диске останется старая граница и недописанные записи не будут видны
+func (lm *Manager) writeCurrentBlock() error {
+	boundary, err := lm.logPage.GetUint32(blockStart)
+	if err != nil {
+		return err
+	}
+
+	if boundary == lm.savedBoundary {
+		return nil
+	}
+
+	if err := lm.logPage.SetUint32(blockStart, lm.savedBoundary); err != nil {
+		return err
+	}
+
+	err = lm.writeAndSync(lm.currentBlock)
+
+	if err1 := lm.logPage.SetUint32(blockStart, boundary); err1 != nil {
+		return err1
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := lm.writeAndSync(lm.currentBlock); err != nil {
+		return err
+	}
+
+	lm.savedBoundary = boundary
+
+	return nil
+}
+
+func (lm *Manager) writeAndSync(blk types.Block) error {
+	if err := lm.fm.Write(blk, lm.logPage); err != nil {
+		return err
+	}
+
+	return lm.fm.Sync(blk.Filename)
+}
+
 // Iterator возвращает новый итератор по журналу
 func (lm *Manager) Iterator() (*Iterator, error) {
 	if err := lm.Flush(0, true); err != nil {
@@ -105,7 +161,11 @@ func (lm *Manager) Append(logRec []byte) (types.LSN, error) {
 	lm.m.Lock()
 	defer lm.m.Unlock()
 
-	boundary := lm.logPage.GetUint32(blockStart)
+	boundary, err := lm.logPage.GetUint32(blockStart)
+	if err != nil {
+		return 0, errors.WithMessage(ErrFailedToAppendNewRecord, err.Error())
+	}
+
 	recsize := uint32(len(logRec))
 	bytesNeeded := recsize + int32Size
 
@@ -123,13 +183,19 @@ func (lm *Manager) Append(logRec []byte) (types.LSN, error) {
 			return 0, errors.WithMessage(ErrFailedToAppendNewRecord, err.Error())
 		}
 
-		boundary = lm.logPage.GetUint32(blockStart)
+		boundary = lm.fm.BlockSize()
 	}
 
 	// Новую запись пишем в конец блока. Конец — это граница последней записи в логе
 	recPos := boundary - bytesNeeded
-	lm.logPage.SetBytes(recPos, logRec)
-	lm.logPage.SetUint32(blockStart, recPos) // Устанавливаем новую границу
+	if err := lm.logPage.SetBytes(recPos, logRec); err != nil {
+		return 0, errors.WithMessage(ErrFailedToAppendNewRecord, err.Error())
+	}
+
+	// Устанавливаем новую границу
+	if err := lm.logPage.SetUint32(blockStart, recPos); err != nil {
+		return 0, errors.WithMessage(ErrFailedToAppendNewRecord, err.Error())
+	}
 
 	lm.latestLSN++
 
@@ -143,11 +209,15 @@ func (lm *Manager) appendNewBlock() (types.Block, error) {
 		return blk, err
 	}
 
-	lm.logPage.SetUint32(blockStart, lm.fm.BlockSize())
+	if err = lm.logPage.SetUint32(blockStart, lm.fm.BlockSize()); err != nil {
+		return blk, err
+	}
 
-	if err = lm.fm.Write(blk, lm.logPage); err != nil {
+	if err = lm.writeAndSync(blk); err != nil {
 		return blk, err
 	}
 
+	lm.savedBoundary = lm.fm.BlockSize()
+
 	return blk, nil
 }
@@ -73,7 +73,7 @@ func (ts *WalManagerTestSuite) TestCreateManagerExistsLogFile() {
 	ts.Require().NotNil(fm)
 
 	p := types.NewPage(defaultBlockSize)
-	p.SetUint32(0, 4)
+	ts.Require().NoError(p.SetUint32(0, 4))
 
 	for i := 0; i < 2; i++ {
 		_, nerr := fm.Append(walFile)
@@ -116,3 +116,84 @@ func (ts *WalManagerTestSuite) TestCreateRecords() {
 		i--
 	}
 }
+
+func (ts *WalManagerTestSuite) readRecords(fm *storage.Manager) []string {
+	m, err := wal.NewManager(fm, walFile)
+	ts.Require().NoError(err)
+
+	it, err := m.Iterator()
+	ts.Require().NoError(err)
+
+	records := []string{}
+
+	for it.HasNext() {
+		d, err := it.Next()
+		ts.Require().NoError(err)
+
+		records = append(records, string(d))
+	}
+
+	return records
+}
+
+func (ts *WalManagerTestSuite) TestTornFlush() {
+	// Создание управляющего файла — одна запись на диск, создание журнала — две, сброс блока с новыми записями — еще две
+	const writesBeforeCrash = 5
+
+	for seed := int64(0); seed < 10; seed++ {
+		disk := storage.NewMemoryBackend()
+		faulty := storage.NewFaultyBackend(disk,
+			storage.WithCrashAfterWrites(writesBeforeCrash),
+			storage.WithTornWrites(40),
+			storage.WithFaultSeed(seed),
+		)
+
+		fm, err := storage.NewManager(faulty, defaultBlockSize)
+		ts.Require().NoError(err)
+
+		m, err := wal.NewManager(fm, walFile)
+		ts.Require().NoError(err)
+
+		lsn, err := m.Append([]byte("committed"))
+		ts.Require().NoError(err)
+		ts.Require().NoError(m.Flush(lsn, false))
+
+		lsn, err = m.Append([]byte("torn"))
+		ts.Require().NoError(err)
+		ts.Require().Error(m.Flush(lsn, false))
+		ts.Require().True(faulty.Crashed())
+
+		fm, err = storage.NewManager(disk, defaultBlockSize)
+		ts.Require().NoError(err)
+
+		// Оборванная запись блока не видна и не портит записи, которые уже были на диске
+		ts.Equal([]string{"committed"}, ts.readRecords(fm))
+	}
+}
+
+func (ts *WalManagerTestSuite) TestEmptyLastBlock() {
+	fm, err := storage.NewManager(storage.NewMemoryBackend(), defaultBlockSize)
+	ts.Require().NoError(err)
+
+	m, err := wal.NewManager(fm, walFile)
+	ts.Require().NoError(err)
+
+	lsn, err := m.Append([]byte("record 1"))
+	ts.Require().NoError(err)
+	ts.Require().NoError(m.Flush(lsn, false))
+
+	// Сбой между добавлением блока в журнал и записью его заголовка
+	_, err = fm.Append(walFile)
+	ts.Require().NoError(err)
+
+	ts.Equal([]string{"record 1"}, ts.readRecords(fm))
+
+	m, err = wal.NewManager(fm, walFile)
+	ts.Require().NoError(err)
+
+	lsn, err = m.Append([]byte("record 2"))
+	ts.Require().NoError(err)
+	ts.Require().NoError(m.Flush(lsn, false))
+
+	ts.Equal([]string{"record 2", "record 1"}, ts.readRecords(fm))
+}
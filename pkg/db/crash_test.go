@@ -0,0 +1,251 @@
+package db_test
+
+import (
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/storage"
+	"github.com/unhandled-exception/sophiadb/pkg/db"
+)
+
+const (
+	crashSeeds         = 50
+	crashCycles        = 4
+	crashTransactions  = 25
+	crashBlockSize     = 512
+	crashSectorSize    = 128
+	crashBuffersPool   = 8
+	crashMaxWrites     = 250
+	crashIOErrorRate   = 0.001
+	crashRollbackRatio = 0.2
+)
+
+// crashState — содержимое таблицы: id → val
+type crashState map[int64]int64
+
+// TestCrashRecovery гоняет случайные транзакции на хранилище со сбоями: база падает после случайного числа записей,
+// последняя запись обрывается, несброшенные записи теряются, а чтения и записи иногда завершаются ошибкой.
+// После каждого сбоя открываем базу заново и проверяем, что в таблице видны ровно подтвержденные транзакции.
+// Транзакция, на фиксации которой случился сбой, может как сохраниться, так и пропасть, но только целиком.
+// На четных seed таблица сжата, на каждом третьем база зашифрована
+func TestCrashRecovery(t *testing.T) {
+	for seed := int64(1); seed <= crashSeeds; seed++ {
+		t.Run(fmt.Sprintf("seed_%d", seed), func(t *testing.T) {
+			runCrashWorkload(t, seed)
+		})
+	}
+}
+
+func runCrashWorkload(t *testing.T, seed int64) {
+	t.Helper()
+
+	rnd := rand.New(rand.NewSource(seed)) //nolint:gosec
+	disk := storage.NewMemoryBackend()
+
+	var key []byte
+	if seed%3 == 0 {
+		key = []byte("0123456789abcdef")
+	}
+
+	sdb := openCrashDB(t, disk, key)
+
+	trx, err := sdb.Transaction()
+	require.NoError(t, err)
+
+	cmd := "create table crash (id int64, val int64)"
+	if seed%2 == 0 {
+		cmd += " with (compression = 'zstd')"
+	}
+
+	_, err = sdb.Planner().ExecuteCommand(cmd, trx)
+	require.NoError(t, err)
+	require.NoError(t, trx.Commit())
+	require.NoError(t, sdb.Close())
+
+	committed := crashState{}
+	nextID := int64(1)
+
+	for cycle := 0; cycle < crashCycles; cycle++ {
+		opts := []storage.FaultOpt{
+			storage.WithFaultSeed(rnd.Int63()),
+			storage.WithCrashAfterWrites(rnd.Intn(crashMaxWrites)),
+			storage.WithTornWrites(crashSectorSize),
+		}
+
+		if rnd.Intn(2) == 0 {
+			opts = append(opts, storage.WithDropUnsynced())
+		}
+
+		if rnd.Intn(2) == 0 {
+			opts = append(opts, storage.WithIOErrors(crashIOErrorRate))
+		}
+
+		faulty := storage.NewFaultyBackend(disk, opts...)
+
+		inDoubt := runUntilCrash(t, faulty, key, rnd, committed, &nextID)
+
+		faulty.Crash()
+
+		actual := readCrashState(t, disk, key)
+
+		switch {
+		case maps.Equal(actual, committed):
+		case inDoubt != nil && maps.Equal(actual, inDoubt):
+			committed = inDoubt
+		default:
+			require.Failf(t, "unexpected state after crash",
+				"cycle %d, writes %d\nexpected: %v\nin doubt: %v\nactual: %v",
+				cycle, faulty.Writes(), committed, inDoubt, actual,
+			)
+		}
+	}
+}
+
+// runUntilCrash выполняет транзакции до первой ошибки и подтвержденные добавляет в committed.
+// Возвращает состояние таблицы с транзакцией, фиксация которой завершилась ошибкой, или nil
+func runUntilCrash(t *testing.T, faulty *storage.FaultyBackend, key []byte, rnd *rand.Rand, committed crashState, nextID *int64) crashState {
+	t.Helper()
+
+	sdb, err := db.NewDatabase("",
+		db.WithStorageBackend(faulty),
+		db.WithEncryptionKey(key),
+		db.WithBlockSize(crashBlockSize),
+		db.WithBuffersPoolLen(crashBuffersPool),
+		db.WithBackgroundWriter(0, 0),
+		db.WithReadAhead(0),
+	)
+	if err != nil {
+		// Сбой во время восстановления
+		return nil
+	}
+
+	defer func() {
+		faulty.Crash()
+		_ = sdb.Close()
+	}()
+
+	for i := 0; i < crashTransactions; i++ {
+		trx, err := sdb.Transaction()
+		if err != nil {
+			return nil
+		}
+
+		state := maps.Clone(committed)
+
+		for op := rnd.Intn(5) + 1; op > 0; op-- { //nolint:mnd
+			var cmd string
+
+			ids := make([]int64, 0, len(state))
+			for id := range state {
+				ids = append(ids, id)
+			}
+
+			slices.Sort(ids)
+
+			switch n := rnd.Intn(4); { //nolint:mnd
+			case n < 2 || len(ids) == 0:
+				val := rnd.Int63n(1000) //nolint:mnd
+				cmd = fmt.Sprintf("insert into crash (id, val) values (%d, %d)", *nextID, val)
+				state[*nextID] = val
+				*nextID++
+			case n == 2:
+				id, val := ids[rnd.Intn(len(ids))], rnd.Int63n(1000) //nolint:mnd
+				cmd = fmt.Sprintf("update crash set val = %d where id = %d", val, id)
+				state[id] = val
+			default:
+				id := ids[rnd.Intn(len(ids))]
+				cmd = fmt.Sprintf("delete from crash where id = %d", id)
+				delete(state, id)
+			}
+
+			if _, err := sdb.Planner().ExecuteCommand(cmd, trx); err != nil {
+				return nil
+			}
+		}
+
+		if rnd.Float64() < crashRollbackRatio {
+			if err := trx.Rollback(); err != nil {
+				return nil
+			}
+
+			continue
+		}
+
+		if err := trx.Commit(); err != nil {
+			return state
+		}
+
+		clear(committed)
+		maps.Copy(committed, state)
+	}
+
+	return nil
+}
+
+func openCrashDB(t *testing.T, backend storage.Backend, key []byte) *db.Database {
+	t.Helper()
+
+	sdb, err := db.NewDatabase("",
+		db.WithStorageBackend(backend),
+		db.WithEncryptionKey(key),
+		db.WithBlockSize(crashBlockSize),
+		db.WithBuffersPoolLen(crashBuffersPool),
+		db.WithBackgroundWriter(0, 0),
+	)
+	require.NoError(t, err)
+
+	return sdb
+}
+
+// readCrashState открывает базу без сбоев, при этом она восстанавливается, и читает таблицу
+func readCrashState(t *testing.T, disk storage.Backend, key []byte) crashState {
+	t.Helper()
+
+	sdb := openCrashDB(t, disk, key)
+
+	defer func() {
+		require.NoError(t, sdb.Close())
+	}()
+
+	trx, err := sdb.Transaction()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, trx.Commit())
+	}()
+
+	qp, err := sdb.Planner().CreateQueryPlan("select id, val from crash", trx)
+	require.NoError(t, err)
+
+	sc, err := qp.Open()
+	require.NoError(t, err)
+
+	defer sc.Close()
+
+	state := crashState{}
+
+	require.NoError(t, scan.ForEach(sc, func() (bool, error) {
+		id, err := sc.GetInt64("id")
+		if err != nil {
+			return true, err
+		}
+
+		val, err := sc.GetInt64("val")
+		if err != nil {
+			return true, err
+		}
+
+		require.NotContains(t, state, id)
+
+		state[id] = val
+
+		return false, nil
+	}))
+
+	return state
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"time"
 
 	"github.com/unhandled-exception/sophiadb/internal/pkg/buffers"
@@ -8,6 +9,7 @@ import (
 	"github.com/unhandled-exception/sophiadb/internal/pkg/metadata"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/planner" //nolint:typecheck
 	"github.com/unhandled-exception/sophiadb/internal/pkg/storage"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/systables"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/transaction"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/wal"
 )
@@ -17,20 +19,43 @@ const (
 	DefaultLogFilename = "wal_log.dat"
 
 	DefaultBuffersPoolLen = 1024
+
+	DefaultBackgroundWriterMaxPages = 100
+
+	DefaultReadAheadBlocks = 16
+
+	DefaultMaxOpenFiles = storage.DefaultMaxOpenFiles
+
+	// MemoryDataDir — путь к базе, которая целиком хранится в памяти и пропадает после закрытия
+	MemoryDataDir = storage.MemoryPath
 )
 
 var (
 	DefaultPinLockTimeout         time.Duration = 1 * time.Second
 	DefaultTransactionLockTimeout time.Duration = 1 * time.Second
+	DefaultBackgroundWriterDelay  time.Duration = 200 * time.Millisecond
 )
 
 type Database struct {
 	blockSize      uint32
 	logFileName    string
 	buffersPoolLen int
+	maxOpenFiles   int
+	backend        storage.Backend
+	diskBackend    string
+	compression    string
+	encryptionKey  []byte
+
+	bufferReplacementPolicy string
+	bufferPoolPartitions    int
+	readAheadBlocks         int
+
+	backgroundWriterDelay    time.Duration
+	backgroundWriterMaxPages int
 
 	pinLockTimeout         time.Duration
 	transactionLockTimeout time.Duration
+	transactionPinQuota    int
 
 	fm       *storage.Manager
 	wal      *wal.Manager
@@ -38,15 +63,36 @@ type Database struct {
 	trxMan   *transaction.TRXManager
 	metadata *metadata.Manager
 	planner  planner.Planner
+
+	// opened — база открыта и восстановлена, только такую базу можно закрыть штатно
+	opened bool
 }
 
 type DatabaseOption func(*Database)
 
+// Stats — статистика работы базы
+type Stats struct {
+	// Buffers — обращения к пулу буферов: попадания, промахи, вытеснения, запись измененных блоков и ожидания буферов,
+	// в том числе в разрезе файлов. Помогает подобрать buffers_pool_len
+	Buffers buffers.Stats
+
+	// Files — открытия и закрытия файлов данных. Частые повторные открытия говорят о том, что max_open_files мал
+	Files storage.FilesStats
+}
+
+// NewDatabase открывает базу в папке dataDir. Для MemoryDataDir создает новую базу в памяти
 func NewDatabase(dataDir string, opts ...DatabaseOption) (*Database, error) {
 	db := &Database{
 		blockSize:      DefaultBlockSize,
 		logFileName:    DefaultLogFilename,
 		buffersPoolLen: DefaultBuffersPoolLen,
+		maxOpenFiles:   DefaultMaxOpenFiles,
+
+		bufferReplacementPolicy: buffers.DefaultReplacementPolicyName,
+		readAheadBlocks:         DefaultReadAheadBlocks,
+
+		backgroundWriterDelay:    DefaultBackgroundWriterDelay,
+		backgroundWriterMaxPages: DefaultBackgroundWriterMaxPages,
 
 		pinLockTimeout:         DefaultPinLockTimeout,
 		transactionLockTimeout: DefaultTransactionLockTimeout,
@@ -56,32 +102,80 @@ func NewDatabase(dataDir string, opts ...DatabaseOption) (*Database, error) {
 		opt(db)
 	}
 
-	fm, err := storage.NewFileManager(dataDir, db.blockSize)
+	newPolicy, err := buffers.ReplacementPolicyByName(db.bufferReplacementPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := storage.ValidCompression(db.compression); err != nil {
+		return nil, err
+	}
+
+	if err := storage.ValidBackend(db.diskBackend); err != nil {
+		return nil, err
+	}
+
+	fm, err := db.newStorageManager(dataDir)
 	if err != nil {
 		return nil, err
 	}
 
+	// Часть блоков уже зашифрована новым ключом, такую базу может открыть только RotateKey
+	if fm.Control().KeyRotation {
+		_ = fm.Close()
+
+		return nil, storage.ErrKeyRotationInterrupted
+	}
+
 	wal, err := wal.NewManager(fm, db.logFileName)
 	if err != nil {
+		_ = fm.Close()
+
 		return nil, err
 	}
 
 	db.fm = fm
 	db.wal = wal
 
-	db.bm = buffers.NewManager(db.fm, db.wal, db.buffersPoolLen, buffers.WithPinLockTimeout(db.pinLockTimeout))
-	db.trxMan = transaction.NewTRXManager(db.fm, db.bm, db.wal, transaction.WithLockTimeout(db.transactionLockTimeout))
+	partitions := db.bufferPoolPartitions
+	if partitions <= 0 {
+		partitions = buffers.AutoPartitions(db.buffersPoolLen)
+	}
+
+	db.bm = buffers.NewManager(db.fm, db.wal, db.buffersPoolLen,
+		buffers.WithPinLockTimeout(db.pinLockTimeout),
+		buffers.WithPartitions(partitions),
+		buffers.WithReadAhead(db.readAheadBlocks),
+		buffers.WithReplacementPolicy(newPolicy),
+		buffers.WithBackgroundWriter(db.backgroundWriterDelay, db.backgroundWriterMaxPages),
+	)
+	db.trxMan = transaction.NewTRXManager(db.fm, db.bm, db.wal,
+		transaction.WithLockTimeout(db.transactionLockTimeout),
+		transaction.WithDefaultPinQuota(db.transactionPinQuota),
+	)
 
 	db.metadata, err = db.newMetadataManager()
 	if err != nil {
+		// Освобождаем папку с данными, чтобы базу можно было открыть снова
+		_ = db.Close()
+
 		return nil, err
 	}
 
 	db.planner = planner.NewSQLPlanner(
-		planner.NewSQLQueryPlanner(db.metadata),
-		indexplanner.NewIndexCommandsPlanner(db.metadata),
+		planner.NewSQLQueryPlanner(
+			db.metadata,
+			planner.WithVirtualTable(systables.LocksTableName, systables.NewLocksTable(db.trxMan)),
+			planner.WithVirtualTable(systables.TransactionsTableName, systables.NewTransactionsTable(db.trxMan)),
+			planner.WithVirtualTable(systables.BuffersTableName, systables.NewBuffersTable(db.bm)),
+		),
+		indexplanner.NewIndexCommandsPlanner(db.metadata,
+			indexplanner.WithDefaultCompression(db.compression),
+		),
 	)
 
+	db.opened = true
+
 	return db, nil
 }
 
@@ -103,6 +197,78 @@ func WithBuffersPoolLen(buffersPoolLen int) DatabaseOption {
 	}
 }
 
+// WithBufferReplacementPolicy задает стратегию замены буферов в пуле: clock, lru или lru2
+func WithBufferReplacementPolicy(name string) DatabaseOption {
+	return func(db *Database) {
+		db.bufferReplacementPolicy = name
+	}
+}
+
+// WithBufferPoolPartitions делит пул буферов на n частей с независимыми блокировками,
+// чтобы параллельные запросы меньше ждали друг друга. Ноль — подобрать число частей по числу процессоров
+func WithBufferPoolPartitions(n int) DatabaseOption {
+	return func(db *Database) {
+		db.bufferPoolPartitions = n
+	}
+}
+
+// WithReadAhead задает окно упреждающего чтения: при последовательном чтении таблицы
+// до blocks следующих блоков заранее читаются в пул буферов. Ноль выключает упреждающее чтение
+func WithReadAhead(blocks int) DatabaseOption {
+	return func(db *Database) {
+		db.readAheadBlocks = blocks
+	}
+}
+
+// WithMaxOpenFiles ограничивает число одновременно открытых файлов данных.
+// Давно не использованные файлы закрываются и открываются снова при обращении. Ноль снимает ограничение
+func WithMaxOpenFiles(n int) DatabaseOption {
+	return func(db *Database) {
+		db.maxOpenFiles = n
+	}
+}
+
+// WithCompression задает алгоритм сжатия страниц новых таблиц: zstd, s2 или none.
+// В CREATE TABLE … WITH (compression = '…') можно выбрать другой алгоритм
+func WithCompression(codecName string) DatabaseOption {
+	return func(db *Database) {
+		db.compression = codecName
+	}
+}
+
+// WithEncryptionKey шифрует файлы данных и журнал ключом AES длиной 16, 24 или 32 байта.
+// Ключ задается при создании базы и нужен при каждом открытии, сменить его можно через RotateKey
+func WithEncryptionKey(key []byte) DatabaseOption {
+	return func(db *Database) {
+		db.encryptionKey = key
+	}
+}
+
+// WithStorageBackend задает хранилище файлов базы. Путь к базе тогда не используется
+func WithStorageBackend(backend storage.Backend) DatabaseOption {
+	return func(db *Database) {
+		db.backend = backend
+	}
+}
+
+// WithDiskBackend задает, как читать файлы базы на диске: storage.BackendFile (по умолчанию) читает блоки с диска в буферы,
+// storage.BackendMmap отображает файлы в память, и буферы смотрят прямо в отображение, пока страницу не изменят.
+// Сжатые и зашифрованные файлы всегда читаются с копированием
+func WithDiskBackend(name string) DatabaseOption {
+	return func(db *Database) {
+		db.diskBackend = name
+	}
+}
+
+// WithBackgroundWriter настраивает фоновую запись измененных буферов: раз в delay на диск пишется до maxPages страниц.
+// Нулевой delay выключает фоновую запись
+func WithBackgroundWriter(delay time.Duration, maxPages int) DatabaseOption {
+	return func(db *Database) {
+		db.backgroundWriterDelay = delay
+		db.backgroundWriterMaxPages = maxPages
+	}
+}
+
 func WithPinLockTimeout(pinLockTimeout time.Duration) DatabaseOption {
 	return func(db *Database) {
 		db.pinLockTimeout = pinLockTimeout
@@ -115,16 +281,32 @@ func WithTransactionLockTimeout(transactionLockTimeout time.Duration) DatabaseOp
 	}
 }
 
+// WithTransactionPinQuota ограничивает число буферов, которые может одновременно закрепить одна транзакция.
+// Ноль снимает ограничение
+func WithTransactionPinQuota(quota int) DatabaseOption {
+	return func(db *Database) {
+		db.transactionPinQuota = quota
+	}
+}
+
 func (db *Database) Planner() planner.Planner {
 	return db.planner
 }
 
+// Close закрывает базу. Закрытие считается штатным, если в базе не осталось незавершенных транзакций,
+// иначе при следующем открытии база пройдет восстановление
 func (db *Database) Close() error {
+	db.bm.Close()
+
+	if db.opened && len(db.trxMan.Transactions()) == 0 {
+		db.fm.MarkCleanShutdown()
+	}
+
 	return db.fm.Close()
 }
 
-func (db *Database) Transaction() (*transaction.Transaction, error) {
-	return db.trxMan.Transaction()
+func (db *Database) Transaction(opts ...transaction.TransactionOpt) (*transaction.Transaction, error) {
+	return db.trxMan.Transaction(opts...)
 }
 
 func (db *Database) IsNew() bool {
@@ -139,12 +321,77 @@ func (db *Database) BlockSize() uint32 {
 	return db.fm.BlockSize()
 }
 
+// CreatedAt возвращает время создания базы
+func (db *Database) CreatedAt() time.Time {
+	return db.fm.Control().CreatedAt
+}
+
+// WasCleanShutdown сообщает, была ли база в прошлый раз закрыта штатно
+func (db *Database) WasCleanShutdown() bool {
+	return db.fm.Control().CleanShutdown
+}
+
 func (db *Database) LogFileName() string {
 	return db.wal.LogFileName
 }
 
 func (db *Database) BuffersPoolLen() int {
-	return db.bm.Len
+	return db.bm.Len()
+}
+
+// ResizeBufferPool меняет длину пула буферов без перезапуска базы. Число частей пула не меняется.
+// При уменьшении ждет, пока освободятся закрепленные буферы, поэтому его нельзя вызывать из открытой транзакции
+func (db *Database) ResizeBufferPool(n int) error {
+	return db.bm.Resize(n)
+}
+
+// ResizeBufferPoolContext меняет длину пула буферов. Ожидание освобождения буферов прерывается по отмене ctx
+func (db *Database) ResizeBufferPoolContext(ctx context.Context, n int) error {
+	return db.bm.ResizeContext(ctx, n)
+}
+
+func (db *Database) BufferReplacementPolicy() string {
+	return db.bufferReplacementPolicy
+}
+
+// BufferPoolPartitions возвращает число частей пула буферов
+func (db *Database) BufferPoolPartitions() int {
+	return db.bm.Partitions()
+}
+
+func (db *Database) ReadAheadBlocks() int {
+	return db.bm.ReadAheadWindow()
+}
+
+func (db *Database) MaxOpenFiles() int {
+	return db.fm.MaxOpenFiles()
+}
+
+// Encrypted возвращает true, если файлы базы зашифрованы
+func (db *Database) Encrypted() bool {
+	return db.fm.Encrypted()
+}
+
+// Compression возвращает алгоритм сжатия страниц новых таблиц
+func (db *Database) Compression() string {
+	return db.compression
+}
+
+// DiskBackend возвращает, как база читает файлы на диске, см. WithDiskBackend
+func (db *Database) DiskBackend() string {
+	if db.diskBackend == "" {
+		return storage.BackendFile
+	}
+
+	return db.diskBackend
+}
+
+func (db *Database) BackgroundWriterDelay() time.Duration {
+	return db.backgroundWriterDelay
+}
+
+func (db *Database) BackgroundWriterMaxPages() int {
+	return db.backgroundWriterMaxPages
 }
 
 func (db *Database) PinLockTimeout() time.Duration {
@@ -155,6 +402,41 @@ func (db *Database) TransactionLockTimeout() time.Duration {
 	return db.trxMan.LockTimeout
 }
 
+func (db *Database) TransactionPinQuota() int {
+	return db.trxMan.PinQuota
+}
+
+// Stats возвращает статистику работы базы
+func (db *Database) Stats() Stats {
+	return Stats{
+		Buffers: db.bm.Stats(),
+		Files:   db.fm.FilesStats(),
+	}
+}
+
+func (db *Database) newStorageManager(dataDir string) (*storage.Manager, error) {
+	opts := []storage.ManagerOpt{
+		storage.WithMaxOpenFiles(db.maxOpenFiles),
+	}
+
+	if len(db.encryptionKey) > 0 {
+		opts = append(opts, storage.WithEncryptionKey(db.encryptionKey))
+	}
+
+	switch {
+	case db.backend != nil:
+		return storage.NewManager(db.backend, db.blockSize, opts...)
+	case dataDir == MemoryDataDir:
+		return storage.NewManager(storage.NewMemoryBackend(), db.blockSize, opts...)
+	}
+
+	if db.diskBackend == storage.BackendMmap {
+		return storage.NewMmapFileManager(dataDir, db.blockSize, opts...)
+	}
+
+	return storage.NewFileManager(dataDir, db.blockSize, opts...)
+}
+
 func (db *Database) newMetadataManager() (*metadata.Manager, error) {
 	var err error
 
@@ -1,14 +1,22 @@
 package db_test
 
 import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
 	"path"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/buffers"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/storage"
 	"github.com/unhandled-exception/sophiadb/pkg/db"
 )
 
@@ -18,6 +26,11 @@ const (
 	testWOBlockSize      = 3 * 1024
 	testWOLogFileName    = "custom_wal.log"
 	testWOBuffersPoolLen = 123
+	testWOBufferPolicy   = "lru2"
+	testWOPinQuota       = 32
+	testWOPartitions     = 3
+	testWOReadAhead      = 7
+	testWOMaxOpenFiles   = 9
 )
 
 var (
@@ -49,8 +62,15 @@ func (ts *DatabaseTestSuite) TestNewDatabase_Default() {
 	assert.EqualValues(t, db.DefaultBlockSize, sut.BlockSize())
 	assert.EqualValues(t, db.DefaultLogFilename, sut.LogFileName())
 	assert.EqualValues(t, db.DefaultBuffersPoolLen, sut.BuffersPoolLen())
+	assert.Equal(t, "clock", sut.BufferReplacementPolicy())
+	assert.Equal(t, buffers.AutoPartitions(db.DefaultBuffersPoolLen), sut.BufferPoolPartitions())
+	assert.Equal(t, db.DefaultReadAheadBlocks, sut.ReadAheadBlocks())
+	assert.Equal(t, db.DefaultMaxOpenFiles, sut.MaxOpenFiles())
 	assert.EqualValues(t, db.DefaultPinLockTimeout, sut.PinLockTimeout())
 	assert.EqualValues(t, db.DefaultTransactionLockTimeout, sut.TransactionLockTimeout())
+	assert.EqualValues(t, db.DefaultBackgroundWriterDelay, sut.BackgroundWriterDelay())
+	assert.EqualValues(t, db.DefaultBackgroundWriterMaxPages, sut.BackgroundWriterMaxPages())
+	assert.Zero(t, sut.TransactionPinQuota())
 }
 
 func (ts *DatabaseTestSuite) TestNewDatabase_WithOptions() {
@@ -62,8 +82,13 @@ func (ts *DatabaseTestSuite) TestNewDatabase_WithOptions() {
 		db.WithBlockSize(testWOBlockSize),
 		db.WithLogFileName(testWOLogFileName),
 		db.WithBuffersPoolLen(testWOBuffersPoolLen),
+		db.WithBufferReplacementPolicy(testWOBufferPolicy),
+		db.WithBufferPoolPartitions(testWOPartitions),
+		db.WithReadAhead(testWOReadAhead),
+		db.WithMaxOpenFiles(testWOMaxOpenFiles),
 		db.WithPinLockTimeout(testWOPinLockTimeout),
 		db.WithTransactionLockTimeout(testWOTransactionLockTimeout),
+		db.WithTransactionPinQuota(testWOPinQuota),
 	)
 	require.NoError(t, err)
 
@@ -76,8 +101,21 @@ func (ts *DatabaseTestSuite) TestNewDatabase_WithOptions() {
 	assert.EqualValues(t, testWOBlockSize, sut.BlockSize())
 	assert.EqualValues(t, testWOLogFileName, sut.LogFileName())
 	assert.EqualValues(t, testWOBuffersPoolLen, sut.BuffersPoolLen())
+	assert.Equal(t, testWOBufferPolicy, sut.BufferReplacementPolicy())
 	assert.EqualValues(t, testWOPinLockTimeout, sut.PinLockTimeout())
 	assert.EqualValues(t, testWOTransactionLockTimeout, sut.TransactionLockTimeout())
+	assert.EqualValues(t, testWOPinQuota, sut.TransactionPinQuota())
+	assert.Equal(t, testWOPartitions, sut.BufferPoolPartitions())
+	assert.Equal(t, testWOReadAhead, sut.ReadAheadBlocks())
+	assert.Equal(t, testWOMaxOpenFiles, sut.MaxOpenFiles())
+}
+
+func (ts *DatabaseTestSuite) TestNewDatabase_UnknownBufferReplacementPolicy() {
+	t := ts.T()
+	path := path.Join(t.TempDir(), testDataDir)
+
+	_, err := db.NewDatabase(path, db.WithBufferReplacementPolicy("mru"))
+	require.ErrorIs(t, err, buffers.ErrUnknownReplacementPolicy)
 }
 
 func (ts *DatabaseTestSuite) TestNewDatabase_ExistsDatabase() {
@@ -96,6 +134,460 @@ func (ts *DatabaseTestSuite) TestNewDatabase_ExistsDatabase() {
 	require.NoError(t, sut.Close())
 }
 
+func (ts *DatabaseTestSuite) TestNewDatabase_ControlFile() {
+	t := ts.T()
+	path := path.Join(t.TempDir(), testDataDir)
+
+	sdb, err := db.NewDatabase(path, db.WithBlockSize(testWOBlockSize))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), sdb.CreatedAt(), time.Minute)
+	assert.True(t, sdb.WasCleanShutdown())
+
+	createdAt := sdb.CreatedAt()
+
+	require.NoError(t, sdb.Close())
+
+	_, err = db.NewDatabase(path)
+	require.ErrorIs(t, err, storage.ErrBlockSizeMismatch)
+	assert.Contains(t, err.Error(), "data dir block size 3072, requested 8192")
+
+	sut, err := db.NewDatabase(path, db.WithBlockSize(testWOBlockSize))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, sut.Close())
+	}()
+
+	assert.False(t, sut.IsNew())
+	assert.True(t, sut.WasCleanShutdown())
+	assert.True(t, createdAt.Equal(sut.CreatedAt()))
+}
+
+func (ts *DatabaseTestSuite) TestClose_ActiveTransaction() {
+	t := ts.T()
+	path := path.Join(t.TempDir(), testDataDir)
+
+	sdb, err := db.NewDatabase(path)
+	require.NoError(t, err)
+
+	_, err = sdb.Transaction()
+	require.NoError(t, err)
+
+	// Закрытие с незавершенной транзакцией штатным не считается
+	require.NoError(t, sdb.Close())
+
+	sdb, err = db.NewDatabase(path)
+	require.NoError(t, err)
+	assert.False(t, sdb.WasCleanShutdown())
+	require.NoError(t, sdb.Close())
+
+	sdb, err = db.NewDatabase(path)
+	require.NoError(t, err)
+	assert.True(t, sdb.WasCleanShutdown())
+	require.NoError(t, sdb.Close())
+}
+
+func (ts *DatabaseTestSuite) TestNewDatabase_Compression() {
+	t := ts.T()
+	path := path.Join(t.TempDir(), testDataDir)
+
+	_, err := db.NewDatabase(path, db.WithCompression("lzma"))
+	require.ErrorIs(t, err, storage.ErrUnknownCodec)
+
+	sdb, err := db.NewDatabase(path, db.WithCompression(storage.CompressionZstd))
+	require.NoError(t, err)
+	assert.Equal(t, storage.CompressionZstd, sdb.Compression())
+
+	trx, err := sdb.Transaction()
+	require.NoError(t, err)
+
+	for _, cmd := range []string{
+		"create table t_default (id int64, name varchar(100))",
+		"create table t_none (id int64, name varchar(100)) with (compression = 'none')",
+		"create table t_s2 (id int64, name varchar(100)) with (compression = 's2')",
+		"create table t_text (id int64, body text)",
+	} {
+		_, err = sdb.Planner().ExecuteCommand(cmd, trx)
+		require.NoError(t, err, cmd)
+	}
+
+	_, err = sdb.Planner().ExecuteCommand("create table t_bad (id int64) with (compression = 'lzma')", trx)
+	require.ErrorContains(t, err, "\"lzma\": unknown compression codec")
+
+	for _, table := range []string{"t_default", "t_none", "t_s2"} {
+		for i := 0; i < 200; i++ {
+			_, err = sdb.Planner().ExecuteCommand(fmt.Sprintf("insert into %s (id, name) values (%d, 'name %d')", table, i, i), trx)
+			require.NoError(t, err)
+		}
+	}
+
+	_, err = sdb.Planner().ExecuteCommand(
+		fmt.Sprintf("insert into t_text (id, body) values (1, '%s')", strings.Repeat("body ", 1000)), trx)
+	require.NoError(t, err)
+
+	require.NoError(t, trx.Commit())
+	require.NoError(t, sdb.Close())
+
+	assert.FileExists(t, filepath.Join(path, "t_default.tbl"+storage.BlockMapSuffix))
+	assert.FileExists(t, filepath.Join(path, "t_text.ovf"+storage.BlockMapSuffix))
+	assert.FileExists(t, filepath.Join(path, "t_s2.tbl"+storage.BlockMapSuffix))
+	assert.NoFileExists(t, filepath.Join(path, "t_none.tbl"+storage.BlockMapSuffix))
+
+	// Сжатие записано в файлах таблиц, поэтому база открывается без параметра
+	sut, err := db.NewDatabase(path)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, sut.Close())
+	}()
+
+	trx, err = sut.Transaction()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, trx.Commit())
+	}()
+
+	for _, table := range []string{"t_default", "t_none", "t_s2"} {
+		qp, err := sut.Planner().CreateQueryPlan("select id, name from "+table, trx)
+		require.NoError(t, err)
+
+		sc, err := qp.Open()
+		require.NoError(t, err)
+
+		count := 0
+
+		require.NoError(t, scan.ForEach(sc, func() (bool, error) {
+			id, err := sc.GetInt64("id")
+			require.NoError(t, err)
+
+			name, err := sc.GetString("name")
+			require.NoError(t, err)
+			assert.Equal(t, fmt.Sprintf("name %d", id), name)
+
+			count++
+
+			return false, nil
+		}))
+
+		sc.Close()
+
+		assert.Equal(t, 200, count, table)
+	}
+}
+
+func (ts *DatabaseTestSuite) TestNewDatabase_Mmap() {
+	t := ts.T()
+	path := path.Join(t.TempDir(), testDataDir)
+
+	_, err := db.NewDatabase(path, db.WithDiskBackend("tape"))
+	require.ErrorIs(t, err, storage.ErrUnknownBackend)
+
+	sdb, err := db.NewDatabase(path, db.WithDiskBackend(storage.BackendMmap), db.WithBuffersPoolLen(8))
+	require.NoError(t, err)
+	assert.Equal(t, storage.BackendMmap, sdb.DiskBackend())
+
+	trx, err := sdb.Transaction()
+	require.NoError(t, err)
+
+	_, err = sdb.Planner().ExecuteCommand("create table t (id int64, name varchar(100))", trx)
+	require.NoError(t, err)
+
+	for i := 0; i < 300; i++ {
+		_, err = sdb.Planner().ExecuteCommand(fmt.Sprintf("insert into t (id, name) values (%d, 'name %d')", i, i), trx)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, trx.Commit())
+
+	// Пул меньше таблицы, поэтому изменения идут и по страницам, которые буферы читают через отображение
+	trx, err = sdb.Transaction()
+	require.NoError(t, err)
+
+	_, err = sdb.Planner().ExecuteCommand("update t set name = 'updated' where id = 150", trx)
+	require.NoError(t, err)
+
+	require.NoError(t, trx.Commit())
+	require.NoError(t, sdb.Close())
+
+	sut, err := db.NewDatabase(path)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, sut.Close())
+	}()
+
+	assert.Equal(t, storage.BackendFile, sut.DiskBackend())
+
+	trx, err = sut.Transaction()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, trx.Commit())
+	}()
+
+	qp, err := sut.Planner().CreateQueryPlan("select id, name from t", trx)
+	require.NoError(t, err)
+
+	sc, err := qp.Open()
+	require.NoError(t, err)
+
+	count := 0
+
+	require.NoError(t, scan.ForEach(sc, func() (bool, error) {
+		id, err := sc.GetInt64("id")
+		require.NoError(t, err)
+
+		name, err := sc.GetString("name")
+		require.NoError(t, err)
+
+		if id == 150 {
+			assert.Equal(t, "updated", name)
+		} else {
+			assert.Equal(t, fmt.Sprintf("name %d", id), name)
+		}
+
+		count++
+
+		return false, nil
+	}))
+
+	assert.Equal(t, 300, count)
+}
+
+func (ts *DatabaseTestSuite) TestNewDatabase_Encryption() {
+	t := ts.T()
+	path := path.Join(t.TempDir(), testDataDir)
+
+	oldKey := []byte("0123456789abcdef0123456789abcdef")
+	newKey := []byte("fedcba9876543210")
+
+	sdb, err := db.NewDatabase(path, db.WithEncryptionKey(oldKey))
+	require.NoError(t, err)
+	assert.True(t, sdb.Encrypted())
+
+	trx, err := sdb.Transaction()
+	require.NoError(t, err)
+
+	_, err = sdb.Planner().ExecuteCommand("create table secrets (id int64, name varchar(100))", trx)
+	require.NoError(t, err)
+
+	_, err = sdb.Planner().ExecuteCommand("insert into secrets (id, name) values (1, 'top secret')", trx)
+	require.NoError(t, err)
+
+	require.NoError(t, trx.Commit())
+	require.NoError(t, sdb.Close())
+
+	for _, filename := range []string{"secrets.tbl", db.DefaultLogFilename} {
+		data, err := os.ReadFile(filepath.Join(path, filename))
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "top secret", filename)
+	}
+
+	_, err = db.NewDatabase(path)
+	require.ErrorIs(t, err, storage.ErrEncryptionKeyRequired)
+
+	_, err = db.NewDatabase(path, db.WithEncryptionKey(newKey))
+	require.ErrorIs(t, err, storage.ErrWrongKey)
+
+	require.NoError(t, db.RotateKey(path, oldKey, newKey))
+
+	_, err = db.NewDatabase(path, db.WithEncryptionKey(oldKey))
+	require.ErrorIs(t, err, storage.ErrWrongKey)
+
+	sut, err := db.NewDatabase(path, db.WithEncryptionKey(newKey))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, sut.Close())
+	}()
+
+	// Пока база открыта, сменить ключ нельзя
+	require.ErrorIs(t, db.RotateKey(path, newKey, oldKey), storage.ErrDatabaseLocked)
+
+	trx, err = sut.Transaction()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, trx.Commit())
+	}()
+
+	qp, err := sut.Planner().CreateQueryPlan("select name from secrets where id = 1", trx)
+	require.NoError(t, err)
+
+	sc, err := qp.Open()
+	require.NoError(t, err)
+
+	defer sc.Close()
+
+	ok, err := sc.Next()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	name, err := sc.GetString("name")
+	require.NoError(t, err)
+	assert.Equal(t, "top secret", name)
+}
+
+func (ts *DatabaseTestSuite) TestRotateKey_Interrupted() {
+	t := ts.T()
+
+	oldKey := []byte("0123456789abcdef")
+	newKey := []byte("fedcba9876543210")
+
+	disk := storage.NewMemoryBackend()
+
+	sdb, err := db.NewDatabase("", db.WithStorageBackend(disk), db.WithEncryptionKey(oldKey))
+	require.NoError(t, err)
+
+	trx, err := sdb.Transaction()
+	require.NoError(t, err)
+
+	_, err = sdb.Planner().ExecuteCommand("create table secrets (id int64)", trx)
+	require.NoError(t, err)
+
+	_, err = sdb.Planner().ExecuteCommand("insert into secrets (id) values (1)", trx)
+	require.NoError(t, err)
+
+	require.NoError(t, trx.Commit())
+	require.NoError(t, sdb.Close())
+
+	// База упала, ключ меняем только после восстановления
+	faulty := storage.NewFaultyBackend(disk)
+
+	_, err = db.NewDatabase("", db.WithStorageBackend(faulty), db.WithEncryptionKey(oldKey))
+	require.NoError(t, err)
+
+	faulty.Crash()
+
+	require.ErrorIs(t, db.RotateKey("", oldKey, newKey, db.WithStorageBackend(disk)), storage.ErrRecoveryRequired)
+
+	sdb, err = db.NewDatabase("", db.WithStorageBackend(disk), db.WithEncryptionKey(oldKey))
+	require.NoError(t, err)
+	require.NoError(t, sdb.Close())
+
+	// Прерванную смену ключа можно только запустить снова
+	faulty = storage.NewFaultyBackend(disk, storage.WithCrashAfterWrites(4))
+	require.Error(t, db.RotateKey("", oldKey, newKey, db.WithStorageBackend(faulty)))
+	faulty.Crash()
+
+	_, err = db.NewDatabase("", db.WithStorageBackend(disk), db.WithEncryptionKey(oldKey))
+	require.ErrorIs(t, err, storage.ErrKeyRotationInterrupted)
+
+	require.NoError(t, db.RotateKey("", oldKey, newKey, db.WithStorageBackend(disk)))
+
+	sdb, err = db.NewDatabase("", db.WithStorageBackend(disk), db.WithEncryptionKey(newKey))
+	require.NoError(t, err)
+	require.NoError(t, sdb.Close())
+}
+
+func (ts *DatabaseTestSuite) TestReadKeyFile() {
+	t := ts.T()
+	dir := t.TempDir()
+
+	raw := []byte("0123456789abcdef")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "raw.key"), raw, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hex.key"), []byte(hex.EncodeToString(raw)+"\n"), 0o600))
+
+	for _, name := range []string{"raw.key", "hex.key"} {
+		key, err := db.ReadKeyFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, raw, key, name)
+	}
+
+	_, err := db.ReadKeyFile(filepath.Join(dir, "missing.key"))
+	require.ErrorIs(t, err, db.ErrBadKeyFile)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "short.key"), []byte("short"), 0o600))
+
+	_, err = db.ReadKeyFile(filepath.Join(dir, "short.key"))
+	require.ErrorIs(t, err, db.ErrBadKeyFile)
+}
+
+func (ts *DatabaseTestSuite) TestNewDatabase_InMemory() {
+	t := ts.T()
+
+	sut, err := db.NewDatabase(db.MemoryDataDir)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, sut.Close())
+	}()
+
+	assert.True(t, sut.IsNew())
+	assert.Equal(t, db.MemoryDataDir, sut.DataDir())
+	assert.NoDirExists(t, db.MemoryDataDir)
+
+	trx, err := sut.Transaction()
+	require.NoError(t, err)
+
+	_, err = sut.Planner().ExecuteCommand("create table table1 (id int64)", trx)
+	require.NoError(t, err)
+
+	_, err = sut.Planner().ExecuteCommand("insert into table1 (id) values (1)", trx)
+	require.NoError(t, err)
+
+	require.NoError(t, trx.Commit())
+}
+
+func (ts *DatabaseTestSuite) TestResizeBufferPool() {
+	t := ts.T()
+	path := path.Join(t.TempDir(), testDataDir)
+
+	sut, err := db.NewDatabase(path, db.WithBuffersPoolLen(16))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, sut.Close())
+	}()
+
+	trx, err := sut.Transaction()
+	require.NoError(t, err)
+
+	_, err = sut.Planner().ExecuteCommand("create table table1 (id int64)", trx)
+	require.NoError(t, err)
+
+	_, err = sut.Planner().ExecuteCommand("insert into table1 (id) values (1)", trx)
+	require.NoError(t, err)
+
+	require.NoError(t, trx.Commit())
+
+	require.NoError(t, sut.ResizeBufferPool(4))
+	assert.Equal(t, 4, sut.BuffersPoolLen())
+
+	require.NoError(t, sut.ResizeBufferPool(32))
+	assert.Equal(t, 32, sut.BuffersPoolLen())
+
+	require.ErrorIs(t, sut.ResizeBufferPool(0), buffers.ErrBadPoolLen)
+
+	trx, err = sut.Transaction()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, trx.Commit())
+	}()
+
+	qp, err := sut.Planner().CreateQueryPlan("select id from table1", trx)
+	require.NoError(t, err)
+
+	sc, err := qp.Open()
+	require.NoError(t, err)
+
+	defer sc.Close()
+
+	ok, err := sc.Next()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// Закрепленный сканом буфер остается в пуле
+	require.NoError(t, sut.ResizeBufferPoolContext(context.Background(), 1))
+	assert.Equal(t, 1, sut.BuffersPoolLen())
+
+	id, err := sc.GetInt64("id")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, id)
+}
+
 func (ts *DatabaseTestSuite) TestPlanner() {
 	t := ts.T()
 	path := path.Join(t.TempDir(), testDataDir)
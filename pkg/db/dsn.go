@@ -7,29 +7,56 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/buffers"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/storage"
 )
 
 const (
 	optBlockSize              = "block_size"
 	optLogFilename            = "log_file_name"
 	optBuffersPoolLen         = "buffers_pool_len"
+	optBufferReplacement      = "buffer_replacement_policy"
+	optBufferPoolPartitions   = "buffer_pool_partitions"
+	optReadAheadBlocks        = "read_ahead_blocks"
+	optMaxOpenFiles           = "max_open_files"
+	optCompression            = "compression"
+	optKeyFile                = "key_file"
+	optStorageBackend         = "storage_backend"
+	optBgWriterDelay          = "bgwriter_delay"
+	optBgWriterMaxPages       = "bgwriter_max_pages"
 	optPinLockTimeout         = "pin_lock_timeout"
 	optTransactionLockTimeout = "transaction_lock_timeout"
+	optTransactionPinQuota    = "transaction_pin_quota"
 )
 
 type embedDSN struct {
 	DataDir                string
 	LogFileName            string
 	BuffersPoolLen         int
+	BufferReplacement      string
+	BufferPoolPartitions   int
+	ReadAheadBlocks        int
+	MaxOpenFiles           int
+	Compression            string
+	KeyFile                string
+	StorageBackend         string
+	BgWriterDelay          time.Duration
+	BgWriterMaxPages       int
 	BlockSize              uint32
 	PinLockTimeout         time.Duration
 	TransactionLockTimeout time.Duration
+	TransactionPinQuota    int
 }
 
 func parseEmbedDSN(dsn string) (embedDSN, error) {
 	d := embedDSN{
 		LogFileName:            DefaultLogFilename,
 		BuffersPoolLen:         DefaultBuffersPoolLen,
+		BufferReplacement:      buffers.DefaultReplacementPolicyName,
+		ReadAheadBlocks:        DefaultReadAheadBlocks,
+		MaxOpenFiles:           DefaultMaxOpenFiles,
+		BgWriterDelay:          DefaultBackgroundWriterDelay,
+		BgWriterMaxPages:       DefaultBackgroundWriterMaxPages,
 		BlockSize:              DefaultBlockSize,
 		PinLockTimeout:         DefaultPinLockTimeout,
 		TransactionLockTimeout: DefaultTransactionLockTimeout,
@@ -71,6 +98,61 @@ func parseEmbedDSN(dsn string) (embedDSN, error) {
 			}
 
 			d.BuffersPoolLen = int(v)
+		case optBufferReplacement:
+			if _, err1 := buffers.ReplacementPolicyByName(values[0]); err1 != nil {
+				return d, errors.WithMessage(ErrBadDSN, err1.Error())
+			}
+
+			d.BufferReplacement = values[0]
+		case optBufferPoolPartitions:
+			v, err1 := strconv.ParseInt(values[0], 10, 32) //nolint:mnd
+			if err1 != nil {
+				return d, errors.WithMessagef(ErrBadDSN, "bad int value: %s", err1)
+			}
+
+			d.BufferPoolPartitions = int(v)
+		case optReadAheadBlocks:
+			v, err1 := strconv.ParseInt(values[0], 10, 32) //nolint:mnd
+			if err1 != nil {
+				return d, errors.WithMessagef(ErrBadDSN, "bad int value: %s", err1)
+			}
+
+			d.ReadAheadBlocks = int(v)
+		case optMaxOpenFiles:
+			v, err1 := strconv.ParseInt(values[0], 10, 32) //nolint:mnd
+			if err1 != nil {
+				return d, errors.WithMessagef(ErrBadDSN, "bad int value: %s", err1)
+			}
+
+			d.MaxOpenFiles = int(v)
+		case optCompression:
+			if err1 := storage.ValidCompression(values[0]); err1 != nil {
+				return d, errors.WithMessage(ErrBadDSN, err1.Error())
+			}
+
+			d.Compression = values[0]
+		case optKeyFile:
+			d.KeyFile = values[0]
+		case optStorageBackend:
+			if err1 := storage.ValidBackend(values[0]); err1 != nil {
+				return d, errors.WithMessage(ErrBadDSN, err1.Error())
+			}
+
+			d.StorageBackend = values[0]
+		case optBgWriterDelay:
+			v, err1 := time.ParseDuration(values[0])
+			if err1 != nil {
+				return d, errors.WithMessagef(ErrBadDSN, "bad duration value: %s", err1)
+			}
+
+			d.BgWriterDelay = v
+		case optBgWriterMaxPages:
+			v, err1 := strconv.ParseInt(values[0], 10, 32) //nolint:mnd
+			if err1 != nil {
+				return d, errors.WithMessagef(ErrBadDSN, "bad int value: %s", err1)
+			}
+
+			d.BgWriterMaxPages = int(v)
 		case optPinLockTimeout:
 			v, err1 := time.ParseDuration(values[0])
 			if err1 != nil {
@@ -85,6 +167,13 @@ func parseEmbedDSN(dsn string) (embedDSN, error) {
 			}
 
 			d.TransactionLockTimeout = v
+		case optTransactionPinQuota:
+			v, err1 := strconv.ParseInt(values[0], 10, 32) //nolint:mnd
+			if err1 != nil {
+				return d, errors.WithMessagef(ErrBadDSN, "bad int value: %s", err1)
+			}
+
+			d.TransactionPinQuota = int(v)
 		default:
 			return d, errors.WithMessagef(ErrBadDSN, "unknown key: %s", name)
 		}
@@ -3,17 +3,75 @@
 // Строка соединения со встроенной базой:
 // path_to_db_folder?block_size=4096&transaction_lock_timeout=3s
 //
+// Папку с данными одновременно может открыть только один процесс. Остальные получат ошибку storage.ErrDatabaseLocked
+// с pid процесса, который держит базу.
+//
 // Допустимые параметры:
-//   block_size (uint32) — размер блока в байтах
+//   block_size (uint32) — размер блока в байтах. Должен совпадать с размером, с которым создана база
 //   buffers_pool_len (int) — длина пула буферов. Общий размер в памяти buffers_poll_size*block_size
+//   buffer_replacement_policy (string) — стратегия замены буферов: clock (по умолчанию), lru или lru2 (LRU-K, устойчива к сканированию таблиц)
+//   buffer_pool_partitions (int) — на сколько частей с независимыми блокировками делить пул буферов, 0 — по числу процессоров
+//   read_ahead_blocks (int) — сколько следующих блоков заранее читать в пул при последовательном чтении таблицы, 0 — выключить
+//   max_open_files (int) — сколько файлов данных держать открытыми одновременно, 0 — без ограничений
+//   compression (string) — сжатие страниц новых таблиц: zstd, s2 или none (по умолчанию).
+//     Для отдельной таблицы: CREATE TABLE t (...) WITH (compression = 'zstd')
+//   key_file (string) — путь к файлу с ключом AES (16, 24 или 32 байта как есть или в hex). База создается зашифрованной
+//     и открывается только с этим ключом. Ключ меняется офлайн через RotateKey или sophiadb rotate-key
+//   storage_backend (string) — как читать файлы данных: file (по умолчанию) или mmap — отображать файлы в память
+//     и не копировать страницы в буферы. Подходит для нагрузки, где чтений намного больше, чем записей
 //   log_file_name (string) — имя файла для wal-лога
-//   pin_lock_timeout (duration) — таймаут для пина буферов
+//   bgwriter_delay (duration) — период фоновой записи измененных буферов на диск, 0 — выключить
+//   bgwriter_max_pages (int) — сколько страниц фоновая запись пишет за один период
+//   pin_lock_timeout (duration) — таймаут ожидания свободного буфера. Ожидание прерывается и по отмене контекста запроса
 //   transaction_lock_timeout (duration) - таймаут ожидания взятия блокировки транзакцией
+//   transaction_pin_quota (int) — сколько буферов может одновременно закрепить одна транзакция, 0 — без ограничений
+//
+// Вместо пути можно указать :memory:, тогда база целиком хранится в памяти, например ":memory:?buffers_pool_len=64".
+// Каждый sql.Open создает отдельную базу, все соединения пула работают с ней. База пропадает после закрытия sql.DB
 //
 // duration format:
 // ParseDuration parses a duration string. A duration string is a possibly signed sequence of
 // decimal numbers, each with optional fraction and a unit suffix, such as "300ms", "-1.5h" or "2h45m".
 // Valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h".
+//
+// Транзакции:
+// BeginTx поддерживает уровни изоляции sql.LevelDefault (serializable), sql.LevelSerializable,
+// sql.LevelRepeatableRead и sql.LevelReadCommitted, а также режим только для чтения (ReadOnly).
+// Для остальных уровней изоляции возвращается ErrUnsupportedIsolationLevel.
+// Внутри транзакции работают SAVEPOINT name, ROLLBACK TO [SAVEPOINT] name и RELEASE [SAVEPOINT] name.
+// ROLLBACK TO откатывает изменения после точки сохранения, но не снимает блокировки.
+// В режиме автокоммита каждая команда — отдельная транзакция, поэтому точки сохранения в нём бесполезны.
+// SELECT ... FOR UPDATE | FOR SHARE [NOWAIT | SKIP LOCKED] блокирует выбранные записи до конца транзакции
+// на любом уровне изоляции, UPDATE и DELETE ждут эти блокировки. На уровнях serializable и repeatable read
+// FOR UPDATE блокирует еще и блоки с записями, потому что прочитанные блоки и так остаются заблокированными.
+// NOWAIT сразу возвращает ошибку, если запись занята. SKIP LOCKED пропускает занятые записи, а также блоки,
+// которые меняет другая транзакция: незафиксированные изменения читать нельзя.
+//
+// Системные представления (только для чтения):
+//   sdb_locks (filename, block, slot, key, mode, trx, waiters, wait_ms) — блокировки активных транзакций и кто их ждёт,
+//     у блокировки записи slot — слот записи в блоке, у блокировки всего блока — NULL,
+//     у блокировки значения индекса filename — имя индекса, key — значение, а block и slot — NULL
+//   sdb_transactions (trx, started_at, state, isolation, read_only, pinned_buffers, locks) — активные транзакции
+//   sdb_buffers (frame, filename, block, pins, dirty_trx, lsn) — буферы пула и блоки в них
+//
+// NULL:
+// Поля, которые INSERT не перечислил, получают NULL. NULL можно передать литералом или nil в плейсхолдере,
+// в результатах запросов NULL приходит как nil, его читают в sql.NullString, sql.NullInt64 и т.п.
+// Сравнение с NULL никогда не выполняется, записи с NULL выбирают условиями IS NULL и IS NOT NULL
+//
+// TEXT и BLOB:
+// Поля без ограничения длины: CREATE TABLE t (body text, data blob). Значения длиннее нескольких сотен байт
+// хранятся в отдельном файле страниц переполнения таблицы, страницы освобождаются при UPDATE и DELETE.
+// TEXT приходит в результатах как string, BLOB — как []byte. В плейсхолдерах можно передавать string и []byte.
+// Такое значение целиком собирается в памяти при переходе к строке результата.
+// Запрос с контекстом WithLargeValueReaders читает TEXT и BLOB потоком: колонки приходят как *LargeValue (io.Reader
+// с длиной Size), страницы переполнения читаются, только когда приложение читает значение, а значения колонок,
+// которые приложение не читает, не читаются совсем. Значение сканируют в переменную *db.LargeValue или any
+// (NULL приходит как nil) либо в io.Reader для колонок без NULL. Дочитать значение нужно, пока не закрыты
+// строки результата, и до того, как та же транзакция изменит или удалит запись: после закрытия строк Read
+// возвращает ErrRowsClosed.
+// database/sql закрывает строки сам, когда Next вернул false. В READ COMMITTED значение читается сразу,
+// потому что после перехода к следующей строке другая транзакция может его изменить
 
 package db
 
@@ -27,8 +85,8 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/unhandled-exception/sophiadb/internal/pkg/planner"
-	"github.com/unhandled-exception/sophiadb/internal/pkg/records"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/concurrency"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/transaction"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/utils"
 )
@@ -43,6 +101,7 @@ var (
 	_ driver.Driver        = &EmbedDriver{}
 	_ driver.DriverContext = &EmbedDriver{}
 	_ driver.Connector     = &connector{}
+	_ driver.Connector     = &memoryConnector{}
 
 	_ driver.Pinger          = &EmbedConn{}
 	_ driver.SessionResetter = &EmbedConn{}
@@ -65,6 +124,13 @@ func NewEmbedDriver() *EmbedDriver {
 }
 
 func (d *EmbedDriver) OpenConnector(dsn string) (driver.Connector, error) {
+	if isMemoryDSN(dsn) {
+		return &memoryConnector{
+			dsn:    dsn,
+			driver: d,
+		}, nil
+	}
+
 	c := connector{
 		dsn:    dsn,
 		driver: d,
@@ -114,13 +180,32 @@ func (d *EmbedDriver) Close() error {
 }
 
 func (d *EmbedDriver) newDB(dsn embedDSN) (*Database, error) {
+	var key []byte
+
+	if dsn.KeyFile != "" {
+		var err error
+
+		if key, err = ReadKeyFile(dsn.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+
 	return NewDatabase(
 		dsn.DataDir,
+		WithEncryptionKey(key),
 		WithBlockSize(dsn.BlockSize),
 		WithLogFileName(dsn.LogFileName),
 		WithBuffersPoolLen(dsn.BuffersPoolLen),
+		WithBufferReplacementPolicy(dsn.BufferReplacement),
+		WithBufferPoolPartitions(dsn.BufferPoolPartitions),
+		WithReadAhead(dsn.ReadAheadBlocks),
+		WithMaxOpenFiles(dsn.MaxOpenFiles),
+		WithCompression(dsn.Compression),
+		WithDiskBackend(dsn.StorageBackend),
+		WithBackgroundWriter(dsn.BgWriterDelay, dsn.BgWriterMaxPages),
 		WithPinLockTimeout(dsn.PinLockTimeout),
 		WithTransactionLockTimeout(dsn.TransactionLockTimeout),
+		WithTransactionPinQuota(dsn.TransactionPinQuota),
 	)
 }
 
@@ -178,16 +263,55 @@ func (e *EmbedConn) Close() error {
 	return e.trx.Rollback()
 }
 
-func (e *EmbedConn) BeginTx(_ context.Context, _ driver.TxOptions) (driver.Tx, error) {
+func (e *EmbedConn) BeginTx(_ context.Context, opts driver.TxOptions) (driver.Tx, error) {
 	if e.inTrx {
 		return nil, ErrTransactionAlreadyStarted
 	}
 
+	trxOpts, err := transactionOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Для транзакции с параметрами по умолчанию подходит уже открытая транзакция соединения
+	if len(trxOpts) > 0 {
+		if err = e.trx.Rollback(); err != nil {
+			return nil, err
+		}
+
+		e.trx, err = e.db.Transaction(trxOpts...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	e.inTrx = true
 
 	return e, nil
 }
 
+// transactionOptions переводит параметры транзакции database/sql в опции транзакции
+func transactionOptions(opts driver.TxOptions) ([]transaction.TransactionOpt, error) {
+	var trxOpts []transaction.TransactionOpt
+
+	//nolint:exhaustive
+	switch sql.IsolationLevel(opts.Isolation) {
+	case sql.LevelDefault, sql.LevelSerializable:
+	case sql.LevelRepeatableRead:
+		trxOpts = append(trxOpts, transaction.WithIsolationLevel(concurrency.RepeatableRead))
+	case sql.LevelReadCommitted:
+		trxOpts = append(trxOpts, transaction.WithIsolationLevel(concurrency.ReadCommitted))
+	default:
+		return nil, errors.WithMessage(ErrUnsupportedIsolationLevel, sql.IsolationLevel(opts.Isolation).String())
+	}
+
+	if opts.ReadOnly {
+		trxOpts = append(trxOpts, transaction.WithReadOnly())
+	}
+
+	return trxOpts, nil
+}
+
 func (e *EmbedConn) Begin() (driver.Tx, error) {
 	return nil, errors.New("Begin is not implemented, use BeginTx instead")
 }
@@ -243,7 +367,13 @@ func (s embedStmt) NumInput() int {
 	return -1
 }
 
-func (s embedStmt) ExecContext(_ context.Context, args []driver.NamedValue) (driver.Result, error) {
+// ExecContext выполняет команду. Отмена ctx прерывает ожидание свободного буфера
+func (s embedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	trx := s.conn.TRX()
+
+	trx.SetContext(ctx)
+	defer trx.SetContext(context.Background())
+
 	return s.exec(s.statement, args)
 }
 
@@ -259,6 +389,13 @@ func (s embedStmt) exec(statement string, args []driver.NamedValue) (driver.Resu
 
 	rows, err := s.planner.ExecuteCommand(statement, s.conn.TRX())
 	if err != nil {
+		// В режиме автокоммита откатываем неудачную команду, чтобы не держать её блокировки
+		if !s.conn.inTrx {
+			if rerr := s.conn.Rollback(); rerr != nil {
+				return nil, errors.WithMessage(err, rerr.Error())
+			}
+		}
+
 		return nil, err
 	}
 
@@ -272,15 +409,27 @@ func (s embedStmt) exec(statement string, args []driver.NamedValue) (driver.Resu
 	return stmtResult{rows: rows}, nil
 }
 
+// QueryContext выполняет запрос. Контекст действует, пока не закрыты строки результата
 func (s embedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
-	return s.query(s.statement, args)
+	trx := s.conn.TRX()
+
+	trx.SetContext(ctx)
+
+	rows, err := s.query(s.statement, args, largeValueReaders(ctx))
+	if err != nil {
+		trx.SetContext(context.Background())
+
+		return nil, err
+	}
+
+	return rows, nil
 }
 
 func (s embedStmt) Query(args []driver.Value) (driver.Rows, error) {
 	return nil, errors.New("Query is not implemented, use QueryContext instead")
 }
 
-func (s embedStmt) query(query string, args []driver.NamedValue) (driver.Rows, error) {
+func (s embedStmt) query(query string, args []driver.NamedValue, largeValues bool) (driver.Rows, error) {
 	query, err := applyPlaceholders(query, args)
 	if err != nil {
 		return nil, err
@@ -297,14 +446,22 @@ func (s embedStmt) query(query string, args []driver.NamedValue) (driver.Rows, e
 	}
 
 	return embedRows{
-		plan: plan,
-		scan: scan,
+		trx:         s.conn.TRX(),
+		plan:        plan,
+		scan:        scan,
+		largeValues: largeValues,
+		state:       &rowsState{},
 	}, nil
 }
 
 type embedRows struct {
+	trx  *transaction.Transaction
 	plan planner.Plan
 	scan scan.Scan
+
+	// largeValues — отдавать TEXT и BLOB как *LargeValue
+	largeValues bool
+	state       *rowsState
 }
 
 func (r embedRows) Columns() []string {
@@ -312,12 +469,21 @@ func (r embedRows) Columns() []string {
 }
 
 func (r embedRows) Close() error {
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+
+	r.state.closed = true
+
 	r.scan.Close()
+	r.trx.SetContext(context.Background())
 
 	return nil
 }
 
 func (r embedRows) Next(dest []driver.Value) error {
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+
 	ok, err := r.scan.Next()
 	if err != nil {
 		return err
@@ -329,25 +495,23 @@ func (r embedRows) Next(dest []driver.Value) error {
 
 	schema := r.plan.Schema()
 
-	var val any
-
+	// Без WithLargeValueReaders значения TEXT и BLOB читаются целиком, даже если приложение не использует эти колонки
 	for i, field := range schema.Fields() {
-		switch schema.Type(field) { //nolint:exhaustive
-		case records.Int64Field:
-			val, err = r.scan.GetInt64(field)
-		case records.Int8Field:
-			val, err = r.scan.GetInt8(field)
-		case records.StringField:
-			val, err = r.scan.GetString(field)
-		default:
-			err = scan.ErrUnknownFieldType
+		if r.largeValues && schema.Type(field).IsLarge() {
+			if dest[i], err = r.largeValue(field); err != nil {
+				return err
+			}
+
+			continue
 		}
 
+		// NULL приходит как NullConstant, его значение — nil
+		val, err := r.scan.GetVal(field)
 		if err != nil {
 			return err
 		}
 
-		dest[i] = val
+		dest[i] = val.Value()
 	}
 
 	return nil
@@ -0,0 +1,154 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/storage"
+	"github.com/unhandled-exception/sophiadb/pkg/db"
+)
+
+const (
+	benchRows        = 500
+	benchPointTables = 8
+	benchPointRows   = 8
+)
+
+func newBenchDB(b *testing.B, params string) *sql.DB {
+	b.Helper()
+
+	bdb, err := sql.Open(db.EmbedDriverName, b.TempDir()+"?"+params)
+	require.NoError(b, err)
+
+	ctx := context.Background()
+
+	_, err = bdb.ExecContext(ctx, "create table bench (id int64, name varchar(20))")
+	require.NoError(b, err)
+
+	for i := 0; i < benchRows; i++ {
+		_, err = bdb.ExecContext(ctx, "insert into bench (id, name) values (?, ?)", i, fmt.Sprintf("name %d", i))
+		require.NoError(b, err)
+	}
+
+	return bdb
+}
+
+// newPointReadsDB создает benchPointTables таблиц по benchPointRows строк, каждая таблица умещается в один блок.
+// SELECT не выбирает индексы, а сканирует таблицу целиком, поэтому точечное чтение — это запрос к таблице из одного блока
+func newPointReadsDB(b *testing.B, params string) *sql.DB {
+	b.Helper()
+
+	bdb, err := sql.Open(db.EmbedDriverName, b.TempDir()+"?"+params)
+	require.NoError(b, err)
+
+	ctx := context.Background()
+
+	for t := 0; t < benchPointTables; t++ {
+		_, err = bdb.ExecContext(ctx, fmt.Sprintf("create table point_%d (id int64, name varchar(20))", t))
+		require.NoError(b, err)
+
+		for i := 0; i < benchPointRows; i++ {
+			_, err = bdb.ExecContext(ctx, fmt.Sprintf("insert into point_%d (id, name) values (?, ?)", t), i, fmt.Sprintf("name %d", i))
+			require.NoError(b, err)
+		}
+	}
+
+	return bdb
+}
+
+// BenchmarkPointReads_Parallel — точечные чтения из многих горутин через драйвер.
+// У каждой горутины свое соединение и одна транзакция только для чтения на все чтения: такая транзакция не пишет журнал,
+// поэтому время уходит на план запроса, пул буферов и блокировки, а не на запись на диск.
+// Сравнивает пул буферов из одной части и пул, разбитый на части по числу процессоров
+func BenchmarkPointReads_Parallel(b *testing.B) {
+	for _, partitions := range []int{1, 0} {
+		name := "partitions=auto"
+		if partitions > 0 {
+			name = fmt.Sprintf("partitions=%d", partitions)
+		}
+
+		b.Run(name, func(b *testing.B) {
+			bdb := newPointReadsDB(b, fmt.Sprintf("buffer_pool_partitions=%d", partitions))
+			defer bdb.Close()
+
+			b.SetParallelism(4) //nolint:mnd
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				if err := pointReads(bdb, pb); err != nil {
+					b.Error(err)
+				}
+			})
+		})
+	}
+}
+
+func pointReads(bdb *sql.DB, pb *testing.PB) error {
+	ctx := context.Background()
+	rnd := rand.New(rand.NewSource(rand.Int63())) //nolint:gosec
+
+	conn, err := bdb.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback() //nolint:errcheck
+
+	var name string
+
+	for pb.Next() {
+		query := fmt.Sprintf("select name from point_%d where id = ?", rnd.Intn(benchPointTables))
+		if err := tx.QueryRowContext(ctx, query, rnd.Intn(benchPointRows)).Scan(&name); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// BenchmarkFullScan — чтение всей таблицы через пул буферов, который меньше таблицы, поэтому блоки все время читаются заново.
+// Сравнивает чтение файлов с диска и отображение файлов в память
+func BenchmarkFullScan(b *testing.B) {
+	for _, backend := range []string{storage.BackendFile, storage.BackendMmap} {
+		b.Run("storage_backend="+backend, func(b *testing.B) {
+			bdb := newBenchDB(b, "buffers_pool_len=8&read_ahead_blocks=0&storage_backend="+backend)
+			defer bdb.Close()
+
+			ctx := context.Background()
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				rows, err := bdb.QueryContext(ctx, "select id, name from bench")
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				count := 0
+
+				for rows.Next() {
+					count++
+				}
+
+				if err := rows.Close(); err != nil {
+					b.Fatal(err)
+				}
+
+				if count != benchRows {
+					b.Fatalf("count = %d, want %d", count, benchRows)
+				}
+			}
+		})
+	}
+}
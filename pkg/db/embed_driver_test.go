@@ -1,10 +1,15 @@
 package db_test
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,6 +17,10 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"github.com/unhandled-exception/sophiadb/internal/pkg/parse"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/storage"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/testutil"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/transaction"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/types"
 	"github.com/unhandled-exception/sophiadb/pkg/db"
 )
 
@@ -107,8 +116,16 @@ func (ts *EmbedDriverTestSuite) TestNewEmbedDriverConnection_BadDSN() {
 		{"", db.ErrBadDSN, "empty path: bad DSN"},
 		{path + "?block_size=ddd", db.ErrBadDSN, "bad uint32 value: strconv.ParseUint: parsing \"ddd\": invalid syntax: bad DSN"},
 		{path + "?buffers_pool_len=ddd", db.ErrBadDSN, "bad int value: strconv.ParseInt: parsing \"ddd\": invalid syntax: bad DSN"},
+		{path + "?buffer_replacement_policy=mru", db.ErrBadDSN, "\"mru\": unknown replacement policy: buffers error: bad DSN"},
+		{path + "?buffer_pool_partitions=all", db.ErrBadDSN, "bad int value: strconv.ParseInt: parsing \"all\": invalid syntax: bad DSN"},
+		{path + "?max_open_files=x", db.ErrBadDSN, "bad int value: strconv.ParseInt: parsing \"x\": invalid syntax: bad DSN"},
+		{path + "?compression=lzma", db.ErrBadDSN, "\"lzma\": unknown compression codec: storage error: bad DSN"},
+		{path + "?storage_backend=tape", db.ErrBadDSN, "\"tape\": unknown storage backend: storage error: bad DSN"},
+		{path + "?read_ahead_blocks=x", db.ErrBadDSN, "bad int value: strconv.ParseInt: parsing \"x\": invalid syntax: bad DSN"},
+		{path + "?bgwriter_delay=1", db.ErrBadDSN, "bad duration value: time: missing unit in duration \"1\": bad DSN"},
 		{path + "?pin_lock_timeout=24", db.ErrBadDSN, "bad duration value: time: missing unit in duration \"24\": bad DSN"},
 		{path + "?transaction_lock_timeout=35", db.ErrBadDSN, "bad duration value: time: missing unit in duration \"35\": bad DSN"},
+		{path + "?transaction_pin_quota=many", db.ErrBadDSN, "bad int value: strconv.ParseInt: parsing \"many\": invalid syntax: bad DSN"},
 	}
 
 	for _, tc := range tests {
@@ -143,8 +160,17 @@ func (ts *EmbedDriverTestSuite) TestNewEmbedDirverConnection_WithOption() {
 			"?block_size=15000"+
 			"&log_file_name=new_wal.log"+
 			"&buffers_pool_len=12345"+
+			"&buffer_replacement_policy=lru"+
+			"&buffer_pool_partitions=4"+
+			"&read_ahead_blocks=0"+
+			"&max_open_files=0"+
+			"&compression=s2"+
+			"&storage_backend=mmap"+
 			"&pin_lock_timeout=4m"+
-			"&transaction_lock_timeout=25s",
+			"&transaction_lock_timeout=25s"+
+			"&bgwriter_delay=50ms"+
+			"&bgwriter_max_pages=20"+
+			"&transaction_pin_quota=64",
 	)
 	require.NoError(t, err)
 	assert.NotNil(t, edb)
@@ -165,13 +191,54 @@ func (ts *EmbedDriverTestSuite) TestNewEmbedDirverConnection_WithOption() {
 		assert.EqualValues(t, 15000, rdb.DB().BlockSize())
 		assert.EqualValues(t, "new_wal.log", rdb.DB().LogFileName())
 		assert.EqualValues(t, 12345, rdb.DB().BuffersPoolLen())
+		assert.Equal(t, "lru", rdb.DB().BufferReplacementPolicy())
+		assert.Equal(t, 4, rdb.DB().BufferPoolPartitions())
+		assert.Zero(t, rdb.DB().ReadAheadBlocks())
+		assert.Zero(t, rdb.DB().MaxOpenFiles())
+		assert.Equal(t, "s2", rdb.DB().Compression())
+		assert.Equal(t, "mmap", rdb.DB().DiskBackend())
 		assert.EqualValues(t, 4*time.Minute, rdb.DB().PinLockTimeout())
 		assert.EqualValues(t, 25*time.Second, rdb.DB().TransactionLockTimeout())
+		assert.EqualValues(t, 50*time.Millisecond, rdb.DB().BackgroundWriterDelay())
+		assert.EqualValues(t, 20, rdb.DB().BackgroundWriterMaxPages())
+		assert.EqualValues(t, 64, rdb.DB().TransactionPinQuota())
 
 		return nil
 	})
 }
 
+func (ts *EmbedDriverTestSuite) TestEncryptedDatabase() {
+	t := ts.T()
+
+	dbPath := t.TempDir()
+	keyFile := filepath.Join(t.TempDir(), "sdb.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("00112233445566778899aabbccddeeff"), 0o600))
+
+	edb, err := sql.Open(db.EmbedDriverName, dbPath+"?key_file="+keyFile)
+	require.NoError(t, err)
+
+	conn, err := edb.Conn(context.Background())
+	require.NoError(t, err)
+
+	_ = conn.Raw(func(driverConn any) error {
+		rdb, ok := driverConn.(interface{ DB() *db.Database })
+		require.True(t, ok)
+
+		assert.True(t, rdb.DB().Encrypted())
+
+		return nil
+	})
+
+	require.NoError(t, conn.Close())
+	require.NoError(t, edb.Close())
+
+	edb, err = sql.Open(db.EmbedDriverName, t.TempDir()+"?key_file="+filepath.Join(t.TempDir(), "missing.key"))
+	require.NoError(t, err)
+
+	_, err = edb.Conn(context.Background())
+	require.ErrorIs(t, err, db.ErrBadKeyFile)
+}
+
 func (ts *EmbedDriverTestSuite) TestExec() {
 	t := ts.T()
 
@@ -193,6 +260,64 @@ func (ts *EmbedDriverTestSuite) TestExec() {
 	assert.EqualValues(t, 1, rows)
 }
 
+func (ts *EmbedDriverTestSuite) TestExec_AutocommitRollbackOnError() {
+	t := ts.T()
+
+	ctx := context.Background()
+
+	edb, err := sql.Open(db.EmbedDriverName, t.TempDir()+"?transaction_lock_timeout=100ms")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, edb.Close())
+	}()
+
+	con1, err := edb.Conn(ctx)
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, con1.Close())
+	}()
+
+	con2, err := edb.Conn(ctx)
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, con2.Close())
+	}()
+
+	_, err = con1.ExecContext(ctx, "create table table1 (id int64, name varchar(100))")
+	require.NoError(t, err)
+
+	_, err = con1.ExecContext(ctx, "insert into table1 (id, name) values (1, 'name 1')")
+	require.NoError(t, err)
+
+	// Команда падает после того, как заняла слот и взяла блокировку блока
+	_, err = con2.ExecContext(ctx, "insert into table1 (id, name, unknown) values (2, 'name 2', 3)")
+	require.Error(t, err)
+
+	_, err = con1.ExecContext(ctx, "insert into table1 (id, name) values (3, 'name 3')")
+	require.NoError(t, err)
+
+	rows, err := con2.QueryContext(ctx, "select id from table1")
+	require.NoError(t, err)
+
+	ids := []int64{}
+
+	for rows.Next() {
+		var id int64
+
+		require.NoError(t, rows.Scan(&id))
+
+		ids = append(ids, id)
+	}
+
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+
+	assert.Equal(t, []int64{1, 3}, ids)
+}
+
 func (ts *EmbedDriverTestSuite) TestQuery() {
 	t := ts.T()
 
@@ -431,7 +556,7 @@ func (ts *EmbedDriverTestSuite) TestStartTransactionAlreadyStarted() {
 	require.NoError(t, tx1.Commit())
 }
 
-func (ts *EmbedDriverTestSuite) TestPlaceholders_Ok() {
+func (ts *EmbedDriverTestSuite) TestTransaction_ReadOnly() {
 	t := ts.T()
 
 	ctx := context.Background()
@@ -439,28 +564,39 @@ func (ts *EmbedDriverTestSuite) TestPlaceholders_Ok() {
 	sut, clean := ts.newConnSUT()
 	defer clean()
 
-	var err error
+	_, err := sut.ExecContext(ctx, "create table table1 (id int64, name varchar(100), age int8)")
+	require.NoError(t, err)
 
-	_, err = sut.ExecContext(ctx, "create table table1 (id int64, name varchar(100), age int8)")
+	_, err = sut.ExecContext(ctx, "insert into table1 (id, name, age) values (1, 'name 1', 1)")
 	require.NoError(t, err)
 
-	_, err = sut.ExecContext(ctx, "insert into table1 (id, name, age) values (?, ?, ?)", 1, "name '1'", 15)
-	assert.NoError(t, err)
+	tx1, err := sut.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	require.NoError(t, err)
 
-	rows, err := sut.QueryContext(ctx, "select id, name, age from table1 where id = ? and name=:name and age = ?", 1, sql.Named("name", "name '1'"), 15)
-	assert.NoError(t, err)
-	assert.NoError(t, rows.Err())
-	assert.NoError(t, rows.Close()) //nolint:sqlclosecheck
+	row := scanRowToRecord(t, tx1.QueryRowContext(ctx, "select id, name, age from table1 where id = 1"))
+	assert.Equal(t, "name 1", row.Name)
 
-	_, err = sut.ExecContext(ctx, "insert into table1 (id, name, age) values (:id, :name, :age)",
-		sql.Named("id", 2),
-		sql.Named("name", "name '2'"),
-		sql.Named("age", 25),
-	)
-	assert.NoError(t, err)
+	_, err = tx1.ExecContext(ctx, "insert into table1 (id, name, age) values (2, 'name 2', 2)")
+	assert.ErrorContains(t, err, "read-only")
+
+	_, err = tx1.ExecContext(ctx, "update table1 set name = 'new name 1' where id = 1")
+	assert.ErrorContains(t, err, "read-only")
+
+	_, err = tx1.QueryContext(ctx, "select id, name, age from table1 for update")
+	assert.ErrorContains(t, err, "read-only")
+
+	require.NoError(t, tx1.Commit())
+
+	tx2, err := sut.BeginTx(ctx, nil)
+	require.NoError(t, err)
+
+	row = scanRowToRecord(t, tx2.QueryRowContext(ctx, "select id, name, age from table1 where id = 1"))
+	assert.Equal(t, "name 1", row.Name)
+
+	require.NoError(t, tx2.Commit())
 }
 
-func (ts *EmbedDriverTestSuite) TestPlaceholders_Errors() {
+func (ts *EmbedDriverTestSuite) TestTransaction_Savepoints() {
 	t := ts.T()
 
 	ctx := context.Background()
@@ -468,23 +604,921 @@ func (ts *EmbedDriverTestSuite) TestPlaceholders_Errors() {
 	sut, clean := ts.newConnSUT()
 	defer clean()
 
-	var err error
+	_, err := sut.ExecContext(ctx, "create table table1 (id int64, name varchar(100), age int8)")
+	require.NoError(t, err)
 
-	_, err = sut.ExecContext(ctx, "insert into table1 (id, name) values (?, ?)")
-	assert.ErrorIs(t, err, db.ErrFailedProcessPlaceholders)
+	tx1, err := sut.BeginTx(ctx, nil)
+	require.NoError(t, err)
 
-	_, err = sut.ExecContext(ctx, "insert into table1 (id, name) values (?, ?)", sql.Named("id", 1), "name 1")
-	assert.ErrorIs(t, err, db.ErrFailedProcessPlaceholders)
+	_, err = tx1.ExecContext(ctx, "insert into table1 (id, name, age) values (1, 'name 1', 1)")
+	require.NoError(t, err)
 
-	_, err = sut.ExecContext(ctx, "insert into table1 (id, name) values (?, ?)", 10, 10.45)
-	assert.ErrorIs(t, err, db.ErrUnserializableValue)
+	_, err = tx1.ExecContext(ctx, "savepoint sp1")
+	require.NoError(t, err)
 
-	_, err = sut.ExecContext(ctx, "insert into table1 (id, name) values (:id, :name)")
-	assert.ErrorIs(t, err, db.ErrFailedProcessPlaceholders)
+	_, err = tx1.ExecContext(ctx, "insert into table1 (id, name, age) values (2, 'name 2', 2)")
+	require.NoError(t, err)
 
-	_, err = sut.ExecContext(ctx, "insert into table1 (id, name) values (:id, :name)", sql.Named("id", 1), sql.Named("username", "name 1"))
-	assert.ErrorIs(t, err, db.ErrFailedProcessPlaceholders)
+	_, err = tx1.ExecContext(ctx, "update table1 set name = 'new name 1' where id = 1")
+	require.NoError(t, err)
 
-	_, err = sut.ExecContext(ctx, "insert into table1 (id, name) values (:id, :name?)", sql.Named("id", 1), sql.Named("username", "name 1"))
-	assert.ErrorIs(t, err, db.ErrFailedProcessPlaceholders)
+	_, err = tx1.ExecContext(ctx, "rollback to savepoint sp1")
+	require.NoError(t, err)
+
+	_, err = tx1.ExecContext(ctx, "insert into table1 (id, name, age) values (3, 'name 3', 3)")
+	require.NoError(t, err)
+
+	_, err = tx1.ExecContext(ctx, "release savepoint sp1")
+	require.NoError(t, err)
+
+	_, err = tx1.ExecContext(ctx, "rollback to savepoint sp1")
+	require.ErrorContains(t, err, "savepoint not found")
+
+	require.NoError(t, tx1.Commit())
+
+	rows, err := sut.QueryContext(ctx, "select id from table1")
+	assert.Equal(t, 2, countRows(t, rows, err))
+
+	row := scanRowToRecord(t, sut.QueryRowContext(ctx, "select id, name, age from table1 where id = 1"))
+	assert.Equal(t, "name 1", row.Name)
+
+	row = scanRowToRecord(t, sut.QueryRowContext(ctx, "select id, name, age from table1 where id = 3"))
+	assert.Equal(t, "name 3", row.Name)
+}
+
+func (ts *EmbedDriverTestSuite) TestTransaction_ReadCommitted() {
+	t := ts.T()
+
+	ctx := context.Background()
+
+	db, cleanDB := ts.newDB()
+	defer cleanDB()
+
+	con1, err := db.Conn(ctx)
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, con1.Close())
+	}()
+
+	con2, err := db.Conn(ctx)
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, con2.Close())
+	}()
+
+	_, err = con1.ExecContext(ctx, "create table table1 (id int64, name varchar(100), age int8)")
+	require.NoError(t, err)
+
+	_, err = con1.ExecContext(ctx, "insert into table1 (id, name, age) values (1, 'name 1', 1)")
+	require.NoError(t, err)
+
+	tx1, err := con1.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	require.NoError(t, err)
+
+	row := scanRowToRecord(t, tx1.QueryRowContext(ctx, "select id, name, age from table1 where id = 1"))
+	assert.Equal(t, "name 1", row.Name)
+
+	// Читающая транзакция не держит блокировки, поэтому запись из другого соединения не ждёт её завершения
+	_, err = con2.ExecContext(ctx, "update table1 set name = 'new name 1' where id = 1")
+	require.NoError(t, err)
+
+	row = scanRowToRecord(t, tx1.QueryRowContext(ctx, "select id, name, age from table1 where id = 1"))
+	assert.Equal(t, "new name 1", row.Name)
+
+	require.NoError(t, tx1.Commit())
+}
+
+func (ts *EmbedDriverTestSuite) TestTransaction_IsolationLevels() {
+	t := ts.T()
+
+	ctx := context.Background()
+
+	sut, clean := ts.newConnSUT()
+	defer clean()
+
+	for _, level := range []sql.IsolationLevel{
+		sql.LevelDefault,
+		sql.LevelReadCommitted,
+		sql.LevelRepeatableRead,
+		sql.LevelSerializable,
+	} {
+		tx, err := sut.BeginTx(ctx, &sql.TxOptions{Isolation: level})
+		require.NoError(t, err, level.String())
+		require.NoError(t, tx.Commit())
+	}
+
+	for _, level := range []sql.IsolationLevel{
+		sql.LevelReadUncommitted,
+		sql.LevelWriteCommitted,
+		sql.LevelSnapshot,
+		sql.LevelLinearizable,
+	} {
+		_, err := sut.BeginTx(ctx, &sql.TxOptions{Isolation: level})
+		assert.ErrorIs(t, err, db.ErrUnsupportedIsolationLevel, level.String())
+	}
+}
+
+// newTwoBlocksDB создаёт базу с таблицей phantoms из двух полностью заполненных блоков и два соединения к ней
+func (ts *EmbedDriverTestSuite) newTwoBlocksDB(lockTimeout time.Duration) (*sql.Conn, *sql.Conn, func()) {
+	t := ts.T()
+
+	ctx := context.Background()
+
+	pdb, err := sql.Open(db.EmbedDriverName, t.TempDir()+"?block_size=400&transaction_lock_timeout="+lockTimeout.String())
+	require.NoError(t, err)
+
+	con1, err := pdb.Conn(ctx)
+	require.NoError(t, err)
+
+	con2, err := pdb.Conn(ctx)
+	require.NoError(t, err)
+
+	_, err = con1.ExecContext(ctx, "create table phantoms (id int64, dept int64)")
+	require.NoError(t, err)
+
+	// Запись занимает 17 байт с картой NULL и 5 байт в каталоге слотов, поэтому в блок размером 400 байт помещается 17 записей
+	for i := 0; i < 34; i++ {
+		_, err = con1.ExecContext(ctx, "insert into phantoms (id, dept) values (?, ?)", i, i%10)
+		require.NoError(t, err)
+	}
+
+	return con1, con2, func() {
+		assert.NoError(t, con1.Close())
+		assert.NoError(t, con2.Close())
+		assert.NoError(t, pdb.Close())
+	}
+}
+
+func (ts *EmbedDriverTestSuite) TestTransaction_NoPhantomsInSerializable() {
+	t := ts.T()
+
+	ctx := context.Background()
+
+	for _, freeSlot := range []bool{false, true} {
+		con1, con2, clean := ts.newTwoBlocksDB(100 * time.Millisecond)
+
+		if freeSlot {
+			// Новая запись займёт освободившийся слот в уже прочитанном блоке
+			_, err := con1.ExecContext(ctx, "delete from phantoms where id = 1")
+			require.NoError(t, err)
+		}
+
+		tx1, err := con1.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+		require.NoError(t, err)
+
+		query := "select id, dept from phantoms where dept = 5"
+
+		rows, err := tx1.QueryContext(ctx, query)
+		before := countRows(t, rows, err)
+		assert.Equal(t, 3, before)
+
+		_, err = con2.ExecContext(ctx, "insert into phantoms (id, dept) values (100, 5)")
+		assert.ErrorContains(t, err, "failed to lock block")
+
+		rows, err = tx1.QueryContext(ctx, query)
+		assert.Equal(t, before, countRows(t, rows, err))
+
+		require.NoError(t, tx1.Commit())
+
+		_, err = con2.ExecContext(ctx, "insert into phantoms (id, dept) values (100, 5)")
+		require.NoError(t, err)
+
+		rows, err = con1.QueryContext(ctx, query)
+		assert.Equal(t, before+1, countRows(t, rows, err))
+
+		clean()
+	}
+}
+
+func (ts *EmbedDriverTestSuite) TestTransaction_PhantomsInRepeatableRead() {
+	t := ts.T()
+
+	ctx := context.Background()
+
+	con1, con2, clean := ts.newTwoBlocksDB(100 * time.Millisecond)
+	defer clean()
+
+	tx1, err := con1.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	require.NoError(t, err)
+
+	query := "select id, dept from phantoms where dept = 5"
+
+	rows, err := tx1.QueryContext(ctx, query)
+	before := countRows(t, rows, err)
+
+	// Блоки заполнены, поэтому запись попадёт в новый блок, а блокировку конца файла repeatable read не держит
+	_, err = con2.ExecContext(ctx, "insert into phantoms (id, dept) values (100, 5)")
+	require.NoError(t, err)
+
+	rows, err = tx1.QueryContext(ctx, query)
+	assert.Equal(t, before+1, countRows(t, rows, err))
+
+	require.NoError(t, tx1.Commit())
+}
+
+func (ts *EmbedDriverTestSuite) TestSelectForUpdate_ReadModifyWrite() {
+	t := ts.T()
+
+	ctx := context.Background()
+
+	con1, con2, clean := ts.newTwoBlocksDB(5 * time.Second)
+	defer clean()
+
+	incAge := func(con *sql.Conn, done chan<- struct{}) {
+		defer close(done)
+
+		tx, err := con.BeginTx(ctx, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		var dept int64
+
+		if err = tx.QueryRowContext(ctx, "select dept from phantoms where id = 5 for update").Scan(&dept); !assert.NoError(t, err) {
+			_ = tx.Rollback()
+
+			return
+		}
+
+		// Даём второй транзакции дойти до чтения
+		time.Sleep(50 * time.Millisecond)
+
+		if _, err = tx.ExecContext(ctx, "update phantoms set dept = ? where id = 5", dept+1); !assert.NoError(t, err) {
+			_ = tx.Rollback()
+
+			return
+		}
+
+		assert.NoError(t, tx.Commit())
+	}
+
+	// Обычное чтение взяло бы slock в обеих транзакциях, и ни одна не смогла бы его повысить до xlock
+	done1 := make(chan struct{})
+	done2 := make(chan struct{})
+
+	go incAge(con1, done1)
+	go incAge(con2, done2)
+
+	<-done1
+	<-done2
+
+	var dept int64
+
+	require.NoError(t, con1.QueryRowContext(ctx, "select dept from phantoms where id = 5").Scan(&dept))
+	assert.EqualValues(t, 7, dept)
+}
+
+func (ts *EmbedDriverTestSuite) TestSelectForUpdate_NoWaitAndSkipLocked() {
+	t := ts.T()
+
+	ctx := context.Background()
+
+	con1, con2, clean := ts.newTwoBlocksDB(5 * time.Second)
+	defer clean()
+
+	tx1, err := con1.BeginTx(ctx, nil)
+	require.NoError(t, err)
+
+	// Читаем одну запись, поэтому заблокирован только первый блок
+	var id int64
+
+	require.NoError(t, tx1.QueryRowContext(ctx, "select id from phantoms for update skip locked").Scan(&id))
+	assert.EqualValues(t, 0, id)
+
+	tx2, err := con2.BeginTx(ctx, nil)
+	require.NoError(t, err)
+
+	started := time.Now()
+
+	_, err = tx2.QueryContext(ctx, "select id from phantoms for share nowait")
+	assert.ErrorContains(t, err, "lock is not available")
+
+	_, err = tx2.QueryContext(ctx, "select id from phantoms for update nowait")
+	assert.ErrorContains(t, err, "lock is not available")
+
+	assert.Less(t, time.Since(started), time.Second)
+
+	// Вторая транзакция пропускает заблокированный блок и берёт следующую свободную запись
+	require.NoError(t, tx2.QueryRowContext(ctx, "select id from phantoms for update skip locked").Scan(&id))
+	assert.EqualValues(t, 17, id)
+
+	rows, err := tx2.QueryContext(ctx, "select id from phantoms where dept = 5 for update skip locked")
+	assert.Equal(t, 1, countRows(t, rows, err))
+
+	require.NoError(t, tx1.Commit())
+	require.NoError(t, tx2.Commit())
+}
+
+func (ts *EmbedDriverTestSuite) TestSelectForUpdate_RowLocksInReadCommitted() {
+	t := ts.T()
+
+	ctx := context.Background()
+
+	con1, con2, clean := ts.newTwoBlocksDB(100 * time.Millisecond)
+	defer clean()
+
+	rc := &sql.TxOptions{Isolation: sql.LevelReadCommitted}
+
+	tx1, err := con1.BeginTx(ctx, rc)
+	require.NoError(t, err)
+
+	tx2, err := con2.BeginTx(ctx, rc)
+	require.NoError(t, err)
+
+	var id int64
+
+	require.NoError(t, tx1.QueryRowContext(ctx, "select id from phantoms for update skip locked").Scan(&id))
+	assert.EqualValues(t, 0, id)
+
+	// Блокируется запись, а не блок: вторая транзакция берет следующую запись того же блока
+	require.NoError(t, tx2.QueryRowContext(ctx, "select id from phantoms for update skip locked").Scan(&id))
+	assert.EqualValues(t, 1, id)
+
+	err = tx2.QueryRowContext(ctx, "select id from phantoms where id = 0 for share nowait").Scan(&id)
+	assert.ErrorContains(t, err, "lock is not available")
+
+	rows, err := tx2.QueryContext(ctx, "select id from phantoms where dept = 0 for share skip locked")
+	assert.Equal(t, 3, countRows(t, rows, err))
+
+	require.NoError(t, tx2.Commit())
+
+	// Измененный блок нельзя читать до фиксации, поэтому SKIP LOCKED пропускает его целиком
+	_, err = tx1.ExecContext(ctx, "update phantoms set dept = 100 where id = 0")
+	require.NoError(t, err)
+
+	tx2, err = con2.BeginTx(ctx, rc)
+	require.NoError(t, err)
+
+	require.NoError(t, tx2.QueryRowContext(ctx, "select id from phantoms for update skip locked").Scan(&id))
+	assert.EqualValues(t, 17, id)
+
+	require.NoError(t, tx2.Commit())
+	require.NoError(t, tx1.Commit())
+}
+
+func (ts *EmbedDriverTestSuite) TestSelectForShare_ReadCommitted() {
+	t := ts.T()
+
+	ctx := context.Background()
+
+	con1, con2, clean := ts.newTwoBlocksDB(100 * time.Millisecond)
+	defer clean()
+
+	tx1, err := con1.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	require.NoError(t, err)
+
+	rows, err := tx1.QueryContext(ctx, "select id from phantoms where id = 1 for share")
+	assert.Equal(t, 1, countRows(t, rows, err))
+
+	// FOR SHARE держит блокировку записи до конца транзакции и на уровне READ COMMITTED
+	_, err = con2.ExecContext(ctx, "update phantoms set dept = 100 where id = 1")
+	require.ErrorContains(t, err, "failed to lock")
+
+	// Остальные записи блока свободны
+	_, err = con2.ExecContext(ctx, "update phantoms set dept = 100 where id = 2")
+	require.NoError(t, err)
+
+	require.NoError(t, tx1.Commit())
+
+	_, err = con2.ExecContext(ctx, "update phantoms set dept = 100 where id = 1")
+	require.NoError(t, err)
+}
+
+func (ts *EmbedDriverTestSuite) TestSystemViews() {
+	t := ts.T()
+
+	ctx := context.Background()
+
+	con1, con2, clean := ts.newTwoBlocksDB(5 * time.Second)
+	defer clean()
+
+	tx1, err := con1.BeginTx(ctx, nil)
+	require.NoError(t, err)
+
+	var id, trx1 int64
+
+	require.NoError(t, tx1.QueryRowContext(ctx, "select id from phantoms for update").Scan(&id))
+
+	require.NoError(t, con2.QueryRowContext(ctx, "select trx from sdb_locks where mode = 'xlock' and block = 0").Scan(&trx1))
+
+	var state, isolation string
+
+	var readOnly, pinned, locks int64
+
+	require.NoError(t, con2.QueryRowContext(ctx, "select state, isolation, read_only, pinned_buffers, locks from sdb_transactions where trx = ?", trx1).
+		Scan(&state, &isolation, &readOnly, &pinned, &locks))
+	assert.Equal(t, "active", state)
+	assert.Equal(t, "serializable", isolation)
+	assert.Zero(t, readOnly)
+	assert.Positive(t, locks)
+
+	// Вторая транзакция ждёт блокировку первой
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		var id2 int64
+
+		assert.NoError(t, con2.QueryRowContext(ctx, "select id from phantoms where id = 0 for update").Scan(&id2))
+	}()
+
+	var waiters string
+
+	require.Eventually(t, func() bool {
+		var holder int64
+
+		row := tx1.QueryRowContext(ctx, "select trx, waiters from sdb_locks where mode = 'xlock' and block = 0 and filename = 'phantoms.tbl'")
+
+		return row.Scan(&holder, &waiters) == nil && holder == trx1 && waiters != ""
+	}, 3*time.Second, 10*time.Millisecond)
+
+	rows, err := tx1.QueryContext(ctx, "select trx from sdb_transactions where state = 'waiting'")
+	assert.Equal(t, 1, countRows(t, rows, err))
+
+	require.NoError(t, tx1.Commit())
+
+	<-done
+}
+
+func (ts *EmbedDriverTestSuite) TestBuffersViewAndStats() {
+	t := ts.T()
+
+	ctx := context.Background()
+
+	con1, _, clean := ts.newTwoBlocksDB(time.Second)
+	defer clean()
+
+	rows, err := con1.QueryContext(ctx, "select id from phantoms")
+	assert.Equal(t, 34, countRows(t, rows, err))
+
+	rows, err = con1.QueryContext(ctx, "select frame, block, pins, dirty_trx from sdb_buffers where filename = 'phantoms.tbl'")
+	assert.Equal(t, 2, countRows(t, rows, err))
+
+	rows, err = con1.QueryContext(ctx, "select frame from sdb_buffers where block = -1")
+	assert.Positive(t, countRows(t, rows, err))
+
+	require.NoError(t, con1.Raw(func(driverConn any) error {
+		rdb, ok := driverConn.(interface{ DB() *db.Database })
+		require.True(t, ok)
+
+		stats := rdb.DB().Stats().Buffers
+		assert.Positive(t, stats.Hits)
+		assert.Positive(t, stats.Misses)
+		assert.Positive(t, stats.Files["phantoms.tbl"].Hits)
+		assert.Positive(t, stats.HitRatio())
+
+		return nil
+	}))
+}
+
+func (ts *EmbedDriverTestSuite) TestPlaceholders_Ok() {
+	t := ts.T()
+
+	ctx := context.Background()
+
+	sut, clean := ts.newConnSUT()
+	defer clean()
+
+	var err error
+
+	_, err = sut.ExecContext(ctx, "create table table1 (id int64, name varchar(100), age int8)")
+	require.NoError(t, err)
+
+	_, err = sut.ExecContext(ctx, "insert into table1 (id, name, age) values (?, ?, ?)", 1, "name '1'", 15)
+	assert.NoError(t, err)
+
+	rows, err := sut.QueryContext(ctx, "select id, name, age from table1 where id = ? and name=:name and age = ?", 1, sql.Named("name", "name '1'"), 15)
+	assert.NoError(t, err)
+	assert.NoError(t, rows.Err())
+	assert.NoError(t, rows.Close()) //nolint:sqlclosecheck
+
+	_, err = sut.ExecContext(ctx, "insert into table1 (id, name, age) values (:id, :name, :age)",
+		sql.Named("id", 2),
+		sql.Named("name", "name '2'"),
+		sql.Named("age", 25),
+	)
+	assert.NoError(t, err)
+}
+
+func (ts *EmbedDriverTestSuite) TestPlaceholders_Errors() {
+	t := ts.T()
+
+	ctx := context.Background()
+
+	sut, clean := ts.newConnSUT()
+	defer clean()
+
+	var err error
+
+	_, err = sut.ExecContext(ctx, "insert into table1 (id, name) values (?, ?)")
+	assert.ErrorIs(t, err, db.ErrFailedProcessPlaceholders)
+
+	_, err = sut.ExecContext(ctx, "insert into table1 (id, name) values (?, ?)", sql.Named("id", 1), "name 1")
+	assert.ErrorIs(t, err, db.ErrFailedProcessPlaceholders)
+
+	_, err = sut.ExecContext(ctx, "insert into table1 (id, name) values (?, ?)", 10, 10.45)
+	assert.ErrorIs(t, err, db.ErrUnserializableValue)
+
+	_, err = sut.ExecContext(ctx, "insert into table1 (id, name) values (:id, :name)")
+	assert.ErrorIs(t, err, db.ErrFailedProcessPlaceholders)
+
+	_, err = sut.ExecContext(ctx, "insert into table1 (id, name) values (:id, :name)", sql.Named("id", 1), sql.Named("username", "name 1"))
+	assert.ErrorIs(t, err, db.ErrFailedProcessPlaceholders)
+
+	_, err = sut.ExecContext(ctx, "insert into table1 (id, name) values (:id, :name?)", sql.Named("id", 1), sql.Named("username", "name 1"))
+	assert.ErrorIs(t, err, db.ErrFailedProcessPlaceholders)
+}
+
+func (ts *EmbedDriverTestSuite) TestNulls() {
+	t := ts.T()
+
+	ctx := context.Background()
+
+	sut, clean := ts.newConnSUT()
+	defer clean()
+
+	_, err := sut.ExecContext(ctx, "create table table1 (id int64, name varchar(100), age int8)")
+	require.NoError(t, err)
+
+	_, err = sut.ExecContext(ctx, "insert into table1 (id, name, age) values (?, ?, ?)", 1, nil, 15)
+	require.NoError(t, err)
+
+	_, err = sut.ExecContext(ctx, "insert into table1 (id) values (2)")
+	require.NoError(t, err)
+
+	_, err = sut.ExecContext(ctx, "insert into table1 (id, name, age) values (3, 'name 3', 0)")
+	require.NoError(t, err)
+
+	res, err := sut.ExecContext(ctx, "update table1 set age = null where id = 3")
+	require.NoError(t, err)
+
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+
+	type qres struct {
+		id   int64
+		name sql.NullString
+		age  sql.NullInt16
+	}
+
+	query := func(q string, args ...any) []qres {
+		rows, qerr := sut.QueryContext(ctx, q, args...)
+		require.NoError(t, qerr)
+
+		defer func() {
+			assert.NoError(t, rows.Close())
+		}()
+
+		result := []qres{}
+
+		for rows.Next() {
+			var r qres
+
+			require.NoError(t, rows.Scan(&r.id, &r.name, &r.age))
+
+			result = append(result, r)
+		}
+
+		require.NoError(t, rows.Err())
+
+		return result
+	}
+
+	assert.Equal(t, []qres{
+		{id: 1, age: sql.NullInt16{Int16: 15, Valid: true}},
+		{id: 2},
+		{id: 3, name: sql.NullString{String: "name 3", Valid: true}},
+	}, query("select id, name, age from table1"))
+
+	assert.Equal(t, []qres{
+		{id: 2},
+		{id: 3, name: sql.NullString{String: "name 3", Valid: true}},
+	}, query("select id, name, age from table1 where age is null"))
+
+	assert.Equal(t, []qres{
+		{id: 3, name: sql.NullString{String: "name 3", Valid: true}},
+	}, query("select id, name, age from table1 where name is not null and age is null"))
+
+	assert.Empty(t, query("select id, name, age from table1 where name = ?", nil))
+	assert.Empty(t, query("select id, name, age from table1 where age = 0"))
+}
+
+func (ts *EmbedDriverTestSuite) TestTransactionPinQuota() {
+	t := ts.T()
+
+	ctx := context.Background()
+
+	edb, err := sql.Open(db.EmbedDriverName, t.TempDir()+"?transaction_pin_quota=2")
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, edb.Close())
+	}()
+
+	sut, err := edb.Conn(ctx)
+	require.NoError(t, err)
+
+	defer func() {
+		assert.NoError(t, sut.Close())
+	}()
+
+	_, err = sut.ExecContext(ctx, "create table quota1 (id int64)")
+	require.NoError(t, err)
+
+	_, err = sut.ExecContext(ctx, "insert into quota1 (id) values (1)")
+	require.NoError(t, err)
+
+	rows, err := sut.QueryContext(ctx, "select id from quota1")
+	assert.Equal(t, 1, countRows(t, rows, err))
+
+	require.NoError(t, sut.Raw(func(driverConn any) error {
+		conn, ok := driverConn.(*db.EmbedConn)
+		require.True(t, ok)
+
+		trx := conn.TRX()
+
+		for _, filename := range []string{"quota1.tbl", "sdb_tables.tbl"} {
+			block := types.Block{Filename: filename, Number: 0}
+
+			require.NoError(t, trx.Pin(block))
+			defer trx.Unpin(block)
+		}
+
+		assert.ErrorIs(t, trx.Pin(types.Block{Filename: "sbb_tables_fields.tbl", Number: 0}), transaction.ErrPinQuotaExceeded)
+
+		return nil
+	}))
+}
+
+func (ts *EmbedDriverTestSuite) TestMemoryDatabase() {
+	t := ts.T()
+
+	ctx := context.Background()
+
+	first, err := sql.Open(db.EmbedDriverName, db.MemoryDataDir+"?buffers_pool_len=64")
+	require.NoError(t, err)
+
+	defer first.Close()
+
+	second, err := sql.Open(db.EmbedDriverName, db.MemoryDataDir)
+	require.NoError(t, err)
+
+	defer second.Close()
+
+	_, err = first.ExecContext(ctx, "create table table1 (id int64, name varchar(100))")
+	require.NoError(t, err)
+
+	_, err = first.ExecContext(ctx, "insert into table1 (id, name) values (1, 'name 1')")
+	require.NoError(t, err)
+
+	// Все соединения пула работают с одной базой
+	first.SetMaxIdleConns(0)
+
+	var name string
+
+	require.NoError(t, first.QueryRowContext(ctx, "select name from table1 where id = 1").Scan(&name))
+	assert.Equal(t, "name 1", name)
+
+	// У каждого sql.DB своя база
+	require.Error(t, second.QueryRowContext(ctx, "select id from table1").Scan(new(int64)))
+
+	conn, err := first.Conn(ctx)
+	require.NoError(t, err)
+
+	_ = conn.Raw(func(driverConn any) error {
+		rdb, ok := driverConn.(interface{ DB() *db.Database })
+		require.True(t, ok)
+
+		assert.Equal(t, db.MemoryDataDir, rdb.DB().DataDir())
+		assert.Equal(t, 64, rdb.DB().BuffersPoolLen())
+
+		return nil
+	})
+
+	require.NoError(t, conn.Close())
+}
+
+func (ts *EmbedDriverTestSuite) TestDataDirLock() {
+	t := ts.T()
+
+	dsn := t.TempDir()
+
+	first := db.NewEmbedDriver()
+
+	conn, err := first.Open(dsn)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	// Другой экземпляр драйвера не может открыть папку, пока ее держит первый
+	second := db.NewEmbedDriver()
+
+	_, err = second.Open(dsn)
+	require.ErrorIs(t, err, storage.ErrDatabaseLocked)
+
+	require.NoError(t, first.Close())
+
+	conn, err = second.Open(dsn)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	require.NoError(t, second.Close())
+}
+
+func (ts *EmbedDriverTestSuite) TestLargeValues() {
+	t := ts.T()
+
+	ctx := context.Background()
+
+	sut, clean := ts.newConnSUT()
+	defer clean()
+
+	_, err := sut.ExecContext(ctx, "create table table1 (id int64, body text, data blob)")
+	require.NoError(t, err)
+
+	short := "it's a \\short\\ text\nwith two lines"
+	long := strings.Repeat("long 'text' \\ with\nnewlines ", 1000)
+	blob := bytes.Repeat([]byte{0, 1, 2, '\'', '\\', '\n', 0xff}, 1000)
+
+	_, err = sut.ExecContext(ctx, "insert into table1 (id, body, data) values (?, ?, ?)", 1, short, []byte("bytes"))
+	require.NoError(t, err)
+
+	_, err = sut.ExecContext(ctx, "insert into table1 (id, body, data) values (?, ?, ?)", 2, long, blob)
+	require.NoError(t, err)
+
+	_, err = sut.ExecContext(ctx, "insert into table1 (id) values (3)")
+	require.NoError(t, err)
+
+	type qres struct {
+		id   int64
+		body sql.NullString
+		data []byte
+	}
+
+	query := func(q string, args ...any) []qres {
+		rows, qerr := sut.QueryContext(ctx, q, args...)
+		require.NoError(t, qerr)
+
+		defer func() {
+			assert.NoError(t, rows.Close())
+		}()
+
+		result := []qres{}
+
+		for rows.Next() {
+			var r qres
+
+			require.NoError(t, rows.Scan(&r.id, &r.body, &r.data))
+
+			result = append(result, r)
+		}
+
+		require.NoError(t, rows.Err())
+
+		return result
+	}
+
+	assert.Equal(t, []qres{
+		{id: 1, body: sql.NullString{String: short, Valid: true}, data: []byte("bytes")},
+		{id: 2, body: sql.NullString{String: long, Valid: true}, data: blob},
+		{id: 3},
+	}, query("select id, body, data from table1"))
+
+	assert.Equal(t, []qres{
+		{id: 2, body: sql.NullString{String: long, Valid: true}, data: blob},
+	}, query("select id, body, data from table1 where body = ?", long))
+
+	_, err = sut.ExecContext(ctx, "update table1 set body = ?, data = ? where id = 1", long+"!", blob[:10])
+	require.NoError(t, err)
+
+	_, err = sut.ExecContext(ctx, "update table1 set body = ?, data = null where id = 2", short)
+	require.NoError(t, err)
+
+	_, err = sut.ExecContext(ctx, "delete from table1 where id = 3")
+	require.NoError(t, err)
+
+	assert.Equal(t, []qres{
+		{id: 1, body: sql.NullString{String: long + "!", Valid: true}, data: blob[:10]},
+		{id: 2, body: sql.NullString{String: short, Valid: true}},
+	}, query("select id, body, data from table1"))
+
+	var body string
+
+	require.NoError(t, sut.QueryRowContext(ctx, "select body from table1 where id = 1").Scan(&body))
+	assert.Equal(t, long+"!", body)
+}
+
+func (ts *EmbedDriverTestSuite) TestLargeValueReaders() {
+	t := ts.T()
+
+	ctx := context.Background()
+
+	pdb, err := sql.Open(db.EmbedDriverName, t.TempDir()+"?transaction_lock_timeout=1s")
+	require.NoError(t, err)
+
+	defer pdb.Close()
+
+	con1, err := pdb.Conn(ctx)
+	require.NoError(t, err)
+
+	defer con1.Close()
+
+	_, err = con1.ExecContext(ctx, "create table table1 (id int64, body text, data blob)")
+	require.NoError(t, err)
+
+	long := strings.Repeat("long text ", 2000)
+	blob := bytes.Repeat([]byte{0, 1, 2, 0xff}, 2000)
+
+	_, err = con1.ExecContext(ctx, "insert into table1 (id, body, data) values (1, 'short', ?)", []byte("bytes"))
+	require.NoError(t, err)
+
+	_, err = con1.ExecContext(ctx, "insert into table1 (id, body, data) values (2, ?, ?)", long, blob)
+	require.NoError(t, err)
+
+	_, err = con1.ExecContext(ctx, "insert into table1 (id) values (3)")
+	require.NoError(t, err)
+
+	var embedConn *db.EmbedConn
+
+	require.NoError(t, con1.Raw(func(driverConn any) error {
+		embedConn, _ = driverConn.(*db.EmbedConn)
+
+		return nil
+	}))
+	require.NotNil(t, embedConn)
+
+	rows, err := con1.QueryContext(db.WithLargeValueReaders(ctx), "select id, body, data from table1")
+	require.NoError(t, err)
+
+	var (
+		id     int64
+		body   *db.LargeValue
+		data   any
+		unread *db.LargeValue
+	)
+
+	// Короткие значения лежат в записи
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(&id, &body, &data))
+	assert.EqualValues(t, 5, body.Size())
+	assert.Equal(t, []byte("short"), testutil.Must(io.ReadAll(body)))
+	assert.Equal(t, []byte("bytes"), testutil.Must(io.ReadAll(data.(io.Reader))))
+
+	// Страницы переполнения читаются, только когда приложение читает значение
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(&id, &body, &data))
+
+	locks := embedConn.TRX().Info().Locks
+
+	assert.EqualValues(t, len(long), body.Size())
+	assert.Equal(t, []byte(long), testutil.Must(io.ReadAll(body)))
+	assert.Greater(t, embedConn.TRX().Info().Locks, locks)
+
+	unread, _ = data.(*db.LargeValue)
+	require.NotNil(t, unread)
+	assert.EqualValues(t, len(blob), unread.Size())
+
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(&id, &body, &data))
+	assert.Nil(t, body)
+	assert.Nil(t, data)
+
+	require.False(t, rows.Next())
+	require.NoError(t, rows.Err())
+
+	// После закрытия строк значение прочитать нельзя
+	_, err = io.ReadAll(unread)
+	require.ErrorIs(t, err, db.ErrRowsClosed)
+
+	// В READ COMMITTED значение читается сразу, поэтому изменения других транзакций его не затрагивают
+	tx, err := con1.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	require.NoError(t, err)
+
+	rows, err = tx.QueryContext(db.WithLargeValueReaders(ctx), "select body from table1 where id = 2")
+	require.NoError(t, err)
+
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(&body))
+
+	con2, err := pdb.Conn(ctx)
+	require.NoError(t, err)
+
+	defer con2.Close()
+
+	_, err = con2.ExecContext(ctx, "update table1 set body = ? where id = 2", strings.Repeat("new text ", 2000))
+	require.NoError(t, err)
+
+	// Новое значение занимает страницы, которые освободил UPDATE
+	_, err = con2.ExecContext(ctx, "insert into table1 (id, body) values (4, ?)", strings.Repeat("reused ", 2000))
+	require.NoError(t, err)
+
+	value, err := io.ReadAll(body)
+
+	require.NoError(t, rows.Close())
+	require.NoError(t, tx.Commit())
+
+	require.NoError(t, err)
+	assert.Equal(t, []byte(long), value)
 }
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// ReadKeyFile читает ключ шифрования из файла: 16, 24 или 32 байта как есть или в hex
+func ReadKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithMessage(ErrBadKeyFile, err.Error())
+	}
+
+	if key, err := hex.DecodeString(string(bytes.TrimSpace(data))); err == nil && isKeyLength(len(key)) {
+		return key, nil
+	}
+
+	if !isKeyLength(len(data)) {
+		return nil, errors.WithMessagef(ErrBadKeyFile, "%s: key must be 16, 24 or 32 bytes", path)
+	}
+
+	return data, nil
+}
+
+func isKeyLength(n int) bool {
+	return n == 16 || n == 24 || n == 32 //nolint:mnd
+}
+
+// RotateKey перешифровывает базу в папке dataDir ключом newKey. База в это время должна быть закрыта, причем штатно:
+// после сбоя ее сначала открывают через NewDatabase, чтобы прошло восстановление.
+// Если смена ключа прервалась, ее надо запустить снова с теми же ключами.
+// Размер блока, если он не по умолчанию, задается через WithBlockSize
+func RotateKey(dataDir string, oldKey, newKey []byte, opts ...DatabaseOption) error {
+	db := &Database{
+		blockSize:    DefaultBlockSize,
+		maxOpenFiles: DefaultMaxOpenFiles,
+	}
+
+	for _, opt := range opts {
+		opt(db)
+	}
+
+	db.encryptionKey = oldKey
+
+	fm, err := db.newStorageManager(dataDir)
+	if err != nil {
+		return err
+	}
+
+	if err := fm.RotateKey(newKey); err != nil {
+		_ = fm.Close()
+
+		return err
+	}
+
+	return fm.Close()
+}
@@ -7,4 +7,7 @@ var (
 	ErrUnserializableValue       = errors.New("unserializable value")
 	ErrTransactionAlreadyStarted = errors.New("transaction already started")
 	ErrBadDSN                    = errors.New("bad DSN")
+	ErrUnsupportedIsolationLevel = errors.New("unsupported isolation level")
+	ErrBadKeyFile                = errors.New("bad key file")
+	ErrRowsClosed                = errors.New("rows are closed")
 )
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+
+	"github.com/unhandled-exception/sophiadb/internal/pkg/scan"
+	"github.com/unhandled-exception/sophiadb/internal/pkg/tx/concurrency"
+)
+
+type largeValueReadersKey struct{}
+
+// WithLargeValueReaders включает потоковое чтение TEXT и BLOB в запросах с этим контекстом.
+// Такие колонки приходят как *LargeValue, а страницы переполнения читаются, только когда приложение читает значение
+func WithLargeValueReaders(ctx context.Context) context.Context {
+	return context.WithValue(ctx, largeValueReadersKey{}, true)
+}
+
+func largeValueReaders(ctx context.Context) bool {
+	on, _ := ctx.Value(largeValueReadersKey{}).(bool)
+
+	return on
+}
+
+// LargeValue — значение TEXT или BLOB, которое читается по частям. Читать его можно, пока не закрыты строки результата
+type LargeValue struct {
+	r    io.Reader
+	size int64
+	rows *rowsState
+}
+
+func (v *LargeValue) Read(p []byte) (int, error) {
+	v.rows.mu.Lock()
+	defer v.rows.mu.Unlock()
+
+	if v.rows.closed {
+		return 0, ErrRowsClosed
+	}
+
+	return v.r.Read(p)
+}
+
+// Size возвращает длину значения в байтах
+func (v *LargeValue) Size() int64 {
+	return v.size
+}
+
+// rowsState — общее состояние строк результата и значений LargeValue, которые из них прочитаны
+type rowsState struct {
+	mu     sync.Mutex
+	closed bool
+}
+
+// largeValue возвращает поле TEXT или BLOB текущей записи как *LargeValue, для NULL — nil
+func (r embedRows) largeValue(field string) (any, error) {
+	reader, size, err := scan.LargeReader(r.scan, field)
+	if err != nil || reader == nil {
+		return nil, err
+	}
+
+	// В READ COMMITTED блокировка записи снимается сразу после чтения, и другая транзакция может освободить
+	// страницы значения, поэтому его приходится прочитать сразу
+	if r.trx.IsolationLevel() == concurrency.ReadCommitted {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	return &LargeValue{
+		r:    reader,
+		size: size,
+		rows: r.state,
+	}, nil
+}
@@ -100,11 +100,22 @@ func fetchPositionalArgs(args []driver.NamedValue) []driver.Value {
 
 func serializeValue(value driver.Value) ([]byte, error) {
 	switch v := value.(type) {
+	case nil:
+		return []byte("null"), nil
 	case int64:
 		return []byte(strconv.FormatInt(v, 10)), nil //nolint:mnd
 	case string:
-		return []byte("'" + strings.ReplaceAll(v, "'", `\'`) + "'"), nil
+		return quoteString(v), nil
+	case []byte:
+		return quoteString(string(v)), nil
 	}
 
 	return []byte{}, errors.WithMessagef(ErrUnserializableValue, "%v", value)
 }
+
+var stringEscaper = strings.NewReplacer(`\`, `\\`, "'", `\'`, "\n", `\n`)
+
+// quoteString заключает строку в кавычки. Перевод строки экранируется, потому что SQL-лексер не пропускает его внутри строки
+func quoteString(value string) []byte {
+	return []byte("'" + stringEscaper.Replace(value) + "'")
+}
@@ -23,3 +23,18 @@ func scanRowToRecord(t *testing.T, row *sql.Row) table1Record {
 
 	return rec
 }
+
+func countRows(t *testing.T, rows *sql.Rows, err error) int {
+	require.NoError(t, err)
+
+	defer rows.Close()
+
+	cnt := 0
+	for rows.Next() {
+		cnt++
+	}
+
+	require.NoError(t, rows.Err())
+
+	return cnt
+}
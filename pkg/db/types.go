@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql/driver"
 	"io"
+	"strings"
+	"sync"
 )
 
 type stmtResult struct {
@@ -38,3 +40,53 @@ func (t connector) Close() error {
 
 	return nil
 }
+
+// memoryConnector создает для каждого sql.DB свою базу в памяти. База создается при первом соединении
+type memoryConnector struct {
+	dsn    string
+	driver *EmbedDriver
+
+	mu sync.Mutex
+	db *Database
+}
+
+func isMemoryDSN(dsn string) bool {
+	return dsn == MemoryDataDir || strings.HasPrefix(dsn, MemoryDataDir+"?")
+}
+
+func (t *memoryConnector) Connect(_ context.Context) (driver.Conn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.db == nil {
+		parsedDSN, err := parseEmbedDSN(t.dsn)
+		if err != nil {
+			return nil, err
+		}
+
+		t.db, err = t.driver.newDB(parsedDSN)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return NewEmbedConn(t.db)
+}
+
+func (t *memoryConnector) Driver() driver.Driver {
+	return t.driver
+}
+
+func (t *memoryConnector) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.db == nil {
+		return nil
+	}
+
+	err := t.db.Close()
+	t.db = nil
+
+	return err
+}